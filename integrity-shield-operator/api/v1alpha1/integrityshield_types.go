@@ -17,8 +17,10 @@
 package v1alpha1
 
 import (
+	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -32,19 +34,21 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	policyv1 "k8s.io/api/policy/v1beta1"
 	rbacv1 "k8s.io/api/rbac/v1"
-	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 
 	ec "github.com/IBM/integrity-enforcer/shield/pkg/apis/shieldconfig/v1alpha1"
 	sigconf "github.com/IBM/integrity-enforcer/shield/pkg/apis/signerconfig/v1alpha1"
 )
 
 const (
+	DefaultIntegrityShieldCRName              = "integrity-shield-server"
 	DefaultIntegrityShieldCRDName             = "integrityshields.apis.integrityshield.io"
 	DefaultShieldConfigCRDName                = "shieldconfigs.apis.integrityshield.io"
 	DefaultSignerConfigCRDName                = "signerconfigs.apis.integrityshield.io"
 	DefaultResourceSignatureCRDName           = "resourcesignatures.apis.integrityshield.io"
 	DefaultResourceSigningProfileCRDName      = "resourcesigningprofiles.apis.integrityshield.io"
 	DefaultHelmReleaseMetadataCRDName         = "helmreleasemetadatas.apis.integrityshield.io"
+	DefaultIntegrityShieldReportCRDName       = "integrityshieldreports.apis.integrityshield.io"
 	DefaultSignerConfigCRName                 = "signer-config"
 	DefaultIShieldAdminClusterRoleName        = "ishield-admin-clusterrole"
 	DefaultIShieldAdminClusterRoleBindingName = "ishield-admin-clusterrolebinding"
@@ -55,7 +59,19 @@ const (
 	WebhookRulesForRoksYamlPath               = "./resources/webhook-rules-for-roks.yaml"
 	DefaultKeyringFilename                    = "pubring.gpg"
 	DefaultIShieldWebhookTimeout              = 10
-	SATokenPath                               = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	DefaultCertValidity                       = 2 * 365 * 24 * time.Hour
+	DefaultCertRotateBefore                   = 30 * 24 * time.Hour
+	DefaultCertOverlapPeriod                  = 10 * time.Minute
+	DefaultAutoscalingMinReplicas             = 2
+	DefaultJWKSRefreshInterval                = time.Hour
+	DefaultKeyRotationOverlapPeriod           = 7 * 24 * time.Hour
+	DefaultAutoscalingMaxReplicas             = 5
+	DefaultAutoscalingTargetCPUUtilization    = 80
+	DefaultServerShutdownDelaySec             = 5
+	DefaultServerShutdownTimeoutSec           = 20
+	// time to flush profile status, Events and audit sinks after requests are drained
+	ServerShutdownFlushPeriodSec = 10
+	SATokenPath                  = "/var/run/secrets/kubernetes.io/serviceaccount/token"
 
 	CleanupFinalizerName = "cleanup.finalizers.integrityshield.io"
 )
@@ -81,6 +97,7 @@ type IntegrityShieldSpec struct {
 	IgnoreDefaultIShieldCR bool              `json:"ignoreDefaultIShieldCR,omitempty"`
 	Security               SecurityConfig    `json:"security,omitempty"`
 	KeyConfig              []KeyConfig       `json:"keyConfig,omitempty"`
+	KeyRotations           []KeyRotation     `json:"keyRotations,omitempty"`
 	Server                 ServerContainer   `json:"server,omitempty"`
 	Logger                 LoggerContainer   `json:"logger,omitempty"`
 	Observer               ObserverContainer `json:"observer,omitempty"`
@@ -98,6 +115,76 @@ type IntegrityShieldSpec struct {
 	WebhookConfigName          string     `json:"webhookConfigName,omitempty"`
 	WebhookNamespacedResource  admv1.Rule `json:"webhookNamespacedResource,omitempty"`
 	WebhookClusterResource     admv1.Rule `json:"webhookClusterResource,omitempty"`
+	// defaults are Fail / 10 / None / Equivalent
+	WebhookFailurePolicy  *admv1.FailurePolicyType `json:"webhookFailurePolicy,omitempty"`
+	WebhookTimeoutSeconds *int32                   `json:"webhookTimeoutSeconds,omitempty"`
+	WebhookSideEffects    *admv1.SideEffectClass   `json:"webhookSideEffects,omitempty"`
+	WebhookMatchPolicy    *admv1.MatchPolicyType   `json:"webhookMatchPolicy,omitempty"`
+	// selector for namespaced requests; if empty, namespaces out of the scope of ShieldConfig and RSPs are excluded automatically
+	WebhookNamespaceSelector *metav1.LabelSelector `json:"webhookNamespaceSelector,omitempty"`
+	WebhookObjectSelector    *metav1.LabelSelector `json:"webhookObjectSelector,omitempty"`
+
+	WebhookCertRotation CertRotationConfig `json:"webhookCertRotation,omitempty"`
+
+	// serve v1beta1 of iShield resources; once it is served, the API server prefers v1beta1,
+	// and every read of iShield resources depends on the conversion webhook of iShield server
+	V1beta1APIEnabled bool `json:"v1beta1APIEnabled,omitempty"`
+
+	HighAvailability HighAvailabilityConfig `json:"highAvailability,omitempty"`
+
+	Uninstall UninstallConfig `json:"uninstall,omitempty"`
+}
+
+type UninstallPolicy string
+
+const (
+	UninstallPolicyRetain UninstallPolicy = "Retain"
+	UninstallPolicyDelete UninstallPolicy = "Delete"
+)
+
+// UninstallConfig defines which CRDs are deleted with this CR. Deleting a CRD deletes all of its custom resources.
+type UninstallConfig struct {
+	// policy for CRDs of iShield configuration (ShieldConfig, SignerConfig); default is Delete
+	CRDPolicy UninstallPolicy `json:"crdPolicy,omitempty"`
+	// policy for CRDs of user data (ResourceSignature, ResourceSigningProfile, HelmReleaseMetadata); default is Retain
+	DataPolicy UninstallPolicy `json:"dataPolicy,omitempty"`
+}
+
+// HighAvailabilityConfig keeps iShield server available during voluntary disruptions such as node drains.
+// Unless specified, a PodDisruptionBudget, pod anti-affinity and topology spread constraints are set when the server runs more than one replica.
+type HighAvailabilityConfig struct {
+	PodDisruptionBudget PodDisruptionBudgetConfig `json:"podDisruptionBudget,omitempty"`
+	// default spreads server pods over zones if possible
+	TopologySpreadConstraints []v1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+	Autoscaling               AutoscalingConfig             `json:"autoscaling,omitempty"`
+}
+
+type PodDisruptionBudgetConfig struct {
+	// default is true if the server runs more than one replica
+	Enabled *bool `json:"enabled,omitempty"`
+	// default is minAvailable 1; only one of them can be specified
+	MinAvailable   *intstr.IntOrString `json:"minAvailable,omitempty"`
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// AutoscalingConfig configures HorizontalPodAutoscaler for the server; replicaCount is ignored when enabled
+type AutoscalingConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// defaults are 2 / 5 / 80
+	MinReplicas                    *int32 `json:"minReplicas,omitempty"`
+	MaxReplicas                    int32  `json:"maxReplicas,omitempty"`
+	TargetCPUUtilizationPercentage *int32 `json:"targetCPUUtilizationPercentage,omitempty"`
+}
+
+// CertRotationConfig configures the automatic rotation of the webhook server certificate and its CA
+type CertRotationConfig struct {
+	Disabled bool `json:"disabled,omitempty"`
+	// validity of the generated CA and server certificate (default 17520h)
+	Validity *metav1.Duration `json:"validity,omitempty"`
+	// rotation starts when the remaining validity of the server certificate becomes shorter than this (default 720h)
+	RotateBefore *metav1.Duration `json:"rotateBefore,omitempty"`
+	// both the old and new CAs are trusted for this period before and after switching the server certificate (default 10m)
+	OverlapPeriod *metav1.Duration `json:"overlapPeriod,omitempty"`
 }
 
 type SecurityConfig struct {
@@ -122,11 +209,58 @@ type CertPoolConfig struct {
 	KeyValue         []byte `json:"keyValue,omitempty"`
 }
 
+// KeyConfig is a source of verification keys. One of secretName, configMapName or jwksURL is specified.
 type KeyConfig struct {
 	Name          string               `json:"name,omitempty"`
 	FileName      string               `json:"fileName,omitempty"`
 	SecretName    string               `json:"secretName,omitempty"`
 	SignatureType common.SignatureType `json:"signatureType,omitempty"`
+	ConfigMapName string               `json:"configMapName,omitempty"`
+	// files in the secret or ConfigMap to be loaded; fileName (pgp) or all .crt/.pem files (x509) are loaded if empty
+	FileNames []string `json:"fileNames,omitempty"`
+	// x5c certificates of a JWKS document are loaded as x509 keys; the operator fetches the document periodically
+	JWKSURL string `json:"jwksURL,omitempty"`
+	// PEM encoded CA certificates to verify the https server of jwksURL; the system roots are used if empty
+	JWKSCABundle string `json:"jwksCABundle,omitempty"`
+}
+
+func (self KeyConfig) GetSignatureType() common.SignatureType {
+	if self.SignatureType == common.SignatureTypeDefault {
+		return common.SignatureTypePGP
+	}
+	return self.SignatureType
+}
+
+// GetKeyFiles returns files to be loaded, or nil if all files in the source are loaded
+func (self KeyConfig) GetKeyFiles() []string {
+	if len(self.FileNames) > 0 {
+		return self.FileNames
+	}
+	if self.GetSignatureType() == common.SignatureTypePGP {
+		if self.FileName != "" {
+			return []string{self.FileName}
+		}
+		return []string{DefaultKeyringFilename}
+	}
+	return nil
+}
+
+// KeyRotation replaces the keyConfig of a signer in signerConfig with a new one.
+// Both keyConfigs are trusted during the overlap period, then the old one is retired.
+type KeyRotation struct {
+	// name of a signer in signerConfig
+	Signer string `json:"signer"`
+	// name of a keyConfig which replaces the current keyConfig of the signer
+	NewKeyConfig string `json:"newKeyConfig"`
+	// both keyConfigs are trusted for this period after the rotation starts (default 168h)
+	OverlapPeriod *metav1.Duration `json:"overlapPeriod,omitempty"`
+}
+
+func (self KeyRotation) GetOverlapPeriod() time.Duration {
+	if self.OverlapPeriod != nil && self.OverlapPeriod.Duration > 0 {
+		return self.OverlapPeriod.Duration
+	}
+	return DefaultKeyRotationOverlapPeriod
 }
 
 type ServerContainer struct {
@@ -140,6 +274,16 @@ type ServerContainer struct {
 	ContextLogEnabled      bool                    `json:"contextLogEnabled,omitempty"`
 	ShieldCmReloadSec      int32                   `json:"shieldCmReloadSec,omitempty"`
 	EnforcePolicyReloadSec int32                   `json:"shieldPolicyReloadSec,omitempty"`
+	// max number of admission requests processed concurrently; server default is used if 0
+	MaxInflightRequests int32 `json:"maxInflightRequests,omitempty"`
+	// "Fail" (deny; default) or "Ignore" (allow without verification) for requests received while the server is saturated
+	SaturationFailurePolicy string `json:"saturationFailurePolicy,omitempty"`
+	MaxRequestBodyBytes     int64  `json:"maxRequestBodyBytes,omitempty"`
+	// time to keep serving after the server becomes unready, until the pod is removed from the service endpoints
+	ShutdownDelaySec   int32 `json:"shutdownDelaySec,omitempty"`
+	ShutdownTimeoutSec int32 `json:"shutdownTimeoutSec,omitempty"`
+	// Secrets mounted at /audit-sinks/<secret name>/, e.g. tokenFile and caFile of audit sinks
+	AuditSinkSecrets []string `json:"auditSinkSecrets,omitempty"`
 }
 
 type LoggerContainer struct {
@@ -162,6 +306,18 @@ type ObserverContainer struct {
 	ImagePullPolicy v1.PullPolicy           `json:"imagePullPolicy,omitempty"`
 	Image           string                  `json:"image,omitempty"`
 	Resources       v1.ResourceRequirements `json:"resources,omitempty"`
+	// local history of decision records, which can be queried after the event log is rotated
+	EventStore ObserverEventStore `json:"eventStore,omitempty"`
+	// re-label resources which have been changed from their signed messages without passing the webhook as unverified
+	DriftRelabel bool `json:"driftRelabel,omitempty"`
+}
+
+type ObserverEventStore struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// PVC to keep the history over pod restarts; if empty, an emptyDir volume is used
+	PersistentVolumeClaimName string `json:"persistentVolumeClaimName,omitempty"`
+	// records older than this are removed; default is 168 (7 days)
+	RetentionHours int `json:"retentionHours,omitempty"`
 }
 
 type EsConfig struct {
@@ -194,14 +350,138 @@ type ProfileConfig struct {
 	Name                            string `json:"name,omitempty"`
 }
 
+type IntegrityShieldConditionType string
+
+const (
+	// all keyring secrets exist and contain at least one verification key
+	ConditionKeyringReady IntegrityShieldConditionType = "KeyringReady"
+	// webhook server certificate exists and is valid
+	ConditionCertReady IntegrityShieldConditionType = "CertReady"
+	// webhook server deployment has available replicas
+	ConditionServerAvailable IntegrityShieldConditionType = "ServerAvailable"
+	// MutatingWebhookConfiguration exists with the CA bundle of the current certificate
+	ConditionWebhookConfigured IntegrityShieldConditionType = "WebhookConfigured"
+	// ShieldConfig built from this CR passes validation
+	ConditionConfigValid IntegrityShieldConditionType = "ConfigValid"
+	// this instance does not conflict with other instances in the name, namespace or target namespaces
+	ConditionIsolated IntegrityShieldConditionType = "Isolated"
+	// spec of this CR passes validation; an instance with an invalid spec is not reconciled
+	ConditionSpecValid IntegrityShieldConditionType = "SpecValid"
+)
+
+// ModeInactive is reported as the active mode while requests are not verified by the webhook
+const ModeInactive = "inactive"
+
+type IntegrityShieldCondition struct {
+	Type               IntegrityShieldConditionType `json:"type"`
+	Status             v1.ConditionStatus           `json:"status"`
+	ObservedGeneration int64                        `json:"observedGeneration,omitempty"`
+	LastTransitionTime metav1.Time                  `json:"lastTransitionTime,omitempty"`
+	Reason             string                       `json:"reason,omitempty"`
+	Message            string                       `json:"message,omitempty"`
+}
+
+// KeyStatus is the verification keys loaded from a key source
+type KeyStatus struct {
+	Name          string               `json:"name"`
+	SecretName    string               `json:"secretName,omitempty"`
+	ConfigMapName string               `json:"configMapName,omitempty"`
+	JWKSURL       string               `json:"jwksURL,omitempty"`
+	SignatureType common.SignatureType `json:"signatureType,omitempty"`
+	Fingerprints  []string             `json:"fingerprints,omitempty"`
+	// e.g. keys in JWKS which are not loaded
+	Message string `json:"message,omitempty"`
+}
+
+type KeyRotationPhase string
+
+const (
+	KeyRotationPhaseOverlapping KeyRotationPhase = "Overlapping"
+	KeyRotationPhaseCompleted   KeyRotationPhase = "Completed"
+)
+
+// KeyRotationStatus is the progress of a key rotation
+type KeyRotationStatus struct {
+	Signer       string           `json:"signer"`
+	OldKeyConfig string           `json:"oldKeyConfig,omitempty"`
+	NewKeyConfig string           `json:"newKeyConfig"`
+	Phase        KeyRotationPhase `json:"phase,omitempty"`
+	StartTime    *metav1.Time     `json:"startTime,omitempty"`
+	// the old keyConfig is not trusted after this time
+	RetireTime *metav1.Time `json:"retireTime,omitempty"`
+	// resources whose latest signature was verified only with the old keyConfig, from the key inventory of the observer
+	ResourcesSignedByOldKey []string `json:"resourcesSignedByOldKey,omitempty"`
+	Message                 string   `json:"message,omitempty"`
+}
+
+// CertStatus is the state of the webhook server certificate
+type CertStatus struct {
+	NotAfter *metav1.Time `json:"notAfter,omitempty"`
+	// phase of the ongoing rotation (TrustingNewCA / ServingNewCert); empty if no rotation is in progress
+	RotationPhase    string       `json:"rotationPhase,omitempty"`
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
+}
+
 // IntegrityShieldStatus defines the observed state of IntegrityShield
 type IntegrityShieldStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// mode of the ShieldConfig (enforce / detect) if requests are verified by the webhook, otherwise "inactive"
+	Mode         string                     `json:"mode,omitempty"`
+	Keys         []KeyStatus                `json:"keys,omitempty"`
+	KeyRotations []KeyRotationStatus        `json:"keyRotations,omitempty"`
+	Cert         *CertStatus                `json:"cert,omitempty"`
+	Conditions   []IntegrityShieldCondition `json:"conditions,omitempty"`
+	// resources which are removed or retained when this CR is deleted
+	Uninstall *UninstallReport `json:"uninstall,omitempty"`
+}
+
+// UninstallReport lists resources as "Kind/name" with the number of custom resources in each CRD
+type UninstallReport struct {
+	Remove []string `json:"remove,omitempty"`
+	Retain []string `json:"retain,omitempty"`
+}
+
+// GetCondition returns the condition of the type, or nil if not found
+func (self *IntegrityShieldStatus) GetCondition(condType IntegrityShieldConditionType) *IntegrityShieldCondition {
+	for i := range self.Conditions {
+		if self.Conditions[i].Type == condType {
+			return &self.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds or updates the condition; LastTransitionTime is changed only when the status is changed
+func (self *IntegrityShieldStatus) SetCondition(cond IntegrityShieldCondition) {
+	current := self.GetCondition(cond.Type)
+	if current == nil {
+		if cond.LastTransitionTime.IsZero() {
+			cond.LastTransitionTime = metav1.Now()
+		}
+		self.Conditions = append(self.Conditions, cond)
+		return
+	}
+	if current.Status != cond.Status {
+		current.Status = cond.Status
+		current.LastTransitionTime = metav1.Now()
+	}
+	current.ObservedGeneration = cond.ObservedGeneration
+	current.Reason = cond.Reason
+	current.Message = cond.Message
+}
+
+// IsConditionTrue returns true if the condition of the type exists and its status is True
+func (self *IntegrityShieldStatus) IsConditionTrue(condType IntegrityShieldConditionType) bool {
+	cond := self.GetCondition(condType)
+	return cond != nil && cond.Status == v1.ConditionTrue
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Mode",type=string,JSONPath=`.status.mode`
+// +kubebuilder:printcolumn:name="Server",type=string,JSONPath=`.status.conditions[?(@.type=="ServerAvailable")].status`
+// +kubebuilder:printcolumn:name="Webhook",type=string,JSONPath=`.status.conditions[?(@.type=="WebhookConfigured")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // IntegrityShield is the Schema for the integrityshields API
 type IntegrityShield struct {
@@ -225,8 +505,21 @@ func init() {
 	SchemeBuilder.Register(&IntegrityShield{}, &IntegrityShieldList{})
 }
 
+// IsDefaultInstance returns true for the instance with the default name, whose resources keep the names in the spec.
+// Other instances suffix the names with the instance name, so that multiple instances can run in a cluster.
+func (self *IntegrityShield) IsDefaultInstance() bool {
+	return self.Name == DefaultIntegrityShieldCRName
+}
+
+func (self *IntegrityShield) getInstanceScopedName(name string) string {
+	if self.IsDefaultInstance() || name == "" {
+		return name
+	}
+	return fmt.Sprintf("%s-%s", name, self.Name)
+}
+
 func (self *IntegrityShield) GetSecurityContextConstraintsName() string {
-	return self.Spec.Security.SecurityContextConstraintsName
+	return self.getInstanceScopedName(self.Spec.Security.SecurityContextConstraintsName)
 }
 
 func (self *IntegrityShield) GetIntegrityShieldCRDName() string {
@@ -253,12 +546,16 @@ func (self *IntegrityShield) GetHelmReleaseMetadataCRDName() string {
 	return DefaultHelmReleaseMetadataCRDName
 }
 
+func (self *IntegrityShield) GetIntegrityShieldReportCRDName() string {
+	return DefaultIntegrityShieldReportCRDName
+}
+
 func (self *IntegrityShield) GetShieldConfigCRName() string {
 	return self.Spec.ShieldConfigCrName
 }
 
 func (self *IntegrityShield) GetSignerConfigCRName() string {
-	return DefaultSignerConfigCRName
+	return self.getInstanceScopedName(DefaultSignerConfigCRName)
 }
 
 func (self *IntegrityShield) GetRegKeySecretName() string {
@@ -274,51 +571,196 @@ func (self *IntegrityShield) GetServiceAccountName() string {
 }
 
 func (self *IntegrityShield) GetClusterRoleName() string {
-	return self.Spec.Security.ClusterRole
+	return self.getInstanceScopedName(self.Spec.Security.ClusterRole)
 }
 
 func (self *IntegrityShield) GetClusterRoleBindingName() string {
-	return self.Spec.Security.ClusterRoleBinding
+	return self.getInstanceScopedName(self.Spec.Security.ClusterRoleBinding)
 }
 
 func (self *IntegrityShield) GetDryRunRoleName() string {
-	return self.Spec.Security.ClusterRole + "-sim"
+	return self.GetClusterRoleName() + "-sim"
 }
 
 func (self *IntegrityShield) GetDryRunRoleBindingName() string {
-	return self.Spec.Security.ClusterRoleBinding + "-sim"
+	return self.GetClusterRoleBindingName() + "-sim"
 }
 
 func (self *IntegrityShield) GetIShieldAdminClusterRoleName() string {
-	return DefaultIShieldAdminClusterRoleName
+	return self.getInstanceScopedName(DefaultIShieldAdminClusterRoleName)
 }
 
 func (self *IntegrityShield) GetIShieldAdminClusterRoleBindingName() string {
-	return DefaultIShieldAdminClusterRoleBindingName
+	return self.getInstanceScopedName(DefaultIShieldAdminClusterRoleBindingName)
 }
 
 func (self *IntegrityShield) GetIShieldAdminRoleName() string {
-	return DefaultIShieldAdminRoleName
+	return self.getInstanceScopedName(DefaultIShieldAdminRoleName)
 }
 
 func (self *IntegrityShield) GetIShieldAdminRoleBindingName() string {
-	return DefaultIShieldAdminRoleBindingName
+	return self.getInstanceScopedName(DefaultIShieldAdminRoleBindingName)
 }
 
 func (self *IntegrityShield) GetPodSecurityPolicyName() string {
-	return self.Spec.Security.PodSecurityPolicyName
+	return self.getInstanceScopedName(self.Spec.Security.PodSecurityPolicyName)
 }
 
 func (self *IntegrityShield) GetIShieldServerDeploymentName() string {
 	return self.Name
 }
 
+// GetServerMinReplicas returns the number of server replicas which run at least
+func (self *IntegrityShield) GetServerMinReplicas() int32 {
+	if self.Spec.HighAvailability.Autoscaling.Enabled {
+		return self.GetAutoscalingMinReplicas()
+	}
+	if self.Spec.ReplicaCount != nil {
+		return *self.Spec.ReplicaCount
+	}
+	return 1
+}
+
+// GetTerminationGracePeriodSeconds returns the grace period which covers the shutdown delay, draining requests and flushing
+func (self *IntegrityShield) GetTerminationGracePeriodSeconds() int64 {
+	delay := int64(DefaultServerShutdownDelaySec)
+	if self.Spec.Server.ShutdownDelaySec > 0 {
+		delay = int64(self.Spec.Server.ShutdownDelaySec)
+	}
+	timeout := int64(DefaultServerShutdownTimeoutSec)
+	if self.Spec.Server.ShutdownTimeoutSec > 0 {
+		timeout = int64(self.Spec.Server.ShutdownTimeoutSec)
+	}
+	return delay + timeout + ServerShutdownFlushPeriodSec
+}
+
+func (self *IntegrityShield) IsHighlyAvailable() bool {
+	return self.GetServerMinReplicas() > 1
+}
+
+func (self *IntegrityShield) IsPodDisruptionBudgetEnabled() bool {
+	if enabled := self.Spec.HighAvailability.PodDisruptionBudget.Enabled; enabled != nil {
+		return *enabled
+	}
+	return self.IsHighlyAvailable()
+}
+
+// GetJWKSConfigMapName returns the name of ConfigMap which holds certificates fetched from JWKS URL of the key config
+func (self *IntegrityShield) GetJWKSConfigMapName(keyConfigName string) string {
+	return fmt.Sprintf("%s-%s-jwks", self.Name, keyConfigName)
+}
+
+func (self *IntegrityShield) GetPodDisruptionBudgetName() string {
+	return self.GetIShieldServerDeploymentName()
+}
+
+func (self *IntegrityShield) GetHorizontalPodAutoscalerName() string {
+	return self.GetIShieldServerDeploymentName()
+}
+
+func (self *IntegrityShield) GetAutoscalingMinReplicas() int32 {
+	if minReplicas := self.Spec.HighAvailability.Autoscaling.MinReplicas; minReplicas != nil && *minReplicas > 0 {
+		return *minReplicas
+	}
+	return DefaultAutoscalingMinReplicas
+}
+
+func (self *IntegrityShield) GetAutoscalingMaxReplicas() int32 {
+	if maxReplicas := self.Spec.HighAvailability.Autoscaling.MaxReplicas; maxReplicas > 0 {
+		return maxReplicas
+	}
+	if minReplicas := self.GetAutoscalingMinReplicas(); minReplicas > DefaultAutoscalingMaxReplicas {
+		return minReplicas
+	}
+	return DefaultAutoscalingMaxReplicas
+}
+
+func (self *IntegrityShield) GetAutoscalingTargetCPUUtilization() int32 {
+	if target := self.Spec.HighAvailability.Autoscaling.TargetCPUUtilizationPercentage; target != nil && *target > 0 {
+		return *target
+	}
+	return DefaultAutoscalingTargetCPUUtilization
+}
+
+func (self *IntegrityShield) GetCRDUninstallPolicy() UninstallPolicy {
+	if self.Spec.Uninstall.CRDPolicy == "" {
+		return UninstallPolicyDelete
+	}
+	return self.Spec.Uninstall.CRDPolicy
+}
+
+func (self *IntegrityShield) GetDataUninstallPolicy() UninstallPolicy {
+	if self.Spec.Uninstall.DataPolicy == "" {
+		return UninstallPolicyRetain
+	}
+	return self.Spec.Uninstall.DataPolicy
+}
+
+func (self *IntegrityShield) GetCertValidity() time.Duration {
+	if d := self.Spec.WebhookCertRotation.Validity; d != nil && d.Duration > 0 {
+		return d.Duration
+	}
+	return DefaultCertValidity
+}
+
+// GetCertRotateBefore returns the remaining validity at which the rotation starts;
+// it is shortened if it is not shorter than the validity, so that a new cert is not rotated immediately
+func (self *IntegrityShield) GetCertRotateBefore() time.Duration {
+	rotateBefore := DefaultCertRotateBefore
+	if d := self.Spec.WebhookCertRotation.RotateBefore; d != nil && d.Duration > 0 {
+		rotateBefore = d.Duration
+	}
+	if validity := self.GetCertValidity(); rotateBefore >= validity {
+		rotateBefore = validity / 3
+	}
+	return rotateBefore
+}
+
+func (self *IntegrityShield) GetCertOverlapPeriod() time.Duration {
+	if d := self.Spec.WebhookCertRotation.OverlapPeriod; d != nil && d.Duration > 0 {
+		return d.Duration
+	}
+	return DefaultCertOverlapPeriod
+}
+
 func (self *IntegrityShield) GetWebhookServiceName() string {
 	return self.Spec.WebhookServiceName
 }
 
 func (self *IntegrityShield) GetWebhookConfigName() string {
-	return self.Spec.WebhookConfigName
+	return self.getInstanceScopedName(self.Spec.WebhookConfigName)
+}
+
+func (self *IntegrityShield) GetValidatingWebhookConfigName() string {
+	return self.GetWebhookConfigName() + "-validator"
+}
+
+func (self *IntegrityShield) GetWebhookFailurePolicy() admv1.FailurePolicyType {
+	if self.Spec.WebhookFailurePolicy != nil {
+		return *self.Spec.WebhookFailurePolicy
+	}
+	return admv1.Fail
+}
+
+func (self *IntegrityShield) GetWebhookTimeoutSeconds() int32 {
+	if self.Spec.WebhookTimeoutSeconds != nil {
+		return *self.Spec.WebhookTimeoutSeconds
+	}
+	return DefaultIShieldWebhookTimeout
+}
+
+func (self *IntegrityShield) GetWebhookSideEffects() admv1.SideEffectClass {
+	if self.Spec.WebhookSideEffects != nil {
+		return *self.Spec.WebhookSideEffects
+	}
+	return admv1.SideEffectClassNone
+}
+
+func (self *IntegrityShield) GetWebhookMatchPolicy() admv1.MatchPolicyType {
+	if self.Spec.WebhookMatchPolicy != nil {
+		return *self.Spec.WebhookMatchPolicy
+	}
+	return admv1.Equivalent
 }
 
 func (self *IntegrityShield) GetIShieldResourceList(scheme *runtime.Scheme) ([]*common.ResourceRef, []*common.ResourceRef) {
@@ -389,6 +831,10 @@ func (self *IntegrityShield) GetIShieldResourceList(scheme *runtime.Scheme) ([]*
 			Kind: _crdType.Kind,
 			Name: self.GetHelmReleaseMetadataCRDName(),
 		},
+		{
+			Kind: _crdType.Kind,
+			Name: self.GetIntegrityShieldReportCRDName(),
+		},
 		{
 			Kind:      _ecType.Kind,
 			Name:      self.GetShieldConfigCRName(),
@@ -0,0 +1,307 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package v1alpha1
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"strings"
+
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	iec "github.com/IBM/integrity-enforcer/shield/pkg/shield/config"
+	ishieldvalidation "github.com/IBM/integrity-enforcer/shield/pkg/shield/validation"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	intstr "k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+const (
+	maxWebhookTimeoutSeconds = 30
+)
+
+func (r *IntegrityShield) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:webhookVersions=v1,verbs=create;update,path=/validate-apis-integrityshield-io-v1alpha1-integrityshield,mutating=false,failurePolicy=fail,sideEffects=None,admissionReviewVersions=v1beta1,groups=apis.integrityshield.io,resources=integrityshields,versions=v1alpha1,name=vintegrityshield.kb.io
+
+var _ webhook.Validator = &IntegrityShield{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *IntegrityShield) ValidateCreate() error {
+	return r.validate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *IntegrityShield) ValidateUpdate(old runtime.Object) error {
+	return r.validate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *IntegrityShield) ValidateDelete() error {
+	return nil
+}
+
+func (r *IntegrityShield) validate() error {
+	allErrs := r.ValidateSpec()
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("IntegrityShield").GroupKind(), r.Name, allErrs)
+}
+
+// ValidateSpec returns field errors of the spec, which make iShield not work as intended
+func (r *IntegrityShield) ValidateSpec() field.ErrorList {
+	allErrs := field.ErrorList{}
+	specPath := field.NewPath("spec")
+
+	keyConfigNames := []string{}
+	keyConfigPath := specPath.Child("keyConfig")
+	for i, keyConfig := range r.Spec.KeyConfig {
+		keyPath := keyConfigPath.Index(i)
+		if keyConfig.Name == "" {
+			allErrs = append(allErrs, field.Required(keyPath.Child("name"), ""))
+		} else if common.ExactMatchWithPatternArray(keyConfig.Name, keyConfigNames) {
+			allErrs = append(allErrs, field.Duplicate(keyPath.Child("name"), keyConfig.Name))
+		} else {
+			keyConfigNames = append(keyConfigNames, keyConfig.Name)
+		}
+		switch keyConfig.SignatureType {
+		case common.SignatureTypeDefault, common.SignatureTypePGP, common.SignatureTypeX509:
+		default:
+			supported := []string{string(common.SignatureTypePGP), string(common.SignatureTypeX509)}
+			allErrs = append(allErrs, field.NotSupported(keyPath.Child("signatureType"), keyConfig.SignatureType, supported))
+		}
+		allErrs = append(allErrs, validateKeySource(keyConfig, keyPath)...)
+	}
+
+	// empty signerConfig is replaced with the default one
+	if sc := r.Spec.SignerConfig; sc != nil && (len(sc.Signers) > 0 || len(sc.Policies) > 0) {
+		allErrs = append(allErrs, ishieldvalidation.ValidateSignerConfigSpec(sc, keyConfigNames, specPath.Child("signerConfig"))...)
+	}
+
+	allErrs = append(allErrs, r.validateKeyRotations(keyConfigNames, specPath.Child("keyRotations"))...)
+
+	if conf := r.Spec.ShieldConfig; conf != nil {
+		shieldConfigPath := specPath.Child("shieldConfig")
+		if conf.Mode != iec.UnknownMode && conf.Mode != iec.EnforceMode && conf.Mode != iec.DetectMode {
+			supported := []string{string(iec.EnforceMode), string(iec.DetectMode)}
+			allErrs = append(allErrs, field.NotSupported(shieldConfigPath.Child("mode"), conf.Mode, supported))
+		}
+		if conf.InScopeNamespaceSelector != nil {
+			allErrs = append(allErrs, ishieldvalidation.ValidateNamespaceSelector(conf.InScopeNamespaceSelector, shieldConfigPath.Child("inScopeNamespaceSelector"))...)
+		}
+	}
+
+	profileNames := []string{}
+	for i, profile := range r.Spec.ResourceSigningProfiles {
+		profilePath := specPath.Child("resourceSigningProfiles").Index(i)
+		if profile == nil {
+			continue
+		}
+		if profile.Name == "" {
+			allErrs = append(allErrs, field.Required(profilePath.Child("name"), ""))
+		} else if common.ExactMatchWithPatternArray(profile.Name, profileNames) {
+			allErrs = append(allErrs, field.Duplicate(profilePath.Child("name"), profile.Name))
+		} else {
+			profileNames = append(profileNames, profile.Name)
+		}
+		if profile.ResourceSigningProfileSpec == nil {
+			allErrs = append(allErrs, field.Required(profilePath, "profile without spec protects nothing"))
+			continue
+		}
+		// profiles in the CR are created in the iShield namespace
+		allErrs = append(allErrs, ishieldvalidation.ValidateProfileSpec(profile.ResourceSigningProfileSpec, r.Namespace, r.Namespace, profilePath)...)
+	}
+
+	if timeout := r.Spec.WebhookTimeoutSeconds; timeout != nil && (*timeout < 1 || *timeout > maxWebhookTimeoutSeconds) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("webhookTimeoutSeconds"), *timeout, fmt.Sprintf("must be between 1 and %d", maxWebhookTimeoutSeconds)))
+	}
+
+	certRotation := r.Spec.WebhookCertRotation
+	certRotationPath := specPath.Child("webhookCertRotation")
+	allErrs = append(allErrs, validateDuration(certRotation.Validity, certRotationPath.Child("validity"))...)
+	allErrs = append(allErrs, validateDuration(certRotation.RotateBefore, certRotationPath.Child("rotateBefore"))...)
+	allErrs = append(allErrs, validateDuration(certRotation.OverlapPeriod, certRotationPath.Child("overlapPeriod"))...)
+	allErrs = append(allErrs, r.ValidateCertRotationPeriods(certRotationPath)...)
+	allErrs = append(allErrs, r.validateHighAvailability(specPath.Child("highAvailability"))...)
+
+	uninstallPath := specPath.Child("uninstall")
+	allErrs = append(allErrs, validateUninstallPolicy(r.Spec.Uninstall.CRDPolicy, uninstallPath.Child("crdPolicy"))...)
+	allErrs = append(allErrs, validateUninstallPolicy(r.Spec.Uninstall.DataPolicy, uninstallPath.Child("dataPolicy"))...)
+	return allErrs
+}
+
+func (r *IntegrityShield) validateHighAvailability(fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	ha := r.Spec.HighAvailability
+
+	pdb := ha.PodDisruptionBudget
+	pdbPath := fldPath.Child("podDisruptionBudget")
+	if pdb.MinAvailable != nil && pdb.MaxUnavailable != nil {
+		allErrs = append(allErrs, field.Forbidden(pdbPath.Child("maxUnavailable"), "cannot be specified with minAvailable"))
+	}
+	// a budget which never allows eviction blocks node drains
+	if r.IsPodDisruptionBudgetEnabled() {
+		replicas := int(r.GetServerMinReplicas())
+		if pdb.MinAvailable != nil {
+			minAvailable, err := intstr.GetValueFromIntOrPercent(pdb.MinAvailable, replicas, true)
+			if err != nil {
+				allErrs = append(allErrs, field.Invalid(pdbPath.Child("minAvailable"), pdb.MinAvailable.String(), err.Error()))
+			} else if minAvailable >= replicas {
+				allErrs = append(allErrs, field.Invalid(pdbPath.Child("minAvailable"), pdb.MinAvailable.String(), fmt.Sprintf("must be less than the number of server replicas (%d) so that nodes can be drained", replicas)))
+			}
+		} else if pdb.MaxUnavailable != nil {
+			maxUnavailable, err := intstr.GetValueFromIntOrPercent(pdb.MaxUnavailable, replicas, false)
+			if err != nil {
+				allErrs = append(allErrs, field.Invalid(pdbPath.Child("maxUnavailable"), pdb.MaxUnavailable.String(), err.Error()))
+			} else if maxUnavailable < 1 {
+				allErrs = append(allErrs, field.Invalid(pdbPath.Child("maxUnavailable"), pdb.MaxUnavailable.String(), "must allow at least one pod to be evicted so that nodes can be drained"))
+			}
+		} else if replicas < 2 {
+			allErrs = append(allErrs, field.Invalid(pdbPath.Child("enabled"), true, "the default budget (minAvailable 1) needs at least 2 server replicas so that nodes can be drained"))
+		}
+	}
+
+	autoscaling := ha.Autoscaling
+	if autoscaling.Enabled {
+		autoscalingPath := fldPath.Child("autoscaling")
+		if autoscaling.MinReplicas != nil && *autoscaling.MinReplicas < 1 {
+			allErrs = append(allErrs, field.Invalid(autoscalingPath.Child("minReplicas"), *autoscaling.MinReplicas, "must be greater than 0"))
+		}
+		if autoscaling.MaxReplicas != 0 && autoscaling.MaxReplicas < r.GetAutoscalingMinReplicas() {
+			allErrs = append(allErrs, field.Invalid(autoscalingPath.Child("maxReplicas"), autoscaling.MaxReplicas, "must not be less than minReplicas"))
+		}
+		if target := autoscaling.TargetCPUUtilizationPercentage; target != nil && (*target < 1 || *target > 100) {
+			allErrs = append(allErrs, field.Invalid(autoscalingPath.Child("targetCPUUtilizationPercentage"), *target, "must be between 1 and 100"))
+		}
+	}
+	return allErrs
+}
+
+// validateKeyRotations checks that each rotation replaces the keyConfig of a signer in signerConfig with a declared keyConfig
+func (r *IntegrityShield) validateKeyRotations(keyConfigNames []string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	signerNames := []string{}
+	if sc := r.Spec.SignerConfig; sc != nil {
+		for _, signer := range sc.Signers {
+			signerNames = append(signerNames, signer.Name)
+		}
+	}
+	rotatedSigners := []string{}
+	for i, rotation := range r.Spec.KeyRotations {
+		rotationPath := fldPath.Index(i)
+		if rotation.Signer == "" {
+			allErrs = append(allErrs, field.Required(rotationPath.Child("signer"), ""))
+		} else if !common.ExactMatchWithPatternArray(rotation.Signer, signerNames) {
+			allErrs = append(allErrs, field.NotFound(rotationPath.Child("signer"), rotation.Signer))
+		} else if common.ExactMatchWithPatternArray(rotation.Signer, rotatedSigners) {
+			allErrs = append(allErrs, field.Duplicate(rotationPath.Child("signer"), rotation.Signer))
+		} else {
+			rotatedSigners = append(rotatedSigners, rotation.Signer)
+		}
+		if rotation.NewKeyConfig == "" {
+			allErrs = append(allErrs, field.Required(rotationPath.Child("newKeyConfig"), ""))
+		} else if !common.ExactMatchWithPatternArray(rotation.NewKeyConfig, keyConfigNames) {
+			allErrs = append(allErrs, field.NotFound(rotationPath.Child("newKeyConfig"), rotation.NewKeyConfig))
+		}
+		allErrs = append(allErrs, validateDuration(rotation.OverlapPeriod, rotationPath.Child("overlapPeriod"))...)
+	}
+	return allErrs
+}
+
+// ValidateCertRotationPeriods checks overlapPeriod < rotateBefore < validity with the defaults of unspecified periods,
+// so that the rotation neither starts right after a cert is issued nor is completed after the old cert expires
+func (r *IntegrityShield) ValidateCertRotationPeriods(fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	certRotation := r.Spec.WebhookCertRotation
+	validity := r.GetCertValidity()
+	rotateBefore := r.GetCertRotateBefore()
+	if d := certRotation.RotateBefore; d != nil && d.Duration >= validity {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("rotateBefore"), d.Duration.String(), fmt.Sprintf("must be shorter than validity (%s)", validity.String())))
+	}
+	if overlap := r.GetCertOverlapPeriod(); overlap >= rotateBefore {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("overlapPeriod"), overlap.String(), fmt.Sprintf("must be shorter than rotateBefore (%s)", rotateBefore.String())))
+	}
+	return allErrs
+}
+
+func validateKeySource(keyConfig KeyConfig, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	sources := 0
+	for _, source := range []string{keyConfig.SecretName, keyConfig.ConfigMapName, keyConfig.JWKSURL} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("secretName"), "one of secretName, configMapName or jwksURL must be specified"))
+	} else if sources > 1 {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "only one of secretName, configMapName or jwksURL can be specified"))
+	}
+	for i, fileName := range keyConfig.FileNames {
+		if fileName == "" || strings.Contains(fileName, "/") {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("fileNames").Index(i), fileName, "must be a file name in the secret or ConfigMap"))
+		}
+	}
+	if keyConfig.JWKSURL != "" {
+		jwksPath := fldPath.Child("jwksURL")
+		if u, err := url.Parse(keyConfig.JWKSURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			allErrs = append(allErrs, field.Invalid(jwksPath, keyConfig.JWKSURL, "must be an https URL"))
+		}
+		if keyConfig.JWKSCABundle != "" {
+			if !x509.NewCertPool().AppendCertsFromPEM([]byte(keyConfig.JWKSCABundle)) {
+				allErrs = append(allErrs, field.Invalid(fldPath.Child("jwksCABundle"), "", "must contain PEM encoded certificates"))
+			}
+		}
+		if keyConfig.GetSignatureType() != common.SignatureTypeX509 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("signatureType"), keyConfig.SignatureType, "must be x509 for jwksURL"))
+		}
+		if len(keyConfig.FileNames) > 0 {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("fileNames"), "cannot be specified with jwksURL"))
+		}
+	} else if keyConfig.JWKSCABundle != "" {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("jwksCABundle"), "can be specified only with jwksURL"))
+	}
+	return allErrs
+}
+
+func validateUninstallPolicy(policy UninstallPolicy, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	switch policy {
+	case "", UninstallPolicyRetain, UninstallPolicyDelete:
+	default:
+		supported := []string{string(UninstallPolicyRetain), string(UninstallPolicyDelete)}
+		allErrs = append(allErrs, field.NotSupported(fldPath, policy, supported))
+	}
+	return allErrs
+}
+
+func validateDuration(d *metav1.Duration, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if d != nil && d.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath, d.Duration.String(), "must not be negative"))
+	}
+	return allErrs
+}
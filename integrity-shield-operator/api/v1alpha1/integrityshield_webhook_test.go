@@ -0,0 +1,188 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package v1alpha1
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	"github.com/ghodss/yaml"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	intstr "k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateSpec(t *testing.T) {
+	crBytes, err := ioutil.ReadFile("../../config/samples/apis_v1alpha1_integrityshield.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cr *IntegrityShield
+	if err = yaml.Unmarshal(crBytes, &cr); err != nil {
+		t.Fatal(err)
+	}
+	cr.Namespace = "integrity-shield-operator-system"
+	if err = cr.ValidateCreate(); err != nil {
+		t.Errorf("sample CR must be valid: %s", err.Error())
+	}
+
+	invalidTimeout := int32(60)
+	cr.Spec.WebhookTimeoutSeconds = &invalidTimeout
+	cr.Spec.KeyConfig = append(cr.Spec.KeyConfig, cr.Spec.KeyConfig[0])
+	cr.Spec.SignerConfig.Signers[0].KeyConfig = "unknown-keyconfig"
+	err = cr.ValidateUpdate(cr.DeepCopy())
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("invalid CR must be rejected: %v", err)
+	}
+	causes := err.(*apierrors.StatusError).ErrStatus.Details.Causes
+	expected := map[string]bool{
+		"spec.webhookTimeoutSeconds":             true,
+		"spec.keyConfig[1].name":                 true,
+		"spec.signerConfig.signers[0].keyConfig": true,
+	}
+	for _, cause := range causes {
+		if !expected[cause.Field] {
+			t.Errorf("unexpected error: %s %s", cause.Field, cause.Message)
+		}
+		delete(expected, cause.Field)
+	}
+	if len(expected) > 0 {
+		t.Errorf("expected errors are not returned: %v", expected)
+	}
+}
+
+func TestValidateHighAvailability(t *testing.T) {
+	replicas := int32(2)
+	enabled := true
+	minAvailable := intstr.FromInt(2)
+	cr := &IntegrityShield{}
+	cr.Spec.ReplicaCount = &replicas
+	cr.Spec.HighAvailability.PodDisruptionBudget.MinAvailable = &minAvailable
+	if errs := cr.validateHighAvailability(field.NewPath("spec")); len(errs) != 1 {
+		t.Errorf("minAvailable equal to replicas must be rejected: %v", errs)
+	}
+
+	minAvailable = intstr.FromString("50%")
+	if errs := cr.validateHighAvailability(field.NewPath("spec")); len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+
+	replicas = 1
+	cr.Spec.HighAvailability.PodDisruptionBudget = PodDisruptionBudgetConfig{Enabled: &enabled}
+	if errs := cr.validateHighAvailability(field.NewPath("spec")); len(errs) != 1 {
+		t.Errorf("default budget with a single replica must be rejected: %v", errs)
+	}
+
+	// autoscaling defaults to 2 replicas at least
+	cr.Spec.HighAvailability.Autoscaling.Enabled = true
+	if errs := cr.validateHighAvailability(field.NewPath("spec")); len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	cr.Spec.HighAvailability.Autoscaling.MaxReplicas = 1
+	if errs := cr.validateHighAvailability(field.NewPath("spec")); len(errs) != 1 {
+		t.Errorf("maxReplicas less than minReplicas must be rejected: %v", errs)
+	}
+}
+
+func TestValidateCertRotationPeriods(t *testing.T) {
+	fldPath := field.NewPath("spec").Child("webhookCertRotation")
+	cr := &IntegrityShield{}
+	if errs := cr.ValidateCertRotationPeriods(fldPath); len(errs) != 0 {
+		t.Errorf("default periods must be valid: %v", errs)
+	}
+
+	// the default rotateBefore is shortened for a short validity
+	cr.Spec.WebhookCertRotation.Validity = &metav1.Duration{Duration: 24 * time.Hour}
+	if errs := cr.ValidateCertRotationPeriods(fldPath); len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+
+	cr.Spec.WebhookCertRotation.RotateBefore = &metav1.Duration{Duration: 24 * time.Hour}
+	if errs := cr.ValidateCertRotationPeriods(fldPath); len(errs) != 1 || errs[0].Field != "spec.webhookCertRotation.rotateBefore" {
+		t.Errorf("rotateBefore not shorter than validity must be rejected: %v", errs)
+	}
+
+	cr.Spec.WebhookCertRotation.RotateBefore = &metav1.Duration{Duration: time.Hour}
+	cr.Spec.WebhookCertRotation.OverlapPeriod = &metav1.Duration{Duration: 2 * time.Hour}
+	if errs := cr.ValidateCertRotationPeriods(fldPath); len(errs) != 1 || errs[0].Field != "spec.webhookCertRotation.overlapPeriod" {
+		t.Errorf("overlapPeriod not shorter than rotateBefore must be rejected: %v", errs)
+	}
+}
+
+func TestValidateKeySource(t *testing.T) {
+	keyPath := field.NewPath("spec").Child("keyConfig").Index(0)
+	valid := []KeyConfig{
+		{Name: "secret", SecretName: "keyring-secret"},
+		{Name: "configmap", ConfigMapName: "keyring-cm", FileNames: []string{"team-a.gpg", "team-b.gpg"}},
+		{Name: "jwks", JWKSURL: "https://example.com/.well-known/jwks.json", SignatureType: "x509"},
+	}
+	for _, keyConfig := range valid {
+		if errs := validateKeySource(keyConfig, keyPath); len(errs) != 0 {
+			t.Errorf("%s: unexpected errors: %v", keyConfig.Name, errs)
+		}
+	}
+
+	invalid := []KeyConfig{
+		{Name: "no-source"},
+		{Name: "two-sources", SecretName: "keyring-secret", ConfigMapName: "keyring-cm"},
+		{Name: "bad-file", SecretName: "keyring-secret", FileNames: []string{"../pubring.gpg"}},
+		{Name: "jwks-pgp", JWKSURL: "https://example.com/jwks.json"},
+		{Name: "jwks-not-url", JWKSURL: "example.com/jwks.json", SignatureType: "x509"},
+		{Name: "jwks-http", JWKSURL: "http://example.com/jwks.json", SignatureType: "x509"},
+		{Name: "jwks-bad-ca", JWKSURL: "https://example.com/jwks.json", SignatureType: "x509", JWKSCABundle: "not a cert"},
+		{Name: "ca-without-jwks", SecretName: "keyring-secret", JWKSCABundle: "not a cert"},
+	}
+	for _, keyConfig := range invalid {
+		if errs := validateKeySource(keyConfig, keyPath); len(errs) != 1 {
+			t.Errorf("%s: expected 1 error, but got %v", keyConfig.Name, errs)
+		}
+	}
+}
+
+func TestValidateKeyRotations(t *testing.T) {
+	cr := &IntegrityShield{}
+	cr.Spec.SignerConfig = &common.SignerConfig{
+		Signers: []common.SignerCondition{{Name: "SampleSigner", KeyConfig: "old-key"}},
+	}
+	keyConfigNames := []string{"old-key", "new-key"}
+	fldPath := field.NewPath("spec").Child("keyRotations")
+
+	cr.Spec.KeyRotations = []KeyRotation{{Signer: "SampleSigner", NewKeyConfig: "new-key", OverlapPeriod: &metav1.Duration{Duration: time.Hour}}}
+	if errs := cr.validateKeyRotations(keyConfigNames, fldPath); len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+
+	cr.Spec.KeyRotations = []KeyRotation{
+		{Signer: "UnknownSigner", NewKeyConfig: "new-key"},
+		{Signer: "SampleSigner", NewKeyConfig: "unknown-key", OverlapPeriod: &metav1.Duration{Duration: -time.Hour}},
+		{Signer: "SampleSigner", NewKeyConfig: "new-key"},
+	}
+	errs := cr.validateKeyRotations(keyConfigNames, fldPath)
+	expected := []string{"spec.keyRotations[0].signer", "spec.keyRotations[1].newKeyConfig", "spec.keyRotations[1].overlapPeriod", "spec.keyRotations[2].signer"}
+	if len(errs) != len(expected) {
+		t.Errorf("expected %d errors, but got %v", len(expected), errs)
+		return
+	}
+	for i, err := range errs {
+		if err.Field != expected[i] {
+			t.Errorf("expected an error for %s, but got %v", expected[i], err)
+		}
+	}
+}
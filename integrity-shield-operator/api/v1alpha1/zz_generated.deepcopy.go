@@ -23,12 +23,92 @@ package v1alpha1
 import (
 	resourcesigningprofilev1alpha1 "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
 	"github.com/IBM/integrity-enforcer/shield/pkg/common"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	"k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoscalingConfig) DeepCopyInto(out *AutoscalingConfig) {
+	*out = *in
+	if in.MinReplicas != nil {
+		in, out := &in.MinReplicas, &out.MinReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TargetCPUUtilizationPercentage != nil {
+		in, out := &in.TargetCPUUtilizationPercentage, &out.TargetCPUUtilizationPercentage
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalingConfig.
+func (in *AutoscalingConfig) DeepCopy() *AutoscalingConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoscalingConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertRotationConfig) DeepCopyInto(out *CertRotationConfig) {
+	*out = *in
+	if in.Validity != nil {
+		in, out := &in.Validity, &out.Validity
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.RotateBefore != nil {
+		in, out := &in.RotateBefore, &out.RotateBefore
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.OverlapPeriod != nil {
+		in, out := &in.OverlapPeriod, &out.OverlapPeriod
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertRotationConfig.
+func (in *CertRotationConfig) DeepCopy() *CertRotationConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(CertRotationConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertStatus) DeepCopyInto(out *CertStatus) {
+	*out = *in
+	if in.NotAfter != nil {
+		in, out := &in.NotAfter, &out.NotAfter
+		*out = (*in).DeepCopy()
+	}
+	if in.LastRotationTime != nil {
+		in, out := &in.LastRotationTime, &out.LastRotationTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertStatus.
+func (in *CertStatus) DeepCopy() *CertStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CertStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CertPoolConfig) DeepCopyInto(out *CertPoolConfig) {
 	*out = *in
@@ -79,13 +159,37 @@ func (in *HttpConfig) DeepCopy() *HttpConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HighAvailabilityConfig) DeepCopyInto(out *HighAvailabilityConfig) {
+	*out = *in
+	in.PodDisruptionBudget.DeepCopyInto(&out.PodDisruptionBudget)
+	if in.TopologySpreadConstraints != nil {
+		in, out := &in.TopologySpreadConstraints, &out.TopologySpreadConstraints
+		*out = make([]v1.TopologySpreadConstraint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Autoscaling.DeepCopyInto(&out.Autoscaling)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HighAvailabilityConfig.
+func (in *HighAvailabilityConfig) DeepCopy() *HighAvailabilityConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(HighAvailabilityConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IntegrityShield) DeepCopyInto(out *IntegrityShield) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IntegrityShield.
@@ -106,6 +210,22 @@ func (in *IntegrityShield) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IntegrityShieldCondition) DeepCopyInto(out *IntegrityShieldCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IntegrityShieldCondition.
+func (in *IntegrityShieldCondition) DeepCopy() *IntegrityShieldCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(IntegrityShieldCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IntegrityShieldList) DeepCopyInto(out *IntegrityShieldList) {
 	*out = *in
@@ -198,7 +318,16 @@ func (in *IntegrityShieldSpec) DeepCopyInto(out *IntegrityShieldSpec) {
 	if in.KeyConfig != nil {
 		in, out := &in.KeyConfig, &out.KeyConfig
 		*out = make([]KeyConfig, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.KeyRotations != nil {
+		in, out := &in.KeyRotations, &out.KeyRotations
+		*out = make([]KeyRotation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	in.Server.DeepCopyInto(&out.Server)
 	in.Logger.DeepCopyInto(&out.Logger)
@@ -239,6 +368,39 @@ func (in *IntegrityShieldSpec) DeepCopyInto(out *IntegrityShieldSpec) {
 	}
 	in.WebhookNamespacedResource.DeepCopyInto(&out.WebhookNamespacedResource)
 	in.WebhookClusterResource.DeepCopyInto(&out.WebhookClusterResource)
+	if in.WebhookFailurePolicy != nil {
+		in, out := &in.WebhookFailurePolicy, &out.WebhookFailurePolicy
+		*out = new(admissionregistrationv1.FailurePolicyType)
+		**out = **in
+	}
+	if in.WebhookTimeoutSeconds != nil {
+		in, out := &in.WebhookTimeoutSeconds, &out.WebhookTimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.WebhookSideEffects != nil {
+		in, out := &in.WebhookSideEffects, &out.WebhookSideEffects
+		*out = new(admissionregistrationv1.SideEffectClass)
+		**out = **in
+	}
+	if in.WebhookMatchPolicy != nil {
+		in, out := &in.WebhookMatchPolicy, &out.WebhookMatchPolicy
+		*out = new(admissionregistrationv1.MatchPolicyType)
+		**out = **in
+	}
+	if in.WebhookNamespaceSelector != nil {
+		in, out := &in.WebhookNamespaceSelector, &out.WebhookNamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WebhookObjectSelector != nil {
+		in, out := &in.WebhookObjectSelector, &out.WebhookObjectSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	in.WebhookCertRotation.DeepCopyInto(&out.WebhookCertRotation)
+	in.HighAvailability.DeepCopyInto(&out.HighAvailability)
+	out.Uninstall = in.Uninstall
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IntegrityShieldSpec.
@@ -254,6 +416,37 @@ func (in *IntegrityShieldSpec) DeepCopy() *IntegrityShieldSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IntegrityShieldStatus) DeepCopyInto(out *IntegrityShieldStatus) {
 	*out = *in
+	if in.Keys != nil {
+		in, out := &in.Keys, &out.Keys
+		*out = make([]KeyStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.KeyRotations != nil {
+		in, out := &in.KeyRotations, &out.KeyRotations
+		*out = make([]KeyRotationStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Cert != nil {
+		in, out := &in.Cert, &out.Cert
+		*out = new(CertStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]IntegrityShieldCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Uninstall != nil {
+		in, out := &in.Uninstall, &out.Uninstall
+		*out = new(UninstallReport)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IntegrityShieldStatus.
@@ -269,6 +462,11 @@ func (in *IntegrityShieldStatus) DeepCopy() *IntegrityShieldStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KeyConfig) DeepCopyInto(out *KeyConfig) {
 	*out = *in
+	if in.FileNames != nil {
+		in, out := &in.FileNames, &out.FileNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeyConfig.
@@ -281,6 +479,74 @@ func (in *KeyConfig) DeepCopy() *KeyConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeyRotation) DeepCopyInto(out *KeyRotation) {
+	*out = *in
+	if in.OverlapPeriod != nil {
+		in, out := &in.OverlapPeriod, &out.OverlapPeriod
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeyRotation.
+func (in *KeyRotation) DeepCopy() *KeyRotation {
+	if in == nil {
+		return nil
+	}
+	out := new(KeyRotation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeyRotationStatus) DeepCopyInto(out *KeyRotationStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.RetireTime != nil {
+		in, out := &in.RetireTime, &out.RetireTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ResourcesSignedByOldKey != nil {
+		in, out := &in.ResourcesSignedByOldKey, &out.ResourcesSignedByOldKey
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeyRotationStatus.
+func (in *KeyRotationStatus) DeepCopy() *KeyRotationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KeyRotationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeyStatus) DeepCopyInto(out *KeyStatus) {
+	*out = *in
+	if in.Fingerprints != nil {
+		in, out := &in.Fingerprints, &out.Fingerprints
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeyStatus.
+func (in *KeyStatus) DeepCopy() *KeyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KeyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LoggerContainer) DeepCopyInto(out *LoggerContainer) {
 	*out = *in
@@ -331,6 +597,7 @@ func (in *ObserverContainer) DeepCopyInto(out *ObserverContainer) {
 		(*in).DeepCopyInto(*out)
 	}
 	in.Resources.DeepCopyInto(&out.Resources)
+	out.EventStore = in.EventStore
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObserverContainer.
@@ -343,6 +610,51 @@ func (in *ObserverContainer) DeepCopy() *ObserverContainer {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObserverEventStore) DeepCopyInto(out *ObserverEventStore) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObserverEventStore.
+func (in *ObserverEventStore) DeepCopy() *ObserverEventStore {
+	if in == nil {
+		return nil
+	}
+	out := new(ObserverEventStore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodDisruptionBudgetConfig) DeepCopyInto(out *PodDisruptionBudgetConfig) {
+	*out = *in
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MinAvailable != nil {
+		in, out := &in.MinAvailable, &out.MinAvailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodDisruptionBudgetConfig.
+func (in *PodDisruptionBudgetConfig) DeepCopy() *PodDisruptionBudgetConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PodDisruptionBudgetConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ProfileConfig) DeepCopyInto(out *ProfileConfig) {
 	*out = *in
@@ -417,6 +729,11 @@ func (in *ServerContainer) DeepCopyInto(out *ServerContainer) {
 		(*in).DeepCopyInto(*out)
 	}
 	in.Resources.DeepCopyInto(&out.Resources)
+	if in.AuditSinkSecrets != nil {
+		in, out := &in.AuditSinkSecrets, &out.AuditSinkSecrets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerContainer.
@@ -428,3 +745,43 @@ func (in *ServerContainer) DeepCopy() *ServerContainer {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UninstallConfig) DeepCopyInto(out *UninstallConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UninstallConfig.
+func (in *UninstallConfig) DeepCopy() *UninstallConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(UninstallConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UninstallReport) DeepCopyInto(out *UninstallReport) {
+	*out = *in
+	if in.Remove != nil {
+		in, out := &in.Remove, &out.Remove
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Retain != nil {
+		in, out := &in.Retain, &out.Retain
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UninstallReport.
+func (in *UninstallReport) DeepCopy() *UninstallReport {
+	if in == nil {
+		return nil
+	}
+	out := new(UninstallReport)
+	in.DeepCopyInto(out)
+	return out
+}
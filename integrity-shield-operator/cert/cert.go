@@ -27,7 +27,17 @@ import (
 	"time"
 )
 
+var serialNumberLimit = new(big.Int).Lsh(big.NewInt(1), 128)
+
+const defaultValidity = 2 * 365 * 24 * time.Hour
+
 func GenerateCert(svcName, NS string) ([]byte, []byte, []byte, error) {
+	return GenerateCertWithValidity(svcName, NS, defaultValidity)
+}
+
+// GenerateCertWithValidity generates a new CA and a server certificate signed by it, both valid for the duration
+func GenerateCertWithValidity(svcName, NS string, validity time.Duration) ([]byte, []byte, []byte, error) {
+	now := time.Now()
 	// create CA private key
 	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
@@ -35,8 +45,13 @@ func GenerateCert(svcName, NS string) ([]byte, []byte, []byte, error) {
 	}
 	// create CA
 	cn := svcName + "_ca"
+	// serial numbers must differ between CAs generated for rotation, because both of them are trusted during the overlap
+	caSerial, err := rand.Int(rand.Reader, serialNumberLimit)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 	ca := &x509.Certificate{
-		SerialNumber: big.NewInt(2020),
+		SerialNumber: caSerial,
 		Subject: pkix.Name{
 			CommonName: cn,
 		},
@@ -44,8 +59,8 @@ func GenerateCert(svcName, NS string) ([]byte, []byte, []byte, error) {
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
 		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
 		BasicConstraintsValid: true,
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(2, 0, 0),
+		NotBefore:             now,
+		NotAfter:              now.Add(validity),
 	}
 
 	caBytes, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
@@ -86,13 +101,17 @@ func GenerateCert(svcName, NS string) ([]byte, []byte, []byte, error) {
 	}
 	cn = svcName + "." + NS + ".svc"
 
+	certSerial, err := rand.Int(rand.Reader, serialNumberLimit)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 	cert := &x509.Certificate{
-		SerialNumber: big.NewInt(2020),
+		SerialNumber: certSerial,
 		Subject: pkix.Name{
 			CommonName: cn,
 		},
-		NotBefore:   time.Now(),
-		NotAfter:    time.Now().AddDate(2, 0, 0),
+		NotBefore:   now,
+		NotAfter:    now.Add(validity),
 		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
 		KeyUsage:    x509.KeyUsageDigitalSignature,
 		DNSNames:    []string{cn},
@@ -114,3 +133,38 @@ func GenerateCert(svcName, NS string) ([]byte, []byte, []byte, error) {
 	//ca.crt, tls.key, tls.crt, error
 	return caPEM.Bytes(), tlsPrivKeyPEM.Bytes(), certPEM.Bytes(), err
 }
+
+// ParseCertificates returns all certificates in the PEM bytes (e.g. a CA bundle)
+func ParseCertificates(pemBytes []byte) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	for {
+		var block *pem.Block
+		block, pemBytes = pem.Decode(pemBytes)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// FirstCertificatePEM returns the first certificate in the PEM bytes, which is the newest CA in a bundle made by rotation
+func FirstCertificatePEM(pemBytes []byte) []byte {
+	for {
+		var block *pem.Block
+		block, pemBytes = pem.Decode(pemBytes)
+		if block == nil {
+			return nil
+		}
+		if block.Type == "CERTIFICATE" {
+			return pem.EncodeToMemory(block)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cert
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const maxJWKSSize = 1 << 20
+
+const jwksTimeout = 10 * time.Second
+
+// JSONWebKey is a key in a JWKS document; only the fields used for x509 verification are decoded
+type JSONWebKey struct {
+	Kid string   `json:"kid,omitempty"`
+	Kty string   `json:"kty,omitempty"`
+	Use string   `json:"use,omitempty"`
+	X5c []string `json:"x5c,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKSCertificates is the result of loading a JWKS document.
+// Keys without x5c certificates cannot be used for x509 verification, so their kids are listed in Skipped.
+type JWKSCertificates struct {
+	Certificates []*x509.Certificate
+	Skipped      []string
+}
+
+// PEM returns the certificates as a PEM bundle
+func (self *JWKSCertificates) PEM() []byte {
+	buf := new(bytes.Buffer)
+	for _, c := range self.Certificates {
+		_ = pem.Encode(buf, &pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})
+	}
+	return buf.Bytes()
+}
+
+// FetchJWKS gets a JWKS document from the https URL and returns x5c certificates in it.
+// The server is verified with caBundle (PEM) if specified, or with the system roots otherwise.
+func FetchJWKS(jwksURL string, caBundle []byte) (*JWKSCertificates, error) {
+	if err := checkHTTPSURL(jwksURL); err != nil {
+		return nil, err
+	}
+	client, err := newJWKSClient(caBundle)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Get(jwksURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get JWKS; %s", err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get JWKS; status code %d", resp.StatusCode)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS; %s", err.Error())
+	}
+	return ParseJWKS(data)
+}
+
+func checkHTTPSURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid JWKS URL; %s", err.Error())
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("JWKS URL must be an https URL, but \"%s\" is specified", rawURL)
+	}
+	return nil
+}
+
+// newJWKSClient returns a client which does not follow redirects to non-https URLs
+func newJWKSClient(caBundle []byte) (*http.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(caBundle) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("no certificate is found in the CA bundle for JWKS")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{
+		Timeout:   jwksTimeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return checkHTTPSURL(req.URL.String())
+		},
+	}, nil
+}
+
+// ParseJWKS returns x5c certificates in the JWKS document; the first certificate of each chain is used
+func ParseJWKS(data []byte) (*JWKSCertificates, error) {
+	var jwks JSONWebKeySet
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS; %s", err.Error())
+	}
+	result := &JWKSCertificates{}
+	for i, key := range jwks.Keys {
+		kid := key.Kid
+		if kid == "" {
+			kid = fmt.Sprintf("keys[%d]", i)
+		}
+		if key.Use != "" && key.Use != "sig" {
+			result.Skipped = append(result.Skipped, kid)
+			continue
+		}
+		if len(key.X5c) == 0 {
+			result.Skipped = append(result.Skipped, kid)
+			continue
+		}
+		// x5c is base64 (not base64url) encoded DER
+		der, err := base64.StdEncoding.DecodeString(key.X5c[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode x5c of %s; %s", kid, err.Error())
+		}
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse x5c of %s; %s", kid, err.Error())
+		}
+		result.Certificates = append(result.Certificates, c)
+	}
+	if len(result.Certificates) == 0 {
+		return nil, fmt.Errorf("JWKS contains no key with x5c certificate")
+	}
+	return result, nil
+}
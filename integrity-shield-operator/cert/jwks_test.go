@@ -0,0 +1,77 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cert
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchJWKS(t *testing.T) {
+	caCertPEMBytes, _, _, err := GenerateCert("test-service", "test-ns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCerts, err := ParseCertificates(caCertPEMBytes)
+	if err != nil || len(caCerts) != 1 {
+		t.Fatalf("failed to parse CA cert; %v", err)
+	}
+	x5c := base64.StdEncoding.EncodeToString(caCerts[0].Raw)
+	jwks := fmt.Sprintf(`{"keys":[{"kid":"signer","kty":"RSA","use":"sig","x5c":["%s"]},{"kid":"no-cert","kty":"RSA","n":"AQAB","e":"AQAB"}]}`, x5c)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/jwks.json" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(jwks))
+	}))
+	defer server.Close()
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	result, err := FetchJWKS(server.URL+"/jwks.json", caBundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Certificates) != 1 || !result.Certificates[0].Equal(caCerts[0]) {
+		t.Errorf("x5c certificate is not loaded")
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0] != "no-cert" {
+		t.Errorf("key without x5c must be skipped: %v", result.Skipped)
+	}
+	pemCerts, err := ParseCertificates(result.PEM())
+	if err != nil || len(pemCerts) != 1 {
+		t.Errorf("failed to encode certificates to PEM; %v", err)
+	}
+
+	if _, err = FetchJWKS(server.URL+"/not-found", caBundle); err == nil {
+		t.Errorf("error status must be reported")
+	}
+	if _, err = FetchJWKS(server.URL+"/jwks.json", nil); err == nil {
+		t.Errorf("server which is not trusted by the system roots must be rejected")
+	}
+
+	httpServer := httptest.NewServer(server.Config.Handler)
+	defer httpServer.Close()
+	if _, err = FetchJWKS(httpServer.URL+"/jwks.json", caBundle); err == nil {
+		t.Errorf("http URL must be rejected")
+	}
+}
@@ -0,0 +1,247 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	apiv1alpha1 "github.com/IBM/integrity-enforcer/integrity-shield-operator/api/v1alpha1"
+	cert "github.com/IBM/integrity-enforcer/integrity-shield-operator/cert"
+	res "github.com/IBM/integrity-enforcer/integrity-shield-operator/resources"
+	admregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+/**********************************************
+
+				Cert Rotation
+
+***********************************************/
+
+const (
+	certRotationPhaseAnnotationKey     = "integrityshield.io/certRotationPhase"
+	certRotationPhaseTimeAnnotationKey = "integrityshield.io/certRotationPhaseTime"
+	lastCertRotationAnnotationKey      = "integrityshield.io/lastCertRotation"
+
+	// the new key pair is staged in the TLS secret until the webhook trusts the new CA
+	nextTLSCertKey = "next.crt"
+	nextTLSKeyKey  = "next.key"
+	caCertKey      = "ca.crt"
+
+	// the webhook trusts both the old and new CAs, and the server still serves the old cert
+	CertRotationPhaseTrustingNewCA = "TrustingNewCA"
+	// the server serves the new cert, and the webhook still trusts the old CA too
+	CertRotationPhaseServingNewCert = "ServingNewCert"
+
+	maxCertCheckInterval = 12 * time.Hour
+)
+
+var certRotationPath = field.NewPath("spec").Child("webhookCertRotation")
+
+// rotateCertSecret advances the rotation of the webhook server cert in the TLS secret by one phase if it is due,
+// and returns true if the secret is changed.
+//   (idle) -> TrustingNewCA: a new CA and cert are generated; ca.crt contains both the new and old CAs
+//   TrustingNewCA -> ServingNewCert: after the overlap period and once the webhook trusts both CAs, the new cert is served
+//   ServingNewCert -> (idle): after the overlap period, the old CA is removed from ca.crt
+// If the current cert is already expired or broken, a new CA and cert are set at once because there is nothing to keep.
+func rotateCertSecret(instance *apiv1alpha1.IntegrityShield, secret *corev1.Secret, now time.Time, caBundleApplied bool) (bool, error) {
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	overlap := instance.GetCertOverlapPeriod()
+	phase := secret.Annotations[certRotationPhaseAnnotationKey]
+	phaseTime, _ := time.Parse(time.RFC3339, secret.Annotations[certRotationPhaseTimeAnnotationKey])
+
+	switch phase {
+	case CertRotationPhaseTrustingNewCA:
+		if now.Sub(phaseTime) < overlap || !caBundleApplied {
+			return false, nil
+		}
+		secret.Data[corev1.TLSCertKey] = secret.Data[nextTLSCertKey]
+		secret.Data[corev1.TLSPrivateKeyKey] = secret.Data[nextTLSKeyKey]
+		delete(secret.Data, nextTLSCertKey)
+		delete(secret.Data, nextTLSKeyKey)
+		setCertRotationPhase(secret, CertRotationPhaseServingNewCert, now)
+		return true, nil
+	case CertRotationPhaseServingNewCert:
+		if now.Sub(phaseTime) < overlap {
+			return false, nil
+		}
+		secret.Data[caCertKey] = cert.FirstCertificatePEM(secret.Data[caCertKey])
+		setCertRotationPhase(secret, "", now)
+		secret.Annotations[lastCertRotationAnnotationKey] = now.UTC().Format(time.RFC3339)
+		return true, nil
+	}
+
+	notAfter, err := getCertNotAfter(secret)
+	if err == nil && now.Before(notAfter) && notAfter.Sub(now) > instance.GetCertRotateBefore() {
+		return false, nil
+	}
+
+	ca, tlsKey, tlsCert, genErr := cert.GenerateCertWithValidity(instance.GetWebhookServiceName(), instance.Namespace, instance.GetCertValidity())
+	if genErr != nil {
+		return false, genErr
+	}
+	if err != nil || !now.Before(notAfter) {
+		// no valid cert to keep serving; replace everything immediately
+		secret.Data[corev1.TLSCertKey] = tlsCert
+		secret.Data[corev1.TLSPrivateKeyKey] = tlsKey
+		secret.Data[caCertKey] = ca
+		setCertRotationPhase(secret, "", now)
+		secret.Annotations[lastCertRotationAnnotationKey] = now.UTC().Format(time.RFC3339)
+		return true, nil
+	}
+	// the new CA comes first so that it is kept when the old one is removed
+	secret.Data[caCertKey] = append(append([]byte{}, ca...), secret.Data[caCertKey]...)
+	secret.Data[nextTLSCertKey] = tlsCert
+	secret.Data[nextTLSKeyKey] = tlsKey
+	setCertRotationPhase(secret, CertRotationPhaseTrustingNewCA, now)
+	return true, nil
+}
+
+func setCertRotationPhase(secret *corev1.Secret, phase string, now time.Time) {
+	if phase == "" {
+		delete(secret.Annotations, certRotationPhaseAnnotationKey)
+		delete(secret.Annotations, certRotationPhaseTimeAnnotationKey)
+		return
+	}
+	secret.Annotations[certRotationPhaseAnnotationKey] = phase
+	secret.Annotations[certRotationPhaseTimeAnnotationKey] = now.UTC().Format(time.RFC3339)
+}
+
+func getCertNotAfter(secret *corev1.Secret) (time.Time, error) {
+	certs, err := cert.ParseCertificates(secret.Data[corev1.TLSCertKey])
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(certs) == 0 {
+		return time.Time{}, fmt.Errorf("no certificate is found")
+	}
+	return certs[0].NotAfter, nil
+}
+
+// getCertCheckInterval returns the duration until the next rotation phase is due
+func getCertCheckInterval(instance *apiv1alpha1.IntegrityShield, secret *corev1.Secret, now time.Time) time.Duration {
+	interval := maxCertCheckInterval
+	if phase := secret.Annotations[certRotationPhaseAnnotationKey]; phase != "" {
+		phaseTime, _ := time.Parse(time.RFC3339, secret.Annotations[certRotationPhaseTimeAnnotationKey])
+		interval = phaseTime.Add(instance.GetCertOverlapPeriod()).Sub(now)
+	} else if notAfter, err := getCertNotAfter(secret); err == nil {
+		interval = notAfter.Add(-instance.GetCertRotateBefore()).Sub(now)
+	}
+	if interval > maxCertCheckInterval {
+		interval = maxCertCheckInterval
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+	return interval
+}
+
+func getCertStatus(secret *corev1.Secret) *apiv1alpha1.CertStatus {
+	status := &apiv1alpha1.CertStatus{
+		RotationPhase: secret.Annotations[certRotationPhaseAnnotationKey],
+	}
+	if notAfter, err := getCertNotAfter(secret); err == nil {
+		t := metav1.NewTime(notAfter)
+		status.NotAfter = &t
+	}
+	if lastRotation, err := time.Parse(time.RFC3339, secret.Annotations[lastCertRotationAnnotationKey]); err == nil {
+		t := metav1.NewTime(lastRotation)
+		status.LastRotationTime = &t
+	}
+	return status
+}
+
+// isCABundleApplied returns true if the webhooks trust the CA bundle, or if the webhooks do not exist yet
+func (r *IntegrityShieldReconciler) isCABundleApplied(instance *apiv1alpha1.IntegrityShield, caBundle []byte) bool {
+	found := &admregv1.MutatingWebhookConfiguration{}
+	err := r.Get(context.Background(), types.NamespacedName{Name: res.BuildMutatingWebhookConfigurationForIShield(instance).Name}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return false
+	}
+	for _, webhook := range found.Webhooks {
+		if !bytes.Equal(webhook.ClientConfig.CABundle, caBundle) {
+			return false
+		}
+	}
+	foundValidating := &admregv1.ValidatingWebhookConfiguration{}
+	err = r.Get(context.Background(), types.NamespacedName{Name: instance.GetValidatingWebhookConfigName()}, foundValidating)
+	if err != nil {
+		return errors.IsNotFound(err)
+	}
+	for _, webhook := range foundValidating.Webhooks {
+		if !bytes.Equal(webhook.ClientConfig.CABundle, caBundle) {
+			return false
+		}
+	}
+	return true
+}
+
+// rotateTlsSecret updates the TLS secret if a rotation phase is due, and returns true if it is updated
+func (r *IntegrityShieldReconciler) rotateTlsSecret(instance *apiv1alpha1.IntegrityShield, found *corev1.Secret) (bool, error) {
+	if instance.Spec.WebhookCertRotation.Disabled {
+		return false, nil
+	}
+	reqLogger := r.Log.WithValues(
+		"Secret.Namespace", instance.Namespace,
+		"Instance.Name", instance.Name,
+		"Secret.Name", found.Name)
+
+	// the CR may not have been validated by the webhook; the current cert is kept until the periods are fixed
+	if errs := instance.ValidateCertRotationPeriods(certRotationPath); len(errs) > 0 {
+		reqLogger.Error(errs.ToAggregate(), "Skip webhook server certificate rotation")
+		return false, nil
+	}
+
+	caBundleApplied := r.isCABundleApplied(instance, found.Data[caCertKey])
+	rotated, err := rotateCertSecret(instance, found, time.Now(), caBundleApplied)
+	if err != nil {
+		reqLogger.Error(err, "Failed to rotate webhook server certificate")
+		return false, err
+	}
+	if !rotated {
+		return false, nil
+	}
+	if err = r.Update(context.Background(), found); err != nil {
+		reqLogger.Error(err, "Failed to update TLS secret for certificate rotation")
+		return false, err
+	}
+	phase := found.Annotations[certRotationPhaseAnnotationKey]
+	if phase == "" {
+		phase = "Completed"
+	}
+	reqLogger.Info("Webhook server certificate rotation has progressed", "Phase", phase)
+	return true, nil
+}
+
+// requeueAfterForCert returns when the next reconcile should check the certificate
+func (r *IntegrityShieldReconciler) requeueAfterForCert(instance *apiv1alpha1.IntegrityShield) time.Duration {
+	secret := &corev1.Secret{}
+	err := r.Get(context.Background(), types.NamespacedName{Name: instance.GetWebhookServerTlsSecretName(), Namespace: instance.Namespace}, secret)
+	if err != nil {
+		return time.Minute
+	}
+	return getCertCheckInterval(instance, secret, time.Now())
+}
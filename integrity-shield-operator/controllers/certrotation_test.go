@@ -0,0 +1,120 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package controllers
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	apiv1alpha1 "github.com/IBM/integrity-enforcer/integrity-shield-operator/api/v1alpha1"
+	cert "github.com/IBM/integrity-enforcer/integrity-shield-operator/cert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRotateCertSecret(t *testing.T) {
+	instance := &apiv1alpha1.IntegrityShield{}
+	instance.Namespace = "test-ns"
+	instance.Spec.WebhookCertRotation.Validity = &metav1.Duration{Duration: 48 * time.Hour}
+	instance.Spec.WebhookCertRotation.RotateBefore = &metav1.Duration{Duration: 24 * time.Hour}
+
+	ca, tlsKey, tlsCert, err := cert.GenerateCertWithValidity("test-svc", "test-ns", 48*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := &corev1.Secret{Data: map[string][]byte{"ca.crt": ca, "tls.key": tlsKey, "tls.crt": tlsCert}}
+
+	now := time.Now()
+	if rotated, err := rotateCertSecret(instance, secret, now, true); err != nil || rotated {
+		t.Fatalf("rotation must not start while the cert is valid long enough; %v", err)
+	}
+
+	// rotation starts within rotateBefore; the new CA is trusted but the old cert is still served
+	now = now.Add(30 * time.Hour)
+	if rotated, err := rotateCertSecret(instance, secret, now, true); err != nil || !rotated {
+		t.Fatalf("rotation must start; %v", err)
+	}
+	if secret.Annotations[certRotationPhaseAnnotationKey] != CertRotationPhaseTrustingNewCA {
+		t.Fatalf("unexpected phase: %v", secret.Annotations)
+	}
+	if cas, _ := cert.ParseCertificates(secret.Data["ca.crt"]); len(cas) != 2 {
+		t.Errorf("both the new and old CAs must be trusted, but got %d", len(cas))
+	}
+	if !bytes.Equal(secret.Data["tls.crt"], tlsCert) {
+		t.Errorf("old cert must be served until the new CA is trusted")
+	}
+
+	// the new cert is not served until the overlap period passes and the webhook has the new CA bundle
+	if rotated, _ := rotateCertSecret(instance, secret, now.Add(time.Minute), true); rotated {
+		t.Errorf("new cert must not be served within the overlap period")
+	}
+	now = now.Add(instance.GetCertOverlapPeriod())
+	if rotated, _ := rotateCertSecret(instance, secret, now, false); rotated {
+		t.Errorf("new cert must not be served until the webhook trusts the new CA")
+	}
+	if rotated, err := rotateCertSecret(instance, secret, now, true); err != nil || !rotated {
+		t.Fatalf("new cert must be served; %v", err)
+	}
+	if secret.Annotations[certRotationPhaseAnnotationKey] != CertRotationPhaseServingNewCert || bytes.Equal(secret.Data["tls.crt"], tlsCert) {
+		t.Fatalf("new cert is not served: %v", secret.Annotations)
+	}
+	if _, ok := secret.Data[nextTLSCertKey]; ok {
+		t.Errorf("staged cert must be removed")
+	}
+
+	// the old CA is removed after the overlap period
+	now = now.Add(instance.GetCertOverlapPeriod())
+	if rotated, err := rotateCertSecret(instance, secret, now, true); err != nil || !rotated {
+		t.Fatalf("rotation must be completed; %v", err)
+	}
+	if _, ok := secret.Annotations[certRotationPhaseAnnotationKey]; ok || secret.Annotations[lastCertRotationAnnotationKey] == "" {
+		t.Errorf("unexpected annotations after rotation: %v", secret.Annotations)
+	}
+	if cas, _ := cert.ParseCertificates(secret.Data["ca.crt"]); len(cas) != 1 || bytes.Equal(secret.Data["ca.crt"], ca) {
+		t.Errorf("only the new CA must be trusted")
+	}
+	if cond := checkCertSecret(secret, now); cond.Status != corev1.ConditionTrue {
+		t.Errorf("rotated cert is not valid: %s", cond.Message)
+	}
+	if status := getCertStatus(secret); status.NotAfter == nil || status.LastRotationTime == nil || status.RotationPhase != "" {
+		t.Errorf("unexpected cert status: %v", status)
+	}
+}
+
+func TestRotateExpiredCertSecret(t *testing.T) {
+	instance := &apiv1alpha1.IntegrityShield{}
+	ca, tlsKey, tlsCert, err := cert.GenerateCertWithValidity("test-svc", "test-ns", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := &corev1.Secret{Data: map[string][]byte{"ca.crt": ca, "tls.key": tlsKey, "tls.crt": tlsCert}}
+
+	now := time.Now().Add(2 * time.Hour)
+	if rotated, err := rotateCertSecret(instance, secret, now, false); err != nil || !rotated {
+		t.Fatalf("expired cert must be replaced; %v", err)
+	}
+	if _, ok := secret.Annotations[certRotationPhaseAnnotationKey]; ok {
+		t.Errorf("expired cert must be replaced without overlap")
+	}
+	if cond := checkCertSecret(secret, now); cond.Status != corev1.ConditionTrue {
+		t.Errorf("replaced cert is not valid: %s", cond.Message)
+	}
+	if interval := getCertCheckInterval(instance, secret, now); interval != maxCertCheckInterval {
+		t.Errorf("unexpected check interval: %s", interval)
+	}
+}
@@ -0,0 +1,230 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	apiv1alpha1 "github.com/IBM/integrity-enforcer/integrity-shield-operator/api/v1alpha1"
+	res "github.com/IBM/integrity-enforcer/integrity-shield-operator/resources"
+	rsp "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+/**********************************************
+
+				Multiple Instances
+
+***********************************************/
+
+// instanceConflicts is the result of checking all instances in the cluster.
+// Instances are checked in the order of creation, and an instance which conflicts with an earlier active instance is not reconciled.
+type instanceConflicts struct {
+	active    []apiv1alpha1.IntegrityShield
+	conflicts map[string][]string
+	// target namespaces of active instances
+	targets map[string][]string
+}
+
+func instanceKey(instance *apiv1alpha1.IntegrityShield) string {
+	return fmt.Sprintf("%s/%s", instance.Namespace, instance.Name)
+}
+
+// getTargetNamespaces returns namespaces where the instance verifies requests, in the same way as the webhook namespaceSelector.
+// RSPs in the instance namespace belong to the instance. RSPs in other namespaces are loaded by every instance,
+// so they are given only to the earliest instance, whose webhook is not restricted by other instances.
+func getTargetNamespaces(instance *apiv1alpha1.IntegrityShield, instanceNamespaces map[string]bool, earliest bool, profiles []rsp.ResourceSigningProfile, namespaces []corev1.Namespace) []string {
+	ownProfiles := []rsp.ResourceSigningProfile{}
+	for _, p := range profiles {
+		ns := p.GetNamespace()
+		if ns == instance.Namespace || (earliest && !instanceNamespaces[ns]) {
+			ownProfiles = append(ownProfiles, p)
+		}
+	}
+	return res.GetTargetNamespaces(instance, ownProfiles, namespaces)
+}
+
+// checkInstanceConflicts finds instances which cannot run together with earlier ones.
+// Names of cluster scope resources are derived from the instance name, and iShield resources such as SignerConfig are loaded
+// from the instance namespace, so both must be unique. Target namespaces must not overlap so that a request is verified by one instance.
+func checkInstanceConflicts(instances []apiv1alpha1.IntegrityShield, profiles []rsp.ResourceSigningProfile, namespaces []corev1.Namespace) *instanceConflicts {
+	sorted := make([]apiv1alpha1.IntegrityShield, len(instances))
+	copy(sorted, instances)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ti, tj := sorted[i].CreationTimestamp, sorted[j].CreationTimestamp
+		if !ti.Equal(&tj) {
+			return ti.Before(&tj)
+		}
+		return instanceKey(&sorted[i]) < instanceKey(&sorted[j])
+	})
+	instanceNamespaces := map[string]bool{}
+	for i := range sorted {
+		instanceNamespaces[sorted[i].Namespace] = true
+	}
+
+	result := &instanceConflicts{conflicts: map[string][]string{}, targets: map[string][]string{}}
+	targetsOf := map[string]string{}
+	for i := range sorted {
+		instance := &sorted[i]
+		reasons := []string{}
+		for j := range result.active {
+			other := &result.active[j]
+			if other.Name == instance.Name {
+				reasons = append(reasons, fmt.Sprintf("instance name \"%s\" is already used in namespace %s", instance.Name, other.Namespace))
+			}
+			if other.Namespace == instance.Namespace {
+				reasons = append(reasons, fmt.Sprintf("instance \"%s\" already exists in namespace %s", other.Name, instance.Namespace))
+			}
+		}
+		targets := getTargetNamespaces(instance, instanceNamespaces, len(result.active) == 0, profiles, namespaces)
+		overlaps := map[string][]string{}
+		for _, ns := range targets {
+			if owner, ok := targetsOf[ns]; ok {
+				overlaps[owner] = append(overlaps[owner], ns)
+			}
+		}
+		owners := []string{}
+		for owner := range overlaps {
+			owners = append(owners, owner)
+		}
+		sort.Strings(owners)
+		for _, owner := range owners {
+			reasons = append(reasons, fmt.Sprintf("namespaces [%s] are already targeted by instance %s", strings.Join(overlaps[owner], ", "), owner))
+		}
+
+		if len(reasons) > 0 {
+			result.conflicts[instanceKey(instance)] = reasons
+			continue
+		}
+		result.active = append(result.active, *instance)
+		result.targets[instanceKey(instance)] = targets
+		for _, ns := range targets {
+			targetsOf[ns] = instanceKey(instance)
+		}
+	}
+	return result
+}
+
+// getOtherInstanceNamespaces returns target namespaces of other active instances, which are excluded from the webhook of this instance
+func (self *instanceConflicts) getOtherInstanceNamespaces(instance *apiv1alpha1.IntegrityShield) []string {
+	others := []string{}
+	for i := range self.active {
+		other := &self.active[i]
+		if instanceKey(other) == instanceKey(instance) {
+			continue
+		}
+		others = append(others, self.targets[instanceKey(other)]...)
+	}
+	sort.Strings(others)
+	return others
+}
+
+// mergeNamespaces returns the sorted union of the namespace lists
+func mergeNamespaces(lists ...[]string) []string {
+	found := map[string]bool{}
+	merged := []string{}
+	for _, list := range lists {
+		for _, ns := range list {
+			if !found[ns] {
+				found[ns] = true
+				merged = append(merged, ns)
+			}
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+func (r *IntegrityShieldReconciler) checkAllInstances() (*instanceConflicts, error) {
+	ctx := context.Background()
+	instances := &apiv1alpha1.IntegrityShieldList{}
+	if err := r.List(ctx, instances); err != nil {
+		return nil, err
+	}
+	rspList := &rsp.ResourceSigningProfileList{}
+	if err := r.List(ctx, rspList); err != nil && !meta.IsNoMatchError(err) {
+		return nil, err
+	}
+	nsList := &corev1.NamespaceList{}
+	if err := r.List(ctx, nsList); err != nil {
+		return nil, err
+	}
+	return checkInstanceConflicts(instances.Items, rspList.Items, nsList.Items), nil
+}
+
+// getInstanceConflicts returns reasons why this instance cannot run together with earlier instances, or nil if it can
+func (r *IntegrityShieldReconciler) getInstanceConflicts(instance *apiv1alpha1.IntegrityShield) ([]string, error) {
+	result, err := r.checkAllInstances()
+	if err != nil {
+		return nil, err
+	}
+	return result.conflicts[instanceKey(instance)], nil
+}
+
+func (r *IntegrityShieldReconciler) getOtherInstanceNamespaces(instance *apiv1alpha1.IntegrityShield) ([]string, error) {
+	result, err := r.checkAllInstances()
+	if err != nil {
+		return nil, err
+	}
+	return result.getOtherInstanceNamespaces(instance), nil
+}
+
+// checkIsolation reports whether this instance conflicts with earlier instances
+func (r *IntegrityShieldReconciler) checkIsolation(instance *apiv1alpha1.IntegrityShield) apiv1alpha1.IntegrityShieldCondition {
+	condType := apiv1alpha1.ConditionIsolated
+	conflicts, err := r.getInstanceConflicts(instance)
+	if err != nil {
+		return newCondition(condType, false, "Unknown", fmt.Sprintf("failed to check other instances; %s", err.Error()))
+	}
+	if len(conflicts) > 0 {
+		return newCondition(condType, false, "Conflict", strings.Join(conflicts, "; "))
+	}
+	return newCondition(condType, true, "Isolated", "no other instance targets the same namespaces")
+}
+
+// isOnlyInstance returns true if no other instance exists, e.g. to decide whether shared CRDs can be deleted
+func (r *IntegrityShieldReconciler) isOnlyInstance(instance *apiv1alpha1.IntegrityShield) (bool, error) {
+	instances := &apiv1alpha1.IntegrityShieldList{}
+	if err := r.List(context.Background(), instances); err != nil {
+		return false, err
+	}
+	for i := range instances.Items {
+		if instances.Items[i].UID != instance.UID {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// instanceExists returns true if an instance with the UID exists
+func (r *IntegrityShieldReconciler) instanceExists(uid types.UID) (bool, error) {
+	instances := &apiv1alpha1.IntegrityShieldList{}
+	if err := r.List(context.Background(), instances); err != nil {
+		return false, err
+	}
+	for i := range instances.Items {
+		if instances.Items[i].UID == uid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
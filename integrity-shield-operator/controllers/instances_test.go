@@ -0,0 +1,124 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	apiv1alpha1 "github.com/IBM/integrity-enforcer/integrity-shield-operator/api/v1alpha1"
+	rsp "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	iec "github.com/IBM/integrity-enforcer/shield/pkg/shield/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCheckInstanceConflicts(t *testing.T) {
+	start := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	newInstance := func(name, namespace string, created time.Duration, include ...string) apiv1alpha1.IntegrityShield {
+		instance := apiv1alpha1.IntegrityShield{}
+		instance.Name = name
+		instance.Namespace = namespace
+		instance.CreationTimestamp = metav1.NewTime(start.Add(created))
+		instance.Spec.ShieldConfig = &iec.ShieldConfig{}
+		if len(include) > 0 {
+			instance.Spec.ShieldConfig.InScopeNamespaceSelector = &common.NamespaceSelector{Include: include}
+		}
+		return instance
+	}
+	namespaces := []corev1.Namespace{}
+	for _, name := range []string{"shield-a", "shield-b", "tenant-a1", "tenant-a2", "tenant-b1"} {
+		ns := corev1.Namespace{}
+		ns.Name = name
+		namespaces = append(namespaces, ns)
+	}
+
+	tenantA := newInstance("integrity-shield-server", "shield-a", 0, "tenant-a*")
+	tenantB := newInstance("shield-b", "shield-b", time.Minute, "tenant-b*")
+	result := checkInstanceConflicts([]apiv1alpha1.IntegrityShield{tenantB, tenantA}, nil, namespaces)
+	if len(result.conflicts) != 0 || len(result.active) != 2 {
+		t.Errorf("instances with separate namespaces must not conflict: %v", result.conflicts)
+	}
+	if others := result.getOtherInstanceNamespaces(&tenantA); !reflect.DeepEqual(others, []string{"shield-b", "tenant-b1"}) {
+		t.Errorf("unexpected namespaces of other instances: %v", others)
+	}
+
+	// the later instance is in conflict
+	overlapping := newInstance("shield-c", "shield-c", 2*time.Minute, "tenant-a1", "tenant-b1")
+	sameNamespace := newInstance("shield-d", "shield-a", 3*time.Minute)
+	sameName := newInstance("shield-b", "shield-e", 4*time.Minute)
+	result = checkInstanceConflicts([]apiv1alpha1.IntegrityShield{sameName, sameNamespace, overlapping, tenantB, tenantA}, nil, namespaces)
+	if len(result.active) != 2 {
+		t.Errorf("only the earlier instances must be active: %v", result.active)
+	}
+	if reasons := result.conflicts["shield-c/shield-c"]; len(reasons) != 2 {
+		t.Errorf("overlapping target namespaces must be reported for each instance: %v", reasons)
+	}
+	if reasons := result.conflicts["shield-a/shield-d"]; len(reasons) != 2 {
+		t.Errorf("the same namespace must be reported: %v", reasons)
+	}
+	if reasons := result.conflicts["shield-e/shield-b"]; len(reasons) != 1 {
+		t.Errorf("the same name must be reported: %v", reasons)
+	}
+
+	// RSPs in the instance namespace and shared RSPs extend the target namespaces in the same way as the webhook
+	newProfile := func(name, namespace string, include ...string) rsp.ResourceSigningProfile {
+		profile := rsp.ResourceSigningProfile{}
+		profile.Name = name
+		profile.Namespace = namespace
+		if len(include) > 0 {
+			profile.Spec.TargetNamespaceSelector = &common.NamespaceSelector{Include: include}
+		}
+		return profile
+	}
+	tenantC := newInstance("shield-c", "shield-c", 2*time.Minute, "tenant-c1")
+	profiles := []rsp.ResourceSigningProfile{
+		newProfile("rsp-b", "shield-b", "tenant-a2"),
+		newProfile("rsp-shared", "tenant-b1"),
+	}
+	result = checkInstanceConflicts([]apiv1alpha1.IntegrityShield{tenantC, tenantB, tenantA}, profiles, append(namespaces, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant-c1"}}))
+	if reasons := result.conflicts["shield-b/shield-b"]; len(reasons) != 1 {
+		t.Errorf("target namespaces of RSPs must be checked: %v", reasons)
+	}
+	if others := result.getOtherInstanceNamespaces(&tenantC); !reflect.DeepEqual(others, []string{"shield-a", "tenant-a1", "tenant-a2", "tenant-b1"}) {
+		t.Errorf("shared RSPs must be given to the earliest instance: %v", others)
+	}
+
+	if merged := mergeNamespaces([]string{"ns2", "ns1"}, []string{"ns1", "ns3"}); !reflect.DeepEqual(merged, []string{"ns1", "ns2", "ns3"}) {
+		t.Errorf("unexpected merged namespaces: %v", merged)
+	}
+}
+
+func TestInstanceScopedNames(t *testing.T) {
+	instance := &apiv1alpha1.IntegrityShield{}
+	instance.Name = apiv1alpha1.DefaultIntegrityShieldCRName
+	instance.Spec.WebhookConfigName = "ishield-webhook-config"
+	instance.Spec.Security.ClusterRole = "ishield-cluster-role"
+	if instance.GetWebhookConfigName() != "ishield-webhook-config" || instance.GetClusterRoleName() != "ishield-cluster-role" {
+		t.Errorf("the default instance must keep the names in the spec")
+	}
+
+	instance.Name = "tenant-a"
+	if instance.GetWebhookConfigName() != "ishield-webhook-config-tenant-a" || instance.GetValidatingWebhookConfigName() != "ishield-webhook-config-tenant-a-validator" {
+		t.Errorf("unexpected webhook names: %s, %s", instance.GetWebhookConfigName(), instance.GetValidatingWebhookConfigName())
+	}
+	if instance.GetDryRunRoleName() != "ishield-cluster-role-tenant-a-sim" || instance.GetSignerConfigCRName() != apiv1alpha1.DefaultSignerConfigCRName+"-tenant-a" {
+		t.Errorf("unexpected role names: %s, %s", instance.GetDryRunRoleName(), instance.GetSignerConfigCRName())
+	}
+}
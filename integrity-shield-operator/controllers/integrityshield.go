@@ -17,6 +17,7 @@
 package controllers
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io/ioutil"
@@ -32,19 +33,23 @@ import (
 	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
 	admregv1 "k8s.io/api/admissionregistration/v1"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1beta1"
 	rbacv1 "k8s.io/api/rbac/v1"
-	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 
 	cert "github.com/IBM/integrity-enforcer/integrity-shield-operator/cert"
 
 	ec "github.com/IBM/integrity-enforcer/shield/pkg/apis/shieldconfig/v1alpha1"
 	sigconf "github.com/IBM/integrity-enforcer/shield/pkg/apis/signerconfig/v1alpha1"
 
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -75,6 +80,18 @@ func (r *IntegrityShieldReconciler) createOrUpdateCRD(instance *apiv1alpha1.Inte
 	// If CRD does not exist, create it and requeue
 	err = r.Get(ctx, types.NamespacedName{Name: expected.Name, Namespace: ""}, found)
 
+	// the conversion webhook trusts the same CA as the admission webhooks
+	if conv := expected.Spec.Conversion; conv != nil && conv.Webhook != nil && conv.Webhook.ClientConfig != nil {
+		secret := &corev1.Secret{}
+		secretErr := r.Get(ctx, types.NamespacedName{Name: instance.GetWebhookServerTlsSecretName(), Namespace: instance.Namespace}, secret)
+		if cabundle, ok := secret.Data["ca.crt"]; secretErr == nil && ok {
+			conv.Webhook.ClientConfig.CABundle = cabundle
+		} else if err == nil && found.Spec.Conversion != nil && found.Spec.Conversion.Webhook != nil && found.Spec.Conversion.Webhook.ClientConfig != nil {
+			// CABundle cannot be updated without the secret
+			conv.Webhook.ClientConfig.CABundle = found.Spec.Conversion.Webhook.ClientConfig.CABundle
+		}
+	}
+
 	if err != nil && errors.IsNotFound(err) {
 		reqLogger.Info("Creating a new resource")
 		err = r.Create(ctx, expected)
@@ -91,8 +108,28 @@ func (r *IntegrityShieldReconciler) createOrUpdateCRD(instance *apiv1alpha1.Inte
 	} else if err != nil {
 		return ctrl.Result{}, err
 	} else {
-		if !reflect.DeepEqual(expected.Spec, found.Spec) {
+		// CRDs are shared by all instances, and only the instance which owns them updates them.
+		// CRDs released by a deleted instance are adopted by the next instance reconciled.
+		adopt := false
+		if owner := metav1.GetControllerOf(found); owner == nil {
+			adopt = true
+		} else if owner.UID != instance.UID {
+			exists, err := r.instanceExists(owner.UID)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if exists {
+				return ctrl.Result{}, nil
+			}
+			adopt = true
+		}
+		if adopt || !reflect.DeepEqual(expected.Spec, found.Spec) {
+			ownerReferences := expected.OwnerReferences
 			expected.ObjectMeta = found.ObjectMeta
+			if adopt {
+				reqLogger.Info("Adopting the resource")
+				expected.OwnerReferences = ownerReferences
+			}
 			err = r.Update(ctx, expected)
 			if err != nil {
 				reqLogger.Error(err, "Failed to update the resource")
@@ -157,6 +194,12 @@ func (r *IntegrityShieldReconciler) createOrUpdateHelmReleaseMetadataCRD(
 	return r.createOrUpdateCRD(instance, expected)
 }
 
+func (r *IntegrityShieldReconciler) createOrUpdateIntegrityShieldReportCRD(
+	instance *apiv1alpha1.IntegrityShield) (ctrl.Result, error) {
+	expected := res.BuildIntegrityShieldReportCRD(instance)
+	return r.createOrUpdateCRD(instance, expected)
+}
+
 func (r *IntegrityShieldReconciler) createOrUpdateResourceSigningProfileCRD(
 	instance *apiv1alpha1.IntegrityShield) (ctrl.Result, error) {
 	expected := res.BuildResourceSigningProfileCRD(instance)
@@ -186,6 +229,12 @@ func (r *IntegrityShieldReconciler) deleteHelmReleaseMetadataCRD(
 	return r.deleteCRD(instance, expected)
 }
 
+func (r *IntegrityShieldReconciler) deleteIntegrityShieldReportCRD(
+	instance *apiv1alpha1.IntegrityShield) (ctrl.Result, error) {
+	expected := res.BuildIntegrityShieldReportCRD(instance)
+	return r.deleteCRD(instance, expected)
+}
+
 func (r *IntegrityShieldReconciler) deleteResourceSigningProfileCRD(
 	instance *apiv1alpha1.IntegrityShield) (ctrl.Result, error) {
 	expected := res.BuildResourceSigningProfileCRD(instance)
@@ -461,7 +510,15 @@ func (r *IntegrityShieldReconciler) createOrUpdateClusterRole(instance *apiv1alp
 		return ctrl.Result{}, err
 	}
 
-	// No extra validation
+	// rules depend on RSPs, so they are updated when RSPs are changed
+	if !equality.Semantic.DeepEqual(found.Rules, expected.Rules) {
+		found.Rules = expected.Rules
+		reqLogger.Info("Updating rules of the resource")
+		if err = r.Update(ctx, found); err != nil {
+			reqLogger.Error(err, "Failed to update the resource")
+			return ctrl.Result{}, err
+		}
+	}
 
 	// No reconcile was necessary
 	return ctrl.Result{}, nil
@@ -712,10 +769,39 @@ func (r *IntegrityShieldReconciler) createOrUpdateRoleForIShield(
 
 func (r *IntegrityShieldReconciler) createOrUpdateClusterRoleForIShield(
 	instance *apiv1alpha1.IntegrityShield) (ctrl.Result, error) {
-	expected := res.BuildClusterRoleForIShield(instance)
+	protectedResources, err := r.getProtectedResources(instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	expected := res.BuildClusterRoleForIShieldWithTargets(instance, protectedResources)
 	return r.createOrUpdateClusterRole(instance, expected)
 }
 
+// getProtectedResources returns the resource types which may be protected by RSPs in the cluster or in the CR
+func (r *IntegrityShieldReconciler) getProtectedResources(instance *apiv1alpha1.IntegrityShield) ([]schema.GroupVersionResource, error) {
+	if r.DiscoveryClient == nil {
+		return nil, nil
+	}
+	profiles := []rsp.ResourceSigningProfile{}
+	for _, prof := range instance.Spec.ResourceSigningProfiles {
+		if prof.ResourceSigningProfileSpec != nil {
+			profiles = append(profiles, *res.BuildResourceSigningProfileForIShield(instance, prof))
+		}
+	}
+	rspList := &rsp.ResourceSigningProfileList{}
+	if err := r.List(context.Background(), rspList); err != nil && !meta.IsNoMatchError(err) {
+		return nil, err
+	}
+	profiles = append(profiles, rspList.Items...)
+
+	// some API groups may be unavailable (e.g. metrics), and resources in other groups are still returned
+	resourceLists, err := r.DiscoveryClient.ServerPreferredResources()
+	if err != nil && len(resourceLists) == 0 {
+		return nil, err
+	}
+	return rsp.GetProtectedResources(resourceLists, profiles), nil
+}
+
 func (r *IntegrityShieldReconciler) deleteClusterRoleForIShield(
 	instance *apiv1alpha1.IntegrityShield) (ctrl.Result, error) {
 	expected := res.BuildClusterRoleForIShield(instance)
@@ -799,16 +885,14 @@ func (r *IntegrityShieldReconciler) deletePodSecurityPolicy(instance *apiv1alpha
 ***********************************************/
 
 func (r *IntegrityShieldReconciler) isKeyRingReady(instance *apiv1alpha1.IntegrityShield) (bool, string) {
-	ctx := context.Background()
-	found := &corev1.Secret{}
 	okCount := 0
 	nonReadyKey := ""
 	for _, keyConf := range instance.Spec.KeyConfig {
-		err := r.Get(ctx, types.NamespacedName{Name: keyConf.SecretName, Namespace: instance.Namespace}, found)
+		_, _, err := r.getKeySourceData(instance, keyConf)
 		if err == nil {
 			okCount += 1
 		} else {
-			nonReadyKey = keyConf.SecretName
+			nonReadyKey = getKeySourceName(keyConf)
 			break
 		}
 	}
@@ -855,7 +939,14 @@ func (r *IntegrityShieldReconciler) createOrUpdateCertSecret(instance *apiv1alph
 		return ctrl.Result{}, err
 	}
 
-	// No extra validation
+	// rotate the cert before it expires
+	rotated, err := r.rotateTlsSecret(instance, found)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if rotated {
+		return ctrl.Result{Requeue: true, RequeueAfter: time.Second * 1}, nil
+	}
 
 	// No reconcile was necessary
 	return ctrl.Result{}, nil
@@ -869,7 +960,7 @@ func addCertValues(instance *apiv1alpha1.IntegrityShield, expected *corev1.Secre
 		"Secret.Name", expected.Name)
 
 	// generate and put certsß
-	ca, tlsKey, tlsCert, err := cert.GenerateCert(instance.GetWebhookServiceName(), instance.Namespace)
+	ca, tlsKey, tlsCert, err := cert.GenerateCertWithValidity(instance.GetWebhookServiceName(), instance.Namespace, instance.GetCertValidity())
 	if err != nil {
 		reqLogger.Error(err, "Failed to generate certs")
 	}
@@ -977,7 +1068,14 @@ func (r *IntegrityShieldReconciler) createOrUpdateDeployment(instance *apiv1alph
 		return ctrl.Result{Requeue: true, RequeueAfter: time.Second * 1}, nil
 	} else if err != nil {
 		return ctrl.Result{}, err
-	} else if !res.EqualDeployments(expected, found) {
+	}
+
+	if instance.Spec.HighAvailability.Autoscaling.Enabled {
+		// replicas are managed by HorizontalPodAutoscaler
+		expected.Spec.Replicas = found.Spec.Replicas
+	}
+
+	if !res.EqualDeployments(expected, found) {
 		// If spec is incorrect, update it and requeue
 		found.ObjectMeta.Labels = expected.ObjectMeta.Labels
 		found.Spec = expected.Spec
@@ -1003,6 +1101,199 @@ func (r *IntegrityShieldReconciler) createOrUpdateWebhookDeployment(instance *ap
 	return r.createOrUpdateDeployment(instance, expected)
 }
 
+// delete server deployment; it does not requeue if it does not exist
+func (r *IntegrityShieldReconciler) deleteWebhookDeployment(instance *apiv1alpha1.IntegrityShield) (ctrl.Result, error) {
+	ctx := context.Background()
+	found := &appsv1.Deployment{}
+
+	reqLogger := r.Log.WithValues(
+		"Instance.Name", instance.Name,
+		"Deployment.Name", instance.GetIShieldServerDeploymentName())
+
+	err := r.Get(ctx, types.NamespacedName{Name: instance.GetIShieldServerDeploymentName(), Namespace: instance.Namespace}, found)
+
+	if err == nil {
+		reqLogger.Info("Deleting the IShield server Deployment")
+		err = r.Delete(ctx, found)
+		if err != nil {
+			reqLogger.Error(err, "Failed to delete the IShield server Deployment")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{Requeue: true, RequeueAfter: time.Second * 1}, nil
+	} else if errors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	} else {
+		return ctrl.Result{}, err
+	}
+}
+
+/**********************************************
+
+				PodDisruptionBudget
+
+***********************************************/
+
+func (r *IntegrityShieldReconciler) createOrUpdatePodDisruptionBudget(instance *apiv1alpha1.IntegrityShield) (ctrl.Result, error) {
+	ctx := context.Background()
+	expected := res.BuildPodDisruptionBudgetForIShield(instance)
+	found := &policyv1.PodDisruptionBudget{}
+
+	reqLogger := r.Log.WithValues(
+		"Instance.Name", instance.Name,
+		"PodDisruptionBudget.Name", expected.Name)
+
+	// Set CR instance as the owner and controller
+	err := controllerutil.SetControllerReference(instance, expected, r.Scheme)
+	if err != nil {
+		reqLogger.Error(err, "Failed to define expected resource")
+		return ctrl.Result{}, err
+	}
+
+	// If PodDisruptionBudget does not exist, create it and requeue
+	err = r.Get(ctx, types.NamespacedName{Name: expected.Name, Namespace: instance.Namespace}, found)
+
+	if err != nil && errors.IsNotFound(err) {
+		reqLogger.Info("Creating a new resource")
+		err = r.Create(ctx, expected)
+		if err != nil && errors.IsAlreadyExists(err) {
+			// Already exists from previous reconcile, requeue.
+			reqLogger.Info("Skip reconcile: resource already exists")
+			return ctrl.Result{Requeue: true}, nil
+		} else if err != nil {
+			reqLogger.Error(err, "Failed to create new resource")
+			return ctrl.Result{}, err
+		}
+		// Created successfully - return and requeue
+		return ctrl.Result{Requeue: true, RequeueAfter: time.Second * 1}, nil
+	} else if err != nil {
+		return ctrl.Result{}, err
+	} else if !reflect.DeepEqual(found.Spec.MinAvailable, expected.Spec.MinAvailable) ||
+		!reflect.DeepEqual(found.Spec.MaxUnavailable, expected.Spec.MaxUnavailable) ||
+		!reflect.DeepEqual(found.Spec.Selector, expected.Spec.Selector) {
+		// spec of policy/v1beta1 PodDisruptionBudget is immutable before k8s 1.15, so recreate it
+		reqLogger.Info("Recreating PodDisruptionBudget to apply the change")
+		err = r.Delete(ctx, found)
+		if err != nil {
+			reqLogger.Error(err, "Failed to delete PodDisruptionBudget")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{Requeue: true, RequeueAfter: time.Second * 1}, nil
+	}
+
+	// No reconcile was necessary
+	return ctrl.Result{}, nil
+}
+
+// delete PodDisruptionBudget; it does not requeue if it does not exist
+func (r *IntegrityShieldReconciler) deletePodDisruptionBudget(instance *apiv1alpha1.IntegrityShield) (ctrl.Result, error) {
+	ctx := context.Background()
+	found := &policyv1.PodDisruptionBudget{}
+
+	reqLogger := r.Log.WithValues(
+		"Instance.Name", instance.Name,
+		"PodDisruptionBudget.Name", instance.GetPodDisruptionBudgetName())
+
+	err := r.Get(ctx, types.NamespacedName{Name: instance.GetPodDisruptionBudgetName(), Namespace: instance.Namespace}, found)
+
+	if err == nil {
+		reqLogger.Info("Deleting the IShield PodDisruptionBudget")
+		err = r.Delete(ctx, found)
+		if err != nil {
+			reqLogger.Error(err, "Failed to delete the IShield PodDisruptionBudget")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{Requeue: true, RequeueAfter: time.Second * 1}, nil
+	} else if errors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	} else {
+		return ctrl.Result{}, err
+	}
+}
+
+/**********************************************
+
+				HorizontalPodAutoscaler
+
+***********************************************/
+
+func (r *IntegrityShieldReconciler) createOrUpdateHorizontalPodAutoscaler(instance *apiv1alpha1.IntegrityShield) (ctrl.Result, error) {
+	ctx := context.Background()
+	expected := res.BuildHorizontalPodAutoscalerForIShield(instance)
+	found := &autoscalingv1.HorizontalPodAutoscaler{}
+
+	reqLogger := r.Log.WithValues(
+		"Instance.Name", instance.Name,
+		"HorizontalPodAutoscaler.Name", expected.Name)
+
+	// Set CR instance as the owner and controller
+	err := controllerutil.SetControllerReference(instance, expected, r.Scheme)
+	if err != nil {
+		reqLogger.Error(err, "Failed to define expected resource")
+		return ctrl.Result{}, err
+	}
+
+	// If HorizontalPodAutoscaler does not exist, create it and requeue
+	err = r.Get(ctx, types.NamespacedName{Name: expected.Name, Namespace: instance.Namespace}, found)
+
+	if err != nil && errors.IsNotFound(err) {
+		reqLogger.Info("Creating a new resource")
+		err = r.Create(ctx, expected)
+		if err != nil && errors.IsAlreadyExists(err) {
+			// Already exists from previous reconcile, requeue.
+			reqLogger.Info("Skip reconcile: resource already exists")
+			return ctrl.Result{Requeue: true}, nil
+		} else if err != nil {
+			reqLogger.Error(err, "Failed to create new resource")
+			return ctrl.Result{}, err
+		}
+		// Created successfully - return and requeue
+		return ctrl.Result{Requeue: true, RequeueAfter: time.Second * 1}, nil
+	} else if err != nil {
+		return ctrl.Result{}, err
+	} else if !reflect.DeepEqual(found.Spec, expected.Spec) {
+		// If spec is incorrect, update it and requeue
+		found.ObjectMeta.Labels = expected.ObjectMeta.Labels
+		found.Spec = expected.Spec
+		err = r.Update(ctx, found)
+		if err != nil {
+			reqLogger.Error(err, "Failed to update HorizontalPodAutoscaler", "Namespace", instance.Namespace, "Name", found.Name)
+			return ctrl.Result{}, err
+		}
+		reqLogger.Info("Updating IntegrityShield HorizontalPodAutoscaler", "HorizontalPodAutoscaler.Name", found.Name)
+		// Spec updated - return and requeue
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	// No reconcile was necessary
+	return ctrl.Result{}, nil
+}
+
+// delete HorizontalPodAutoscaler; it does not requeue if it does not exist
+func (r *IntegrityShieldReconciler) deleteHorizontalPodAutoscaler(instance *apiv1alpha1.IntegrityShield) (ctrl.Result, error) {
+	ctx := context.Background()
+	found := &autoscalingv1.HorizontalPodAutoscaler{}
+
+	reqLogger := r.Log.WithValues(
+		"Instance.Name", instance.Name,
+		"HorizontalPodAutoscaler.Name", instance.GetHorizontalPodAutoscalerName())
+
+	err := r.Get(ctx, types.NamespacedName{Name: instance.GetHorizontalPodAutoscalerName(), Namespace: instance.Namespace}, found)
+
+	if err == nil {
+		reqLogger.Info("Deleting the IShield HorizontalPodAutoscaler")
+		err = r.Delete(ctx, found)
+		if err != nil {
+			reqLogger.Error(err, "Failed to delete the IShield HorizontalPodAutoscaler")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{Requeue: true, RequeueAfter: time.Second * 1}, nil
+	} else if errors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	} else {
+		return ctrl.Result{}, err
+	}
+}
+
 /**********************************************
 
 				Service
@@ -1064,12 +1355,23 @@ func (r *IntegrityShieldReconciler) createOrUpdateWebhookService(instance *apiv1
 
 func (r *IntegrityShieldReconciler) createOrUpdateWebhook(instance *apiv1alpha1.IntegrityShield) (ctrl.Result, error) {
 	ctx := context.Background()
-	expected := res.BuildMutatingWebhookConfigurationForIShield(instance)
 	found := &admregv1.MutatingWebhookConfiguration{}
 
 	reqLogger := r.Log.WithValues(
 		"Instance.Name", instance.Name,
-		"MutatingWebhookConfiguration.Name", expected.Name)
+		"MutatingWebhookConfiguration.Name", instance.GetWebhookConfigName())
+
+	// out-of-scope namespaces are excluded so that their requests never reach the webhook
+	var outOfScopeNamespaces []string
+	if instance.Spec.WebhookNamespaceSelector == nil {
+		var err error
+		outOfScopeNamespaces, err = r.getOutOfScopeNamespaces(instance)
+		if err != nil {
+			reqLogger.Error(err, "Failed to get out-of-scope namespaces")
+			return ctrl.Result{}, err
+		}
+	}
+	expected := res.BuildMutatingWebhookConfigurationForIShieldWithScope(instance, outOfScopeNamespaces)
 
 	// Set CR instance as the owner and controller
 	err := controllerutil.SetControllerReference(instance, expected, r.Scheme)
@@ -1078,21 +1380,24 @@ func (r *IntegrityShieldReconciler) createOrUpdateWebhook(instance *apiv1alpha1.
 		return ctrl.Result{}, err
 	}
 
-	// If PodSecurityPolicy does not exist, create it and requeue
+	// locad cabundle
+	secret := &corev1.Secret{}
+	err = r.Get(ctx, types.NamespacedName{Name: instance.GetWebhookServerTlsSecretName(), Namespace: instance.Namespace}, secret)
+	if err != nil {
+		reqLogger.Error(err, "Fail to load CABundle from Secret")
+	}
+	cabundle, ok := secret.Data["ca.crt"]
+	if ok {
+		for i := range expected.Webhooks {
+			expected.Webhooks[i].ClientConfig.CABundle = cabundle
+		}
+	}
+
+	// If MutatingWebhookConfiguration does not exist, create it and requeue
 	err = r.Get(ctx, types.NamespacedName{Name: expected.Name}, found)
 
 	if err != nil && errors.IsNotFound(err) {
 		reqLogger.Info("Creating a new resource")
-		// locad cabundle
-		secret := &corev1.Secret{}
-		err = r.Get(ctx, types.NamespacedName{Name: instance.GetWebhookServerTlsSecretName(), Namespace: instance.Namespace}, secret)
-		if err != nil {
-			reqLogger.Error(err, "Fail to load CABundle from Secret")
-		}
-		cabundle, ok := secret.Data["ca.crt"]
-		if ok {
-			expected.Webhooks[0].ClientConfig.CABundle = cabundle
-		}
 
 		err = r.Create(ctx, expected)
 		if err != nil && errors.IsAlreadyExists(err) {
@@ -1114,11 +1419,175 @@ func (r *IntegrityShieldReconciler) createOrUpdateWebhook(instance *apiv1alpha1.
 		return ctrl.Result{}, err
 	}
 
-	// No extra validation
+	// keep the webhooks in sync with the CR, the scope and the TLS secret (which is changed by cert rotation)
+	if !ok {
+		// CABundle cannot be updated without the secret
+		for i := range expected.Webhooks {
+			if i < len(found.Webhooks) {
+				expected.Webhooks[i].ClientConfig.CABundle = found.Webhooks[i].ClientConfig.CABundle
+			}
+		}
+	}
+	if isWebhookChanged(found, expected) {
+		found.Webhooks = expected.Webhooks
+		err = r.Update(ctx, found)
+		if err != nil {
+			reqLogger.Error(err, "Failed to update the webhook")
+			return ctrl.Result{}, err
+		}
+		reqLogger.Info("Webhook has been updated.", "Name", instance.Name, "OutOfScopeNamespaces", len(outOfScopeNamespaces))
+		return ctrl.Result{Requeue: true, RequeueAfter: time.Second * 1}, nil
+	}
+
+	// No reconcile was necessary
+	return ctrl.Result{}, nil
+
+}
+
+// isWebhookChanged compares only the fields set by the operator, because the other fields are defaulted by the API server
+func isWebhookChanged(found, expected *admregv1.MutatingWebhookConfiguration) bool {
+	if len(found.Webhooks) != len(expected.Webhooks) {
+		return true
+	}
+	for i := range expected.Webhooks {
+		f := found.Webhooks[i]
+		e := expected.Webhooks[i]
+		if f.Name != e.Name ||
+			!bytes.Equal(f.ClientConfig.CABundle, e.ClientConfig.CABundle) ||
+			!equality.Semantic.DeepEqual(f.Rules, e.Rules) ||
+			!equality.Semantic.DeepEqual(f.FailurePolicy, e.FailurePolicy) ||
+			!equality.Semantic.DeepEqual(f.MatchPolicy, e.MatchPolicy) ||
+			!equality.Semantic.DeepEqual(f.NamespaceSelector, e.NamespaceSelector) ||
+			!equality.Semantic.DeepEqual(f.ObjectSelector, e.ObjectSelector) ||
+			!equality.Semantic.DeepEqual(f.SideEffects, e.SideEffects) ||
+			!equality.Semantic.DeepEqual(f.TimeoutSeconds, e.TimeoutSeconds) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *IntegrityShieldReconciler) createOrUpdateValidatingWebhook(instance *apiv1alpha1.IntegrityShield) (ctrl.Result, error) {
+	ctx := context.Background()
+	found := &admregv1.ValidatingWebhookConfiguration{}
+
+	reqLogger := r.Log.WithValues(
+		"Instance.Name", instance.Name,
+		"ValidatingWebhookConfiguration.Name", instance.GetValidatingWebhookConfigName())
+
+	// iShield resources in namespaces of other instances are validated by them
+	otherInstanceNamespaces, err := r.getOtherInstanceNamespaces(instance)
+	if err != nil {
+		reqLogger.Error(err, "Failed to get namespaces of other instances")
+		return ctrl.Result{}, err
+	}
+	expected := res.BuildValidatingWebhookConfigurationForIShieldWithScope(instance, otherInstanceNamespaces)
+
+	// Set CR instance as the owner and controller
+	err = controllerutil.SetControllerReference(instance, expected, r.Scheme)
+	if err != nil {
+		reqLogger.Error(err, "Failed to define expected resource")
+		return ctrl.Result{}, err
+	}
+
+	// load cabundle
+	secret := &corev1.Secret{}
+	err = r.Get(ctx, types.NamespacedName{Name: instance.GetWebhookServerTlsSecretName(), Namespace: instance.Namespace}, secret)
+	if err != nil {
+		reqLogger.Error(err, "Fail to load CABundle from Secret")
+	}
+	cabundle, ok := secret.Data["ca.crt"]
+	if ok {
+		for i := range expected.Webhooks {
+			expected.Webhooks[i].ClientConfig.CABundle = cabundle
+		}
+	}
+
+	// If ValidatingWebhookConfiguration does not exist, create it and requeue
+	err = r.Get(ctx, types.NamespacedName{Name: expected.Name}, found)
+
+	if err != nil && errors.IsNotFound(err) {
+		reqLogger.Info("Creating a new resource")
+
+		err = r.Create(ctx, expected)
+		if err != nil && errors.IsAlreadyExists(err) {
+			// Already exists from previous reconcile, requeue.
+			reqLogger.Info("Skip reconcile: resource already exists")
+			return ctrl.Result{Requeue: true}, nil
+		} else if err != nil {
+			reqLogger.Error(err, "Failed to create new resource")
+			return ctrl.Result{}, err
+		}
+		// Created successfully - return and requeue
+
+		reqLogger.Info("Validating webhook has been created.", "Name", instance.Name)
+		return ctrl.Result{Requeue: true, RequeueAfter: time.Second * 1}, nil
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !ok {
+		// CABundle cannot be updated without the secret
+		for i := range expected.Webhooks {
+			if i < len(found.Webhooks) {
+				expected.Webhooks[i].ClientConfig.CABundle = found.Webhooks[i].ClientConfig.CABundle
+			}
+		}
+	}
+	if isValidatingWebhookChanged(found, expected) {
+		found.Webhooks = expected.Webhooks
+		err = r.Update(ctx, found)
+		if err != nil {
+			reqLogger.Error(err, "Failed to update the validating webhook")
+			return ctrl.Result{}, err
+		}
+		reqLogger.Info("Validating webhook has been updated.", "Name", instance.Name)
+		return ctrl.Result{Requeue: true, RequeueAfter: time.Second * 1}, nil
+	}
 
 	// No reconcile was necessary
 	return ctrl.Result{}, nil
+}
+
+func isValidatingWebhookChanged(found, expected *admregv1.ValidatingWebhookConfiguration) bool {
+	if len(found.Webhooks) != len(expected.Webhooks) {
+		return true
+	}
+	for i := range expected.Webhooks {
+		f := found.Webhooks[i]
+		e := expected.Webhooks[i]
+		if f.Name != e.Name ||
+			!bytes.Equal(f.ClientConfig.CABundle, e.ClientConfig.CABundle) ||
+			!equality.Semantic.DeepEqual(f.Rules, e.Rules) ||
+			!equality.Semantic.DeepEqual(f.FailurePolicy, e.FailurePolicy) ||
+			!equality.Semantic.DeepEqual(f.MatchPolicy, e.MatchPolicy) ||
+			!equality.Semantic.DeepEqual(f.TimeoutSeconds, e.TimeoutSeconds) {
+			return true
+		}
+	}
+	return false
+}
 
+// getOutOfScopeNamespaces lists namespaces where iShield does not verify any request with current ShieldConfig and RSPs
+func (r *IntegrityShieldReconciler) getOutOfScopeNamespaces(instance *apiv1alpha1.IntegrityShield) ([]string, error) {
+	ctx := context.Background()
+	rspList := &rsp.ResourceSigningProfileList{}
+	err := r.List(ctx, rspList)
+	if err != nil {
+		return nil, err
+	}
+	nsList := &corev1.NamespaceList{}
+	err = r.List(ctx, nsList)
+	if err != nil {
+		return nil, err
+	}
+	outOfScope := res.GetOutOfScopeNamespaces(instance, rspList.Items, nsList.Items)
+	// requests in namespaces of other instances are verified by them
+	otherInstanceNamespaces, err := r.getOtherInstanceNamespaces(instance)
+	if err != nil {
+		return nil, err
+	}
+	return mergeNamespaces(outOfScope, otherInstanceNamespaces), nil
 }
 
 // delete webhookconfiguration
@@ -1148,6 +1617,33 @@ func (r *IntegrityShieldReconciler) deleteWebhook(instance *apiv1alpha1.Integrit
 	}
 }
 
+// delete validating webhookconfiguration; unlike deleteWebhook, it does not requeue if it does not exist
+func (r *IntegrityShieldReconciler) deleteValidatingWebhook(instance *apiv1alpha1.IntegrityShield) (ctrl.Result, error) {
+	ctx := context.Background()
+	expected := res.BuildValidatingWebhookConfigurationForIShield(instance)
+	found := &admregv1.ValidatingWebhookConfiguration{}
+
+	reqLogger := r.Log.WithValues(
+		"Instance.Name", instance.Name,
+		"ValidatingWebhookConfiguration.Name", expected.Name)
+
+	err := r.Get(ctx, types.NamespacedName{Name: expected.Name}, found)
+
+	if err == nil {
+		reqLogger.Info("Deleting the IShield validating webhook")
+		err = r.Delete(ctx, found)
+		if err != nil {
+			reqLogger.Error(err, "Failed to delete the IShield validating webhook")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{Requeue: true, RequeueAfter: time.Second * 1}, nil
+	} else if errors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	} else {
+		return ctrl.Result{}, err
+	}
+}
+
 // wait function
 func (r *IntegrityShieldReconciler) isDeploymentAvailable(instance *apiv1alpha1.IntegrityShield) bool {
 	ctx := context.Background()
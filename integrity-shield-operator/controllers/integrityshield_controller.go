@@ -22,14 +22,25 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	apisv1alpha1 "github.com/IBM/integrity-enforcer/integrity-shield-operator/api/v1alpha1"
 	"github.com/IBM/integrity-enforcer/integrity-shield-operator/resources"
+	rsp "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
 )
 
 var log = logf.Log.WithName("controller_integrityshield")
@@ -39,15 +50,20 @@ type IntegrityShieldReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+	// used to find resource types protected by RSPs
+	DiscoveryClient discovery.DiscoveryInterface
 }
 
 // +kubebuilder:rbac:groups=core,resources=services;serviceaccounts;events;configmaps;secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=apis.integrityshield.io,resources=integrityshields;integrityshields/finalizers;shieldconfigs;signerconfigs;resourcesigningprofiles;resourcesignatures;helmreleasemetadatas,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=apis.integrityshield.io,resources=integrityshields;integrityshields/finalizers;integrityshields/status;shieldconfigs;signerconfigs;resourcesigningprofiles;resourcesignatures;helmreleasemetadatas;integrityshieldreports,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=*
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles;clusterrolebindings;roles;rolebindings,verbs=*
-// +kubebuilder:rbac:groups=policy,resources=podsecuritypolicies,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=policy,resources=podsecuritypolicies;poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=mutatingwebhookconfigurations,verbs=*
+// +kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=validatingwebhookconfigurations,verbs=*
 
 func (r *IntegrityShieldReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
@@ -79,12 +95,24 @@ func (r *IntegrityShieldReconciler) Reconcile(req ctrl.Request) (ctrl.Result, er
 	// Integrity Shield is under deletion - finalizer step
 	if !instance.ObjectMeta.DeletionTimestamp.IsZero() {
 		if containsString(instance.ObjectMeta.Finalizers, apisv1alpha1.CleanupFinalizerName) {
-			if err := r.deleteClusterScopedChildrenResources(instance); err != nil {
+			// an instance in conflict has created nothing, and its resource names may be used by another instance
+			conflicts, err := r.getInstanceConflicts(instance)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			result := ctrl.Result{}
+			if len(conflicts) == 0 {
+				result, err = r.uninstall(instance)
+			}
+			if err != nil {
 				// if fail to delete the external dependency here, return with error
 				// so that it can be retried
 				reqLogger.Error(err, "Error occured during finalizer process. retrying soon.")
 				return ctrl.Result{}, err
 			}
+			if result.Requeue {
+				return result, nil
+			}
 
 			// remove our finalizer from the list and update it.
 			instance.ObjectMeta.Finalizers = removeString(instance.ObjectMeta.Finalizers, apisv1alpha1.CleanupFinalizerName)
@@ -97,8 +125,37 @@ func (r *IntegrityShieldReconciler) Reconcile(req ctrl.Request) (ctrl.Result, er
 
 	// otherwise, normal reconcile
 
+	// report conditions on the CR status at whichever step this reconcile ends
+	defer func() {
+		if err := r.updateStatus(instance); err != nil {
+			reqLogger.Error(err, "Failed to update IntegrityShield status")
+		}
+	}()
+
+	// an instance which conflicts with an earlier instance is not reconciled until the conflict is resolved
+	if conflicts, err := r.getInstanceConflicts(instance); err != nil {
+		return ctrl.Result{}, err
+	} else if len(conflicts) > 0 {
+		reqLogger.Info("IntegrityShield conflicts with other instances. Skip reconciling.", "Conflicts", conflicts)
+		return ctrl.Result{RequeueAfter: time.Minute}, nil
+	}
+
+	// the validating webhook for IntegrityShield is optional, so the spec is validated here too;
+	// a fixed spec changes the generation and triggers reconcile
+	if errs := instance.ValidateSpec(); len(errs) > 0 {
+		reqLogger.Info("IntegrityShield spec is invalid. Skip reconciling.", "Errors", errs.ToAggregate().Error())
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.syncJWKSKeys(instance); err != nil {
+		reqLogger.Error(err, "Failed to sync keys from JWKS")
+		return ctrl.Result{}, err
+	}
+
+	r.syncKeyRotations(instance)
+
 	if ok, nonReadyKey := r.isKeyRingReady(instance); !ok {
-		reqLogger.Info(fmt.Sprintf("KeyRing secret \"%s\" does not exist. Skip reconciling.", nonReadyKey))
+		reqLogger.Info(fmt.Sprintf("Key source \"%s\" does not exist. Skip reconciling.", nonReadyKey))
 		return ctrl.Result{Requeue: true}, nil
 	}
 
@@ -123,6 +180,11 @@ func (r *IntegrityShieldReconciler) Reconcile(req ctrl.Request) (ctrl.Result, er
 		return recResult, recErr
 	}
 
+	recResult, recErr = r.createOrUpdateIntegrityShieldReportCRD(instance)
+	if recErr != nil || recResult.Requeue {
+		return recResult, recErr
+	}
+
 	enabledPulgins := instance.Spec.ShieldConfig.GetEnabledPlugins()
 	if enabledPulgins["helm"] {
 		recResult, recErr = r.createOrUpdateHelmReleaseMetadataCRD(instance)
@@ -226,6 +288,26 @@ func (r *IntegrityShieldReconciler) Reconcile(req ctrl.Request) (ctrl.Result, er
 		return recResult, recErr
 	}
 
+	// PodDisruptionBudget
+	if instance.IsPodDisruptionBudgetEnabled() {
+		recResult, recErr = r.createOrUpdatePodDisruptionBudget(instance)
+	} else {
+		recResult, recErr = r.deletePodDisruptionBudget(instance)
+	}
+	if recErr != nil || recResult.Requeue {
+		return recResult, recErr
+	}
+
+	// HorizontalPodAutoscaler
+	if instance.Spec.HighAvailability.Autoscaling.Enabled {
+		recResult, recErr = r.createOrUpdateHorizontalPodAutoscaler(instance)
+	} else {
+		recResult, recErr = r.deleteHorizontalPodAutoscaler(instance)
+	}
+	if recErr != nil || recResult.Requeue {
+		return recResult, recErr
+	}
+
 	//Service
 	recResult, recErr = r.createOrUpdateWebhookService(instance)
 	if recErr != nil || recResult.Requeue {
@@ -239,7 +321,15 @@ func (r *IntegrityShieldReconciler) Reconcile(req ctrl.Request) (ctrl.Result, er
 		if recErr != nil || recResult.Requeue {
 			return recResult, recErr
 		}
+		recResult, recErr = r.createOrUpdateValidatingWebhook(instance)
+		if recErr != nil || recResult.Requeue {
+			return recResult, recErr
+		}
 	} else {
+		recResult, recErr = r.deleteValidatingWebhook(instance)
+		if recErr != nil || recResult.Requeue {
+			return recResult, recErr
+		}
 		recResult, recErr = r.deleteWebhook(instance)
 		if recErr != nil || recResult.Requeue {
 			return recResult, recErr
@@ -250,76 +340,51 @@ func (r *IntegrityShieldReconciler) Reconcile(req ctrl.Request) (ctrl.Result, er
 	// since we updated the status in the CR, sleep 5 seconds to allow the CR to be refreshed.
 	time.Sleep(5 * time.Second)
 
-	return ctrl.Result{}, nil
+	// check the webhook server cert, JWKS and key rotations again when they are due
+	requeueAfter := r.requeueAfterForCert(instance)
+	if jwksInterval := requeueAfterForJWKS(instance); jwksInterval > 0 && jwksInterval < requeueAfter {
+		requeueAfter = jwksInterval
+	}
+	if keyRotationInterval := requeueAfterForKeyRotation(instance, time.Now()); keyRotationInterval > 0 && keyRotationInterval < requeueAfter {
+		requeueAfter = keyRotationInterval
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
 func (r *IntegrityShieldReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// RSPs and namespaces change the scope of the webhook, and other instances change namespaces excluded from it
+	toAllInstances := &handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.requestsForAllInstances)}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&apisv1alpha1.IntegrityShield{}).
 		Owns(&apisv1alpha1.IntegrityShield{}).
+		Watches(&source.Kind{Type: &apisv1alpha1.IntegrityShield{}}, toAllInstances, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &rsp.ResourceSigningProfile{}}, toAllInstances, builder.WithPredicates(predicate.Funcs{
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				// ignore status updates by iShield server
+				oldRSP, ok1 := e.ObjectOld.(*rsp.ResourceSigningProfile)
+				newRSP, ok2 := e.ObjectNew.(*rsp.ResourceSigningProfile)
+				return !ok1 || !ok2 || !equality.Semantic.DeepEqual(oldRSP.Spec, newRSP.Spec)
+			},
+		})).
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, toAllInstances, builder.WithPredicates(predicate.Funcs{
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				return !equality.Semantic.DeepEqual(e.MetaOld.GetLabels(), e.MetaNew.GetLabels())
+			},
+		})).
 		Complete(r)
 }
 
-func (r *IntegrityShieldReconciler) deleteClusterScopedChildrenResources(instance *apisv1alpha1.IntegrityShield) error {
-	// delete any cluster scope resources owned by the instance
-	// (In Iubernetes 1.20 and later, a garbage collector ignore cluster scope children even if their owner is deleted)
-	var err error
-	_, err = r.deleteWebhook(instance)
-	if err != nil {
-		return err
-	}
-	_, err = r.deletePodSecurityPolicy(instance)
-	if err != nil {
-		return err
-	}
-	if !instance.Spec.Security.AutoIShieldAdminCreationDisabled {
-		_, err = r.deleteClusterRoleBindingForIShieldAdmin(instance)
-		if err != nil {
-			return err
-		}
-		_, err = r.deleteClusterRoleForIShieldAdmin(instance)
-		if err != nil {
-			return err
-		}
-	}
-	_, err = r.deleteClusterRoleBindingForIShield(instance)
-	if err != nil {
-		return err
-	}
-	_, err = r.deleteClusterRoleForIShield(instance)
-	if err != nil {
-		return err
-	}
-
-	enabledPulgins := instance.Spec.ShieldConfig.GetEnabledPlugins()
-	if enabledPulgins["helm"] {
-		_, err = r.deleteHelmReleaseMetadataCRD(instance)
-		if err != nil {
-			return err
-		}
+func (r *IntegrityShieldReconciler) requestsForAllInstances(obj handler.MapObject) []reconcile.Request {
+	instances := &apisv1alpha1.IntegrityShieldList{}
+	if err := r.List(context.Background(), instances); err != nil {
+		r.Log.Error(err, "Failed to list IntegrityShield")
+		return nil
 	}
-
-	_, err = r.deleteResourceSigningProfileCRD(instance)
-	if err != nil {
-		return err
+	requests := []reconcile.Request{}
+	for _, instance := range instances.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}})
 	}
-
-	_, err = r.deleteResourceSignatureCRD(instance)
-	if err != nil {
-		return err
-	}
-
-	_, err = r.deleteSignerConfigCRD(instance)
-	if err != nil {
-		return err
-	}
-
-	_, err = r.deleteShieldConfigCRD(instance)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return requests
 }
 
 // Helper functions to check and remove string from a slice of strings.
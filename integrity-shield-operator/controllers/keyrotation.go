@@ -0,0 +1,193 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	apiv1alpha1 "github.com/IBM/integrity-enforcer/integrity-shield-operator/api/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+/**********************************************
+
+				Key Rotation
+
+***********************************************/
+
+const (
+	// max number of resources signed by the old key which are listed in the status
+	maxReportedResourcesSignedByOldKey = 50
+	// resources signed by the old key are checked again at this interval
+	keyInventoryCheckInterval = 10 * time.Minute
+)
+
+// syncKeyRotations updates the progress of key rotations in the CR status, which decides keyConfigs of signers in SignerConfig.
+// Resources signed only by the old key are reported from the key inventory of the observer.
+func (r *IntegrityShieldReconciler) syncKeyRotations(instance *apiv1alpha1.IntegrityShield) {
+	reqLogger := r.Log.WithValues("Instance.Name", instance.Name)
+
+	statuses := newKeyRotationStatuses(instance, time.Now())
+	if len(statuses) == 0 {
+		instance.Status.KeyRotations = nil
+		return
+	}
+	inventory, err := r.getKeyInventory(instance)
+	for i := range statuses {
+		status := &statuses[i]
+		if status.OldKeyConfig == "" || status.OldKeyConfig == status.NewKeyConfig {
+			continue
+		}
+		if status.Phase == apiv1alpha1.KeyRotationPhaseCompleted && !isKeyRotationCompleted(instance.Status.KeyRotations, *status) {
+			reqLogger.Info(fmt.Sprintf("KeyConfig \"%s\" of signer \"%s\" is retired", status.OldKeyConfig, status.Signer))
+		}
+		if err != nil {
+			status.Message = fmt.Sprintf("resources signed by the old key are unknown; %s", err.Error())
+			continue
+		}
+		status.ResourcesSignedByOldKey, status.Message = getResourcesSignedByOldKey(inventory, *status)
+	}
+	instance.Status.KeyRotations = statuses
+}
+
+// newKeyRotationStatuses computes the progress of key rotations in the spec.
+// A rotation starts when it first appears in the spec, and the old keyConfig is retired when the overlap period passes.
+func newKeyRotationStatuses(instance *apiv1alpha1.IntegrityShield, now time.Time) []apiv1alpha1.KeyRotationStatus {
+	statuses := []apiv1alpha1.KeyRotationStatus{}
+	for _, rotation := range instance.Spec.KeyRotations {
+		status := apiv1alpha1.KeyRotationStatus{Signer: rotation.Signer, NewKeyConfig: rotation.NewKeyConfig}
+		for _, current := range instance.Status.KeyRotations {
+			if current.Signer == rotation.Signer && current.NewKeyConfig == rotation.NewKeyConfig {
+				status = *current.DeepCopy()
+				break
+			}
+		}
+		status.Message = ""
+
+		if status.StartTime == nil {
+			keyConfig, found := getSignerKeyConfig(instance.Spec.SignerConfig, rotation.Signer)
+			if !found {
+				status.Phase = ""
+				status.Message = fmt.Sprintf("signer \"%s\" is not found in signerConfig", rotation.Signer)
+				statuses = append(statuses, status)
+				continue
+			}
+			if keyConfig == rotation.NewKeyConfig {
+				// signerConfig is already updated to the new keyConfig
+				status.Phase = apiv1alpha1.KeyRotationPhaseCompleted
+				statuses = append(statuses, status)
+				continue
+			}
+			startTime := metav1.NewTime(now.Truncate(time.Second))
+			status.StartTime = &startTime
+			status.OldKeyConfig = keyConfig
+		}
+		retireTime := metav1.NewTime(status.StartTime.Add(rotation.GetOverlapPeriod()))
+		status.RetireTime = &retireTime
+		if now.Before(retireTime.Time) {
+			status.Phase = apiv1alpha1.KeyRotationPhaseOverlapping
+		} else {
+			status.Phase = apiv1alpha1.KeyRotationPhaseCompleted
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func getSignerKeyConfig(signerConfig *common.SignerConfig, signerName string) (string, bool) {
+	if signerConfig == nil {
+		return "", false
+	}
+	for _, signer := range signerConfig.Signers {
+		if signer.Name == signerName {
+			return signer.KeyConfig, true
+		}
+	}
+	return "", false
+}
+
+func isKeyRotationCompleted(statuses []apiv1alpha1.KeyRotationStatus, status apiv1alpha1.KeyRotationStatus) bool {
+	for _, s := range statuses {
+		if s.Signer == status.Signer && s.NewKeyConfig == status.NewKeyConfig {
+			return s.Phase == apiv1alpha1.KeyRotationPhaseCompleted
+		}
+	}
+	return false
+}
+
+// getResourcesSignedByOldKey returns resources whose latest signature was verified with the old keyConfig but not with the new one
+func getResourcesSignedByOldKey(inventory common.KeyInventory, status apiv1alpha1.KeyRotationStatus) ([]string, string) {
+	resources := inventory.ResourcesVerifiedOnlyBy(status.OldKeyConfig, []string{status.NewKeyConfig})
+	if len(resources) == 0 {
+		return nil, ""
+	}
+	msg := ""
+	if status.Phase == apiv1alpha1.KeyRotationPhaseCompleted {
+		msg = fmt.Sprintf("%d resources are signed only by the retired keyConfig \"%s\"; they must be signed again with the new key", len(resources), status.OldKeyConfig)
+	} else {
+		msg = fmt.Sprintf("%d resources are signed only by the keyConfig \"%s\"; they must be signed again with the new key before %s", len(resources), status.OldKeyConfig, status.RetireTime.UTC().Format(time.RFC3339))
+	}
+	if len(resources) > maxReportedResourcesSignedByOldKey {
+		resources = resources[:maxReportedResourcesSignedByOldKey]
+	}
+	return resources, msg
+}
+
+// getKeyInventory loads the key inventory which the observer records from the events of the server
+func (r *IntegrityShieldReconciler) getKeyInventory(instance *apiv1alpha1.IntegrityShield) (common.KeyInventory, error) {
+	cm := &corev1.ConfigMap{}
+	err := r.Get(context.Background(), types.NamespacedName{Name: common.KeyInventoryConfigMapName, Namespace: instance.Namespace}, cm)
+	if errors.IsNotFound(err) {
+		return nil, fmt.Errorf("key inventory \"%s\" is not found; the observer must be enabled", common.KeyInventoryConfigMapName)
+	} else if err != nil {
+		return nil, err
+	}
+	inventory := common.KeyInventory{}
+	if err := json.Unmarshal([]byte(cm.Data[common.KeyInventoryDataKey]), &inventory); err != nil {
+		return nil, fmt.Errorf("failed to parse key inventory \"%s\"; %s", common.KeyInventoryConfigMapName, err.Error())
+	}
+	return inventory, nil
+}
+
+// requeueAfterForKeyRotation returns when the next reconcile should retire an old keyConfig or check the key inventory again,
+// or 0 if no rotation is in progress
+func requeueAfterForKeyRotation(instance *apiv1alpha1.IntegrityShield, now time.Time) time.Duration {
+	requeueAfter := time.Duration(0)
+	for _, status := range instance.Status.KeyRotations {
+		if status.OldKeyConfig == "" {
+			continue
+		}
+		d := keyInventoryCheckInterval
+		if status.Phase == apiv1alpha1.KeyRotationPhaseOverlapping && status.RetireTime != nil && status.RetireTime.Sub(now) < d {
+			d = status.RetireTime.Sub(now)
+			if d < time.Second {
+				d = time.Second
+			}
+		}
+		if requeueAfter == 0 || d < requeueAfter {
+			requeueAfter = d
+		}
+	}
+	return requeueAfter
+}
@@ -0,0 +1,96 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	apiv1alpha1 "github.com/IBM/integrity-enforcer/integrity-shield-operator/api/v1alpha1"
+	res "github.com/IBM/integrity-enforcer/integrity-shield-operator/resources"
+	sigconf "github.com/IBM/integrity-enforcer/shield/pkg/apis/signerconfig/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRotateSignerKey(t *testing.T) {
+	instance := &apiv1alpha1.IntegrityShield{}
+	instance.Spec.SignerConfig = &common.SignerConfig{
+		Policies: []common.SignerConfigCondition{{Namespaces: []string{"*"}, Signers: []string{"SampleSigner"}}},
+		Signers: []common.SignerCondition{
+			{Name: "SampleSigner", KeyConfig: "old-key", Subjects: []common.SubjectMatchPattern{{Email: "*"}}},
+		},
+	}
+	instance.Spec.KeyRotations = []apiv1alpha1.KeyRotation{
+		{Signer: "SampleSigner", NewKeyConfig: "new-key", OverlapPeriod: &metav1.Duration{Duration: time.Hour}},
+	}
+	start := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	// both keys are trusted during the overlap period
+	instance.Status.KeyRotations = newKeyRotationStatuses(instance, start)
+	status := instance.Status.KeyRotations[0]
+	if status.Phase != apiv1alpha1.KeyRotationPhaseOverlapping || status.OldKeyConfig != "old-key" || !status.RetireTime.Time.Equal(start.Add(time.Hour)) {
+		t.Errorf("unexpected status at the start: %v", status)
+	}
+	if keyConfigs := getSignerKeyConfigs(res.BuildSignerConfigForIShield(instance), "SampleSigner"); len(keyConfigs) != 2 {
+		t.Errorf("both keys must be trusted during the overlap period: %v", keyConfigs)
+	}
+	if d := requeueAfterForKeyRotation(instance, start.Add(55*time.Minute)); d != 5*time.Minute {
+		t.Errorf("reconcile must be requeued when the old key is retired: %s", d)
+	}
+
+	// the start time is kept over reconciles, then the old key is retired
+	instance.Status.KeyRotations = newKeyRotationStatuses(instance, start.Add(30*time.Minute))
+	if instance.Status.KeyRotations[0].Phase != apiv1alpha1.KeyRotationPhaseOverlapping {
+		t.Errorf("rotation must be overlapping: %v", instance.Status.KeyRotations[0])
+	}
+	instance.Status.KeyRotations = newKeyRotationStatuses(instance, start.Add(time.Hour))
+	if instance.Status.KeyRotations[0].Phase != apiv1alpha1.KeyRotationPhaseCompleted {
+		t.Errorf("rotation must be completed: %v", instance.Status.KeyRotations[0])
+	}
+	if keyConfigs := getSignerKeyConfigs(res.BuildSignerConfigForIShield(instance), "SampleSigner"); len(keyConfigs) != 1 || keyConfigs[0] != "new-key" {
+		t.Errorf("only the new key must be trusted after the rotation: %v", keyConfigs)
+	}
+
+	inventory := common.KeyInventory{
+		"ConfigMap/test-ns/signed-by-old-key":  {KeyConfigs: []string{"old-key"}},
+		"ConfigMap/test-ns/signed-by-new-key":  {KeyConfigs: []string{"new-key"}},
+		"ConfigMap/test-ns/signed-by-both-key": {KeyConfigs: []string{"old-key", "new-key"}},
+	}
+	resources, msg := getResourcesSignedByOldKey(inventory, instance.Status.KeyRotations[0])
+	if len(resources) != 1 || resources[0] != "ConfigMap/test-ns/signed-by-old-key" || msg == "" {
+		t.Errorf("unexpected resources signed by the old key: %v; %s", resources, msg)
+	}
+
+	// rotation is done if signerConfig already uses the new key
+	instance.Spec.SignerConfig.Signers[0].KeyConfig = "new-key"
+	instance.Status.KeyRotations = nil
+	statuses := newKeyRotationStatuses(instance, start)
+	if statuses[0].Phase != apiv1alpha1.KeyRotationPhaseCompleted || statuses[0].OldKeyConfig != "" {
+		t.Errorf("unexpected status for the signer with the new key: %v", statuses[0])
+	}
+}
+
+func getSignerKeyConfigs(signerConfig *sigconf.SignerConfig, signerName string) []string {
+	keyConfigs := []string{}
+	for _, signer := range signerConfig.Spec.Config.Signers {
+		if signer.Name == signerName {
+			keyConfigs = append(keyConfigs, signer.KeyConfig)
+		}
+	}
+	return keyConfigs
+}
@@ -0,0 +1,202 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	apiv1alpha1 "github.com/IBM/integrity-enforcer/integrity-shield-operator/api/v1alpha1"
+	cert "github.com/IBM/integrity-enforcer/integrity-shield-operator/cert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+/**********************************************
+
+				Key Source
+
+***********************************************/
+
+const (
+	jwksCertFileName             = "jwks.pem"
+	jwksURLAnnotationKey         = "integrityshield.io/jwksURL"
+	jwksFetchedAtAnnotationKey   = "integrityshield.io/jwksFetchedAt"
+	jwksSkippedKeysAnnotationKey = "integrityshield.io/jwksSkippedKeys"
+	jwksErrorAnnotationKey       = "integrityshield.io/jwksError"
+)
+
+// getKeySourceData returns files in the source of the key config, which are projected into the server
+func (r *IntegrityShieldReconciler) getKeySourceData(instance *apiv1alpha1.IntegrityShield, keyConf apiv1alpha1.KeyConfig) (map[string][]byte, *corev1.ConfigMap, error) {
+	ctx := context.Background()
+	if keyConf.JWKSURL != "" || keyConf.ConfigMapName != "" {
+		name := keyConf.ConfigMapName
+		if keyConf.JWKSURL != "" {
+			name = instance.GetJWKSConfigMapName(keyConf.Name)
+		}
+		cm := &corev1.ConfigMap{}
+		err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: instance.Namespace}, cm)
+		if err != nil {
+			return nil, nil, err
+		}
+		data := map[string][]byte{}
+		for k, v := range cm.Data {
+			data[k] = []byte(v)
+		}
+		for k, v := range cm.BinaryData {
+			data[k] = v
+		}
+		return data, cm, nil
+	}
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: keyConf.SecretName, Namespace: instance.Namespace}, secret)
+	if err != nil {
+		return nil, nil, err
+	}
+	return secret.Data, nil, nil
+}
+
+func getKeySourceName(keyConf apiv1alpha1.KeyConfig) string {
+	if keyConf.JWKSURL != "" {
+		return keyConf.JWKSURL
+	} else if keyConf.ConfigMapName != "" {
+		return keyConf.ConfigMapName
+	}
+	return keyConf.SecretName
+}
+
+// syncJWKSKeys fetches JWKS documents of key configs and stores their certificates in ConfigMaps which are mounted to the server.
+// A document is fetched again only after the refresh interval unless the URL is changed.
+// If fetching fails, the certificates fetched last time are kept.
+func (r *IntegrityShieldReconciler) syncJWKSKeys(instance *apiv1alpha1.IntegrityShield) error {
+	for _, keyConf := range instance.Spec.KeyConfig {
+		if keyConf.JWKSURL == "" {
+			continue
+		}
+		if err := r.syncJWKSConfigMap(instance, keyConf, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *IntegrityShieldReconciler) syncJWKSConfigMap(instance *apiv1alpha1.IntegrityShield, keyConf apiv1alpha1.KeyConfig, now time.Time) error {
+	ctx := context.Background()
+	name := instance.GetJWKSConfigMapName(keyConf.Name)
+	reqLogger := r.Log.WithValues(
+		"Instance.Name", instance.Name,
+		"ConfigMap.Name", name)
+
+	found := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: instance.Namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+	if exists && !isJWKSRefreshDue(found, keyConf.JWKSURL, now) {
+		return nil
+	}
+
+	cm := found.DeepCopy()
+	if !exists {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: instance.Namespace,
+				Labels: map[string]string{
+					"app":                          instance.Name,
+					"app.kubernetes.io/name":       instance.Name,
+					"app.kubernetes.io/managed-by": "operator",
+				},
+			},
+		}
+		if err := controllerutil.SetControllerReference(instance, cm, r.Scheme); err != nil {
+			return err
+		}
+	}
+	if cm.Annotations == nil {
+		cm.Annotations = map[string]string{}
+	}
+	cm.Annotations[jwksFetchedAtAnnotationKey] = now.UTC().Format(time.RFC3339)
+
+	jwks, fetchErr := cert.FetchJWKS(keyConf.JWKSURL, []byte(keyConf.JWKSCABundle))
+	if fetchErr != nil {
+		reqLogger.Error(fetchErr, fmt.Sprintf("Failed to fetch JWKS from %s", keyConf.JWKSURL))
+		if !exists {
+			// the server cannot start without this ConfigMap, so wait for the next reconcile
+			return nil
+		}
+		cm.Annotations[jwksErrorAnnotationKey] = fetchErr.Error()
+		if cm.Annotations[jwksURLAnnotationKey] != keyConf.JWKSURL {
+			// certificates of the previous URL must not be trusted anymore
+			cm.Data = map[string]string{jwksCertFileName: ""}
+			cm.Annotations[jwksURLAnnotationKey] = keyConf.JWKSURL
+		}
+	} else {
+		reqLogger.Info(fmt.Sprintf("%d certificates are fetched from JWKS %s", len(jwks.Certificates), keyConf.JWKSURL))
+		cm.Annotations[jwksURLAnnotationKey] = keyConf.JWKSURL
+		cm.Annotations[jwksSkippedKeysAnnotationKey] = strings.Join(jwks.Skipped, ",")
+		delete(cm.Annotations, jwksErrorAnnotationKey)
+		cm.Data = map[string]string{jwksCertFileName: string(jwks.PEM())}
+	}
+
+	if !exists {
+		return r.Create(ctx, cm)
+	}
+	return r.Update(ctx, cm)
+}
+
+func isJWKSRefreshDue(cm *corev1.ConfigMap, url string, now time.Time) bool {
+	if cm.Annotations[jwksURLAnnotationKey] != url {
+		return true
+	}
+	fetchedAt, err := time.Parse(time.RFC3339, cm.Annotations[jwksFetchedAtAnnotationKey])
+	if err != nil {
+		return true
+	}
+	return !now.Before(fetchedAt.Add(apiv1alpha1.DefaultJWKSRefreshInterval))
+}
+
+// getJWKSMessage describes the last fetch of the JWKS document for the key status
+func getJWKSMessage(cm *corev1.ConfigMap) string {
+	if cm == nil {
+		return ""
+	}
+	msgs := []string{}
+	if errMsg := cm.Annotations[jwksErrorAnnotationKey]; errMsg != "" {
+		msgs = append(msgs, fmt.Sprintf("failed to refresh JWKS at %s; %s", cm.Annotations[jwksFetchedAtAnnotationKey], errMsg))
+	}
+	if skipped := cm.Annotations[jwksSkippedKeysAnnotationKey]; skipped != "" {
+		msgs = append(msgs, fmt.Sprintf("keys without x5c certificate are not loaded: %s", skipped))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// requeueAfterForJWKS returns the interval to refresh JWKS documents, or 0 if no key config uses JWKS
+func requeueAfterForJWKS(instance *apiv1alpha1.IntegrityShield) time.Duration {
+	for _, keyConf := range instance.Spec.KeyConfig {
+		if keyConf.JWKSURL != "" {
+			return apiv1alpha1.DefaultJWKSRefreshInterval
+		}
+	}
+	return 0
+}
@@ -0,0 +1,52 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	apiv1alpha1 "github.com/IBM/integrity-enforcer/integrity-shield-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestIsJWKSRefreshDue(t *testing.T) {
+	url := "https://example.com/jwks.json"
+	now := time.Now()
+	cm := &corev1.ConfigMap{}
+	cm.Annotations = map[string]string{
+		jwksURLAnnotationKey:       url,
+		jwksFetchedAtAnnotationKey: now.Add(-time.Minute).UTC().Format(time.RFC3339),
+	}
+	if isJWKSRefreshDue(cm, url, now) {
+		t.Errorf("JWKS must not be fetched again within the refresh interval")
+	}
+	if !isJWKSRefreshDue(cm, "https://example.com/other.json", now) {
+		t.Errorf("JWKS must be fetched when the URL is changed")
+	}
+	if !isJWKSRefreshDue(cm, url, now.Add(apiv1alpha1.DefaultJWKSRefreshInterval)) {
+		t.Errorf("JWKS must be fetched after the refresh interval")
+	}
+
+	cm.Annotations[jwksErrorAnnotationKey] = "status code 503"
+	cm.Annotations[jwksSkippedKeysAnnotationKey] = "kid-1,kid-2"
+	msg := getJWKSMessage(cm)
+	if !strings.Contains(msg, "status code 503") || !strings.Contains(msg, "kid-1,kid-2") {
+		t.Errorf("fetch error and skipped keys must be reported: %s", msg)
+	}
+}
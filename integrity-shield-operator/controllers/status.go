@@ -0,0 +1,305 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	apiv1alpha1 "github.com/IBM/integrity-enforcer/integrity-shield-operator/api/v1alpha1"
+	cert "github.com/IBM/integrity-enforcer/integrity-shield-operator/cert"
+	res "github.com/IBM/integrity-enforcer/integrity-shield-operator/resources"
+	ec "github.com/IBM/integrity-enforcer/shield/pkg/apis/shieldconfig/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	iec "github.com/IBM/integrity-enforcer/shield/pkg/shield/config"
+	"golang.org/x/crypto/openpgp"
+	admregv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+/**********************************************
+
+				Status
+
+***********************************************/
+
+// updateStatus reports conditions of the components, loaded keys and the active mode on the CR status.
+// The status is updated only when it is changed, so that the update does not trigger reconcile endlessly.
+func (r *IntegrityShieldReconciler) updateStatus(instance *apiv1alpha1.IntegrityShield) error {
+	ctx := context.Background()
+	found := &apiv1alpha1.IntegrityShield{}
+	err := r.Get(ctx, types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}, found)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	status := found.Status.DeepCopy()
+	status.ObservedGeneration = found.Generation
+
+	keyringCond, keys := r.checkKeyring(instance)
+	certCond, certStatus := r.checkCert(instance)
+	conditions := []apiv1alpha1.IntegrityShieldCondition{
+		r.checkIsolation(instance),
+		checkSpec(instance),
+		r.checkConfig(instance),
+		keyringCond,
+		certCond,
+		r.checkServer(instance),
+		r.checkWebhook(instance),
+	}
+	for _, cond := range conditions {
+		cond.ObservedGeneration = found.Generation
+		status.SetCondition(cond)
+	}
+	status.Keys = keys
+	status.KeyRotations = instance.Status.KeyRotations
+	status.Cert = certStatus
+	status.Mode = getActiveMode(instance, status)
+	status.Uninstall = r.buildUninstallReport(instance)
+
+	if equality.Semantic.DeepEqual(found.Status, *status) {
+		return nil
+	}
+	found.Status = *status
+	return r.Status().Update(ctx, found)
+}
+
+// getActiveMode returns the ShieldConfig mode only if requests are actually verified by the webhook
+func getActiveMode(instance *apiv1alpha1.IntegrityShield, status *apiv1alpha1.IntegrityShieldStatus) string {
+	if !status.IsConditionTrue(apiv1alpha1.ConditionServerAvailable) || !status.IsConditionTrue(apiv1alpha1.ConditionWebhookConfigured) {
+		return apiv1alpha1.ModeInactive
+	}
+	mode := iec.EnforceMode
+	if instance.Spec.ShieldConfig != nil && instance.Spec.ShieldConfig.Mode != iec.UnknownMode {
+		mode = instance.Spec.ShieldConfig.Mode
+	}
+	return string(mode)
+}
+
+func newCondition(condType apiv1alpha1.IntegrityShieldConditionType, ok bool, reason, msg string) apiv1alpha1.IntegrityShieldCondition {
+	status := corev1.ConditionFalse
+	if ok {
+		status = corev1.ConditionTrue
+	}
+	return apiv1alpha1.IntegrityShieldCondition{
+		Type:    condType,
+		Status:  status,
+		Reason:  reason,
+		Message: msg,
+	}
+}
+
+// checkSpec reports validation errors of the CR spec, which stop reconciling
+func checkSpec(instance *apiv1alpha1.IntegrityShield) apiv1alpha1.IntegrityShieldCondition {
+	condType := apiv1alpha1.ConditionSpecValid
+	if errs := instance.ValidateSpec(); len(errs) > 0 {
+		return newCondition(condType, false, "Invalid", errs.ToAggregate().Error())
+	}
+	return newCondition(condType, true, "Valid", "spec passes validation")
+}
+
+// checkConfig validates the ShieldConfig CR which is actually loaded by the server
+func (r *IntegrityShieldReconciler) checkConfig(instance *apiv1alpha1.IntegrityShield) apiv1alpha1.IntegrityShieldCondition {
+	condType := apiv1alpha1.ConditionConfigValid
+	found := &ec.ShieldConfig{}
+	err := r.Get(context.Background(), types.NamespacedName{Name: instance.GetShieldConfigCRName(), Namespace: instance.Namespace}, found)
+	if err != nil {
+		return newCondition(condType, false, "NotFound", fmt.Sprintf("failed to get ShieldConfig \"%s\"; %s", instance.GetShieldConfigCRName(), err.Error()))
+	}
+	if found.Spec.ShieldConfig == nil {
+		return newCondition(condType, false, "Invalid", "ShieldConfig is empty")
+	}
+	if err := found.Spec.ShieldConfig.Validate(); err != nil {
+		return newCondition(condType, false, "Invalid", err.Error())
+	}
+	return newCondition(condType, true, "Valid", fmt.Sprintf("ShieldConfig \"%s\" is valid", found.Name))
+}
+
+// checkKeyring confirms that every keyring secret contains verification keys, and returns their fingerprints
+func (r *IntegrityShieldReconciler) checkKeyring(instance *apiv1alpha1.IntegrityShield) (apiv1alpha1.IntegrityShieldCondition, []apiv1alpha1.KeyStatus) {
+	condType := apiv1alpha1.ConditionKeyringReady
+	keys := []apiv1alpha1.KeyStatus{}
+	if len(instance.Spec.KeyConfig) == 0 {
+		return newCondition(condType, false, "NoKeyConfig", "no keyConfig is specified"), keys
+	}
+	failed := []string{}
+	keyCount := 0
+	for _, keyConf := range instance.Spec.KeyConfig {
+		sigType := keyConf.GetSignatureType()
+		keyStatus := apiv1alpha1.KeyStatus{Name: keyConf.Name, SecretName: keyConf.SecretName, ConfigMapName: keyConf.ConfigMapName, JWKSURL: keyConf.JWKSURL, SignatureType: sigType}
+		sourceName := getKeySourceName(keyConf)
+
+		data, cm, err := r.getKeySourceData(instance, keyConf)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", sourceName, err.Error()))
+			keys = append(keys, keyStatus)
+			continue
+		}
+		if keyConf.JWKSURL != "" {
+			keyStatus.Message = getJWKSMessage(cm)
+		}
+		fingerprints, err := getKeyFingerprints(data, sigType, keyConf.GetKeyFiles())
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", sourceName, err.Error()))
+		} else if len(fingerprints) == 0 {
+			failed = append(failed, fmt.Sprintf("%s: no key found", sourceName))
+		}
+		keyStatus.Fingerprints = fingerprints
+		keyCount += len(fingerprints)
+		keys = append(keys, keyStatus)
+	}
+	if len(failed) > 0 {
+		return newCondition(condType, false, "KeyNotLoaded", fmt.Sprintf("failed to load verification keys; %s", strings.Join(failed, "; "))), keys
+	}
+	return newCondition(condType, true, "KeyLoaded", fmt.Sprintf("%d keys are loaded from %d key sources", keyCount, len(instance.Spec.KeyConfig))), keys
+}
+
+// getKeyFingerprints returns pgp key fingerprints or sha256 digests of x509 certificates in the files of the key source.
+// If fileNames is nil, all .crt/.pem files are loaded (x509 only). The format is the same as the one reported by the server.
+func getKeyFingerprints(data map[string][]byte, sigType common.SignatureType, fileNames []string) ([]string, error) {
+	fingerprints := []string{}
+	if sigType == common.SignatureTypePGP {
+		if len(fileNames) == 0 {
+			fileNames = []string{apiv1alpha1.DefaultKeyringFilename}
+		}
+		for _, fileName := range fileNames {
+			keyRingBytes, ok := data[fileName]
+			if !ok {
+				return nil, fmt.Errorf("\"%s\" is not found in the key source", fileName)
+			}
+			keyRing, err := openpgp.ReadKeyRing(bytes.NewReader(keyRingBytes))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read keyring \"%s\"; %s", fileName, err.Error())
+			}
+			for _, ent := range keyRing {
+				if ent.PrimaryKey != nil {
+					fingerprints = append(fingerprints, fmt.Sprintf("%X", ent.PrimaryKey.Fingerprint))
+				}
+			}
+		}
+	} else if sigType == common.SignatureTypeX509 {
+		names := []string{}
+		if len(fileNames) > 0 {
+			for _, name := range fileNames {
+				if _, ok := data[name]; !ok {
+					return nil, fmt.Errorf("\"%s\" is not found in the key source", name)
+				}
+				names = append(names, name)
+			}
+		} else {
+			for name := range data {
+				if path.Ext(name) == ".crt" || path.Ext(name) == ".pem" {
+					names = append(names, name)
+				}
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			certs, err := cert.ParseCertificates(data[name])
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse \"%s\"; %s", name, err.Error())
+			}
+			for _, c := range certs {
+				fingerprints = append(fingerprints, fmt.Sprintf("%X", sha256.Sum256(c.Raw)))
+			}
+		}
+	} else {
+		return nil, fmt.Errorf("unknown signature type \"%s\"", sigType)
+	}
+	return fingerprints, nil
+}
+
+// checkCert confirms that the webhook server certificate is valid now, and returns its expiry and rotation state
+func (r *IntegrityShieldReconciler) checkCert(instance *apiv1alpha1.IntegrityShield) (apiv1alpha1.IntegrityShieldCondition, *apiv1alpha1.CertStatus) {
+	condType := apiv1alpha1.ConditionCertReady
+	secret := &corev1.Secret{}
+	err := r.Get(context.Background(), types.NamespacedName{Name: instance.GetWebhookServerTlsSecretName(), Namespace: instance.Namespace}, secret)
+	if err != nil {
+		return newCondition(condType, false, "NotFound", fmt.Sprintf("failed to get TLS secret \"%s\"; %s", instance.GetWebhookServerTlsSecretName(), err.Error())), nil
+	}
+	if errs := instance.ValidateCertRotationPeriods(certRotationPath); len(errs) > 0 && !instance.Spec.WebhookCertRotation.Disabled {
+		return newCondition(condType, false, "InvalidRotationPeriods", errs.ToAggregate().Error()), getCertStatus(secret)
+	}
+	return checkCertSecret(secret, time.Now()), getCertStatus(secret)
+}
+
+func checkCertSecret(secret *corev1.Secret, now time.Time) apiv1alpha1.IntegrityShieldCondition {
+	condType := apiv1alpha1.ConditionCertReady
+	certs, err := cert.ParseCertificates(secret.Data["tls.crt"])
+	if err != nil || len(certs) == 0 {
+		return newCondition(condType, false, "Invalid", "TLS secret does not contain a valid certificate")
+	}
+	serverCert := certs[0]
+	if now.Before(serverCert.NotBefore) {
+		return newCondition(condType, false, "NotYetValid", fmt.Sprintf("certificate is not valid before %s", serverCert.NotBefore.Format(time.RFC3339)))
+	}
+	if now.After(serverCert.NotAfter) {
+		return newCondition(condType, false, "Expired", fmt.Sprintf("certificate expired at %s", serverCert.NotAfter.Format(time.RFC3339)))
+	}
+	return newCondition(condType, true, "Valid", fmt.Sprintf("certificate is valid until %s", serverCert.NotAfter.Format(time.RFC3339)))
+}
+
+// checkServer confirms that the webhook server has available replicas
+func (r *IntegrityShieldReconciler) checkServer(instance *apiv1alpha1.IntegrityShield) apiv1alpha1.IntegrityShieldCondition {
+	condType := apiv1alpha1.ConditionServerAvailable
+	found := &appsv1.Deployment{}
+	err := r.Get(context.Background(), types.NamespacedName{Name: instance.GetIShieldServerDeploymentName(), Namespace: instance.Namespace}, found)
+	if err != nil {
+		return newCondition(condType, false, "NotFound", fmt.Sprintf("failed to get Deployment \"%s\"; %s", instance.GetIShieldServerDeploymentName(), err.Error()))
+	}
+	msg := fmt.Sprintf("%d/%d replicas are available", found.Status.AvailableReplicas, found.Status.Replicas)
+	if found.Status.AvailableReplicas == 0 {
+		return newCondition(condType, false, "Unavailable", msg)
+	}
+	return newCondition(condType, true, "Available", msg)
+}
+
+// checkWebhook confirms that the webhook configuration trusts the CA of the current TLS secret
+func (r *IntegrityShieldReconciler) checkWebhook(instance *apiv1alpha1.IntegrityShield) apiv1alpha1.IntegrityShieldCondition {
+	condType := apiv1alpha1.ConditionWebhookConfigured
+	ctx := context.Background()
+	webhookName := res.BuildMutatingWebhookConfigurationForIShield(instance).Name
+	found := &admregv1.MutatingWebhookConfiguration{}
+	err := r.Get(ctx, types.NamespacedName{Name: webhookName}, found)
+	if err != nil {
+		return newCondition(condType, false, "NotFound", fmt.Sprintf("failed to get MutatingWebhookConfiguration \"%s\"; %s", webhookName, err.Error()))
+	}
+	secret := &corev1.Secret{}
+	err = r.Get(ctx, types.NamespacedName{Name: instance.GetWebhookServerTlsSecretName(), Namespace: instance.Namespace}, secret)
+	if err != nil {
+		return newCondition(condType, false, "CABundleUnknown", fmt.Sprintf("failed to get TLS secret \"%s\"; %s", instance.GetWebhookServerTlsSecretName(), err.Error()))
+	}
+	for _, webhook := range found.Webhooks {
+		if !bytes.Equal(webhook.ClientConfig.CABundle, secret.Data["ca.crt"]) {
+			return newCondition(condType, false, "CABundleMismatch", fmt.Sprintf("webhook \"%s\" does not trust the CA of the current certificate", webhook.Name))
+		}
+	}
+	return newCondition(condType, true, "Configured", fmt.Sprintf("MutatingWebhookConfiguration \"%s\" is configured", webhookName))
+}
@@ -0,0 +1,145 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package controllers
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	apiv1alpha1 "github.com/IBM/integrity-enforcer/integrity-shield-operator/api/v1alpha1"
+	cert "github.com/IBM/integrity-enforcer/integrity-shield-operator/cert"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const testKeyConfigDir = "../../shield/pkg/shield/testdata/sample-signer-keyconfig"
+
+func TestKeyFingerprints(t *testing.T) {
+	pubring, err := ioutil.ReadFile(testKeyConfigDir + "/pgp/pubring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := &corev1.Secret{Data: map[string][]byte{apiv1alpha1.DefaultKeyringFilename: pubring}}
+	fingerprints, err := getKeyFingerprints(secret.Data, common.SignatureTypePGP, nil)
+	if err != nil || len(fingerprints) == 0 {
+		t.Errorf("failed to get pgp key fingerprints; %v", err)
+	}
+
+	caCert, err := ioutil.ReadFile(testKeyConfigDir + "/x509/ca.crt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret = &corev1.Secret{Data: map[string][]byte{"ca.crt": caCert, "README": []byte("not a cert")}}
+	fingerprints, err = getKeyFingerprints(secret.Data, common.SignatureTypeX509, nil)
+	if err != nil || len(fingerprints) != 1 {
+		t.Errorf("expected 1 x509 fingerprint, but got %v; %v", fingerprints, err)
+	}
+
+	secret = &corev1.Secret{Data: map[string][]byte{"other.gpg": pubring}}
+	if _, err = getKeyFingerprints(secret.Data, common.SignatureTypePGP, nil); err == nil {
+		t.Errorf("missing keyring file must be reported as an error")
+	}
+}
+
+func TestCheckCertSecret(t *testing.T) {
+	_, tlsKey, tlsCert, err := cert.GenerateCert("test-svc", "test-ns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := &corev1.Secret{Data: map[string][]byte{"tls.crt": tlsCert, "tls.key": tlsKey}}
+
+	if cond := checkCertSecret(secret, time.Now()); cond.Status != corev1.ConditionTrue {
+		t.Errorf("certificate is expected to be valid: %s", cond.Message)
+	}
+	if cond := checkCertSecret(secret, time.Now().AddDate(100, 0, 0)); cond.Status != corev1.ConditionFalse || cond.Reason != "Expired" {
+		t.Errorf("certificate is expected to be expired: %s", cond.Message)
+	}
+	if cond := checkCertSecret(&corev1.Secret{}, time.Now()); cond.Status != corev1.ConditionFalse {
+		t.Errorf("empty secret is expected to be invalid")
+	}
+}
+
+func TestCheckSpec(t *testing.T) {
+	instance := &apiv1alpha1.IntegrityShield{}
+	if cond := checkSpec(instance); cond.Status != corev1.ConditionTrue {
+		t.Errorf("empty spec is expected to be valid: %s", cond.Message)
+	}
+	invalidTimeout := int32(60)
+	instance.Spec.WebhookTimeoutSeconds = &invalidTimeout
+	if cond := checkSpec(instance); cond.Status != corev1.ConditionFalse || cond.Reason != "Invalid" {
+		t.Errorf("spec with an invalid timeout is expected to be reported: %s", cond.Message)
+	}
+}
+
+func TestSetCondition(t *testing.T) {
+	status := &apiv1alpha1.IntegrityShieldStatus{}
+	status.SetCondition(newCondition(apiv1alpha1.ConditionServerAvailable, false, "Unavailable", "0/1 replicas are available"))
+	cond := status.GetCondition(apiv1alpha1.ConditionServerAvailable)
+	if cond == nil || cond.LastTransitionTime.IsZero() {
+		t.Fatalf("condition is not added")
+	}
+	transitionTime := cond.LastTransitionTime
+
+	status.SetCondition(newCondition(apiv1alpha1.ConditionServerAvailable, false, "Unavailable", "0/2 replicas are available"))
+	cond = status.GetCondition(apiv1alpha1.ConditionServerAvailable)
+	if len(status.Conditions) != 1 || !cond.LastTransitionTime.Equal(&transitionTime) || cond.Message != "0/2 replicas are available" {
+		t.Errorf("condition must be updated without changing transition time: %v", status.Conditions)
+	}
+
+	status.SetCondition(newCondition(apiv1alpha1.ConditionServerAvailable, true, "Available", "1/1 replicas are available"))
+	if !status.IsConditionTrue(apiv1alpha1.ConditionServerAvailable) {
+		t.Errorf("condition status is not updated: %v", status.Conditions)
+	}
+	if getActiveMode(&apiv1alpha1.IntegrityShield{}, status) != apiv1alpha1.ModeInactive {
+		t.Errorf("mode must be inactive while the webhook is not configured")
+	}
+	status.SetCondition(newCondition(apiv1alpha1.ConditionWebhookConfigured, true, "Configured", ""))
+	if mode := getActiveMode(&apiv1alpha1.IntegrityShield{}, status); mode != "enforce" {
+		t.Errorf("expected enforce mode, but got %s", mode)
+	}
+}
+
+func TestKeyFingerprintsWithFileNames(t *testing.T) {
+	pubring, err := ioutil.ReadFile(testKeyConfigDir + "/pgp/pubring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string][]byte{"team-a.gpg": pubring, "team-b.gpg": pubring}
+	fingerprints, err := getKeyFingerprints(data, common.SignatureTypePGP, []string{"team-a.gpg", "team-b.gpg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	single, _ := getKeyFingerprints(data, common.SignatureTypePGP, []string{"team-a.gpg"})
+	if len(fingerprints) != 2*len(single) {
+		t.Errorf("keys in all files must be loaded: %v", fingerprints)
+	}
+
+	caCert, err := ioutil.ReadFile(testKeyConfigDir + "/x509/ca.crt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle := append(append([]byte{}, caCert...), caCert...)
+	data = map[string][]byte{"bundle.pem": bundle, "other.crt": caCert}
+	fingerprints, err = getKeyFingerprints(data, common.SignatureTypeX509, []string{"bundle.pem"})
+	if err != nil || len(fingerprints) != 2 {
+		t.Errorf("expected 2 x509 fingerprints in the bundle, but got %v; %v", fingerprints, err)
+	}
+	if _, err = getKeyFingerprints(data, common.SignatureTypeX509, []string{"missing.pem"}); err == nil {
+		t.Errorf("missing file must be reported as an error")
+	}
+}
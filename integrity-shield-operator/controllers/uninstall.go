@@ -0,0 +1,268 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apiv1alpha1 "github.com/IBM/integrity-enforcer/integrity-shield-operator/api/v1alpha1"
+	res "github.com/IBM/integrity-enforcer/integrity-shield-operator/resources"
+	admregv1 "k8s.io/api/admissionregistration/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+/**********************************************
+
+				Uninstall
+
+***********************************************/
+
+type crdUninstallTarget struct {
+	crd    *extv1.CustomResourceDefinition
+	policy apiv1alpha1.UninstallPolicy
+}
+
+// getCRDUninstallTargets returns iShield CRDs with the uninstall policy applied to each of them
+func getCRDUninstallTargets(instance *apiv1alpha1.IntegrityShield) []crdUninstallTarget {
+	dataPolicy := instance.GetDataUninstallPolicy()
+	crdPolicy := instance.GetCRDUninstallPolicy()
+	targets := []crdUninstallTarget{}
+	enabledPulgins := instance.Spec.ShieldConfig.GetEnabledPlugins()
+	if enabledPulgins["helm"] {
+		targets = append(targets, crdUninstallTarget{crd: res.BuildHelmReleaseMetadataCRD(instance), policy: dataPolicy})
+	}
+	targets = append(targets,
+		crdUninstallTarget{crd: res.BuildIntegrityShieldReportCRD(instance), policy: dataPolicy},
+		crdUninstallTarget{crd: res.BuildResourceSigningProfileCRD(instance), policy: dataPolicy},
+		crdUninstallTarget{crd: res.BuildResourceSignatureCRD(instance), policy: dataPolicy},
+		crdUninstallTarget{crd: res.BuildSignerConfigCRD(instance), policy: crdPolicy},
+		crdUninstallTarget{crd: res.BuildShieldConfigCRD(instance), policy: crdPolicy},
+	)
+	return targets
+}
+
+// newUninstallReport lists resources which are removed or retained when the CR is deleted.
+// counts is the number of custom resources in each CRD, which are deleted together with the CRD.
+// CRDs shared with other instances are always retained.
+func newUninstallReport(instance *apiv1alpha1.IntegrityShield, counts map[string]int, shared bool) *apiv1alpha1.UninstallReport {
+	report := &apiv1alpha1.UninstallReport{
+		Remove: []string{
+			fmt.Sprintf("ValidatingWebhookConfiguration/%s", instance.GetValidatingWebhookConfigName()),
+			fmt.Sprintf("MutatingWebhookConfiguration/%s", instance.GetWebhookConfigName()),
+			fmt.Sprintf("Deployment/%s/%s", instance.Namespace, instance.GetIShieldServerDeploymentName()),
+			fmt.Sprintf("PodSecurityPolicy/%s", instance.GetPodSecurityPolicyName()),
+		},
+	}
+	if !instance.Spec.Security.AutoIShieldAdminCreationDisabled {
+		report.Remove = append(report.Remove,
+			fmt.Sprintf("ClusterRoleBinding/%s", instance.GetIShieldAdminClusterRoleBindingName()),
+			fmt.Sprintf("ClusterRole/%s", instance.GetIShieldAdminClusterRoleName()),
+		)
+	}
+	report.Remove = append(report.Remove,
+		fmt.Sprintf("ClusterRoleBinding/%s", instance.GetClusterRoleBindingName()),
+		fmt.Sprintf("ClusterRole/%s", instance.GetClusterRoleName()),
+	)
+	for _, target := range getCRDUninstallTargets(instance) {
+		item := fmt.Sprintf("CustomResourceDefinition/%s (%d %s)", target.crd.Name, counts[target.crd.Name], target.crd.Spec.Names.Kind)
+		if shared {
+			report.Retain = append(report.Retain, fmt.Sprintf("%s shared with other instances", item))
+		} else if target.policy == apiv1alpha1.UninstallPolicyDelete {
+			report.Remove = append(report.Remove, item)
+		} else {
+			report.Retain = append(report.Retain, item)
+		}
+	}
+	return report
+}
+
+func (r *IntegrityShieldReconciler) buildUninstallReport(instance *apiv1alpha1.IntegrityShield) *apiv1alpha1.UninstallReport {
+	counts := map[string]int{}
+	for _, target := range getCRDUninstallTargets(instance) {
+		counts[target.crd.Name] = r.countCustomResources(target.crd)
+	}
+	onlyInstance, _ := r.isOnlyInstance(instance)
+	return newUninstallReport(instance, counts, !onlyInstance)
+}
+
+// countCustomResources returns the number of custom resources of the CRD in all namespaces, or 0 if the CRD does not exist
+func (r *IntegrityShieldReconciler) countCustomResources(crd *extv1.CustomResourceDefinition) int {
+	version := ""
+	for _, v := range crd.Spec.Versions {
+		if v.Storage {
+			version = v.Name
+		}
+	}
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(schema.GroupVersionKind{Group: crd.Spec.Group, Version: version, Kind: crd.Spec.Names.ListKind})
+	if err := r.List(context.Background(), list); err != nil {
+		return 0
+	}
+	return len(list.Items)
+}
+
+// uninstall removes the webhooks before any other resources so that the API server does not call the server being removed,
+// then deletes cluster scope resources and the CRDs according to the uninstall policy.
+// (In Kubernetes 1.20 and later, a garbage collector ignores cluster scope children even if their owner is deleted)
+func (r *IntegrityShieldReconciler) uninstall(instance *apiv1alpha1.IntegrityShield) (ctrl.Result, error) {
+	reqLogger := r.Log.WithValues("Instance.Name", instance.Name)
+
+	report := r.buildUninstallReport(instance)
+	reqLogger.Info("Uninstalling IntegrityShield", "Remove", report.Remove, "Retain", report.Retain)
+
+	var err error
+	_, err = r.deleteValidatingWebhook(instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	_, err = r.deleteWebhook(instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	removed, err := r.isWebhookRemoved(instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !removed {
+		reqLogger.Info("Waiting for the webhooks to be removed")
+		return ctrl.Result{Requeue: true, RequeueAfter: time.Second * 1}, nil
+	}
+
+	_, err = r.deleteWebhookDeployment(instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	_, err = r.deletePodSecurityPolicy(instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !instance.Spec.Security.AutoIShieldAdminCreationDisabled {
+		_, err = r.deleteClusterRoleBindingForIShieldAdmin(instance)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		_, err = r.deleteClusterRoleForIShieldAdmin(instance)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	_, err = r.deleteClusterRoleBindingForIShield(instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	_, err = r.deleteClusterRoleForIShield(instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	onlyInstance, err := r.isOnlyInstance(instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	for _, target := range getCRDUninstallTargets(instance) {
+		if !onlyInstance {
+			err = r.releaseCRD(instance, target.crd)
+		} else if target.policy == apiv1alpha1.UninstallPolicyDelete {
+			_, err = r.deleteCRD(instance, target.crd)
+		} else {
+			err = r.retainCRD(instance, target.crd)
+		}
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+func (r *IntegrityShieldReconciler) isWebhookRemoved(instance *apiv1alpha1.IntegrityShield) (bool, error) {
+	ctx := context.Background()
+	err := r.Get(ctx, types.NamespacedName{Name: instance.GetValidatingWebhookConfigName()}, &admregv1.ValidatingWebhookConfiguration{})
+	if err == nil || !errors.IsNotFound(err) {
+		return false, client.IgnoreNotFound(err)
+	}
+	err = r.Get(ctx, types.NamespacedName{Name: instance.GetWebhookConfigName()}, &admregv1.MutatingWebhookConfiguration{})
+	if err == nil || !errors.IsNotFound(err) {
+		return false, client.IgnoreNotFound(err)
+	}
+	return true, nil
+}
+
+// retainCRD keeps the CRD and its custom resources, but stops the conversion webhook
+// because the server which serves it is removed
+func (r *IntegrityShieldReconciler) retainCRD(instance *apiv1alpha1.IntegrityShield, expected *extv1.CustomResourceDefinition) error {
+	ctx := context.Background()
+	found := &extv1.CustomResourceDefinition{}
+
+	reqLogger := r.Log.WithValues(
+		"Instance.Name", instance.Name,
+		"CustomResourceDefinition.Name", expected.Name)
+
+	err := r.Get(ctx, types.NamespacedName{Name: expected.Name}, found)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if found.Spec.Conversion == nil || found.Spec.Conversion.Strategy == extv1.NoneConverter {
+		return nil
+	}
+	reqLogger.Info(fmt.Sprintf("Retaining the IShield CustomResourceDefinition %s without conversion webhook", expected.Name))
+	found.Spec.Conversion = &extv1.CustomResourceConversion{Strategy: extv1.NoneConverter}
+	return r.Update(ctx, found)
+}
+
+// releaseCRD removes the instance from the owners of a CRD shared with other instances, so that another instance adopts it.
+// The conversion webhook is disabled until then because it points to the server of this instance.
+func (r *IntegrityShieldReconciler) releaseCRD(instance *apiv1alpha1.IntegrityShield, expected *extv1.CustomResourceDefinition) error {
+	ctx := context.Background()
+	found := &extv1.CustomResourceDefinition{}
+
+	reqLogger := r.Log.WithValues(
+		"Instance.Name", instance.Name,
+		"CustomResourceDefinition.Name", expected.Name)
+
+	err := r.Get(ctx, types.NamespacedName{Name: expected.Name}, found)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if owner := metav1.GetControllerOf(found); owner == nil || owner.UID != instance.UID {
+		return nil
+	}
+	ownerReferences := []metav1.OwnerReference{}
+	for _, ref := range found.OwnerReferences {
+		if ref.UID != instance.UID {
+			ownerReferences = append(ownerReferences, ref)
+		}
+	}
+	found.OwnerReferences = ownerReferences
+	if found.Spec.Conversion != nil && found.Spec.Conversion.Strategy != extv1.NoneConverter {
+		found.Spec.Conversion = &extv1.CustomResourceConversion{Strategy: extv1.NoneConverter}
+	}
+	reqLogger.Info(fmt.Sprintf("Releasing the IShield CustomResourceDefinition %s shared with other instances", expected.Name))
+	return r.Update(ctx, found)
+}
@@ -0,0 +1,68 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	apiv1alpha1 "github.com/IBM/integrity-enforcer/integrity-shield-operator/api/v1alpha1"
+	res "github.com/IBM/integrity-enforcer/integrity-shield-operator/resources"
+)
+
+func TestUninstallReport(t *testing.T) {
+	instance := res.MergeDefaultIntegrityShieldCR(&apiv1alpha1.IntegrityShield{}, "../resources/default-ishield-cr.yaml")
+	rsCRDName := res.BuildResourceSignatureCRD(instance).Name
+	counts := map[string]int{rsCRDName: 3}
+
+	// ResourceSignatures are retained by default
+	report := newUninstallReport(instance, counts, false)
+	if !strings.HasPrefix(report.Remove[0], "ValidatingWebhookConfiguration/") || !strings.HasPrefix(report.Remove[1], "MutatingWebhookConfiguration/") {
+		t.Errorf("webhooks must be removed first: %v", report.Remove)
+	}
+	if !containsPrefix(report.Retain, "CustomResourceDefinition/"+rsCRDName+" (3 ") {
+		t.Errorf("ResourceSignature CRD must be retained: %v", report.Retain)
+	}
+	if !containsPrefix(report.Remove, "CustomResourceDefinition/"+instance.GetShieldConfigCRDName()) {
+		t.Errorf("ShieldConfig CRD must be removed: %v", report.Remove)
+	}
+
+	instance.Spec.Uninstall.DataPolicy = apiv1alpha1.UninstallPolicyDelete
+	instance.Spec.Uninstall.CRDPolicy = apiv1alpha1.UninstallPolicyRetain
+	report = newUninstallReport(instance, counts, false)
+	if !containsPrefix(report.Remove, "CustomResourceDefinition/"+rsCRDName+" (3 ") {
+		t.Errorf("ResourceSignature CRD must be removed: %v", report.Remove)
+	}
+	if !containsPrefix(report.Retain, "CustomResourceDefinition/"+instance.GetShieldConfigCRDName()) {
+		t.Errorf("ShieldConfig CRD must be retained: %v", report.Retain)
+	}
+
+	// CRDs shared with other instances are retained
+	report = newUninstallReport(instance, counts, true)
+	if containsPrefix(report.Remove, "CustomResourceDefinition/") || !containsPrefix(report.Retain, "CustomResourceDefinition/"+rsCRDName+" (3 ") {
+		t.Errorf("shared CRDs must be retained: %v, %v", report.Remove, report.Retain)
+	}
+}
+
+func containsPrefix(items []string, prefix string) bool {
+	for _, item := range items {
+		if strings.HasPrefix(item, prefix) {
+			return true
+		}
+	}
+	return false
+}
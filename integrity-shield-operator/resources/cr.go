@@ -65,24 +65,18 @@ func BuildShieldConfigForIShield(cr *apiv1alpha1.IntegrityShield, scheme *runtim
 	if len(ecc.Spec.ShieldConfig.KeyPathList) == 0 {
 		keyPathList := []string{}
 		for _, keyConf := range cr.Spec.KeyConfig {
-			sigType := keyConf.SignatureType
-			if sigType == common.SignatureTypeDefault {
-				sigType = common.SignatureTypePGP
-			}
+			sigType := keyConf.GetSignatureType()
 			if sigType == common.SignatureTypePGP {
-				fileName := keyConf.FileName
-				if fileName == "" {
-					fileName = apiv1alpha1.DefaultKeyringFilename
+				// specify .gpg file names in case of pgp
+				for _, fileName := range keyConf.GetKeyFiles() {
+					keyPath := fmt.Sprintf("/%s/%s/%s", keyConf.Name, sigType, fileName)
+					keyPathList = append(keyPathList, keyPath)
 				}
-				// specify .gpg file name in case of pgp --> change to dir name?
-				keyPath := fmt.Sprintf("/%s/%s/%s", keyConf.Name, sigType, fileName)
-				keyPathList = append(keyPathList, keyPath)
 			} else if sigType == common.SignatureTypeX509 {
 				// specify only mounted dir name in case of x509
 				keyPath := fmt.Sprintf("/%s/%s/", keyConf.Name, sigType)
 				keyPathList = append(keyPathList, keyPath)
 			}
-
 		}
 		ecc.Spec.ShieldConfig.KeyPathList = keyPathList
 	}
@@ -140,7 +134,7 @@ func BuildSignerConfigForIShield(cr *apiv1alpha1.IntegrityShield) *sigconf.Signe
 	var signerConfig *common.SignerConfig
 
 	if cr.Spec.SignerConfig != nil {
-		signerConfig = cr.Spec.SignerConfig
+		signerConfig = applyKeyRotations(cr.Spec.SignerConfig, cr.Status.KeyRotations)
 	} else {
 		signerConfig = &common.SignerConfig{
 			Policies: []common.SignerConfigCondition{
@@ -173,6 +167,35 @@ func BuildSignerConfigForIShield(cr *apiv1alpha1.IntegrityShield) *sigconf.Signe
 	return epcr
 }
 
+// applyKeyRotations trusts the new keyConfig of signers in rotation; the old keyConfig is kept only during the overlap period
+func applyKeyRotations(signerConfig *common.SignerConfig, rotations []apiv1alpha1.KeyRotationStatus) *common.SignerConfig {
+	if len(rotations) == 0 {
+		return signerConfig
+	}
+	rotated := signerConfig.DeepCopy()
+	rotated.Signers = []common.SignerCondition{}
+	for _, signer := range signerConfig.Signers {
+		var rotation *apiv1alpha1.KeyRotationStatus
+		for i := range rotations {
+			if rotations[i].Signer == signer.Name && rotations[i].OldKeyConfig == signer.KeyConfig && rotations[i].OldKeyConfig != "" {
+				rotation = &rotations[i]
+				break
+			}
+		}
+		if rotation == nil {
+			rotated.Signers = append(rotated.Signers, signer)
+			continue
+		}
+		if rotation.Phase == apiv1alpha1.KeyRotationPhaseOverlapping {
+			rotated.Signers = append(rotated.Signers, signer)
+		}
+		newSigner := signer
+		newSigner.KeyConfig = rotation.NewKeyConfig
+		rotated.Signers = append(rotated.Signers, newSigner)
+	}
+	return rotated
+}
+
 func BuildResourceSigningProfileForIShield(cr *apiv1alpha1.IntegrityShield, prof *apiv1alpha1.ProfileConfig) *rsp.ResourceSigningProfile {
 	rspfromcr := &rsp.ResourceSigningProfile{}
 	rspfromcr.Spec = *(prof.ResourceSigningProfileSpec)
@@ -1,58 +1,108 @@
-//
-// Copyright 2020 IBM Corporation
-//
-// Licensed under the Apache License, Version 2.0 (the "License");
-// you may not use this file except in compliance with the License.
-// You may obtain a copy of the License at
-//
-// http://www.apache.org/licenses/LICENSE-2.0
-//
-// Unless required by applicable law or agreed to in writing, software
-// distributed under the License is distributed on an "AS IS" BASIS,
-// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
-// See the License for the specific language governing permissions and
-// limitations under the License.
-//
 
 package resources
 
 import (
 	apiv1alpha1 "github.com/IBM/integrity-enforcer/integrity-shield-operator/api/v1alpha1"
-	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func buildCRD(name, namespace string, crdNames extv1.CustomResourceDefinitionNames) *extv1.CustomResourceDefinition {
-	xPreserve := true
+// the conversion between v1alpha1 (storage version) and v1beta1 is done by iShield server.
+// v1beta1 is not served unless it is enabled in CR, because the API server prefers v1beta1 once it is served,
+// and then kubectl, garbage collector and namespace deletion cannot read iShield resources while iShield server is down.
+const crdConversionPath = "/convert"
+
+var xPreserve = true
+
+// buildSchema returns the structural schema for a version;
+// spec is not pruned unless the properties of spec are specified
+func buildSchema(specProps map[string]extv1.JSONSchemaProps) *extv1.CustomResourceValidation {
+	spec := extv1.JSONSchemaProps{
+		Type:       "object",
+		Properties: specProps,
+	}
+	if specProps == nil {
+		spec.XPreserveUnknownFields = &xPreserve
+	}
+	return &extv1.CustomResourceValidation{
+		OpenAPIV3Schema: &extv1.JSONSchemaProps{
+			Type: "object",
+			Properties: map[string]extv1.JSONSchemaProps{
+				"apiVersion": {Type: "string"},
+				"kind":       {Type: "string"},
+				"metadata":   {Type: "object"},
+				"spec":       spec,
+				"status": {
+					Type:                   "object",
+					XPreserveUnknownFields: &xPreserve,
+				},
+			},
+		},
+	}
+}
+
+func objectArraySchema() extv1.JSONSchemaProps {
+	return extv1.JSONSchemaProps{
+		Type: "array",
+		Items: &extv1.JSONSchemaPropsOrArray{
+			Schema: &extv1.JSONSchemaProps{
+				Type:                   "object",
+				XPreserveUnknownFields: &xPreserve,
+			},
+		},
+	}
+}
+
+func buildCRD(cr *apiv1alpha1.IntegrityShield, name string, crdNames extv1.CustomResourceDefinitionNames, v1beta1SpecProps map[string]extv1.JSONSchemaProps) *extv1.CustomResourceDefinition {
 	newCRD := &extv1.CustomResourceDefinition{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "CustomResourceDefinition",
 			APIVersion: "apiextensions.k8s.io/v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: namespace,
+			Name: name,
 		},
 		Spec: extv1.CustomResourceDefinitionSpec{
 			Group: "apis.integrityshield.io",
-			//Version: "v1beta1",
 			Names: crdNames,
-			Scope: "Namespaced",
-			Validation: &extv1.CustomResourceValidation{
-				OpenAPIV3Schema: &extv1.JSONSchemaProps{
-					Type:                   "object",
-					XPreserveUnknownFields: &xPreserve,
-				},
-			},
+			Scope: extv1.NamespaceScoped,
 			Versions: []extv1.CustomResourceDefinitionVersion{
 				{
 					Name:    "v1alpha1",
 					Served:  true,
 					Storage: true,
+					Schema:  buildSchema(nil),
+				},
+				{
+					Name:    "v1beta1",
+					Served:  cr.Spec.V1beta1APIEnabled,
+					Storage: false,
+					Schema:  buildSchema(v1beta1SpecProps),
 				},
 			},
+			Conversion: &extv1.CustomResourceConversion{
+				Strategy: extv1.NoneConverter,
+			},
 		},
 	}
+	if cr.Spec.V1beta1APIEnabled {
+		conversionPath := crdConversionPath
+		port := int32(443)
+		newCRD.Spec.Conversion = &extv1.CustomResourceConversion{
+			Strategy: extv1.WebhookConverter,
+			Webhook: &extv1.WebhookConversion{
+				ClientConfig: &extv1.WebhookClientConfig{
+					Service: &extv1.ServiceReference{
+						Name:      cr.GetWebhookServiceName(),
+						Namespace: cr.Namespace,
+						Path:      &conversionPath,
+						Port:      &port,
+					},
+				},
+				ConversionReviewVersions: []string{"v1beta1"},
+			},
+		}
+	}
 	return newCRD
 }
 
@@ -64,7 +114,13 @@ func BuildSignerConfigCRD(cr *apiv1alpha1.IntegrityShield) *extv1.CustomResource
 		ListKind: "SignerConfigList",
 		Singular: "signerconfig",
 	}
-	return buildCRD(cr.GetSignerConfigCRDName(), cr.Namespace, crdNames)
+	specProps := map[string]extv1.JSONSchemaProps{
+		"config": {
+			Type:                   "object",
+			XPreserveUnknownFields: &xPreserve,
+		},
+	}
+	return buildCRD(cr, cr.GetSignerConfigCRDName(), crdNames, specProps)
 }
 
 //shield config crd
@@ -76,7 +132,13 @@ func BuildShieldConfigCRD(cr *apiv1alpha1.IntegrityShield) *extv1.CustomResource
 		Singular:   "shieldconfig",
 		ShortNames: []string{"econf", "econfs"},
 	}
-	return buildCRD(cr.GetShieldConfigCRDName(), cr.Namespace, crdNames)
+	specProps := map[string]extv1.JSONSchemaProps{
+		"shieldConfig": {
+			Type:                   "object",
+			XPreserveUnknownFields: &xPreserve,
+		},
+	}
+	return buildCRD(cr, cr.GetShieldConfigCRDName(), crdNames, specProps)
 }
 
 //resource signature crd
@@ -88,7 +150,27 @@ func BuildResourceSignatureCRD(cr *apiv1alpha1.IntegrityShield) *extv1.CustomRes
 		Singular:   "resourcesignature",
 		ShortNames: []string{"rsig", "rsigs"},
 	}
-	return buildCRD(cr.GetResourceSignatureCRDName(), cr.Namespace, crdNames)
+	specProps := map[string]extv1.JSONSchemaProps{
+		"data": {
+			Type:     "array",
+			MaxItems: int64Ptr(1),
+			Items: &extv1.JSONSchemaPropsOrArray{
+				Schema: &extv1.JSONSchemaProps{
+					Type:     "object",
+					Required: []string{"message", "signature"},
+					Properties: map[string]extv1.JSONSchemaProps{
+						"message":      {Type: "string"},
+						"messageScope": {Type: "string"},
+						"mutableAttrs": {Type: "string"},
+						"signature":    {Type: "string"},
+						"certificate":  {Type: "string"},
+						"type":         {Type: "string"},
+					},
+				},
+			},
+		},
+	}
+	return buildCRD(cr, cr.GetResourceSignatureCRDName(), crdNames, specProps)
 }
 
 // helm release metadata crd
@@ -100,7 +182,37 @@ func BuildHelmReleaseMetadataCRD(cr *apiv1alpha1.IntegrityShield) *extv1.CustomR
 		Singular:   "helmreleasemetadata",
 		ShortNames: []string{"hrm", "hrms"},
 	}
-	return buildCRD(cr.GetHelmReleaseMetadataCRDName(), cr.Namespace, crdNames)
+	specProps := map[string]extv1.JSONSchemaProps{
+		"name":          {Type: "string"},
+		"chart":         {Type: "string", Format: "byte"},
+		"prov":          {Type: "string", Format: "byte"},
+		"config":        {Type: "string", Format: "byte"},
+		"manifest":      {Type: "string", Format: "byte"},
+		"installOption": {Type: "string"},
+	}
+	return buildCRD(cr, cr.GetHelmReleaseMetadataCRDName(), crdNames, specProps)
+}
+
+// integrity shield report crd
+// The report is written only by the observer, so it is served only in v1alpha1 and has no conversion webhook.
+func BuildIntegrityShieldReportCRD(cr *apiv1alpha1.IntegrityShield) *extv1.CustomResourceDefinition {
+	crdNames := extv1.CustomResourceDefinitionNames{
+		Kind:       "IntegrityShieldReport",
+		Plural:     "integrityshieldreports",
+		ListKind:   "IntegrityShieldReportList",
+		Singular:   "integrityshieldreport",
+		ShortNames: []string{"isr", "isrs"},
+	}
+	newCRD := buildCRD(cr, cr.GetIntegrityShieldReportCRDName(), crdNames, nil)
+	version := newCRD.Spec.Versions[0]
+	version.AdditionalPrinterColumns = []extv1.CustomResourceColumnDefinition{
+		{Name: "Events", Type: "integer", JSONPath: ".status.summary.events"},
+		{Name: "Denied", Type: "integer", JSONPath: ".status.summary.deniedEvents"},
+		{Name: "Updated", Type: "date", JSONPath: ".status.lastUpdated"},
+	}
+	newCRD.Spec.Versions = []extv1.CustomResourceDefinitionVersion{version}
+	newCRD.Spec.Conversion = &extv1.CustomResourceConversion{Strategy: extv1.NoneConverter}
+	return newCRD
 }
 
 // resourcesigningprofile crd
@@ -113,5 +225,41 @@ func BuildResourceSigningProfileCRD(cr *apiv1alpha1.IntegrityShield) *extv1.Cust
 		Singular:   "resourcesigningprofile",
 		ShortNames: []string{"rsp", "rsps"},
 	}
-	return buildCRD(cr.GetResourceSigningProfileCRDName(), cr.Namespace, crdNames)
+	// `unprotectAttrs` is not in v1beta1, so it is pruned
+	specProps := map[string]extv1.JSONSchemaProps{
+		"disabled": {Type: "boolean"},
+		"targetNamespaceSelector": {
+			Type:                   "object",
+			XPreserveUnknownFields: &xPreserve,
+		},
+		"protectRules":      objectArraySchema(),
+		"ignoreRules":       objectArraySchema(),
+		"forceCheckRules":   objectArraySchema(),
+		"kustomizePatterns": objectArraySchema(),
+		"protectAttrs":      objectArraySchema(),
+		"ignoreAttrs":       objectArraySchema(),
+		"ownerTrust": {
+			Type: "object",
+			Properties: map[string]extv1.JSONSchemaProps{
+				"enabled":  {Type: "boolean"},
+				"maxDepth": {Type: "integer"},
+				"ownerKinds": {
+					Type:     "array",
+					MinItems: int64Ptr(1),
+					Items:    &extv1.JSONSchemaPropsOrArray{Schema: &extv1.JSONSchemaProps{Type: "string"}},
+				},
+				"users": {
+					Type:     "array",
+					MinItems: int64Ptr(1),
+					Items:    &extv1.JSONSchemaPropsOrArray{Schema: &extv1.JSONSchemaProps{Type: "string"}},
+				},
+			},
+			Required: []string{"ownerKinds", "users"},
+		},
+	}
+	return buildCRD(cr, cr.GetResourceSigningProfileCRDName(), crdNames, specProps)
+}
+
+func int64Ptr(i int64) *int64 {
+	return &i
 }
@@ -22,17 +22,19 @@ import (
 	"strconv"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	policyv1 "k8s.io/api/policy/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	intstr "k8s.io/apimachinery/pkg/util/intstr"
 
 	apiv1alpha1 "github.com/IBM/integrity-enforcer/integrity-shield-operator/api/v1alpha1"
-	"github.com/IBM/integrity-enforcer/shield/pkg/common"
 	v1 "k8s.io/api/core/v1"
 )
 
-//deployment
+// deployment
 func BuildDeploymentForIShield(cr *apiv1alpha1.IntegrityShield) *appsv1.Deployment {
 	labels := cr.Spec.MetaLabels
+	terminationGracePeriodSeconds := cr.GetTerminationGracePeriodSeconds()
 
 	var volumemounts []v1.VolumeMount
 	var servervolumemounts []v1.VolumeMount
@@ -51,8 +53,7 @@ func BuildDeploymentForIShield(cr *apiv1alpha1.IntegrityShield) *appsv1.Deployme
 		EmptyDirVolume("tmp"),
 	}
 	for _, keyConf := range cr.Spec.KeyConfig {
-		tmpSecretVolume := SecretVolume(keyConf.Name, keyConf.SecretName)
-		volumes = append(volumes, tmpSecretVolume)
+		volumes = append(volumes, KeyConfigVolume(cr, keyConf))
 	}
 
 	servervolumemounts = []v1.VolumeMount{
@@ -71,13 +72,16 @@ func BuildDeploymentForIShield(cr *apiv1alpha1.IntegrityShield) *appsv1.Deployme
 		},
 	}
 	for _, keyConf := range cr.Spec.KeyConfig {
-		sigType := keyConf.SignatureType
-		if sigType == common.SignatureTypeDefault {
-			sigType = common.SignatureTypePGP
-		}
-		tmpVolumeMount := v1.VolumeMount{MountPath: fmt.Sprintf("/%s/%s/", keyConf.Name, sigType), Name: keyConf.Name}
+		tmpVolumeMount := v1.VolumeMount{MountPath: fmt.Sprintf("/%s/%s/", keyConf.Name, keyConf.GetSignatureType()), Name: keyConf.Name}
 		servervolumemounts = append(servervolumemounts, tmpVolumeMount)
 	}
+	// only the server sends records to audit sinks
+	sinkvolumemounts := []v1.VolumeMount{}
+	for i, secretName := range cr.Spec.Server.AuditSinkSecrets {
+		volName := fmt.Sprintf("audit-sink-%d", i)
+		volumes = append(volumes, SecretVolume(volName, secretName))
+		sinkvolumemounts = append(sinkvolumemounts, v1.VolumeMount{MountPath: fmt.Sprintf("/audit-sinks/%s/", secretName), Name: volName, ReadOnly: true})
+	}
 
 	if cr.Spec.Logger.EsConfig.Enabled && cr.Spec.Logger.EsConfig.Scheme == "https" {
 		tlsVolMnt := v1.VolumeMount{
@@ -123,7 +127,7 @@ func BuildDeploymentForIShield(cr *apiv1alpha1.IntegrityShield) *appsv1.Deployme
 				Protocol:      v1.ProtocolTCP,
 			},
 		},
-		VolumeMounts: servervolumemounts,
+		VolumeMounts: append(append([]v1.VolumeMount{}, servervolumemounts...), sinkvolumemounts...),
 		Env: []v1.EnvVar{
 			{
 				Name:  "SHIELD_NS",
@@ -148,6 +152,7 @@ func BuildDeploymentForIShield(cr *apiv1alpha1.IntegrityShield) *appsv1.Deployme
 		},
 		Resources: cr.Spec.Server.Resources,
 	}
+	serverContainer.Env = append(serverContainer.Env, buildServerOptionEnvVars(cr)...)
 
 	loggerContainer := v1.Container{
 		Name:            cr.Spec.Logger.Name,
@@ -230,12 +235,65 @@ func BuildDeploymentForIShield(cr *apiv1alpha1.IntegrityShield) *appsv1.Deployme
 		Resources: cr.Spec.Logger.Resources,
 	}
 
+	observervolumemounts := append([]v1.VolumeMount{}, servervolumemounts...)
+	observerenv := []v1.EnvVar{
+		{
+			Name:  "SHIELD_NS",
+			Value: cr.Namespace,
+		},
+		{
+			Name:  "SHIELD_CONFIG_NAME",
+			Value: cr.GetShieldConfigCRName(),
+		},
+		{
+			Name:  "EVENTS_FILE_PATH",
+			Value: "/ishield-app/public/events.txt",
+		},
+	}
+	// API of events and compliance reports, which is reached through the pod proxy; it is served with TLS and checks the token of callers; it is served with TLS and checks the token of callers
+	observerports := []v1.ContainerPort{
+		{
+			Name:          "api",
+			ContainerPort: 8090,
+			Protocol:      v1.ProtocolTCP,
+		},
+	}
+	if cr.Spec.Observer.EventStore.Enabled {
+		eventStore := cr.Spec.Observer.EventStore
+		if eventStore.PersistentVolumeClaimName != "" {
+			volumes = append(volumes, PersistentVolumeClaimVolume("event-store", eventStore.PersistentVolumeClaimName))
+		} else {
+			volumes = append(volumes, EmptyDirVolume("event-store"))
+		}
+		observervolumemounts = append(observervolumemounts, v1.VolumeMount{
+			MountPath: "/ishield-app/store",
+			Name:      "event-store",
+		})
+		observerenv = append(observerenv, v1.EnvVar{
+			Name:  "EVENT_STORE_DIR",
+			Value: "/ishield-app/store",
+		})
+		if eventStore.RetentionHours > 0 {
+			observerenv = append(observerenv, v1.EnvVar{
+				Name:  "EVENT_RETENTION_HOURS",
+				Value: strconv.Itoa(eventStore.RetentionHours),
+			})
+		}
+	}
+	if cr.Spec.Observer.DriftRelabel {
+		observerenv = append(observerenv, v1.EnvVar{
+			Name:  "DRIFT_RELABEL",
+			Value: "true",
+		})
+	}
+
 	observerContainer := v1.Container{
 		Name:            cr.Spec.Observer.Name,
 		SecurityContext: cr.Spec.Observer.SecurityContext,
 		Image:           cr.Spec.Observer.Image,
 		ImagePullPolicy: cr.Spec.Observer.ImagePullPolicy,
-		VolumeMounts:    servervolumemounts,
+		VolumeMounts:    observervolumemounts,
+		Ports:           observerports,
 		ReadinessProbe: &v1.Probe{
 			InitialDelaySeconds: 10,
 			PeriodSeconds:       10,
@@ -254,20 +312,7 @@ func BuildDeploymentForIShield(cr *apiv1alpha1.IntegrityShield) *appsv1.Deployme
 				},
 			},
 		},
-		Env: []v1.EnvVar{
-			{
-				Name:  "SHIELD_NS",
-				Value: cr.Namespace,
-			},
-			{
-				Name:  "SHIELD_CONFIG_NAME",
-				Value: cr.GetShieldConfigCRName(),
-			},
-			{
-				Name:  "EVENTS_FILE_PATH",
-				Value: "/ishield-app/public/events.txt",
-			},
-		},
+		Env:       observerenv,
 		Resources: cr.Spec.Observer.Resources,
 	}
 
@@ -304,7 +349,7 @@ func BuildDeploymentForIShield(cr *apiv1alpha1.IntegrityShield) *appsv1.Deployme
 					MaxUnavailable: cr.Spec.MaxUnavailable,
 				},
 			},
-			Replicas: cr.Spec.ReplicaCount,
+			Replicas: buildServerReplicas(cr),
 			Selector: &metav1.LabelSelector{
 				MatchLabels: cr.Spec.SelectorLabels,
 			},
@@ -313,13 +358,16 @@ func BuildDeploymentForIShield(cr *apiv1alpha1.IntegrityShield) *appsv1.Deployme
 					Labels: cr.Spec.SelectorLabels,
 				},
 				Spec: v1.PodSpec{
-					ImagePullSecrets:   cr.Spec.ImagePullSecrets,
-					ServiceAccountName: cr.GetServiceAccountName(),
-					SecurityContext:    cr.Spec.Security.PodSecurityContext,
-					Containers:         containers,
-					NodeSelector:       cr.Spec.NodeSelector,
-					Affinity:           cr.Spec.Affinity,
-					Tolerations:        cr.Spec.Tolerations,
+					ImagePullSecrets:          cr.Spec.ImagePullSecrets,
+					ServiceAccountName:        cr.GetServiceAccountName(),
+					SecurityContext:           cr.Spec.Security.PodSecurityContext,
+					Containers:                containers,
+					NodeSelector:              cr.Spec.NodeSelector,
+					Affinity:                  buildServerAffinity(cr),
+					TopologySpreadConstraints: buildServerTopologySpreadConstraints(cr),
+					Tolerations:               cr.Spec.Tolerations,
+					// the server drains requests and flushes pending status and Events before it is killed
+					TerminationGracePeriodSeconds: &terminationGracePeriodSeconds,
 
 					Volumes: volumes,
 				},
@@ -328,11 +376,66 @@ func BuildDeploymentForIShield(cr *apiv1alpha1.IntegrityShield) *appsv1.Deployme
 	}
 }
 
+func buildServerReplicas(cr *apiv1alpha1.IntegrityShield) *int32 {
+	if cr.Spec.HighAvailability.Autoscaling.Enabled {
+		// initial replicas; HorizontalPodAutoscaler manages them afterwards
+		minReplicas := cr.GetAutoscalingMinReplicas()
+		return &minReplicas
+	}
+	return cr.Spec.ReplicaCount
+}
+
+// server pods are spread over nodes by default so that a node drain does not evict all of them at once
+func buildServerAffinity(cr *apiv1alpha1.IntegrityShield) *v1.Affinity {
+	affinity := cr.Spec.Affinity
+	if affinity != nil && !reflect.DeepEqual(*affinity, v1.Affinity{}) {
+		return affinity
+	}
+	if !cr.IsHighlyAvailable() {
+		return affinity
+	}
+	return &v1.Affinity{
+		PodAntiAffinity: &v1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []v1.WeightedPodAffinityTerm{
+				{
+					Weight: 100,
+					PodAffinityTerm: v1.PodAffinityTerm{
+						LabelSelector: &metav1.LabelSelector{
+							MatchLabels: cr.Spec.SelectorLabels,
+						},
+						TopologyKey: "kubernetes.io/hostname",
+					},
+				},
+			},
+		},
+	}
+}
+
+func buildServerTopologySpreadConstraints(cr *apiv1alpha1.IntegrityShield) []v1.TopologySpreadConstraint {
+	constraints := cr.Spec.HighAvailability.TopologySpreadConstraints
+	if len(constraints) > 0 || !cr.IsHighlyAvailable() {
+		return constraints
+	}
+	return []v1.TopologySpreadConstraint{
+		{
+			MaxSkew:           1,
+			TopologyKey:       "topology.kubernetes.io/zone",
+			WhenUnsatisfiable: v1.ScheduleAnyway,
+			LabelSelector: &metav1.LabelSelector{
+				MatchLabels: cr.Spec.SelectorLabels,
+			},
+		},
+	}
+}
+
 // EqualDeployments returns a Boolean
 func EqualDeployments(expected *appsv1.Deployment, found *appsv1.Deployment) bool {
 	if !EqualLabels(found.ObjectMeta.Labels, expected.ObjectMeta.Labels) {
 		return false
 	}
+	if expected.Spec.Replicas != nil && !reflect.DeepEqual(found.Spec.Replicas, expected.Spec.Replicas) {
+		return false
+	}
 	if !EqualPods(expected.Spec.Template, found.Spec.Template) {
 		return false
 	}
@@ -350,6 +453,12 @@ func EqualPods(expected v1.PodTemplateSpec, found v1.PodTemplateSpec) bool {
 	if !reflect.DeepEqual(found.Spec.ServiceAccountName, expected.Spec.ServiceAccountName) {
 		return false
 	}
+	if expected.Spec.Affinity != nil && !reflect.DeepEqual(*expected.Spec.Affinity, v1.Affinity{}) && !reflect.DeepEqual(found.Spec.Affinity, expected.Spec.Affinity) {
+		return false
+	}
+	if !reflect.DeepEqual(found.Spec.TopologySpreadConstraints, expected.Spec.TopologySpreadConstraints) {
+		return false
+	}
 	if len(found.Spec.Containers) != len(expected.Spec.Containers) {
 		return false
 	}
@@ -395,3 +504,83 @@ func EqualLabels(found map[string]string, expected map[string]string) bool {
 func EqualAnnotations(found map[string]string, expected map[string]string) bool {
 	return reflect.DeepEqual(found, expected)
 }
+
+// BuildPodDisruptionBudgetForIShield keeps at least one server pod running during node drains
+func BuildPodDisruptionBudgetForIShield(cr *apiv1alpha1.IntegrityShield) *policyv1.PodDisruptionBudget {
+	labels := map[string]string{
+		"app":                          cr.Name,
+		"app.kubernetes.io/name":       cr.Name,
+		"app.kubernetes.io/managed-by": "operator",
+		"role":                         "security",
+	}
+	conf := cr.Spec.HighAvailability.PodDisruptionBudget
+	spec := policyv1.PodDisruptionBudgetSpec{
+		Selector: &metav1.LabelSelector{
+			MatchLabels: cr.Spec.SelectorLabels,
+		},
+		MinAvailable:   conf.MinAvailable,
+		MaxUnavailable: conf.MaxUnavailable,
+	}
+	if spec.MinAvailable == nil && spec.MaxUnavailable == nil {
+		minAvailable := intstr.FromInt(1)
+		spec.MinAvailable = &minAvailable
+	}
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cr.GetPodDisruptionBudgetName(),
+			Namespace: cr.Namespace,
+			Labels:    labels,
+		},
+		Spec: spec,
+	}
+}
+
+// BuildHorizontalPodAutoscalerForIShield scales the server deployment by CPU utilization
+func BuildHorizontalPodAutoscalerForIShield(cr *apiv1alpha1.IntegrityShield) *autoscalingv1.HorizontalPodAutoscaler {
+	labels := map[string]string{
+		"app":                          cr.Name,
+		"app.kubernetes.io/name":       cr.Name,
+		"app.kubernetes.io/managed-by": "operator",
+		"role":                         "security",
+	}
+	minReplicas := cr.GetAutoscalingMinReplicas()
+	targetCPUUtilization := cr.GetAutoscalingTargetCPUUtilization()
+	return &autoscalingv1.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cr.GetHorizontalPodAutoscalerName(),
+			Namespace: cr.Namespace,
+			Labels:    labels,
+		},
+		Spec: autoscalingv1.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv1.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       cr.GetIShieldServerDeploymentName(),
+			},
+			MinReplicas:                    &minReplicas,
+			MaxReplicas:                    cr.GetAutoscalingMaxReplicas(),
+			TargetCPUUtilizationPercentage: &targetCPUUtilization,
+		},
+	}
+}
+
+// server options are set only when they are specified in CR so that the server defaults are used otherwise
+func buildServerOptionEnvVars(cr *apiv1alpha1.IntegrityShield) []v1.EnvVar {
+	envVars := []v1.EnvVar{}
+	if cr.Spec.Server.MaxInflightRequests > 0 {
+		envVars = append(envVars, v1.EnvVar{Name: "MAX_INFLIGHT_REQUESTS", Value: strconv.Itoa(int(cr.Spec.Server.MaxInflightRequests))})
+	}
+	if cr.Spec.Server.SaturationFailurePolicy != "" {
+		envVars = append(envVars, v1.EnvVar{Name: "SATURATION_FAILURE_POLICY", Value: cr.Spec.Server.SaturationFailurePolicy})
+	}
+	if cr.Spec.Server.MaxRequestBodyBytes > 0 {
+		envVars = append(envVars, v1.EnvVar{Name: "MAX_REQUEST_BODY_BYTES", Value: strconv.FormatInt(cr.Spec.Server.MaxRequestBodyBytes, 10)})
+	}
+	if cr.Spec.Server.ShutdownDelaySec > 0 {
+		envVars = append(envVars, v1.EnvVar{Name: "SHUTDOWN_DELAY_SEC", Value: strconv.Itoa(int(cr.Spec.Server.ShutdownDelaySec))})
+	}
+	if cr.Spec.Server.ShutdownTimeoutSec > 0 {
+		envVars = append(envVars, v1.EnvVar{Name: "SHUTDOWN_TIMEOUT_SEC", Value: strconv.Itoa(int(cr.Spec.Server.ShutdownTimeoutSec))})
+	}
+	return envVars
+}
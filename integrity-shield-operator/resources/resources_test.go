@@ -26,9 +26,16 @@ import (
 	"testing"
 
 	apiv1alpha1 "github.com/IBM/integrity-enforcer/integrity-shield-operator/api/v1alpha1"
+	rsp "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	"github.com/IBM/integrity-enforcer/shield/pkg/common"
 	"github.com/IBM/integrity-enforcer/shield/pkg/util/mapnode"
 	"github.com/ghodss/yaml"
 	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 const defaultIShieldCRPath = "./default-ishield-cr.yaml"
@@ -157,6 +164,39 @@ func TestResourceSigningProfileCRD(t *testing.T) {
 	yamlPath := "./testdata/resourceSigningProfileCRD.yaml"
 	testObjAndYaml(t, obj, yamlPath)
 }
+func TestCRDWithV1beta1API(t *testing.T) {
+	instance := loadTestInstance(t)
+	obj := BuildResourceSigningProfileCRD(instance)
+	for _, v := range obj.Spec.Versions {
+		if v.Name == "v1beta1" && v.Served {
+			t.Errorf("v1beta1 must not be served by default")
+		}
+	}
+	if obj.Spec.Conversion.Strategy != extv1.NoneConverter {
+		t.Errorf("conversion webhook must not be used by default")
+	}
+
+	instance.Spec.V1beta1APIEnabled = true
+	obj = BuildResourceSigningProfileCRD(instance)
+	for _, v := range obj.Spec.Versions {
+		if v.Name == "v1alpha1" && !v.Storage {
+			t.Errorf("v1alpha1 must be the storage version")
+		}
+		if v.Name == "v1beta1" && !v.Served {
+			t.Errorf("v1beta1 must be served when it is enabled")
+		}
+	}
+	conv := obj.Spec.Conversion
+	if conv.Strategy != extv1.WebhookConverter || conv.Webhook == nil || *conv.Webhook.ClientConfig.Service.Path != crdConversionPath {
+		t.Errorf("conversion webhook must be used when v1beta1 is enabled")
+	}
+}
+func TestIntegrityShieldReportCRD(t *testing.T) {
+	instance := loadTestInstance(t)
+	obj := BuildIntegrityShieldReportCRD(instance)
+	yamlPath := "./testdata/integrityShieldReportCRD.yaml"
+	testObjAndYaml(t, obj, yamlPath)
+}
 func TestShieldConfigCR(t *testing.T) {
 	instance := loadTestInstance(t)
 	obj := BuildShieldConfigForIShield(instance, nil, commonProfilePathList)
@@ -237,6 +277,47 @@ func TestClusterRoleForIShield(t *testing.T) {
 	testObjAndYaml(t, obj, yamlPath)
 }
 
+func TestClusterRoleForIShieldWithTargets(t *testing.T) {
+	instance := loadTestInstance(t)
+	targets := []schema.GroupVersionResource{
+		{Group: "apps", Version: "v1", Resource: "deployments"},
+		{Group: "", Version: "v1", Resource: "configmaps"},
+		{Group: "apps", Version: "v1beta1", Resource: "deployments"},
+	}
+	obj := BuildClusterRoleForIShieldWithTargets(instance, targets)
+	listRules := []rbacv1.PolicyRule{}
+	for _, rule := range obj.Rules {
+		for _, verb := range rule.Verbs {
+			if verb == "list" && reflect.DeepEqual(rule.APIGroups, []string{"*"}) {
+				t.Errorf("resources in all groups must not be listed: %v", rule)
+			}
+		}
+		if reflect.DeepEqual(rule.Verbs, []string{"list"}) {
+			listRules = append(listRules, rule)
+		}
+	}
+	expected := []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"list"}},
+		{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"list"}},
+	}
+	if !reflect.DeepEqual(listRules, expected) {
+		t.Errorf("unexpected list rules: %v", listRules)
+	}
+
+	// drifted resources are patched only in the protected resource types
+	instance.Spec.Observer.DriftRelabel = true
+	obj = BuildClusterRoleForIShieldWithTargets(instance, targets)
+	patchRules := []rbacv1.PolicyRule{}
+	for _, rule := range obj.Rules {
+		if reflect.DeepEqual(rule.Verbs, []string{"patch"}) {
+			patchRules = append(patchRules, rule)
+		}
+	}
+	if len(patchRules) != 2 || !reflect.DeepEqual(patchRules[1].Resources, []string{"deployments"}) {
+		t.Errorf("unexpected patch rules: %v", patchRules)
+	}
+}
+
 func TestPodSecurityPolicy(t *testing.T) {
 	instance := loadTestInstance(t)
 	obj := BuildPodSecurityPolicy(instance)
@@ -262,6 +343,64 @@ func TestDeploymentForIShield(t *testing.T) {
 	yamlPath := "./testdata/deploymentForIShield.yaml"
 	testObjAndYaml(t, obj, yamlPath)
 }
+func TestHighAvailabilityDeploymentForIShield(t *testing.T) {
+	instance := loadTestInstance(t)
+	replicas := int32(3)
+	instance.Spec.ReplicaCount = &replicas
+	obj := BuildDeploymentForIShield(instance)
+	podSpec := obj.Spec.Template.Spec
+	if podSpec.Affinity == nil || podSpec.Affinity.PodAntiAffinity == nil {
+		t.Errorf("pod anti-affinity must be set by default when replicaCount > 1")
+	}
+	if len(podSpec.TopologySpreadConstraints) != 1 {
+		t.Errorf("topology spread constraint must be set by default when replicaCount > 1")
+	}
+	if !instance.IsPodDisruptionBudgetEnabled() {
+		t.Errorf("PodDisruptionBudget must be enabled by default when replicaCount > 1")
+	}
+}
+func TestEventStoreDeploymentForIShield(t *testing.T) {
+	instance := loadTestInstance(t)
+	enabled := true
+	instance.Spec.Observer.Enabled = &enabled
+	instance.Spec.Observer.EventStore = apiv1alpha1.ObserverEventStore{Enabled: true, PersistentVolumeClaimName: "ishield-event-store", RetentionHours: 24}
+	obj := BuildDeploymentForIShield(instance)
+	podSpec := obj.Spec.Template.Spec
+	foundVolume := false
+	for _, vol := range podSpec.Volumes {
+		if vol.Name == "event-store" && vol.PersistentVolumeClaim != nil && vol.PersistentVolumeClaim.ClaimName == "ishield-event-store" {
+			foundVolume = true
+		}
+	}
+	if !foundVolume {
+		t.Errorf("event store volume must be mounted from the PVC")
+	}
+	for _, c := range podSpec.Containers {
+		mounted := false
+		for _, vm := range c.VolumeMounts {
+			if vm.Name == "event-store" {
+				mounted = true
+			}
+		}
+		if c.Name == instance.Spec.Observer.Name && !mounted {
+			t.Errorf("event store must be mounted on the observer container")
+		} else if c.Name != instance.Spec.Observer.Name && mounted {
+			t.Errorf("event store must not be mounted on the container %s", c.Name)
+		}
+	}
+}
+func TestPodDisruptionBudgetForIShield(t *testing.T) {
+	instance := loadTestInstance(t)
+	obj := BuildPodDisruptionBudgetForIShield(instance)
+	yamlPath := "./testdata/podDisruptionBudgetForIShield.yaml"
+	testObjAndYaml(t, obj, yamlPath)
+}
+func TestHorizontalPodAutoscalerForIShield(t *testing.T) {
+	instance := loadTestInstance(t)
+	obj := BuildHorizontalPodAutoscalerForIShield(instance)
+	yamlPath := "./testdata/horizontalPodAutoscalerForIShield.yaml"
+	testObjAndYaml(t, obj, yamlPath)
+}
 func TestServiceForIShield(t *testing.T) {
 	instance := loadTestInstance(t)
 	obj := BuildServiceForIShield(instance)
@@ -274,3 +413,57 @@ func TestMutatingWebhookConfigurationForIShield(t *testing.T) {
 	yamlPath := "./testdata/mutatingWebhookConfigurationForIShield.yaml"
 	testObjAndYaml(t, obj, yamlPath)
 }
+
+func TestOutOfScopeNamespaces(t *testing.T) {
+	instance := loadTestInstance(t)
+	instance.Namespace = "ishield-ns"
+	instance.Spec.ShieldConfig.InScopeNamespaceSelector = &common.NamespaceSelector{
+		Include: []string{"app-*"},
+		Exclude: []string{"app-test"},
+	}
+	instance.Spec.ResourceSigningProfiles = nil
+
+	namespaces := []corev1.Namespace{}
+	for _, name := range []string{"ishield-ns", "app-1", "app-test", "team-a", "team-b", "secure", "kube-system"} {
+		ns := corev1.Namespace{}
+		ns.Name = name
+		if name == "secure" {
+			ns.Labels = map[string]string{"protected": "true"}
+		}
+		namespaces = append(namespaces, ns)
+	}
+	profiles := []rsp.ResourceSigningProfile{}
+	// RSP in other namespace protects the namespace itself
+	p1 := rsp.ResourceSigningProfile{}
+	p1.Namespace = "team-a"
+	// RSP in iShield namespace protects the target namespaces
+	p2 := rsp.ResourceSigningProfile{}
+	p2.Namespace = "ishield-ns"
+	p2.Spec.TargetNamespaceSelector = &common.NamespaceSelector{LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"protected": "true"}}}
+	profiles = append(profiles, p1, p2)
+
+	outOfScope := GetOutOfScopeNamespaces(instance, profiles, namespaces)
+	expected := []string{"app-test", "kube-system", "team-b"}
+	if !reflect.DeepEqual(outOfScope, expected) {
+		t.Errorf("expected out-of-scope namespaces %v, but got %v", expected, outOfScope)
+	}
+
+	wc := BuildMutatingWebhookConfigurationForIShieldWithScope(instance, outOfScope)
+	if len(wc.Webhooks) != 2 {
+		t.Fatalf("namespaced and cluster-scope webhooks are expected, but got %d", len(wc.Webhooks))
+	}
+	nsSelector := wc.Webhooks[0].NamespaceSelector
+	if len(nsSelector.MatchExpressions) != 1 || !reflect.DeepEqual(nsSelector.MatchExpressions[0].Values, expected) {
+		t.Errorf("out-of-scope namespaces are not excluded: %v", nsSelector)
+	}
+	if len(wc.Webhooks[1].NamespaceSelector.MatchExpressions) != 0 {
+		t.Errorf("cluster-scope requests must not be filtered by namespace")
+	}
+
+	// namespace selector in the CR is used as is
+	instance.Spec.WebhookNamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"ishield": "enabled"}}
+	wc = BuildMutatingWebhookConfigurationForIShieldWithScope(instance, outOfScope)
+	if !reflect.DeepEqual(wc.Webhooks[0].NamespaceSelector, instance.Spec.WebhookNamespaceSelector) {
+		t.Errorf("namespace selector in the CR is not used: %v", wc.Webhooks[0].NamespaceSelector)
+	}
+}
@@ -17,11 +17,14 @@
 package resources
 
 import (
+	"sort"
+
 	apiv1alpha1 "github.com/IBM/integrity-enforcer/integrity-shield-operator/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1beta1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 //sa
@@ -44,6 +47,13 @@ func BuildServiceAccountForIShield(cr *apiv1alpha1.IntegrityShield) *corev1.Serv
 
 //cluster role
 func BuildClusterRoleForIShield(cr *apiv1alpha1.IntegrityShield) *rbacv1.ClusterRole {
+	return BuildClusterRoleForIShieldWithTargets(cr, nil)
+}
+
+// BuildClusterRoleForIShieldWithTargets builds the cluster role.
+// Resources are listed only for the resource types `protectedResources` which may be protected by RSPs,
+// so that iShield does not list other resources such as secrets in the cluster.
+func BuildClusterRoleForIShieldWithTargets(cr *apiv1alpha1.IntegrityShield, protectedResources []schema.GroupVersionResource) *rbacv1.ClusterRole {
 	labels := map[string]string{
 		"app":                          cr.Name,
 		"app.kubernetes.io/name":       cr.Name,
@@ -79,6 +89,52 @@ func BuildClusterRoleForIShield(cr *apiv1alpha1.IntegrityShield) *rbacv1.Cluster
 					"create", "update", "get",
 				},
 			},
+			// the observer API authenticates and authorizes callers
+			{
+				APIGroups: []string{
+					"authentication.k8s.io",
+				},
+				Resources: []string{
+					"tokenreviews",
+				},
+				Verbs: []string{
+					"create",
+				},
+			},
+			{
+				APIGroups: []string{
+					"authorization.k8s.io",
+				},
+				Resources: []string{
+					"subjectaccessreviews",
+				},
+				Verbs: []string{
+					"create",
+				},
+			},
+			// the observer API authenticates and authorizes callers
+			{
+				APIGroups: []string{
+					"authentication.k8s.io",
+				},
+				Resources: []string{
+					"tokenreviews",
+				},
+				Verbs: []string{
+					"create",
+				},
+			},
+			{
+				APIGroups: []string{
+					"authorization.k8s.io",
+				},
+				Resources: []string{
+					"subjectaccessreviews",
+				},
+				Verbs: []string{
+					"create",
+				},
+			},
 			{
 				APIGroups: []string{
 					"apiextensions.k8s.io",
@@ -117,6 +173,12 @@ func BuildClusterRoleForIShield(cr *apiv1alpha1.IntegrityShield) *rbacv1.Cluster
 			// },
 		},
 	}
+	// the observer lists protected resources for scan
+	role.Rules = append(role.Rules, buildProtectedResourceRules(protectedResources, "list")...)
+	// the observer patches the resourceIntegrity label of drifted resources, which are protected by RSPs
+	if cr.Spec.Observer.DriftRelabel {
+		role.Rules = append(role.Rules, buildProtectedResourceRules(protectedResources, "patch")...)
+	}
 	return role
 }
 
@@ -144,7 +206,7 @@ func BuildClusterRoleBindingForIShield(cr *apiv1alpha1.IntegrityShield) *rbacv1.
 		RoleRef: rbacv1.RoleRef{
 			APIGroup: "rbac.authorization.k8s.io",
 			Kind:     "ClusterRole",
-			Name:     cr.Spec.Security.ClusterRole,
+			Name:     cr.GetClusterRoleName(),
 		},
 	}
 	return rolebinding
@@ -205,7 +267,7 @@ func BuildRoleBindingForIShield(cr *apiv1alpha1.IntegrityShield) *rbacv1.RoleBin
 		RoleRef: rbacv1.RoleRef{
 			APIGroup: "rbac.authorization.k8s.io",
 			Kind:     "Role",
-			Name:     cr.Spec.Security.ClusterRole + "-sim",
+			Name:     cr.GetDryRunRoleName(),
 		},
 	}
 	return rolebinding
@@ -383,3 +445,36 @@ func BuildPodSecurityPolicy(cr *apiv1alpha1.IntegrityShield) *policyv1.PodSecuri
 	}
 	return psp
 }
+
+// buildProtectedResourceRules returns a rule for each API group of the resource types
+func buildProtectedResourceRules(resources []schema.GroupVersionResource, verbs ...string) []rbacv1.PolicyRule {
+	resourcesByGroup := map[string][]string{}
+	for _, gvr := range resources {
+		found := false
+		for _, r := range resourcesByGroup[gvr.Group] {
+			if r == gvr.Resource {
+				found = true
+				break
+			}
+		}
+		if !found {
+			resourcesByGroup[gvr.Group] = append(resourcesByGroup[gvr.Group], gvr.Resource)
+		}
+	}
+	groups := []string{}
+	for group := range resourcesByGroup {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	rules := []rbacv1.PolicyRule{}
+	for _, group := range groups {
+		names := resourcesByGroup[group]
+		sort.Strings(names)
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{group},
+			Resources: names,
+			Verbs:     verbs,
+		})
+	}
+	return rules
+}
@@ -47,6 +47,56 @@ func SecretVolume(name, secretName string) v1.Volume {
 
 }
 
+func ConfigMapVolume(name, configMapName string) v1.Volume {
+
+	return v1.Volume{
+		Name: name,
+		VolumeSource: v1.VolumeSource{
+			ConfigMap: &v1.ConfigMapVolumeSource{
+				LocalObjectReference: v1.LocalObjectReference{Name: configMapName},
+				DefaultMode:          &int420Var,
+			},
+		},
+	}
+}
+
+// KeyConfigVolume returns a volume which projects keys of the key config from its source.
+// If fileNames are specified, only those files are projected.
+func KeyConfigVolume(cr *apiv1alpha1.IntegrityShield, keyConf apiv1alpha1.KeyConfig) v1.Volume {
+	var vol v1.Volume
+	if keyConf.JWKSURL != "" {
+		return ConfigMapVolume(keyConf.Name, cr.GetJWKSConfigMapName(keyConf.Name))
+	} else if keyConf.ConfigMapName != "" {
+		vol = ConfigMapVolume(keyConf.Name, keyConf.ConfigMapName)
+	} else {
+		vol = SecretVolume(keyConf.Name, keyConf.SecretName)
+	}
+	if len(keyConf.FileNames) > 0 {
+		items := []v1.KeyToPath{}
+		for _, fileName := range keyConf.FileNames {
+			items = append(items, v1.KeyToPath{Key: fileName, Path: fileName})
+		}
+		if vol.Secret != nil {
+			vol.Secret.Items = items
+		} else {
+			vol.ConfigMap.Items = items
+		}
+	}
+	return vol
+}
+
+func PersistentVolumeClaimVolume(name, claimName string) v1.Volume {
+
+	return v1.Volume{
+		Name: name,
+		VolumeSource: v1.VolumeSource{
+			PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+				ClaimName: claimName,
+			},
+		},
+	}
+}
+
 func EmptyDirVolume(name string) v1.Volume {
 
 	return v1.Volume{
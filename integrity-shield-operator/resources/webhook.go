@@ -20,8 +20,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"sort"
 
 	apiv1alpha1 "github.com/IBM/integrity-enforcer/integrity-shield-operator/api/v1alpha1"
+	rsp "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
 	"github.com/IBM/integrity-enforcer/shield/pkg/common"
 	"github.com/ghodss/yaml"
 	admregv1 "k8s.io/api/admissionregistration/v1"
@@ -54,10 +56,23 @@ func BuildServiceForIShield(cr *apiv1alpha1.IntegrityShield) *corev1.Service {
 }
 
 //webhook configuration
+
+// namespaces are automatically labeled with their name by kubernetes (1.21+)
+const namespaceNameLabelKey = "kubernetes.io/metadata.name"
+
 func BuildMutatingWebhookConfigurationForIShield(cr *apiv1alpha1.IntegrityShield) *admregv1.MutatingWebhookConfiguration {
+	return BuildMutatingWebhookConfigurationForIShieldWithScope(cr, nil)
+}
+
+// BuildMutatingWebhookConfigurationForIShieldWithScope builds the webhook configuration.
+// Namespaced requests and cluster-scope requests are sent by separate webhooks, so that the namespace selector,
+// which excludes `outOfScopeNamespaces` by default, is applied only to namespaced requests.
+// (namespace selector is evaluated against the Namespace object itself for Namespace requests.)
+func BuildMutatingWebhookConfigurationForIShieldWithScope(cr *apiv1alpha1.IntegrityShield, outOfScopeNamespaces []string) *admregv1.MutatingWebhookConfiguration {
 
 	namespaced := admregv1.NamespacedScope
 	cluster := admregv1.ClusterScope
+	allScopes := admregv1.AllScopes
 
 	namespacedRule := cr.Spec.WebhookNamespacedResource
 	namespacedRule.Scope = &namespaced
@@ -71,21 +86,19 @@ func BuildMutatingWebhookConfigurationForIShield(cr *apiv1alpha1.IntegrityShield
 
 	var empty []byte
 
-	sideEffect := admregv1.SideEffectClassNone
-	timeoutSeconds := int32(apiv1alpha1.DefaultIShieldWebhookTimeout)
-
-	rules := []admregv1.RuleWithOperations{
+	operations := []admregv1.OperationType{
+		admregv1.Create, admregv1.Delete, admregv1.Update,
+	}
+	namespacedRules := []admregv1.RuleWithOperations{
 		{
-			Operations: []admregv1.OperationType{
-				admregv1.Create, admregv1.Delete, admregv1.Update,
-			},
-			Rule: namespacedRule,
+			Operations: operations,
+			Rule:       namespacedRule,
 		},
+	}
+	clusterRules := []admregv1.RuleWithOperations{
 		{
-			Operations: []admregv1.OperationType{
-				admregv1.Create, admregv1.Delete, admregv1.Update,
-			},
-			Rule: clusterRule,
+			Operations: operations,
+			Rule:       clusterRule,
 		},
 	}
 
@@ -94,7 +107,58 @@ func BuildMutatingWebhookConfigurationForIShield(cr *apiv1alpha1.IntegrityShield
 		fpath := filepath.Clean(apiv1alpha1.WebhookRulesForRoksYamlPath)
 		rulesBytes, _ := ioutil.ReadFile(fpath) // NOSONAR
 		_ = yaml.Unmarshal(rulesBytes, &roksRules)
-		rules = roksRules
+		namespacedRules = []admregv1.RuleWithOperations{}
+		clusterRules = []admregv1.RuleWithOperations{}
+		for _, rule := range roksRules {
+			if rule.Scope != nil && *rule.Scope == namespaced {
+				namespacedRules = append(namespacedRules, rule)
+				continue
+			}
+			// rules which may match cluster-scope requests must not be filtered by namespace selector
+			if rule.Scope == nil {
+				rule.Scope = &allScopes
+			}
+			clusterRules = append(clusterRules, rule)
+		}
+	}
+
+	failurePolicy := cr.GetWebhookFailurePolicy()
+	matchPolicy := cr.GetWebhookMatchPolicy()
+	sideEffect := cr.GetWebhookSideEffects()
+	timeoutSeconds := cr.GetWebhookTimeoutSeconds()
+	objectSelector := &metav1.LabelSelector{}
+	if cr.Spec.WebhookObjectSelector != nil {
+		objectSelector = cr.Spec.WebhookObjectSelector.DeepCopy()
+	}
+
+	newWebhook := func(name string, rules []admregv1.RuleWithOperations, namespaceSelector *metav1.LabelSelector) admregv1.MutatingWebhook {
+		return admregv1.MutatingWebhook{
+			Name: name,
+			ClientConfig: admregv1.WebhookClientConfig{
+				Service: &admregv1.ServiceReference{
+					Name:      cr.GetWebhookServiceName(),
+					Namespace: cr.Namespace,
+					Path:      path, //"/mutate"
+				},
+				CABundle: empty,
+			},
+			Rules:                   rules,
+			FailurePolicy:           &failurePolicy,
+			MatchPolicy:             &matchPolicy,
+			NamespaceSelector:       namespaceSelector,
+			ObjectSelector:          objectSelector,
+			SideEffects:             &sideEffect,
+			TimeoutSeconds:          &timeoutSeconds,
+			AdmissionReviewVersions: []string{"v1", "v1beta1"},
+		}
+	}
+
+	webhooks := []admregv1.MutatingWebhook{}
+	if len(namespacedRules) > 0 {
+		webhooks = append(webhooks, newWebhook(fmt.Sprintf("ac-server.%s.svc", cr.Namespace), namespacedRules, buildWebhookNamespaceSelector(cr, outOfScopeNamespaces)))
+	}
+	if len(clusterRules) > 0 {
+		webhooks = append(webhooks, newWebhook(fmt.Sprintf("ac-server-cluster.%s.svc", cr.Namespace), clusterRules, &metav1.LabelSelector{}))
 	}
 
 	wc := &admregv1.MutatingWebhookConfiguration{
@@ -102,18 +166,68 @@ func BuildMutatingWebhookConfigurationForIShield(cr *apiv1alpha1.IntegrityShield
 			Name:      cr.GetWebhookConfigName(),
 			Namespace: cr.Namespace,
 		},
-		Webhooks: []admregv1.MutatingWebhook{
+		Webhooks: webhooks,
+	}
+	return wc
+}
+
+// BuildValidatingWebhookConfigurationForIShield builds the webhook configuration which validates iShield custom resources semantically.
+func BuildValidatingWebhookConfigurationForIShield(cr *apiv1alpha1.IntegrityShield) *admregv1.ValidatingWebhookConfiguration {
+	return BuildValidatingWebhookConfigurationForIShieldWithScope(cr, nil)
+}
+
+// BuildValidatingWebhookConfigurationForIShieldWithScope builds the webhook configuration which validates iShield resources
+// except in `otherInstanceNamespaces`, where they are validated by other instances.
+func BuildValidatingWebhookConfigurationForIShieldWithScope(cr *apiv1alpha1.IntegrityShield, otherInstanceNamespaces []string) *admregv1.ValidatingWebhookConfiguration {
+	validate := "/validate"
+	allScopes := admregv1.AllScopes
+	failurePolicy := cr.GetWebhookFailurePolicy()
+	matchPolicy := cr.GetWebhookMatchPolicy()
+	sideEffect := admregv1.SideEffectClassNone
+	timeoutSeconds := cr.GetWebhookTimeoutSeconds()
+	namespaceSelector := &metav1.LabelSelector{}
+	if len(otherInstanceNamespaces) > 0 {
+		namespaceSelector.MatchExpressions = []metav1.LabelSelectorRequirement{
 			{
-				Name: fmt.Sprintf("ac-server.%s.svc", cr.Namespace),
+				Key:      namespaceNameLabelKey,
+				Operator: metav1.LabelSelectorOpNotIn,
+				Values:   otherInstanceNamespaces,
+			},
+		}
+	}
+
+	var empty []byte
+	wc := &admregv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cr.GetValidatingWebhookConfigName(),
+			Namespace: cr.Namespace,
+		},
+		Webhooks: []admregv1.ValidatingWebhook{
+			{
+				Name: fmt.Sprintf("ac-server-validator.%s.svc", cr.Namespace),
 				ClientConfig: admregv1.WebhookClientConfig{
 					Service: &admregv1.ServiceReference{
 						Name:      cr.GetWebhookServiceName(),
 						Namespace: cr.Namespace,
-						Path:      path, //"/mutate"
+						Path:      &validate,
 					},
 					CABundle: empty,
 				},
-				Rules:                   rules,
+				Rules: []admregv1.RuleWithOperations{
+					{
+						Operations: []admregv1.OperationType{admregv1.Create, admregv1.Update},
+						Rule: admregv1.Rule{
+							APIGroups:   []string{apiv1alpha1.GroupVersion.Group},
+							APIVersions: []string{apiv1alpha1.GroupVersion.Version},
+							Resources:   []string{"resourcesigningprofiles", "signerconfigs", "shieldconfigs", "resourcesignatures"},
+							Scope:       &allScopes,
+						},
+					},
+				},
+				FailurePolicy:           &failurePolicy,
+				MatchPolicy:             &matchPolicy,
+				NamespaceSelector:       namespaceSelector,
+				ObjectSelector:          &metav1.LabelSelector{},
 				SideEffects:             &sideEffect,
 				TimeoutSeconds:          &timeoutSeconds,
 				AdmissionReviewVersions: []string{"v1", "v1beta1"},
@@ -122,3 +236,87 @@ func BuildMutatingWebhookConfigurationForIShield(cr *apiv1alpha1.IntegrityShield
 	}
 	return wc
 }
+
+func buildWebhookNamespaceSelector(cr *apiv1alpha1.IntegrityShield, outOfScopeNamespaces []string) *metav1.LabelSelector {
+	if cr.Spec.WebhookNamespaceSelector != nil {
+		return cr.Spec.WebhookNamespaceSelector.DeepCopy()
+	}
+	selector := &metav1.LabelSelector{}
+	if len(outOfScopeNamespaces) > 0 {
+		// namespaces without the label (e.g. created later) are not excluded
+		selector.MatchExpressions = []metav1.LabelSelectorRequirement{
+			{
+				Key:      namespaceNameLabelKey,
+				Operator: metav1.LabelSelectorOpNotIn,
+				Values:   outOfScopeNamespaces,
+			},
+		}
+	}
+	return selector
+}
+
+// GetOutOfScopeNamespaces returns the namespaces where no request is verified by iShield,
+// i.e. namespaces which match neither ShieldConfig.InScopeNamespaceSelector nor the target namespaces of any RSP.
+// This follows how iShield server decides whether a namespaced request is in scope.
+func GetOutOfScopeNamespaces(cr *apiv1alpha1.IntegrityShield, profiles []rsp.ResourceSigningProfile, namespaces []corev1.Namespace) []string {
+	isTarget := newTargetNamespaceMatcher(cr, profiles)
+	outOfScope := []string{}
+	for i := range namespaces {
+		if !isTarget(&namespaces[i]) {
+			outOfScope = append(outOfScope, namespaces[i].GetName())
+		}
+	}
+	sort.Strings(outOfScope)
+	return outOfScope
+}
+
+// GetTargetNamespaces returns the namespaces where requests are verified by iShield, i.e. the rest of GetOutOfScopeNamespaces
+func GetTargetNamespaces(cr *apiv1alpha1.IntegrityShield, profiles []rsp.ResourceSigningProfile, namespaces []corev1.Namespace) []string {
+	isTarget := newTargetNamespaceMatcher(cr, profiles)
+	targets := []string{}
+	for i := range namespaces {
+		if isTarget(&namespaces[i]) {
+			targets = append(targets, namespaces[i].GetName())
+		}
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+func newTargetNamespaceMatcher(cr *apiv1alpha1.IntegrityShield, profiles []rsp.ResourceSigningProfile) func(ns *corev1.Namespace) bool {
+	var inScopeSelector *common.NamespaceSelector
+	if cr.Spec.ShieldConfig != nil {
+		inScopeSelector = cr.Spec.ShieldConfig.InScopeNamespaceSelector
+	}
+	// profiles in the CR are created in iShield namespace
+	targetSelectors := []*common.NamespaceSelector{}
+	for _, prof := range cr.Spec.ResourceSigningProfiles {
+		if prof.ResourceSigningProfileSpec != nil && prof.TargetNamespaceSelector != nil {
+			targetSelectors = append(targetSelectors, prof.TargetNamespaceSelector)
+		}
+	}
+	targetNamespaces := map[string]bool{cr.Namespace: true}
+	for _, p := range profiles {
+		if p.GetNamespace() != cr.Namespace {
+			targetNamespaces[p.GetNamespace()] = true
+		} else if p.Spec.TargetNamespaceSelector != nil {
+			targetSelectors = append(targetSelectors, p.Spec.TargetNamespaceSelector)
+		}
+	}
+
+	return func(ns *corev1.Namespace) bool {
+		nsName := ns.GetName()
+		if targetNamespaces[nsName] {
+			return true
+		}
+		if inScopeSelector != nil && inScopeSelector.MatchNamespaceName(nsName) {
+			return true
+		}
+		for _, selector := range targetSelectors {
+			if selector.MatchNamespace(ns) {
+				return true
+			}
+		}
+		return false
+	}
+}
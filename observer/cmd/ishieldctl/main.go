@@ -0,0 +1,284 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// ishieldctl is a command line tool for IShield.
+//
+//	ishieldctl events [-n ishield-namespace] [-token token] [-resource Kind/namespace/name] [-user name] [-reason code] [-signer name]
+//	                  [-since 1h | -from RFC3339 -to RFC3339] [-denied] [-limit 100] [-detail] [-output text|json]
+//	ishieldctl compliance [-n ishield-namespace] [-token token] [-format json|csv|sarif] [-o file]
+//
+// `events` searches decisions of IShield, and `compliance` exports the compliance report of the last scan of the
+// observer. Both call the API of the observer, which is reached by the pod proxy of the API server, so the user needs
+// `get` permission of `pods/proxy` in IShield namespace. The observer checks the permission again with the bearer token
+// of the user, which is taken from the kubeconfig or `-token`. The observer event store must be enabled for `events`.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/IBM/integrity-enforcer/observer/pkg/eventstore"
+	"github.com/IBM/integrity-enforcer/observer/pkg/observer"
+	kubeutil "github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const usage = `Usage: ishieldctl <command> [flags]
+
+Commands:
+  events        search decisions of IShield
+  compliance    export the compliance report of protected resources
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	switch os.Args[1] {
+	case "events":
+		if err := runEvents(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+			os.Exit(1)
+		}
+	case "compliance":
+		if err := runCompliance(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func runEvents(args []string) error {
+	fs := flag.NewFlagSet("events", flag.ExitOnError)
+	var namespace, selector, port, token, resource, from, to, output string
+	var since time.Duration
+	var denied, detail bool
+	q := eventstore.Query{}
+	fs.StringVar(&namespace, "n", "integrity-shield-operator-system", "namespace where IShield is installed")
+	fs.StringVar(&selector, "selector", "app=ishield-server", "label selector of IShield server pods")
+	fs.StringVar(&port, "port", "8090", "port of the query API of the observer")
+	fs.StringVar(&token, "token", "", "bearer token to call the API; default is the token in the kubeconfig")
+	fs.StringVar(&resource, "resource", "", "resource of requests as Kind/namespace/name, or Kind/name for cluster scope resources; wildcards are accepted")
+	fs.StringVar(&q.UserName, "user", "", "name of the user who sent requests")
+	fs.StringVar(&q.Reason, "reason", "", "reason code of decisions, e.g. no-signature")
+	fs.StringVar(&q.Signer, "signer", "", "signer of resources")
+	fs.DurationVar(&since, "since", time.Hour, "search decisions in this duration; ignored if -from is set")
+	fs.StringVar(&from, "from", "", "start time of the search in RFC3339")
+	fs.StringVar(&to, "to", "", "end time of the search in RFC3339")
+	fs.BoolVar(&denied, "denied", false, "search denied requests only")
+	fs.IntVar(&q.Limit, "limit", 100, "max number of decisions; the latest ones are shown")
+	fs.BoolVar(&detail, "detail", false, "show messages, errors and diff of each decision")
+	fs.StringVar(&output, "output", "text", "output format: text or json")
+	_ = fs.Parse(args)
+
+	if err := setResourceQuery(&q, resource); err != nil {
+		return err
+	}
+	var err error
+	if from != "" {
+		if q.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return fmt.Errorf("invalid -from; %s", err.Error())
+		}
+	} else if since > 0 {
+		q.From = time.Now().Add(-since)
+	}
+	if to != "" {
+		if q.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return fmt.Errorf("invalid -to; %s", err.Error())
+		}
+	}
+	if denied {
+		allowed := false
+		q.Allowed = &allowed
+	}
+
+	body, err := proxyGet(namespace, selector, port, token, eventstore.QueryPath, q.Values())
+	if err != nil {
+		return err
+	}
+	var result *eventstore.QueryResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("failed to parse the response; %s", err.Error())
+	}
+	if output == "json" {
+		resultBytes, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(resultBytes))
+		return nil
+	}
+	printRecords(result.Items, detail)
+	return nil
+}
+
+// setResourceQuery sets conditions of the query from "Kind/namespace/name" or "Kind/name"
+func setResourceQuery(q *eventstore.Query, resource string) error {
+	if resource == "" {
+		return nil
+	}
+	parts := strings.Split(resource, "/")
+	switch len(parts) {
+	case 1:
+		q.Kind = parts[0]
+	case 2:
+		q.Kind, q.Name = parts[0], parts[1]
+	case 3:
+		q.Kind, q.Namespace, q.Name = parts[0], parts[1], parts[2]
+	default:
+		return fmt.Errorf("invalid -resource `%s`; it must be Kind/namespace/name or Kind/name", resource)
+	}
+	return nil
+}
+
+func runCompliance(args []string) error {
+	fs := flag.NewFlagSet("compliance", flag.ExitOnError)
+	var namespace, selector, port, token, format, outFile string
+	fs.StringVar(&namespace, "n", "integrity-shield-operator-system", "namespace where IShield is installed")
+	fs.StringVar(&selector, "selector", "app=ishield-server", "label selector of IShield server pods")
+	fs.StringVar(&port, "port", "8090", "port of the API of the observer")
+	fs.StringVar(&token, "token", "", "bearer token to call the API; default is the token in the kubeconfig")
+	fs.StringVar(&format, "format", "json", "report format: json, csv or sarif")
+	fs.StringVar(&outFile, "o", "", "output file; default is stdout")
+	_ = fs.Parse(args)
+
+	body, err := proxyGet(namespace, selector, port, token, observer.CompliancePath, url.Values{"format": []string{format}})
+	if err != nil {
+		return err
+	}
+	if outFile == "" {
+		_, err = os.Stdout.Write(body)
+		return err
+	}
+	return ioutil.WriteFile(outFile, body, 0640)
+}
+
+// proxyGet calls the API of the observer in a running IShield server pod through the pod proxy
+func proxyGet(namespace, selector, port, token, path string, params url.Values) ([]byte, error) {
+	config, err := kubeutil.GetKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		token = config.BearerToken
+	}
+	if token == "" && config.BearerTokenFile != "" {
+		tokenBytes, err := ioutil.ReadFile(config.BearerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the token file; %s", err.Error())
+		}
+		token = strings.TrimSpace(string(tokenBytes))
+	}
+	if token == "" {
+		return nil, fmt.Errorf("a bearer token is required to call the observer API; the kubeconfig has no token, so specify -token")
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := client.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	podName := ""
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == v1.PodRunning {
+			podName = pod.Name
+			break
+		}
+	}
+	if podName == "" {
+		return nil, fmt.Errorf("no running IShield server pod is found in %s with selector %s", namespace, selector)
+	}
+
+	req := client.CoreV1().RESTClient().Get().
+		Namespace(namespace).
+		Resource("pods").
+		SubResource("proxy").
+		Name(fmt.Sprintf("https:%s:%s", podName, port)).
+		Suffix(path).
+		SetHeader(observer.TokenHeader, token)
+	for key, values := range params {
+		for _, value := range values {
+			req = req.Param(key, value)
+		}
+	}
+	body, err := req.DoRaw(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s in %s; %s; %s", path, podName, err.Error(), string(body))
+	}
+	return body, nil
+}
+
+func printRecords(records []*eventstore.Record, detail bool) {
+	if len(records) == 0 {
+		fmt.Println("No events found.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tRESULT\tOPERATION\tKIND\tNAMESPACE\tNAME\tUSER\tREASON\tSIGNER")
+	for _, r := range records {
+		result := "ALLOWED"
+		if !r.Allowed {
+			result = "DENIED"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.Timestamp.Format(time.RFC3339), result, r.Operation, r.Kind, orDash(r.Namespace), r.Name, r.UserName, r.ReasonCode, orDash(r.Signer))
+		if detail {
+			_ = w.Flush()
+			printDetail(r)
+		}
+	}
+	_ = w.Flush()
+}
+
+func printDetail(r *eventstore.Record) {
+	for _, item := range []struct{ label, value string }{
+		{"request uid", r.RequestUID},
+		{"message", r.Message},
+		{"denying profile", r.DenyingProfile},
+		{"error", r.Error},
+		{"signature error", r.SignatureError},
+		{"mutation error", r.MutationError},
+	} {
+		if item.value != "" {
+			fmt.Printf("    %s: %s\n", item.label, item.value)
+		}
+	}
+	if r.Diff != "" {
+		fmt.Println("    diff:")
+		for _, line := range strings.Split(r.Diff, "\n") {
+			fmt.Printf("      %s\n", line)
+		}
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
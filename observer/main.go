@@ -36,6 +36,7 @@ func main() {
 
 	eventChannel := make(chan *tail.Line)
 	reportChannel := make(chan bool)
+	scanChannel := make(chan bool)
 
 	iShieldObserver := observer.NewIntegrityShieldObserver(logger)
 	interval := iShieldObserver.IntervalSeconds
@@ -62,13 +63,27 @@ func main() {
 		reportChannel <- true
 	})
 
+	// set gocron job to trigger scan of existing resources
+	if scanInterval := iShieldObserver.ScanIntervalSeconds; scanInterval > 0 {
+		gocron.Every(scanInterval).Second().Do(func() {
+			scanChannel <- true
+		})
+	}
+
+	// serve the query API of events and compliance reports
+	go func() {
+		if err := iShieldObserver.ServeAPI(); err != nil {
+			logger.Errorf("Failed to serve the observer API; %s", err.Error())
+		}
+	}()
+
 	// start gocron goroutine for periodical reporting
 	go func() {
 		<-gocron.Start()
 	}()
 
 	// start observer loop in main thread
-	err = iShieldObserver.Run(eventChannel, reportChannel)
+	err = iShieldObserver.Run(eventChannel, reportChannel, scanChannel)
 	if err != nil {
 		logger.Errorf("Error occured while running observer; %s", err.Error())
 		return
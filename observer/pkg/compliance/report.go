@@ -0,0 +1,290 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package compliance
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	"github.com/IBM/integrity-enforcer/shield/pkg/shield"
+)
+
+type Format string
+
+const (
+	FormatJSON  Format = "json"
+	FormatCSV   Format = "csv"
+	FormatSARIF Format = "sarif"
+)
+
+// ContentTypes of the formats, which are used by the observer API
+var ContentTypes = map[Format]string{
+	FormatJSON:  "application/json",
+	FormatCSV:   "text/csv",
+	FormatSARIF: "application/sarif+json",
+}
+
+// Report is a point-in-time compliance report, which lists resources protected by each ResourceSigningProfile and their verification status
+type Report struct {
+	Timestamp time.Time        `json:"timestamp"`
+	Profiles  []*ProfileReport `json:"profiles"`
+	Errors    []string         `json:"errors,omitempty"`
+}
+
+type ProfileReport struct {
+	// "namespace/name" of the ResourceSigningProfile
+	Profile   string                        `json:"profile"`
+	Count     map[shield.ScanResultType]int `json:"count"`
+	Resources []*shield.ScanResult          `json:"resources"`
+}
+
+// NewReport groups scan results by profiles; profiles which protect no resources are listed too.
+// A resource protected by multiple profiles is listed under each of them.
+func NewReport(results []*shield.ScanResult, profiles []rspapi.ResourceSigningProfile, scanErrors []string, now time.Time) *Report {
+	profileReports := map[string]*ProfileReport{}
+	getProfileReport := func(name string) *ProfileReport {
+		if _, ok := profileReports[name]; !ok {
+			profileReports[name] = &ProfileReport{Profile: name, Count: map[shield.ScanResultType]int{}, Resources: []*shield.ScanResult{}}
+		}
+		return profileReports[name]
+	}
+	for _, profile := range profiles {
+		if profile.Spec.Disabled {
+			continue
+		}
+		getProfileReport(fmt.Sprintf("%s/%s", profile.GetNamespace(), profile.GetName()))
+	}
+	for _, result := range results {
+		for _, name := range result.Profiles {
+			pr := getProfileReport(name)
+			pr.Count[result.Result]++
+			pr.Resources = append(pr.Resources, result)
+		}
+	}
+
+	report := &Report{Timestamp: now.UTC(), Profiles: []*ProfileReport{}, Errors: scanErrors}
+	for _, pr := range profileReports {
+		sort.SliceStable(pr.Resources, func(i, j int) bool {
+			return resourceID(pr.Resources[i]) < resourceID(pr.Resources[j])
+		})
+		report.Profiles = append(report.Profiles, pr)
+	}
+	sort.Slice(report.Profiles, func(i, j int) bool {
+		return report.Profiles[i].Profile < report.Profiles[j].Profile
+	})
+	return report
+}
+
+// resourceID is "Kind/namespace/name", or "Kind/name" for cluster scope resources
+func resourceID(r *shield.ScanResult) string {
+	if r.Namespace == "" {
+		return fmt.Sprintf("%s/%s", r.Kind, r.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", r.Kind, r.Namespace, r.Name)
+}
+
+// Write writes the report in the format
+func (self *Report) Write(w io.Writer, format Format) error {
+	switch format {
+	case FormatJSON:
+		return self.writeJSON(w)
+	case FormatCSV:
+		return self.writeCSV(w)
+	case FormatSARIF:
+		return self.writeSARIF(w)
+	default:
+		return fmt.Errorf("format \"%s\" is not supported; json, csv or sarif", format)
+	}
+}
+
+func (self *Report) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(self)
+}
+
+var csvHeader = []string{"profile", "apiVersion", "kind", "namespace", "name", "result", "signer", "signatureSource", "resourceSignatureUID", "lastVerified", "message"}
+
+// writeCSV writes a row for each pair of a profile and a resource
+func (self *Report) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, pr := range self.Profiles {
+		for _, r := range pr.Resources {
+			row := []string{pr.Profile, r.ApiVersion, r.Kind, r.Namespace, r.Name, string(r.Result), r.Signer, r.SignatureSource, r.ResourceSignatureUID, r.LastVerified, r.Message}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+/**********************************************
+
+				SARIF
+
+***********************************************/
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	toolName     = "integrity-shield"
+	toolURI      = "https://github.com/IBM/integrity-enforcer"
+)
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations"`
+	Results     []sarifResult     `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful bool                `json:"executionSuccessful"`
+	EndTimeUtc          string              `json:"endTimeUtc"`
+	Notifications       []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+type sarifNotification struct {
+	Level   string       `json:"level"`
+	Message sarifMessage `json:"message"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string                 `json:"ruleId"`
+	Kind       string                 `json:"kind"`
+	Level      string                 `json:"level"`
+	Message    sarifMessage           `json:"message"`
+	Locations  []sarifLocation        `json:"locations"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// sarifRules are the scan result types; verified resources are reported as "pass"
+var sarifRules = []struct {
+	result      shield.ScanResultType
+	level       string
+	description string
+}{
+	{shield.ScanResultVerified, "none", "The resource is verified with a valid signature"},
+	{shield.ScanResultUnsigned, "warning", "The resource has no signature"},
+	{shield.ScanResultTampered, "error", "The resource does not match its signature"},
+	{shield.ScanResultUntrustedSigner, "error", "The resource is signed by a signer who is not allowed by the signer config"},
+	{shield.ScanResultError, "warning", "The resource could not be verified"},
+	{shield.ScanResultDrifted, "error", "The resource has been changed from its signed message without passing the admission webhook"},
+}
+
+func (self *Report) writeSARIF(w io.Writer) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{Name: toolName, InformationURI: toolURI, Rules: []sarifRule{}}},
+		Invocations: []sarifInvocation{{
+			ExecutionSuccessful: len(self.Errors) == 0,
+			EndTimeUtc:          self.Timestamp.UTC().Format(time.RFC3339),
+		}},
+		Results: []sarifResult{},
+	}
+	levels := map[shield.ScanResultType]string{}
+	for _, rule := range sarifRules {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: string(rule.result), ShortDescription: sarifMessage{Text: rule.description}})
+		levels[rule.result] = rule.level
+	}
+	for _, errMsg := range self.Errors {
+		run.Invocations[0].Notifications = append(run.Invocations[0].Notifications, sarifNotification{Level: "warning", Message: sarifMessage{Text: errMsg}})
+	}
+	for _, pr := range self.Profiles {
+		for _, r := range pr.Resources {
+			kind := "fail"
+			if r.Result == shield.ScanResultVerified {
+				kind = "pass"
+			}
+			msg := fmt.Sprintf("%s is %s in %s", resourceID(r), strings.ToLower(string(r.Result)), pr.Profile)
+			if r.Message != "" {
+				msg = fmt.Sprintf("%s; %s", msg, r.Message)
+			}
+			props := map[string]interface{}{"profile": pr.Profile, "apiVersion": r.ApiVersion}
+			for key, value := range map[string]string{
+				"signer":               r.Signer,
+				"signatureSource":      r.SignatureSource,
+				"resourceSignatureUID": r.ResourceSignatureUID,
+				"lastVerified":         r.LastVerified,
+				"diff":                 r.Diff,
+			} {
+				if value != "" {
+					props[key] = value
+				}
+			}
+			run.Results = append(run.Results, sarifResult{
+				RuleID:  string(r.Result),
+				Kind:    kind,
+				Level:   levels[r.Result],
+				Message: sarifMessage{Text: msg},
+				Locations: []sarifLocation{{LogicalLocations: []sarifLogicalLocation{{
+					Name:               r.Name,
+					FullyQualifiedName: resourceID(r),
+					Kind:               r.Kind,
+				}}}},
+				Properties: props,
+			})
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&sarifLog{Version: sarifVersion, Schema: sarifSchema, Runs: []sarifRun{run}})
+}
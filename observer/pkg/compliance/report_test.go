@@ -0,0 +1,91 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package compliance
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	"github.com/IBM/integrity-enforcer/shield/pkg/shield"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func getTestReport() *Report {
+	results := []*shield.ScanResult{
+		{ApiVersion: "v1", Kind: "ConfigMap", Namespace: "test-ns", Name: "cm2", Result: shield.ScanResultUnsigned, Message: "no signature found", Profiles: []string{"test-ns/rsp1"}},
+		{ApiVersion: "v1", Kind: "ConfigMap", Namespace: "test-ns", Name: "cm1", Result: shield.ScanResultVerified, Signer: "signer1", SignatureSource: shield.SignatureSourceResourceSignature, ResourceSignatureUID: "uid-1", LastVerified: "2020-10-01T00:00:00Z", Profiles: []string{"test-ns/rsp1", "test-ns/rsp2"}},
+	}
+	profiles := []rspapi.ResourceSigningProfile{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "rsp1"}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "rsp3"}},
+	}
+	return NewReport(results, profiles, nil, time.Date(2020, 10, 2, 0, 0, 0, 0, time.UTC))
+}
+
+func TestNewReport(t *testing.T) {
+	report := getTestReport()
+	if len(report.Profiles) != 3 {
+		t.Fatalf("Failed to test NewReport(); unexpected profiles %v", report.Profiles)
+	}
+	rsp1 := report.Profiles[0]
+	if rsp1.Profile != "test-ns/rsp1" || len(rsp1.Resources) != 2 || rsp1.Resources[0].Name != "cm1" || rsp1.Count[shield.ScanResultUnsigned] != 1 {
+		t.Errorf("Failed to test NewReport(); unexpected report of rsp1 %v", rsp1)
+	}
+	if rsp3 := report.Profiles[2]; rsp3.Profile != "test-ns/rsp3" || len(rsp3.Resources) != 0 {
+		t.Errorf("Failed to test NewReport(); profiles without resources must be listed: %v", rsp3)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	report := getTestReport()
+
+	var buf bytes.Buffer
+	if err := report.Write(&buf, FormatCSV); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(rows) != 4 || len(rows[1]) != len(csvHeader) {
+		t.Fatalf("Failed to test Write(); unexpected csv %v, %v", rows, err)
+	}
+	if rows[1][0] != "test-ns/rsp1" || rows[1][7] != shield.SignatureSourceResourceSignature || rows[1][9] != "2020-10-01T00:00:00Z" {
+		t.Errorf("Failed to test Write(); unexpected csv row %v", rows[1])
+	}
+
+	buf.Reset()
+	if err := report.Write(&buf, FormatSARIF); err != nil {
+		t.Fatal(err)
+	}
+	var sarif sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &sarif); err != nil || sarif.Version != sarifVersion || len(sarif.Runs) != 1 {
+		t.Fatalf("Failed to test Write(); unexpected sarif %s, %v", buf.String(), err)
+	}
+	results := sarif.Runs[0].Results
+	if len(results) != 3 || results[0].Kind != "pass" || results[0].Level != "none" || results[1].RuleID != string(shield.ScanResultUnsigned) || results[1].Level != "warning" {
+		t.Errorf("Failed to test Write(); unexpected sarif results %v", results)
+	}
+	if loc := results[1].Locations[0].LogicalLocations[0]; loc.FullyQualifiedName != "ConfigMap/test-ns/cm2" {
+		t.Errorf("Failed to test Write(); unexpected sarif location %v", loc)
+	}
+
+	if err := report.Write(&buf, "xml"); err == nil {
+		t.Errorf("Failed to test Write(); unknown format must be rejected")
+	}
+}
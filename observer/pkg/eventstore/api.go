@@ -0,0 +1,64 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package eventstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// path of the query API served by the observer
+const QueryPath = "/api/v1/events"
+
+// max number of records returned by the query API when no limit is specified
+const defaultQueryLimit = 100
+
+// QueryResult is the response body of the query API
+type QueryResult struct {
+	Items []*Record `json:"items"`
+}
+
+// NewQueryHandler returns a handler of the query API, which selects records by URL query parameters (see ParseQuery)
+func NewQueryHandler(store *Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, fmt.Sprintf("method %s is not allowed", req.Method), http.StatusMethodNotAllowed)
+			return
+		}
+		q, err := ParseQuery(req.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if q.Limit == 0 {
+			q.Limit = defaultQueryLimit
+		}
+		records, err := store.Query(q)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to query events; %s", err.Error()), http.StatusInternalServerError)
+			return
+		}
+		body, err := json.Marshal(&QueryResult{Items: records})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal events; %s", err.Error()), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	})
+}
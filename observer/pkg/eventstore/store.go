@@ -0,0 +1,525 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package eventstore
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+)
+
+const (
+	segmentPrefix        = "decisions-"
+	activeSegmentSuffix  = ".jsonl"
+	closedSegmentSuffix  = ".jsonl.gz"
+	defaultSegmentSize   = int64(10485760) // 10MB
+	defaultSegmentMaxAge = time.Hour
+	// time format of "timestamp" in event logs
+	eventTimeFormat = "2006-01-02T15:04:05.000Z"
+	// max size of a record line
+	maxRecordSize = 1048576
+)
+
+/**********************************************
+
+				Record
+
+***********************************************/
+
+// Record is a decision of iShield for a request, which is extracted from the event log
+type Record struct {
+	Timestamp      time.Time `json:"timestamp"`
+	RequestUID     string    `json:"requestUid,omitempty"`
+	Namespace      string    `json:"namespace,omitempty"`
+	Name           string    `json:"name"`
+	ApiGroup       string    `json:"apiGroup,omitempty"`
+	ApiVersion     string    `json:"apiVersion,omitempty"`
+	Kind           string    `json:"kind"`
+	Operation      string    `json:"operation"`
+	UserName       string    `json:"userName,omitempty"`
+	Allowed        bool      `json:"allowed"`
+	Verified       bool      `json:"verified,omitempty"`
+	Protected      bool      `json:"protected,omitempty"`
+	ReasonCode     string    `json:"reasonCode,omitempty"`
+	Message        string    `json:"message,omitempty"`
+	Signer         string    `json:"signer,omitempty"`
+	DenyingProfile string    `json:"denyingProfile,omitempty"`
+	// details of the decision, e.g. why the signature or the match of the resource failed
+	Error          string `json:"error,omitempty"`
+	SignatureError string `json:"signatureError,omitempty"`
+	MutationError  string `json:"mutationError,omitempty"`
+	// diff between the requested object and the signed one
+	Diff string `json:"diff,omitempty"`
+}
+
+// NewRecord extracts a record from the event log, or returns nil if the event is not a decision for a request.
+// now is used if the event has no valid timestamp.
+func NewRecord(e map[string]interface{}, now time.Time) *Record {
+	allowed, ok := e["allowed"].(bool)
+	if !ok {
+		return nil
+	}
+	r := &Record{Allowed: allowed, Timestamp: now.UTC()}
+	if ts, ok := e["timestamp"].(string); ok {
+		if t, err := time.Parse(eventTimeFormat, ts); err == nil {
+			r.Timestamp = t
+		}
+	}
+	r.RequestUID, _ = e["request.uid"].(string)
+	r.Namespace, _ = e["namespace"].(string)
+	r.Name, _ = e["name"].(string)
+	r.ApiGroup, _ = e["apiGroup"].(string)
+	r.ApiVersion, _ = e["apiVersion"].(string)
+	r.Kind, _ = e["kind"].(string)
+	r.Operation, _ = e["operation"].(string)
+	r.UserName, _ = e["userName"].(string)
+	r.Verified, _ = e["verified"].(bool)
+	r.Protected, _ = e["protected"].(bool)
+	r.ReasonCode, _ = e["reasonCode"].(string)
+	r.Message, _ = e["msg"].(string)
+	r.Signer, _ = e["sig.signer.displayName"].(string)
+	r.DenyingProfile, _ = e["denyingProfile"].(string)
+	r.Error, _ = e["error"].(string)
+	r.SignatureError, _ = e["sig.errMsg"].(string)
+	r.MutationError, _ = e["ma.errMsg"].(string)
+	r.Diff, _ = e["ma.diff"].(string)
+	return r
+}
+
+/**********************************************
+
+				Query
+
+***********************************************/
+
+// Query selects records; empty conditions match all records.
+// Namespace, Kind, Name, UserName, Reason and Signer accept wildcard patterns such as "kube-*".
+type Query struct {
+	// records in [From, To) are selected
+	From      time.Time
+	To        time.Time
+	Namespace string
+	Kind      string
+	Name      string
+	UserName  string
+	// reason code such as "no-signature"
+	Reason  string
+	Signer  string
+	Allowed *bool
+	// max number of records; the latest records are returned if more records are matched
+	Limit int
+}
+
+// names of URL query parameters of Query
+const (
+	QueryParamFrom      = "from"
+	QueryParamTo        = "to"
+	QueryParamNamespace = "namespace"
+	QueryParamKind      = "kind"
+	QueryParamName      = "name"
+	QueryParamUser      = "user"
+	QueryParamReason    = "reason"
+	QueryParamSigner    = "signer"
+	QueryParamAllowed   = "allowed"
+	QueryParamLimit     = "limit"
+)
+
+// ParseQuery reads a query from URL query parameters; from and to are RFC3339 timestamps.
+func ParseQuery(values url.Values) (Query, error) {
+	q := Query{
+		Namespace: values.Get(QueryParamNamespace),
+		Kind:      values.Get(QueryParamKind),
+		Name:      values.Get(QueryParamName),
+		UserName:  values.Get(QueryParamUser),
+		Reason:    values.Get(QueryParamReason),
+		Signer:    values.Get(QueryParamSigner),
+	}
+	var err error
+	if v := values.Get(QueryParamFrom); v != "" {
+		if q.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return q, fmt.Errorf("invalid %s; %s", QueryParamFrom, err.Error())
+		}
+	}
+	if v := values.Get(QueryParamTo); v != "" {
+		if q.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return q, fmt.Errorf("invalid %s; %s", QueryParamTo, err.Error())
+		}
+	}
+	if v := values.Get(QueryParamAllowed); v != "" {
+		allowed, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, fmt.Errorf("invalid %s; %s", QueryParamAllowed, err.Error())
+		}
+		q.Allowed = &allowed
+	}
+	if v := values.Get(QueryParamLimit); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 0 {
+			return q, fmt.Errorf("invalid %s; %s", QueryParamLimit, v)
+		}
+	}
+	return q, nil
+}
+
+// Values returns URL query parameters of the query, which are read by ParseQuery
+func (self Query) Values() url.Values {
+	values := url.Values{}
+	if !self.From.IsZero() {
+		values.Set(QueryParamFrom, self.From.UTC().Format(time.RFC3339))
+	}
+	if !self.To.IsZero() {
+		values.Set(QueryParamTo, self.To.UTC().Format(time.RFC3339))
+	}
+	for key, value := range map[string]string{
+		QueryParamNamespace: self.Namespace,
+		QueryParamKind:      self.Kind,
+		QueryParamName:      self.Name,
+		QueryParamUser:      self.UserName,
+		QueryParamReason:    self.Reason,
+		QueryParamSigner:    self.Signer,
+	} {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	if self.Allowed != nil {
+		values.Set(QueryParamAllowed, strconv.FormatBool(*self.Allowed))
+	}
+	if self.Limit > 0 {
+		values.Set(QueryParamLimit, strconv.Itoa(self.Limit))
+	}
+	return values
+}
+
+func (self Query) Match(r *Record) bool {
+	if !self.From.IsZero() && r.Timestamp.Before(self.From) {
+		return false
+	}
+	if !self.To.IsZero() && !r.Timestamp.Before(self.To) {
+		return false
+	}
+	if self.Allowed != nil && *self.Allowed != r.Allowed {
+		return false
+	}
+	for _, cond := range []struct{ pattern, value string }{
+		{self.Namespace, r.Namespace},
+		{self.Kind, r.Kind},
+		{self.Name, r.Name},
+		{self.UserName, r.UserName},
+		{self.Reason, r.ReasonCode},
+		{self.Signer, r.Signer},
+	} {
+		if cond.pattern != "" && !common.MatchPattern(cond.pattern, cond.value) {
+			return false
+		}
+	}
+	return true
+}
+
+/**********************************************
+
+				Store
+
+***********************************************/
+
+type Config struct {
+	// directory of segment files, e.g. a mounted PersistentVolume
+	Dir string
+	// records older than the retention are removed; 0 keeps all records
+	Retention time.Duration
+	// the active segment is compressed when it exceeds the size or the age
+	SegmentSize   int64
+	SegmentMaxAge time.Duration
+}
+
+// Store keeps records in segment files named with the time when the segment is created.
+// Records are appended to the active segment, and older segments are compressed with gzip.
+// A segment is removed when all of its records are older than the retention, i.e. when the next segment was created before that.
+type Store struct {
+	config Config
+
+	mu sync.Mutex
+}
+
+type segment struct {
+	path    string
+	start   time.Time
+	closed  bool
+	endTime time.Time
+}
+
+func Open(config Config) (*Store, error) {
+	if config.Dir == "" {
+		return nil, fmt.Errorf("directory of the event store is not specified")
+	}
+	if config.SegmentSize <= 0 {
+		config.SegmentSize = defaultSegmentSize
+	}
+	if config.SegmentMaxAge <= 0 {
+		config.SegmentMaxAge = defaultSegmentMaxAge
+	}
+	if err := os.MkdirAll(config.Dir, 0750); err != nil {
+		return nil, err
+	}
+	return &Store{config: config}, nil
+}
+
+// listSegments returns segments in the order of creation; endTime of the last segment is zero
+func (self *Store) listSegments() ([]*segment, error) {
+	files, err := ioutil.ReadDir(self.config.Dir)
+	if err != nil {
+		return nil, err
+	}
+	segments := []*segment{}
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasPrefix(name, segmentPrefix) {
+			continue
+		}
+		closed := strings.HasSuffix(name, closedSegmentSuffix)
+		if !closed && !strings.HasSuffix(name, activeSegmentSuffix) {
+			continue
+		}
+		startStr := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), closedSegmentSuffix), activeSegmentSuffix)
+		startNano, err := strconv.ParseInt(startStr, 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, &segment{path: filepath.Join(self.config.Dir, name), start: time.Unix(0, startNano).UTC(), closed: closed})
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].start.Before(segments[j].start)
+	})
+	for i := 0; i < len(segments)-1; i++ {
+		segments[i].endTime = segments[i+1].start
+	}
+	return segments, nil
+}
+
+func (self *Store) segmentPath(start time.Time, closed bool) string {
+	suffix := activeSegmentSuffix
+	if closed {
+		suffix = closedSegmentSuffix
+	}
+	return filepath.Join(self.config.Dir, fmt.Sprintf("%s%d%s", segmentPrefix, start.UnixNano(), suffix))
+}
+
+// activeSegment returns the path of the segment to append records, and compresses the current one if it is full or old
+func (self *Store) activeSegment(now time.Time) (string, error) {
+	segments, err := self.listSegments()
+	if err != nil {
+		return "", err
+	}
+	if len(segments) > 0 {
+		last := segments[len(segments)-1]
+		if !last.closed {
+			fi, err := os.Stat(last.path)
+			if err != nil {
+				return "", err
+			}
+			if fi.Size() < self.config.SegmentSize && now.Sub(last.start) < self.config.SegmentMaxAge {
+				return last.path, nil
+			}
+			if err := self.closeSegment(last); err != nil {
+				return "", err
+			}
+		}
+		if !now.After(last.start) {
+			now = last.start.Add(time.Nanosecond)
+		}
+	}
+	return self.segmentPath(now, false), nil
+}
+
+func (self *Store) closeSegment(seg *segment) error {
+	dst := self.segmentPath(seg.start, true)
+	in, err := os.Open(filepath.Clean(seg.path))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0640) // NOSONAR
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+	return os.Remove(seg.path)
+}
+
+// Append writes the records into the active segment
+func (self *Store) Append(records []*Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	return self.append(records, time.Now().UTC())
+}
+
+func (self *Store) append(records []*Record, now time.Time) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	path, err := self.activeSegment(now)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640) // NOSONAR
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, r := range records {
+		line, err := json.Marshal(r)
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+		_, _ = w.Write(line)
+		_ = w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ApplyRetention removes segments whose records are all older than the retention, and returns the number of removed segments
+func (self *Store) ApplyRetention(now time.Time) (int, error) {
+	if self.config.Retention <= 0 {
+		return 0, nil
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	segments, err := self.listSegments()
+	if err != nil {
+		return 0, err
+	}
+	cutoff := now.Add(-self.config.Retention)
+	removed := 0
+	for _, seg := range segments {
+		if seg.endTime.IsZero() || !seg.endTime.Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(seg.path); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Query returns the records matched with the query in the order of time
+// Segments are listed under the lock and read without it, so that a long query does not block appending records.
+func (self *Store) Query(q Query) ([]*Record, error) {
+	self.mu.Lock()
+	segments, err := self.listSegments()
+	self.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	records := []*Record{}
+	for _, seg := range segments {
+		// segments are skipped by the creation time, and records are checked individually
+		if !seg.endTime.IsZero() && !q.From.IsZero() && seg.endTime.Before(q.From) {
+			continue
+		}
+		if !q.To.IsZero() && !seg.start.Before(q.To) {
+			continue
+		}
+		matched, err := self.readSegment(seg, q)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, matched...)
+		if q.Limit > 0 && len(records) > q.Limit {
+			records = records[len(records)-q.Limit:]
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Timestamp.Before(records[j].Timestamp)
+	})
+	return records, nil
+}
+
+// readSegment reads the segment listed before; the active segment may have been compressed, and a segment may have been removed by the retention since then
+func (self *Store) readSegment(seg *segment, q Query) ([]*Record, error) {
+	f, err := os.Open(filepath.Clean(seg.path))
+	if err != nil && os.IsNotExist(err) && !seg.closed {
+		closedSeg := &segment{path: self.segmentPath(seg.start, true), start: seg.start, closed: true, endTime: seg.endTime}
+		return self.readSegment(closedSeg, q)
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	var reader io.Reader = f
+	if seg.closed {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s; %s", seg.path, err.Error())
+		}
+		defer func() {
+			_ = zr.Close()
+		}()
+		reader = zr
+	}
+	records := []*Record{}
+	s := bufio.NewScanner(reader)
+	s.Buffer(make([]byte, 0, 64*1024), maxRecordSize)
+	for s.Scan() {
+		var r *Record
+		// a partially written line is skipped
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil || r == nil {
+			continue
+		}
+		if q.Match(r) {
+			records = append(records, r)
+		}
+	}
+	return records, s.Err()
+}
@@ -0,0 +1,168 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package eventstore
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) (*Store, func()) {
+	dir, err := ioutil.TempDir("", "eventstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := Open(Config{Dir: dir, Retention: 24 * time.Hour, SegmentMaxAge: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, func() { os.RemoveAll(dir) }
+}
+
+func TestNewRecord(t *testing.T) {
+	var e map[string]interface{}
+	_ = json.Unmarshal([]byte(`{"namespace":"test-ns","name":"cm1","kind":"ConfigMap","operation":"CREATE","userName":"user1","allowed":false,"reasonCode":"no-signature","denyingProfile":"test-ns/rsp1","timestamp":"2020-10-01T00:00:00.000Z"}`), &e)
+	r := NewRecord(e, time.Now())
+	if r == nil || r.Allowed || r.ReasonCode != "no-signature" || r.DenyingProfile != "test-ns/rsp1" || !r.Timestamp.Equal(time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Failed to test NewRecord(); unexpected record %v", r)
+	}
+	if r := NewRecord(map[string]interface{}{"name": "cm1"}, time.Now()); r != nil {
+		t.Errorf("Failed to test NewRecord(); events without decision must be ignored")
+	}
+}
+
+func TestAppendAndQuery(t *testing.T) {
+	store, cleanup := newTestStore(t)
+	defer cleanup()
+
+	start := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		now := start.Add(time.Duration(i) * 90 * time.Minute)
+		records := []*Record{
+			{Timestamp: now, Namespace: "test-ns", Kind: "ConfigMap", Name: "cm1", UserName: "user1", Allowed: false, ReasonCode: "no-signature"},
+			{Timestamp: now.Add(time.Minute), Namespace: "kube-system", Kind: "Secret", Name: "s1", UserName: "user2", Allowed: true, ReasonCode: "valid-sig"},
+		}
+		if err := store.append(records, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, _ := ioutil.ReadDir(store.config.Dir)
+	closed := 0
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), closedSegmentSuffix) {
+			closed++
+		}
+	}
+	if len(files) != 3 || closed != 2 {
+		t.Errorf("Failed to test Append(); old segments must be compressed: %d files, %d closed", len(files), closed)
+	}
+
+	denied := false
+	records, err := store.Query(Query{Allowed: &denied})
+	if err != nil || len(records) != 3 {
+		t.Errorf("Failed to test Query(); unexpected denied records %v, %v", records, err)
+	}
+	records, _ = store.Query(Query{Namespace: "kube-*", From: start.Add(time.Hour), To: start.Add(3 * time.Hour)})
+	if len(records) != 1 || records[0].Name != "s1" {
+		t.Errorf("Failed to test Query(); unexpected records in the time range %v", records)
+	}
+	records, _ = store.Query(Query{Reason: "no-signature", UserName: "user1", Limit: 2})
+	if len(records) != 2 || !records[1].Timestamp.Equal(start.Add(180*time.Minute)) {
+		t.Errorf("Failed to test Query(); the latest records must be returned with the limit: %v", records)
+	}
+
+	// the first segment is older than the retention; the last one is always kept
+	removed, err := store.ApplyRetention(start.Add(26 * time.Hour))
+	if err != nil || removed != 1 {
+		t.Errorf("Failed to test ApplyRetention(); %d segments are removed, %v", removed, err)
+	}
+	records, _ = store.Query(Query{})
+	if len(records) != 4 {
+		t.Errorf("Failed to test ApplyRetention(); unexpected records %v", records)
+	}
+}
+
+func TestReadSegmentCompressedAfterListing(t *testing.T) {
+	store, cleanup := newTestStore(t)
+	defer cleanup()
+
+	now := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	if err := store.append([]*Record{{Timestamp: now, Namespace: "test-ns", Kind: "ConfigMap", Name: "cm1", ReasonCode: "no-signature"}}, now); err != nil {
+		t.Fatal(err)
+	}
+	segments, err := store.listSegments()
+	if err != nil || len(segments) != 1 {
+		t.Fatalf("unexpected segments %v, %v", segments, err)
+	}
+	// the active segment is compressed by Append while Query reads segments without the lock
+	if err := store.closeSegment(segments[0]); err != nil {
+		t.Fatal(err)
+	}
+	records, err := store.readSegment(segments[0], Query{})
+	if err != nil || len(records) != 1 {
+		t.Errorf("Failed to test readSegment(); records of the compressed segment must be read %v, %v", records, err)
+	}
+}
+
+func TestQueryHandler(t *testing.T) {
+	store, cleanup := newTestStore(t)
+	defer cleanup()
+
+	now := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	records := []*Record{
+		{Timestamp: now, Namespace: "test-ns", Kind: "Deployment", Name: "app1", UserName: "user1", Allowed: false, ReasonCode: "no-signature", Diff: "spec.replicas: 1 -> 2"},
+		{Timestamp: now.Add(time.Minute), Namespace: "test-ns", Kind: "Deployment", Name: "app2", UserName: "user1", Allowed: true, ReasonCode: "valid-sig", Signer: "signer1"},
+	}
+	if err := store.append(records, now); err != nil {
+		t.Fatal(err)
+	}
+	handler := NewQueryHandler(store)
+
+	denied := false
+	q := Query{Namespace: "test-ns", Kind: "Deployment", Name: "app*", From: now.Add(-time.Hour), Allowed: &denied}
+	parsed, err := ParseQuery(q.Values())
+	if err != nil || parsed.Name != q.Name || !parsed.From.Equal(q.From) || parsed.Allowed == nil || *parsed.Allowed {
+		t.Errorf("Failed to test ParseQuery(); the query is not restored from its values: %v, %v", parsed, err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, QueryPath+"?"+q.Values().Encode(), nil))
+	var result QueryResult
+	_ = json.Unmarshal(w.Body.Bytes(), &result)
+	if w.Code != http.StatusOK || len(result.Items) != 1 || result.Items[0].Diff == "" {
+		t.Errorf("Failed to test NewQueryHandler(); unexpected response %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, QueryPath+"?signer=signer1", nil))
+	_ = json.Unmarshal(w.Body.Bytes(), &result)
+	if w.Code != http.StatusOK || len(result.Items) != 1 || result.Items[0].Name != "app2" {
+		t.Errorf("Failed to test NewQueryHandler(); unexpected response for the signer %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, QueryPath+"?from=yesterday", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Failed to test NewQueryHandler(); invalid time must be rejected, but got %d", w.Code)
+	}
+}
@@ -0,0 +1,189 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package observer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/IBM/integrity-enforcer/observer/pkg/compliance"
+	"github.com/IBM/integrity-enforcer/observer/pkg/eventstore"
+	kubeutil "github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
+	tlsutil "github.com/IBM/integrity-enforcer/shield/pkg/util/tlsutil"
+	authnv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// path of the compliance report API; the format is specified by `format` query parameter (json, csv or sarif)
+const CompliancePath = "/api/v1/compliance"
+
+// TokenHeader is the header of the bearer token of the caller.
+// The API server removes the Authorization header when it proxies a request to a pod, so the token is sent in this header through the pod proxy.
+const TokenHeader = "X-IShield-Token"
+
+const defaultAPITLSCertFile = "/run/secrets/tls/tls.crt"
+const defaultAPITLSKeyFile = "/run/secrets/tls/tls.key"
+const apiCertReloadInterval = 60 * time.Second
+
+// ServeAPI serves the query API of decision records in the event store and the compliance report of the last scan;
+// it blocks until the server stops. The API is reached through the pod proxy of the API server, e.g. by `ishieldctl`.
+// It is served with the TLS certificate of IShield server, and only callers who can `get` `pods/proxy` in IShield namespace are allowed,
+// because the records include user names, diffs of resources and denial messages.
+func (self *IntegrityShieldObserver) ServeAPI() error {
+	mux := http.NewServeMux()
+	if self.EventStore != nil {
+		mux.Handle(eventstore.QueryPath, eventstore.NewQueryHandler(self.EventStore))
+	} else {
+		mux.HandleFunc(eventstore.QueryPath, func(w http.ResponseWriter, req *http.Request) {
+			http.Error(w, "event store is not enabled", http.StatusNotFound)
+		})
+	}
+	mux.HandleFunc(CompliancePath, self.serveComplianceReport)
+
+	config, err := kubeutil.GetKubeConfig()
+	if err != nil {
+		return err
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	certProvider, err := tlsutil.NewCertificateProvider(self.APITLSCertFile, self.APITLSKeyFile, apiCertReloadInterval)
+	if err != nil {
+		return fmt.Errorf("unable to load certs for the observer API; %s", err.Error())
+	}
+	go certProvider.Run(make(chan struct{}))
+
+	server := &http.Server{
+		Addr:         ":" + self.APIPort,
+		Handler:      newAPIAuthHandler(client, self.IShiledNamespace, mux),
+		TLSConfig:    &tls.Config{GetCertificate: certProvider.GetCertificate, MinVersion: tls.VersionTLS12},
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 60 * time.Second,
+	}
+	self.logger.Infof("Serving the observer API on port %s", self.APIPort)
+	return server.ListenAndServeTLS("", "")
+}
+
+// apiAuthHandler authenticates the caller by TokenReview, and checks by SubjectAccessReview that the caller can `get` `pods/proxy` in IShield namespace
+type apiAuthHandler struct {
+	client    kubernetes.Interface
+	namespace string
+	handler   http.Handler
+}
+
+func newAPIAuthHandler(client kubernetes.Interface, namespace string, handler http.Handler) http.Handler {
+	return &apiAuthHandler{client: client, namespace: namespace, handler: handler}
+}
+
+func (self *apiAuthHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	token := req.Header.Get(TokenHeader)
+	if token == "" {
+		token = strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		http.Error(w, fmt.Sprintf("a bearer token is required in %s or Authorization header", TokenHeader), http.StatusUnauthorized)
+		return
+	}
+	ctx := context.Background()
+	review, err := self.client.AuthenticationV1().TokenReviews().Create(ctx, &authnv1.TokenReview{Spec: authnv1.TokenReviewSpec{Token: token}}, metav1.CreateOptions{})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to review the token; %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	if !review.Status.Authenticated {
+		http.Error(w, "the token is not authenticated", http.StatusUnauthorized)
+		return
+	}
+	user := review.Status.User
+	extra := map[string]authzv1.ExtraValue{}
+	for key, value := range user.Extra {
+		extra[key] = authzv1.ExtraValue(value)
+	}
+	sar := &authzv1.SubjectAccessReview{
+		Spec: authzv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			UID:    user.UID,
+			Groups: user.Groups,
+			Extra:  extra,
+			ResourceAttributes: &authzv1.ResourceAttributes{
+				Namespace:   self.namespace,
+				Verb:        "get",
+				Resource:    "pods",
+				Subresource: "proxy",
+			},
+		},
+	}
+	result, err := self.client.AuthorizationV1().SubjectAccessReviews().Create(ctx, sar, metav1.CreateOptions{})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to review the access; %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	if !result.Status.Allowed {
+		http.Error(w, fmt.Sprintf("user %s cannot get pods/proxy in namespace %s", user.Username, self.namespace), http.StatusForbidden)
+		return
+	}
+	self.handler.ServeHTTP(w, req)
+}
+
+func (self *IntegrityShieldObserver) setComplianceReport(report *compliance.Report) {
+	self.complianceMu.Lock()
+	defer self.complianceMu.Unlock()
+	self.complianceReport = report
+}
+
+func (self *IntegrityShieldObserver) getComplianceReport() *compliance.Report {
+	self.complianceMu.RLock()
+	defer self.complianceMu.RUnlock()
+	return self.complianceReport
+}
+
+func (self *IntegrityShieldObserver) serveComplianceReport(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, fmt.Sprintf("method %s is not allowed", req.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	format := compliance.Format(req.URL.Query().Get("format"))
+	if format == "" {
+		format = compliance.FormatJSON
+	}
+	contentType, ok := compliance.ContentTypes[format]
+	if !ok {
+		http.Error(w, fmt.Sprintf("format \"%s\" is not supported; json, csv or sarif", format), http.StatusBadRequest)
+		return
+	}
+	report := self.getComplianceReport()
+	if report == nil {
+		http.Error(w, "no scan has been completed yet", http.StatusServiceUnavailable)
+		return
+	}
+	var buf bytes.Buffer
+	if err := report.Write(&buf, format); err != nil {
+		http.Error(w, fmt.Sprintf("failed to write the compliance report; %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(buf.Bytes())
+}
@@ -0,0 +1,144 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package observer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	"github.com/IBM/integrity-enforcer/shield/pkg/shield"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const driftEventSourceName = "IntegrityShield"
+const driftEventReason = "drift-detected"
+
+// driftedResource is a live resource which has been changed from its signed message without passing the webhook
+type driftedResource struct {
+	gvr    schema.GroupVersionResource
+	obj    *unstructured.Unstructured
+	result *shield.ScanResult
+}
+
+// reportDrift reports drifted resources as Events, and re-labels them as unverified if DriftRelabel is enabled
+func (self *IntegrityShieldObserver) reportDrift(client kubernetes.Interface, dyClient dynamic.Interface, drifted []*driftedResource) {
+	now := time.Now()
+	for _, d := range drifted {
+		self.logger.Warnf("Drift detected: %s %s/%s has been changed from its signed message; %s", d.result.Kind, d.result.Namespace, d.result.Name, d.result.Diff)
+		if err := self.createOrUpdateDriftEvent(client, d, now); err != nil {
+			self.logger.Errorf("Failed to report drift of %s %s/%s; %s", d.result.Kind, d.result.Namespace, d.result.Name, err.Error())
+		}
+		if !self.DriftRelabel {
+			continue
+		}
+		if err := relabelUnverified(dyClient, d); err != nil {
+			self.logger.Errorf("Failed to relabel %s %s/%s as unverified; %s", d.result.Kind, d.result.Namespace, d.result.Name, err.Error())
+		}
+	}
+}
+
+func (self *IntegrityShieldObserver) createOrUpdateDriftEvent(client kubernetes.Interface, d *driftedResource, now time.Time) error {
+	// Events of cluster scope resources are created in the iShield namespace
+	evtNamespace := d.obj.GetNamespace()
+	if evtNamespace == "" {
+		evtNamespace = self.IShiledNamespace
+	}
+	evtName := fmt.Sprintf("ishield-drift-%s-%s", strings.ToLower(d.obj.GetKind()), d.obj.GetName())
+	if len(evtName) > validation.DNS1123SubdomainMaxLength {
+		evtName = evtName[:validation.DNS1123SubdomainMaxLength]
+	}
+	msg := fmt.Sprintf("[IntegrityShieldEvent] Result: drift, Reason: \"%s %s has been changed from its signed message\", Diff: %s", d.obj.GetKind(), d.obj.GetName(), d.result.Diff)
+	// Event.Message can have 1024 chars at most
+	if len(msg) > 1024 {
+		msg = msg[:950] + " ... Trimmed. `Event.Message` can have 1024 chars at maximum."
+	}
+
+	events := client.CoreV1().Events(evtNamespace)
+	current, err := events.Get(context.Background(), evtName, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err == nil {
+		current.Message = msg
+		current.Count = current.Count + 1
+		current.LastTimestamp = metav1.NewTime(now)
+		current.EventTime = metav1.NewMicroTime(now)
+		_, err = events.Update(context.Background(), current, metav1.UpdateOptions{})
+		return err
+	}
+	evt := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      evtName,
+			Namespace: evtNamespace,
+			Annotations: map[string]string{
+				common.EventTypeAnnotationKey: common.EventTypeValueDriftReport,
+			},
+		},
+		InvolvedObject: v1.ObjectReference{
+			Kind:       d.obj.GetKind(),
+			Name:       d.obj.GetName(),
+			Namespace:  d.obj.GetNamespace(),
+			APIVersion: d.obj.GetAPIVersion(),
+			UID:        d.obj.GetUID(),
+		},
+		Type:                driftEventSourceName,
+		Source:              v1.EventSource{Component: driftEventSourceName},
+		ReportingController: driftEventSourceName,
+		ReportingInstance:   evtName,
+		Action:              driftEventReason,
+		Reason:              driftEventReason,
+		Message:             msg,
+		Count:               1,
+		FirstTimestamp:      metav1.NewTime(now),
+		LastTimestamp:       metav1.NewTime(now),
+		EventTime:           metav1.NewMicroTime(now),
+	}
+	_, err = events.Create(context.Background(), evt, metav1.CreateOptions{})
+	return err
+}
+
+// relabelUnverified changes the resourceIntegrity label of the resource from verified to unverified
+func relabelUnverified(dyClient dynamic.Interface, d *driftedResource) error {
+	if d.obj.GetLabels()[common.ResourceIntegrityLabelKey] != common.LabelValueVerified {
+		return nil
+	}
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{
+				common.ResourceIntegrityLabelKey: common.LabelValueUnverified,
+			},
+		},
+	}
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	_, err = dyClient.Resource(d.gvr).Namespace(d.obj.GetNamespace()).Patch(context.Background(), d.obj.GetName(), types.MergePatchType, patchBytes, metav1.PatchOptions{})
+	return err
+}
@@ -0,0 +1,64 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package observer
+
+import (
+	"context"
+	"testing"
+
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	"github.com/IBM/integrity-enforcer/shield/pkg/shield"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestReportDrift(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetNamespace("test-ns")
+	obj.SetName("cm1")
+	obj.SetLabels(map[string]string{common.ResourceIntegrityLabelKey: common.LabelValueVerified})
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	result := &shield.ScanResult{Kind: "ConfigMap", Namespace: "test-ns", Name: "cm1", Result: shield.ScanResultDrifted, Diff: "data.key1: changed"}
+
+	client := k8sfake.NewSimpleClientset()
+	dyClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), obj.DeepCopy())
+	iShieldObserver := &IntegrityShieldObserver{IShiledNamespace: "integrity-shield-operator-system", DriftRelabel: true, logger: testLogger}
+	drifted := []*driftedResource{{gvr: gvr, obj: obj, result: result}}
+	iShieldObserver.reportDrift(client, dyClient, drifted)
+	iShieldObserver.reportDrift(client, dyClient, drifted)
+
+	evt, err := client.CoreV1().Events("test-ns").Get(context.Background(), "ishield-drift-configmap-cm1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evt.Count != 2 || evt.Reason != driftEventReason || evt.Annotations[common.EventTypeAnnotationKey] != common.EventTypeValueDriftReport {
+		t.Errorf("Failed to test reportDrift(); unexpected Event %v", evt)
+	}
+	current, err := dyClient.Resource(gvr).Namespace("test-ns").Get(context.Background(), "cm1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if label := current.GetLabels()[common.ResourceIntegrityLabelKey]; label != common.LabelValueUnverified {
+		t.Errorf("Failed to test reportDrift(); the resource must be re-labeled as unverified: %s", label)
+	}
+}
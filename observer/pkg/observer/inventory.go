@@ -0,0 +1,179 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package observer
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	kubeutil "github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// applyEventsToKeyInventory records the key configs which verified the signature of each allowed request.
+// Deleted resources are removed from the inventory. It returns true if the inventory is changed.
+func applyEventsToKeyInventory(inventory common.KeyInventory, events []map[string]interface{}) bool {
+	changed := false
+	for _, e := range events {
+		kind, _ := e["kind"].(string)
+		namespace, _ := e["namespace"].(string)
+		name, _ := e["name"].(string)
+		operation, _ := e["operation"].(string)
+		if kind == "" || name == "" {
+			continue
+		}
+		resName := common.KeyInventoryResourceName(kind, namespace, name)
+		if operation == "DELETE" {
+			if _, ok := inventory[resName]; ok {
+				delete(inventory, resName)
+				changed = true
+			}
+			continue
+		}
+		if allowed, _ := e["allowed"].(bool); !allowed {
+			continue
+		}
+		keyConfigsIf, _ := e["sig.verifiedKeyConfigs"].([]interface{})
+		keyConfigs := []string{}
+		for _, k := range keyConfigsIf {
+			if kStr, ok := k.(string); ok {
+				keyConfigs = append(keyConfigs, kStr)
+			}
+		}
+		if len(keyConfigs) == 0 {
+			continue
+		}
+		signer, _ := e["sig.signer.displayName"].(string)
+		lastVerified, _ := e["timestamp"].(string)
+		inventory[resName] = common.KeyInventoryEntry{
+			KeyConfigs:   keyConfigs,
+			Signer:       signer,
+			LastVerified: lastVerified,
+		}
+		changed = true
+	}
+	return changed
+}
+
+// a ConfigMap can have 1MiB at most, including metadata
+const maxKeyInventoryBytes = 900 * 1024
+
+// pruneKeyInventory removes entries which are not verified within the retention period, and then the oldest entries
+// until the inventory fits in a ConfigMap. It returns true if the inventory is changed.
+func pruneKeyInventory(inventory common.KeyInventory, now time.Time, retention time.Duration) bool {
+	changed := false
+	size := 2
+	entrySizes := map[string]int{}
+	for resName, entry := range inventory {
+		if lastVerified, err := time.Parse(eventTimeFormat, entry.LastVerified); err == nil && now.Sub(lastVerified) > retention {
+			delete(inventory, resName)
+			changed = true
+			continue
+		}
+		keyBytes, _ := json.Marshal(resName)
+		entryBytes, _ := json.Marshal(entry)
+		// `"key":{...},`
+		entrySizes[resName] = len(keyBytes) + len(entryBytes) + 2
+		size += entrySizes[resName]
+	}
+	if size <= maxKeyInventoryBytes {
+		return changed
+	}
+
+	// entries without a valid timestamp are removed first
+	resNames := []string{}
+	for resName := range inventory {
+		resNames = append(resNames, resName)
+	}
+	sort.Slice(resNames, func(i, j int) bool {
+		ti, _ := time.Parse(eventTimeFormat, inventory[resNames[i]].LastVerified)
+		tj, _ := time.Parse(eventTimeFormat, inventory[resNames[j]].LastVerified)
+		return ti.Before(tj)
+	})
+	for _, resName := range resNames {
+		if size <= maxKeyInventoryBytes {
+			break
+		}
+		size -= entrySizes[resName]
+		delete(inventory, resName)
+		changed = true
+	}
+	return changed
+}
+
+// updateKeyInventory applies the events to the key inventory ConfigMap, which is kept across reports
+func (self *IntegrityShieldObserver) updateKeyInventory(events []map[string]interface{}) error {
+	config, err := kubeutil.GetKubeConfig()
+	if err != nil {
+		return err
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	cmNS := self.IShiledNamespace
+	cmName := common.KeyInventoryConfigMapName
+	inventory := common.KeyInventory{}
+	current, getErr := client.CoreV1().ConfigMaps(cmNS).Get(context.Background(), cmName, metav1.GetOptions{})
+	if getErr != nil && !errors.IsNotFound(getErr) {
+		return getErr
+	}
+	alreadyExists := getErr == nil
+	if alreadyExists {
+		if data, ok := current.Data[common.KeyInventoryDataKey]; ok {
+			if err := json.Unmarshal([]byte(data), &inventory); err != nil {
+				self.logger.Warningf("Failed to parse `%s`; the inventory is recreated; %s", cmName, err.Error())
+				inventory = common.KeyInventory{}
+			}
+		}
+	}
+
+	changed := applyEventsToKeyInventory(inventory, events)
+	retention := time.Duration(self.KeyInventoryRetentionHours) * time.Hour
+	if pruneKeyInventory(inventory, time.Now().UTC(), retention) {
+		changed = true
+	}
+	if !changed && alreadyExists {
+		return nil
+	}
+	inventoryBytes, err := json.Marshal(inventory)
+	if err != nil {
+		return err
+	}
+	data := map[string]string{common.KeyInventoryDataKey: string(inventoryBytes)}
+
+	if alreadyExists {
+		current.Data = data
+		_, err = client.CoreV1().ConfigMaps(cmNS).Update(context.Background(), current, metav1.UpdateOptions{})
+	} else {
+		cm := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: cmName,
+			},
+			Data: data,
+		}
+		_, err = client.CoreV1().ConfigMaps(cmNS).Create(context.Background(), cm, metav1.CreateOptions{})
+	}
+	return err
+}
@@ -18,23 +18,27 @@ package observer
 
 import (
 	"bufio"
-	"context"
 	"encoding/json"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	kubeutil "github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
+	"github.com/IBM/integrity-enforcer/observer/pkg/compliance"
+	"github.com/IBM/integrity-enforcer/observer/pkg/eventstore"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	"github.com/IBM/integrity-enforcer/shield/pkg/shield"
 	"github.com/hpcloud/tail"
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 )
 
 const defaultIntervalSecondsStr = "30"
-const defaultSummaryConfigMapName = "integrity-shield-status-report"
+const defaultEventRetentionHoursStr = "168"
+const defaultKeyInventoryRetentionHoursStr = "720"
+const defaultAPIPort = "8090"
 const timeFormat = "2006-01-02 15:04:05"
 
 type IntegrityShieldObserver struct {
@@ -42,10 +46,31 @@ type IntegrityShieldObserver struct {
 	ShieldConfigName string
 	EventsFilePath   string
 	IntervalSeconds  uint64
+	// interval of the scan of existing resources; 0 disables the scan
+	ScanIntervalSeconds uint64
+	// length of the history window of the report
+	HistoryHours uint64
+	// entries of the key inventory which are not verified again in this period are removed
+	KeyInventoryRetentionHours uint64
+	// decision records are persisted in this store if EVENT_STORE_DIR is set
+	EventStore *eventstore.Store
+	// port of the query API of the event store and compliance reports
+	APIPort string
+	// TLS key pair of the API
+	APITLSCertFile string
+	APITLSKeyFile  string
+	// drifted resources are re-labeled as unverified if DRIFT_RELABEL is true
+	DriftRelabel bool
 
 	loader     *Loader
 	logger     *log.Logger
 	eventQueue []string
+	keyManager *shield.KeyMaterialManager
+	// 1 while a scan is running
+	scanning int32
+	// compliance report of the last scan
+	complianceReport *compliance.Report
+	complianceMu     sync.RWMutex
 }
 
 func NewIntegrityShieldObserver(logger *log.Logger) *IntegrityShieldObserver {
@@ -61,20 +86,90 @@ func NewIntegrityShieldObserver(logger *log.Logger) *IntegrityShieldObserver {
 		logger.Warningf("Failed to parse interval seconds `%s`; use default value: %s", intervalSecondsStr, defaultIntervalSecondsStr)
 		intervalSeconds, _ = strconv.ParseUint(defaultIntervalSecondsStr, 10, 64)
 	}
+	scanIntervalSecondsStr := os.Getenv("SCAN_INTERVAL_SECONDS")
+	if scanIntervalSecondsStr == "" {
+		scanIntervalSecondsStr = defaultScanIntervalSecondsStr
+	}
+	scanIntervalSeconds, err := strconv.ParseUint(scanIntervalSecondsStr, 10, 64)
+	if err != nil {
+		logger.Warningf("Failed to parse scan interval seconds `%s`; use default value: %s", scanIntervalSecondsStr, defaultScanIntervalSecondsStr)
+		scanIntervalSeconds, _ = strconv.ParseUint(defaultScanIntervalSecondsStr, 10, 64)
+	}
+
+	historyHoursStr := os.Getenv("REPORT_HISTORY_HOURS")
+	if historyHoursStr == "" {
+		historyHoursStr = defaultHistoryHoursStr
+	}
+	historyHours, err := strconv.ParseUint(historyHoursStr, 10, 64)
+	if err != nil || historyHours == 0 {
+		logger.Warningf("Failed to parse report history hours `%s`; use default value: %s", historyHoursStr, defaultHistoryHoursStr)
+		historyHours, _ = strconv.ParseUint(defaultHistoryHoursStr, 10, 64)
+	}
+
+	keyInventoryRetentionHoursStr := os.Getenv("KEY_INVENTORY_RETENTION_HOURS")
+	if keyInventoryRetentionHoursStr == "" {
+		keyInventoryRetentionHoursStr = defaultKeyInventoryRetentionHoursStr
+	}
+	keyInventoryRetentionHours, err := strconv.ParseUint(keyInventoryRetentionHoursStr, 10, 64)
+	if err != nil || keyInventoryRetentionHours == 0 {
+		logger.Warningf("Failed to parse key inventory retention hours `%s`; use default value: %s", keyInventoryRetentionHoursStr, defaultKeyInventoryRetentionHoursStr)
+		keyInventoryRetentionHours, _ = strconv.ParseUint(defaultKeyInventoryRetentionHoursStr, 10, 64)
+	}
+
+	var eventStore *eventstore.Store
+	if eventStoreDir := os.Getenv("EVENT_STORE_DIR"); eventStoreDir != "" {
+		retentionHoursStr := os.Getenv("EVENT_RETENTION_HOURS")
+		if retentionHoursStr == "" {
+			retentionHoursStr = defaultEventRetentionHoursStr
+		}
+		retentionHours, err := strconv.ParseUint(retentionHoursStr, 10, 64)
+		if err != nil {
+			logger.Warningf("Failed to parse event retention hours `%s`; use default value: %s", retentionHoursStr, defaultEventRetentionHoursStr)
+			retentionHours, _ = strconv.ParseUint(defaultEventRetentionHoursStr, 10, 64)
+		}
+		eventStore, err = eventstore.Open(eventstore.Config{Dir: eventStoreDir, Retention: time.Duration(retentionHours) * time.Hour})
+		if err != nil {
+			logger.Errorf("Failed to open the event store in `%s`; events are not persisted; %s", eventStoreDir, err.Error())
+		}
+	}
+
+	apiPort := os.Getenv("API_PORT")
+	if apiPort == "" {
+		apiPort = defaultAPIPort
+	}
+
+	apiTLSCertFile := os.Getenv("API_TLS_CERT_FILE")
+	if apiTLSCertFile == "" {
+		apiTLSCertFile = defaultAPITLSCertFile
+	}
+	apiTLSKeyFile := os.Getenv("API_TLS_KEY_FILE")
+	if apiTLSKeyFile == "" {
+		apiTLSKeyFile = defaultAPITLSKeyFile
+	}
+
+	driftRelabel, _ := strconv.ParseBool(os.Getenv("DRIFT_RELABEL"))
 
 	loader := NewLoader(iShieldNS, shieldConfigName)
 
 	return &IntegrityShieldObserver{
-		IShiledNamespace: iShieldNS,
-		ShieldConfigName: shieldConfigName,
-		EventsFilePath:   eventsFilePath,
-		IntervalSeconds:  intervalSeconds,
-		loader:           loader,
-		logger:           logger,
+		IShiledNamespace:           iShieldNS,
+		ShieldConfigName:           shieldConfigName,
+		EventsFilePath:             eventsFilePath,
+		IntervalSeconds:            intervalSeconds,
+		ScanIntervalSeconds:        scanIntervalSeconds,
+		HistoryHours:               historyHours,
+		KeyInventoryRetentionHours: keyInventoryRetentionHours,
+		EventStore:                 eventStore,
+		APIPort:                    apiPort,
+		APITLSCertFile:             apiTLSCertFile,
+		APITLSKeyFile:              apiTLSKeyFile,
+		DriftRelabel:               driftRelabel,
+		loader:                     loader,
+		logger:                     logger,
 	}
 }
 
-func (self *IntegrityShieldObserver) Run(event chan *tail.Line, report chan bool) error {
+func (self *IntegrityShieldObserver) Run(event chan *tail.Line, report chan bool, scan chan bool) error {
 	for {
 		var l *tail.Line
 		select {
@@ -86,6 +181,18 @@ func (self *IntegrityShieldObserver) Run(event chan *tail.Line, report chan bool
 			if err != nil {
 				return err
 			}
+		case <-scan:
+			// scan may take longer than the interval; it runs in background so that events are not blocked
+			if !atomic.CompareAndSwapInt32(&self.scanning, 0, 1) {
+				self.logger.Info("Previous scan is still running; skip this scan")
+				continue
+			}
+			go func() {
+				defer atomic.StoreInt32(&self.scanning, 0)
+				if err := self.Scan(); err != nil {
+					self.logger.Errorf("Failed to scan resources; %s", err.Error())
+				}
+			}()
 		}
 	}
 }
@@ -101,15 +208,43 @@ func (self *IntegrityShieldObserver) report(lines []string) error {
 		self.logger.Errorf("Failed to load events.txt; %s", err.Error())
 		return err
 	}
-	err = self.updateSummary(data, events)
+	self.storeEvents(events)
+	err = self.updateReport(data, events)
+	if err != nil {
+		self.logger.Errorf("Failed to create or update IntegrityShieldReport `%s`; %s", defaultReportName, err.Error())
+		return err
+	}
+	err = self.updateKeyInventory(events)
 	if err != nil {
-		self.logger.Errorf("Failed to create or update `%s`; %s", defaultSummaryConfigMapName, err.Error())
+		self.logger.Errorf("Failed to create or update `%s`; %s", common.KeyInventoryConfigMapName, err.Error())
 		return err
 	}
 	self.logger.Info("Updated a status report")
 	return nil
 }
 
+// storeEvents persists decision records and removes old ones; failures are logged and do not stop reporting
+func (self *IntegrityShieldObserver) storeEvents(events []map[string]interface{}) {
+	if self.EventStore == nil {
+		return
+	}
+	now := time.Now()
+	records := []*eventstore.Record{}
+	for _, e := range events {
+		if r := eventstore.NewRecord(e, now); r != nil {
+			records = append(records, r)
+		}
+	}
+	if err := self.EventStore.Append(records); err != nil {
+		self.logger.Errorf("Failed to store events; %s", err.Error())
+	}
+	if removed, err := self.EventStore.ApplyRetention(now); err != nil {
+		self.logger.Errorf("Failed to remove old events; %s", err.Error())
+	} else if removed > 0 {
+		self.logger.Infof("Removed %d event segments older than the retention", removed)
+	}
+}
+
 func (self *IntegrityShieldObserver) addEvent(line string) {
 	self.eventQueue = append(self.eventQueue, line)
 }
@@ -153,84 +288,6 @@ func readEventsFile(fpath string) ([]map[string]interface{}, error) {
 	return readEventLines(lines)
 }
 
-func (self *IntegrityShieldObserver) summarize(data *RuntimeData, events []map[string]interface{}) map[string]string {
-	summary := map[string]string{}
-
-	opPods, svPods := getIShieldPods(data)
-	podsStatus := makePodsStatus(opPods, svPods)
-	for k, v := range podsStatus {
-		summary["pods."+k] = v
-	}
-
-	rspNum := len(data.RSPList.Items)
-	rsigNum := len(data.ResSigList.Items)
-
-	count := 0
-	denyCount := 0
-	for _, e := range events {
-		allowedIf, ok1 := e["allowed"]
-		if !ok1 {
-			continue
-		}
-		allowed, ok2 := allowedIf.(bool)
-		if !ok2 {
-			continue
-		}
-
-		count++
-		if !allowed {
-			denyCount++
-		}
-	}
-	summary["count.events"] = strconv.Itoa(count)
-	summary["count.deniedEvents"] = strconv.Itoa(denyCount)
-	summary["resource.numOfRSPs"] = strconv.Itoa(rspNum)
-	summary["resource.numOfResSigs"] = strconv.Itoa(rsigNum)
-	summary["__meta.interval"] = strconv.Itoa(int(self.IntervalSeconds))
-	summary["__meta.updatedTimestamp"] = time.Now().UTC().Format(timeFormat)
-	return summary
-}
-
-func (self *IntegrityShieldObserver) updateSummary(data *RuntimeData, events []map[string]interface{}) error {
-
-	summary := self.summarize(data, events)
-
-	config, err := kubeutil.GetKubeConfig()
-	if err != nil {
-		return err
-	}
-	client, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return err
-	}
-
-	cmNS := self.IShiledNamespace
-	cmName := defaultSummaryConfigMapName
-	cm := &v1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: cmName,
-		},
-		Data: summary,
-	}
-	alreadyExists := false
-	current, getErr := client.CoreV1().ConfigMaps(cmNS).Get(context.Background(), cmName, metav1.GetOptions{})
-	if current != nil && getErr == nil {
-		alreadyExists = true
-		cm = current
-		cm.Data = summary
-	}
-
-	if alreadyExists {
-		_, err = client.CoreV1().ConfigMaps(cmNS).Update(context.Background(), cm, metav1.UpdateOptions{})
-	} else {
-		_, err = client.CoreV1().ConfigMaps(cmNS).Create(context.Background(), cm, metav1.CreateOptions{})
-	}
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
 func getIShieldPods(data *RuntimeData) ([]v1.Pod, []v1.Pod) {
 	operatorDeployName := ""
 	serverDeployName := ""
@@ -259,48 +316,3 @@ func getIShieldPods(data *RuntimeData) ([]v1.Pod, []v1.Pod) {
 	}
 	return operatorPods, serverPods
 }
-
-type ContainerStatus struct {
-	Name         string            `json:"name"`
-	State        v1.ContainerState `json:"state"`
-	LastState    v1.ContainerState `json:"lastState"`
-	RestartCount int32             `json:"restartCount"`
-	Ready        bool              `json:"ready"`
-}
-
-func makePodsStatus(opPods, svPods []v1.Pod) map[string]string {
-	s := map[string]string{}
-	for _, pod := range opPods {
-		podName := pod.GetName()
-		podStatus := []ContainerStatus{}
-		for _, status := range pod.Status.ContainerStatuses {
-			tmpStatus := ContainerStatus{
-				Name:         status.Name,
-				State:        status.State,
-				LastState:    status.LastTerminationState,
-				RestartCount: status.RestartCount,
-				Ready:        status.Ready,
-			}
-			podStatus = append(podStatus, tmpStatus)
-		}
-		podStatusBytes, _ := json.Marshal(podStatus)
-		s[podName] = string(podStatusBytes)
-	}
-	for _, pod := range svPods {
-		podName := pod.GetName()
-		podStatus := []ContainerStatus{}
-		for _, status := range pod.Status.ContainerStatuses {
-			tmpStatus := ContainerStatus{
-				Name:         status.Name,
-				State:        status.State,
-				LastState:    status.LastTerminationState,
-				RestartCount: status.RestartCount,
-				Ready:        status.Ready,
-			}
-			podStatus = append(podStatus, tmpStatus)
-		}
-		podStatusBytes, _ := json.Marshal(podStatus)
-		s[podName] = string(podStatusBytes)
-	}
-	return s
-}
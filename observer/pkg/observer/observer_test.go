@@ -17,9 +17,18 @@
 package observer
 
 import (
+	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	israpi "github.com/IBM/integrity-enforcer/shield/pkg/apis/integrityshieldreport/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	"github.com/IBM/integrity-enforcer/shield/pkg/shield"
 	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 var testLogger *log.Logger
@@ -34,3 +43,126 @@ func TestNewIntegrityShieldObserver(t *testing.T) {
 		t.Error("Failed to test NewIntegrityShieldObserver()")
 	}
 }
+
+func TestApplyEventsToKeyInventory(t *testing.T) {
+	lines := []string{
+		`{"kind":"ConfigMap","namespace":"test-ns","name":"cm1","operation":"CREATE","allowed":true,"sig.verifiedKeyConfigs":["old-key"],"sig.signer.displayName":"signer@example.com","timestamp":"2020-10-01T00:00:00.000Z"}`,
+		`{"kind":"ConfigMap","namespace":"test-ns","name":"cm2","operation":"CREATE","allowed":true,"sig.verifiedKeyConfigs":["old-key","new-key"]}`,
+		`{"kind":"ConfigMap","namespace":"test-ns","name":"cm3","operation":"CREATE","allowed":false,"sig.verifiedKeyConfigs":["old-key"]}`,
+		`{"kind":"ConfigMap","namespace":"test-ns","name":"cm4","operation":"CREATE","allowed":true,"sig.verifiedKeyConfigs":["old-key"]}`,
+		`{"kind":"ConfigMap","namespace":"test-ns","name":"cm4","operation":"DELETE","allowed":true}`,
+	}
+	events, _ := readEventLines(lines)
+	inventory := common.KeyInventory{}
+	if changed := applyEventsToKeyInventory(inventory, events); !changed {
+		t.Error("Failed to test applyEventsToKeyInventory(); the inventory must be changed")
+		return
+	}
+	resources := inventory.ResourcesVerifiedOnlyBy("old-key", []string{"new-key"})
+	if len(resources) != 1 || resources[0] != "ConfigMap/test-ns/cm1" {
+		t.Errorf("Failed to test applyEventsToKeyInventory(); unexpected resources %v", resources)
+	}
+	if entry := inventory["ConfigMap/test-ns/cm1"]; entry.Signer != "signer@example.com" || entry.LastVerified != "2020-10-01T00:00:00.000Z" {
+		t.Errorf("Failed to test applyEventsToKeyInventory(); unexpected entry %v", inventory["ConfigMap/test-ns/cm1"])
+	}
+}
+
+func TestPruneKeyInventory(t *testing.T) {
+	now := time.Date(2020, 11, 1, 0, 0, 0, 0, time.UTC)
+	inventory := common.KeyInventory{
+		"ConfigMap/test-ns/old":    {KeyConfigs: []string{"old-key"}, LastVerified: "2020-10-01T00:00:00.000Z"},
+		"ConfigMap/test-ns/recent": {KeyConfigs: []string{"old-key"}, LastVerified: "2020-10-31T00:00:00.000Z"},
+	}
+	if changed := pruneKeyInventory(inventory, now, 7*24*time.Hour); !changed {
+		t.Error("Failed to test pruneKeyInventory(); the inventory must be changed")
+	}
+	if _, ok := inventory["ConfigMap/test-ns/old"]; ok || len(inventory) != 1 {
+		t.Errorf("Failed to test pruneKeyInventory(); unexpected inventory %v", inventory)
+	}
+
+	// the oldest entries are removed until the inventory fits in a ConfigMap
+	keyConfig := strings.Repeat("k", 1024)
+	for i := 0; i < 1000; i++ {
+		lastVerified := now.Add(time.Duration(i-1000) * time.Minute).Format(eventTimeFormat)
+		inventory[fmt.Sprintf("ConfigMap/test-ns/cm%d", i)] = common.KeyInventoryEntry{KeyConfigs: []string{keyConfig}, LastVerified: lastVerified}
+	}
+	pruneKeyInventory(inventory, now, 7*24*time.Hour)
+	inventoryBytes, _ := json.Marshal(inventory)
+	if len(inventoryBytes) > maxKeyInventoryBytes {
+		t.Errorf("Failed to test pruneKeyInventory(); the inventory is too large: %d bytes", len(inventoryBytes))
+	}
+	if _, ok := inventory["ConfigMap/test-ns/cm999"]; !ok {
+		t.Error("Failed to test pruneKeyInventory(); the latest entry must be kept")
+	}
+	if _, ok := inventory["ConfigMap/test-ns/cm0"]; ok {
+		t.Error("Failed to test pruneKeyInventory(); the oldest entry must be removed")
+	}
+}
+
+func TestNewScanReport(t *testing.T) {
+	results := []*shield.ScanResult{
+		{Kind: "ConfigMap", Namespace: "test-ns", Name: "cm2", Result: shield.ScanResultUnsigned},
+		{Kind: "ConfigMap", Namespace: "test-ns", Name: "cm1", Result: shield.ScanResultVerified},
+		{Kind: "ConfigMap", Namespace: "test-ns", Name: "cm3", Result: shield.ScanResultTampered},
+		{Kind: "ConfigMap", Namespace: "test-ns", Name: "cm1", Result: shield.ScanResultUnsigned},
+	}
+	report := newScanReport(results, nil, time.Now())
+	if report.Count[shield.ScanResultUnsigned] != 2 || report.Count[shield.ScanResultVerified] != 1 {
+		t.Errorf("Failed to test newScanReport(); unexpected count %v", report.Count)
+	}
+	if len(report.Results) != 3 || report.Results[0].Name != "cm3" || report.Results[1].Name != "cm1" {
+		t.Errorf("Failed to test newScanReport(); verified resources must not be listed, and others must be sorted: %v", report.Results)
+	}
+}
+
+func TestApplyEventsToHistory(t *testing.T) {
+	now := time.Date(2020, 10, 2, 12, 30, 0, 0, time.UTC)
+	old := israpi.HistoryBucket{Start: metav1.NewTime(now.Add(-30 * time.Hour).Truncate(time.Hour)), Events: 5}
+	previous := israpi.HistoryBucket{Start: metav1.NewTime(now.Add(-time.Hour).Truncate(time.Hour)), Events: 1, DeniedEvents: 1,
+		Namespaces: map[string]int{"test-ns": 1}, DeniedNamespaces: map[string]int{"test-ns": 1}, DeniedResources: map[string]int{"ConfigMap/test-ns/cm1": 1}}
+	lines := []string{
+		`{"kind":"ConfigMap","namespace":"test-ns","name":"cm1","allowed":false,"denyingProfile":"test-ns/test-rsp","timestamp":"2020-10-02T12:10:00.000Z"}`,
+		`{"kind":"ConfigMap","namespace":"test-ns","name":"cm2","allowed":true,"verified":true,"sig.signer.displayName":"signer@example.com","timestamp":"2020-10-02T12:20:00.000Z"}`,
+		`{"kind":"ConfigMap","namespace":"other-ns","name":"cm3","allowed":true,"timestamp":"2020-10-02T11:50:00.000Z"}`,
+		`{"kind":"ConfigMap","namespace":"test-ns","name":"cm4"}`,
+	}
+	events, _ := readEventLines(lines)
+	history := applyEventsToHistory([]israpi.HistoryBucket{old, previous}, events, now, 24*time.Hour)
+	if len(history) != 2 || history[0].Events != 2 || history[1].Events != 2 {
+		t.Errorf("Failed to test applyEventsToHistory(); buckets older than the window must be dropped: %v", history)
+		return
+	}
+	if previous.Events != 1 {
+		t.Errorf("Failed to test applyEventsToHistory(); the given history must not be changed")
+	}
+
+	status := newReportStatus(&RuntimeData{}, history, nil, now)
+	if status.Summary.Events != 4 || status.Summary.DeniedEvents != 2 {
+		t.Errorf("Failed to test newReportStatus(); unexpected summary %v", status.Summary)
+	}
+	if len(status.Namespaces) != 2 || status.Namespaces[0].Namespace != "test-ns" || status.Namespaces[0].DeniedEvents != 2 {
+		t.Errorf("Failed to test newReportStatus(); unexpected namespaces %v", status.Namespaces)
+	}
+	if len(status.Profiles) != 1 || status.Profiles[0].Profile != "test-ns/test-rsp" {
+		t.Errorf("Failed to test newReportStatus(); unexpected profiles %v", status.Profiles)
+	}
+	if len(status.TopDeniedResources) != 1 || status.TopDeniedResources[0].DeniedEvents != 2 {
+		t.Errorf("Failed to test newReportStatus(); unexpected denied resources %v", status.TopDeniedResources)
+	}
+	if len(status.Signers) != 1 || status.Signers[0].VerifiedEvents != 1 {
+		t.Errorf("Failed to test newReportStatus(); unexpected signers %v", status.Signers)
+	}
+}
+
+func TestMakePodsHealth(t *testing.T) {
+	pod := v1.Pod{}
+	pod.Name = "ishield-server-1"
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{
+		{Name: "server", Ready: true, RestartCount: 1, State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}},
+		{Name: "observer", Ready: false, RestartCount: 2, State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+	}
+	podsHealth := makePodsHealth([]v1.Pod{pod}, israpi.ComponentServer)
+	if len(podsHealth) != 1 || podsHealth[0].Ready || podsHealth[0].RestartCount != 3 || podsHealth[0].Containers[1].Reason != "CrashLoopBackOff" {
+		t.Errorf("Failed to test makePodsHealth(); unexpected health %v", podsHealth)
+	}
+}
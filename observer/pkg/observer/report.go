@@ -0,0 +1,316 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package observer
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	israpi "github.com/IBM/integrity-enforcer/shield/pkg/apis/integrityshieldreport/v1alpha1"
+	isrclient "github.com/IBM/integrity-enforcer/shield/pkg/client/integrityshieldreport/clientset/versioned/typed/integrityshieldreport/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	"github.com/IBM/integrity-enforcer/shield/pkg/shield"
+	kubeutil "github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const defaultReportName = "integrity-shield-report"
+const defaultHistoryHoursStr = "24"
+
+// time format of "timestamp" in event logs
+const eventTimeFormat = "2006-01-02T15:04:05.000Z"
+
+// max number of resources in TopDeniedResources
+const maxTopDeniedResources = 10
+
+// max number of denied resources counted in a history bucket, so that the report does not grow with a burst of denied requests
+const maxBucketDeniedResources = 100
+
+// getEventTime returns the time of the event, or now if the event has no valid timestamp
+func getEventTime(e map[string]interface{}, now time.Time) time.Time {
+	if ts, ok := e["timestamp"].(string); ok {
+		if t, err := time.Parse(eventTimeFormat, ts); err == nil {
+			return t
+		}
+	}
+	return now
+}
+
+// applyEventsToHistory counts the events in hourly buckets, and drops buckets older than the window
+func applyEventsToHistory(history []israpi.HistoryBucket, events []map[string]interface{}, now time.Time, window time.Duration) []israpi.HistoryBucket {
+	buckets := map[int64]*israpi.HistoryBucket{}
+	for i := range history {
+		bucket := history[i].DeepCopy()
+		buckets[bucket.Start.Unix()] = bucket
+	}
+	for _, e := range events {
+		allowed, ok := e["allowed"].(bool)
+		if !ok {
+			continue
+		}
+		start := getEventTime(e, now).UTC().Truncate(time.Hour)
+		bucket, ok := buckets[start.Unix()]
+		if !ok {
+			bucket = &israpi.HistoryBucket{Start: metav1.NewTime(start)}
+			buckets[start.Unix()] = bucket
+		}
+		countEvent(bucket, e, allowed)
+	}
+
+	oldest := now.UTC().Truncate(time.Hour).Add(-window + time.Hour)
+	newHistory := []israpi.HistoryBucket{}
+	for _, bucket := range buckets {
+		if bucket.Start.Time.Before(oldest) {
+			continue
+		}
+		newHistory = append(newHistory, *bucket)
+	}
+	sort.Slice(newHistory, func(i, j int) bool {
+		return newHistory[i].Start.Time.Before(newHistory[j].Start.Time)
+	})
+	return newHistory
+}
+
+func countEvent(bucket *israpi.HistoryBucket, e map[string]interface{}, allowed bool) {
+	namespace, _ := e["namespace"].(string)
+	bucket.Events++
+	bucket.Namespaces = incrementCount(bucket.Namespaces, namespace)
+	if allowed {
+		if verified, _ := e["verified"].(bool); verified {
+			if signer, _ := e["sig.signer.displayName"].(string); signer != "" {
+				bucket.Signers = incrementCount(bucket.Signers, signer)
+			}
+		}
+		return
+	}
+
+	bucket.DeniedEvents++
+	bucket.DeniedNamespaces = incrementCount(bucket.DeniedNamespaces, namespace)
+	if profile, _ := e["denyingProfile"].(string); profile != "" {
+		bucket.DeniedProfiles = incrementCount(bucket.DeniedProfiles, profile)
+	}
+	kind, _ := e["kind"].(string)
+	name, _ := e["name"].(string)
+	if kind == "" || name == "" {
+		return
+	}
+	resName := common.KeyInventoryResourceName(kind, namespace, name)
+	if _, ok := bucket.DeniedResources[resName]; ok || len(bucket.DeniedResources) < maxBucketDeniedResources {
+		bucket.DeniedResources = incrementCount(bucket.DeniedResources, resName)
+	}
+}
+
+func incrementCount(counts map[string]int, key string) map[string]int {
+	if counts == nil {
+		counts = map[string]int{}
+	}
+	counts[key]++
+	return counts
+}
+
+// sortedKeys returns keys of the counts in descending order of the count, and in ascending order of the key for the same count
+func sortedKeys(counts map[string]int) []string {
+	keys := []string{}
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// newReportStatus aggregates the history buckets and the current state of iShield into the report status
+func newReportStatus(data *RuntimeData, history []israpi.HistoryBucket, keys []*shield.KeyMaterial, now time.Time) israpi.IntegrityShieldReportStatus {
+	status := israpi.IntegrityShieldReportStatus{
+		LastUpdated: metav1.NewTime(now.UTC()),
+		History:     history,
+	}
+	if data.RSPList != nil {
+		status.Summary.NumOfRSPs = len(data.RSPList.Items)
+	}
+	if data.ResSigList != nil {
+		status.Summary.NumOfResSigs = len(data.ResSigList.Items)
+	}
+
+	namespaces, deniedNamespaces, deniedProfiles, deniedResources, signers := map[string]int{}, map[string]int{}, map[string]int{}, map[string]int{}, map[string]int{}
+	for _, bucket := range history {
+		status.Summary.Events += bucket.Events
+		status.Summary.DeniedEvents += bucket.DeniedEvents
+		for _, pair := range []struct{ from, to map[string]int }{
+			{bucket.Namespaces, namespaces},
+			{bucket.DeniedNamespaces, deniedNamespaces},
+			{bucket.DeniedProfiles, deniedProfiles},
+			{bucket.DeniedResources, deniedResources},
+			{bucket.Signers, signers},
+		} {
+			for key, count := range pair.from {
+				pair.to[key] += count
+			}
+		}
+	}
+
+	for _, ns := range sortedKeys(namespaces) {
+		status.Namespaces = append(status.Namespaces, israpi.NamespaceCount{Namespace: ns, Events: namespaces[ns], DeniedEvents: deniedNamespaces[ns]})
+	}
+	for _, profile := range sortedKeys(deniedProfiles) {
+		status.Profiles = append(status.Profiles, israpi.ProfileCount{Profile: profile, DeniedEvents: deniedProfiles[profile]})
+	}
+	for _, resName := range sortedKeys(deniedResources) {
+		if len(status.TopDeniedResources) >= maxTopDeniedResources {
+			break
+		}
+		status.TopDeniedResources = append(status.TopDeniedResources, israpi.ResourceCount{Resource: resName, DeniedEvents: deniedResources[resName]})
+	}
+	for _, signer := range sortedKeys(signers) {
+		status.Signers = append(status.Signers, israpi.SignerCount{Signer: signer, VerifiedEvents: signers[signer]})
+	}
+
+	if data.ShieldConfig != nil && data.ShieldConfig.Spec.ShieldConfig != nil && data.PodList != nil {
+		opPods, svPods := getIShieldPods(data)
+		status.Pods = append(makePodsHealth(opPods, israpi.ComponentOperator), makePodsHealth(svPods, israpi.ComponentServer)...)
+	}
+	status.VerificationKeys = makeVerificationKeyStatus(keys)
+	return status
+}
+
+func makePodsHealth(pods []v1.Pod, component string) []israpi.PodHealth {
+	podsHealth := []israpi.PodHealth{}
+	for _, pod := range pods {
+		podHealth := israpi.PodHealth{
+			Name:      pod.GetName(),
+			Component: component,
+			Phase:     string(pod.Status.Phase),
+			Ready:     len(pod.Status.ContainerStatuses) > 0,
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			containerHealth := israpi.ContainerHealth{
+				Name:         status.Name,
+				Ready:        status.Ready,
+				RestartCount: status.RestartCount,
+			}
+			if status.State.Running != nil {
+				containerHealth.State = "Running"
+			} else if status.State.Waiting != nil {
+				containerHealth.State = "Waiting"
+				containerHealth.Reason = status.State.Waiting.Reason
+			} else if status.State.Terminated != nil {
+				containerHealth.State = "Terminated"
+				containerHealth.Reason = status.State.Terminated.Reason
+			}
+			podHealth.Ready = podHealth.Ready && status.Ready
+			podHealth.RestartCount += status.RestartCount
+			podHealth.Containers = append(podHealth.Containers, containerHealth)
+		}
+		podsHealth = append(podsHealth, podHealth)
+	}
+	return podsHealth
+}
+
+func makeVerificationKeyStatus(keys []*shield.KeyMaterial) []israpi.VerificationKeyStatus {
+	keysStatus := []israpi.VerificationKeyStatus{}
+	for _, km := range keys {
+		keyStatus := israpi.VerificationKeyStatus{
+			Path:          km.Path,
+			SignatureType: string(km.SignType),
+			Valid:         km.IsValid(),
+			KeyCount:      km.KeyCount(),
+			Fingerprints:  km.Fingerprints(),
+		}
+		if names := shield.GetKeyConfigNames([]string{km.Path}, false); len(names) > 0 {
+			keyStatus.Name = names[0]
+		}
+		if km.Error != nil {
+			keyStatus.Message = km.Error.Error()
+		} else if km.KeyCount() == 0 {
+			keyStatus.Message = "no verification key is found"
+		}
+		keysStatus = append(keysStatus, keyStatus)
+	}
+	return keysStatus
+}
+
+// getVerificationKeys parses the verification keys mounted in the observer container, in the same way as the server
+func (self *IntegrityShieldObserver) getVerificationKeys(data *RuntimeData) []*shield.KeyMaterial {
+	if data.ShieldConfig == nil || data.ShieldConfig.Spec.ShieldConfig == nil {
+		return nil
+	}
+	keyPathList := data.ShieldConfig.Spec.ShieldConfig.KeyPathList
+	if self.keyManager == nil {
+		self.keyManager = shield.NewKeyMaterialManager(keyPathList, time.Duration(self.IntervalSeconds)*time.Second)
+	} else {
+		self.keyManager.SetKeyPathList(keyPathList)
+		self.keyManager.Reload()
+	}
+	return self.keyManager.List()
+}
+
+// updateReport applies the events to the IntegrityShieldReport, which keeps the history of the window across reports
+func (self *IntegrityShieldObserver) updateReport(data *RuntimeData, events []map[string]interface{}) error {
+	config, err := kubeutil.GetKubeConfig()
+	if err != nil {
+		return err
+	}
+	client, err := isrclient.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	reportNS := self.IShiledNamespace
+	reportName := defaultReportName
+	current, getErr := client.IntegrityShieldReports(reportNS).Get(context.Background(), reportName, metav1.GetOptions{})
+	if getErr != nil && !errors.IsNotFound(getErr) {
+		return getErr
+	}
+	alreadyExists := getErr == nil
+	history := []israpi.HistoryBucket{}
+	if alreadyExists {
+		history = current.Status.History
+	}
+
+	now := time.Now()
+	window := time.Duration(self.HistoryHours) * time.Hour
+	history = applyEventsToHistory(history, events, now, window)
+	keys := self.getVerificationKeys(data)
+
+	report := &israpi.IntegrityShieldReport{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: reportName,
+		},
+	}
+	if alreadyExists {
+		report = current
+	}
+	report.Spec = israpi.IntegrityShieldReportSpec{
+		IntervalSeconds:    int(self.IntervalSeconds),
+		HistoryWindowHours: int(self.HistoryHours),
+	}
+	report.Status = newReportStatus(data, history, keys, now)
+
+	if alreadyExists {
+		_, err = client.IntegrityShieldReports(reportNS).Update(context.Background(), report, metav1.UpdateOptions{})
+	} else {
+		_, err = client.IntegrityShieldReports(reportNS).Create(context.Background(), report, metav1.CreateOptions{})
+	}
+	return err
+}
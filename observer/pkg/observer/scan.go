@@ -0,0 +1,200 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package observer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/IBM/integrity-enforcer/observer/pkg/compliance"
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	"github.com/IBM/integrity-enforcer/shield/pkg/shield"
+	kubeutil "github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const defaultScanIntervalSecondsStr = "600"
+const defaultScanReportConfigMapName = "integrity-shield-scan-report"
+const scanReportDataKey = "report.json"
+
+// max number of resources listed in the scan report, so that the report fits in a ConfigMap
+const maxScanReportResults = 500
+
+// number of resources in a page when listing resources for scan
+const scanListPageSize = 500
+
+// ScanReport lists protected resources in the cluster which are not verified, and the number of resources for each result
+type ScanReport struct {
+	Timestamp string                        `json:"timestamp"`
+	Count     map[shield.ScanResultType]int `json:"count"`
+	Results   []*shield.ScanResult          `json:"results,omitempty"`
+	Truncated bool                          `json:"truncated,omitempty"`
+	Errors    []string                      `json:"errors,omitempty"`
+}
+
+// newScanReport summarizes the scan results; verified resources are only counted
+func newScanReport(results []*shield.ScanResult, scanErrors []string, now time.Time) *ScanReport {
+	report := &ScanReport{
+		Timestamp: now.UTC().Format(timeFormat),
+		Count:     map[shield.ScanResultType]int{},
+		Errors:    scanErrors,
+	}
+	for _, result := range results {
+		report.Count[result.Result]++
+		if result.Result == shield.ScanResultVerified {
+			continue
+		}
+		if len(report.Results) >= maxScanReportResults {
+			report.Truncated = true
+			continue
+		}
+		report.Results = append(report.Results, result)
+	}
+	sort.SliceStable(report.Results, func(i, j int) bool {
+		ri, rj := report.Results[i], report.Results[j]
+		if ri.Result != rj.Result {
+			return ri.Result < rj.Result
+		}
+		return fmt.Sprintf("%s/%s/%s", ri.Kind, ri.Namespace, ri.Name) < fmt.Sprintf("%s/%s/%s", rj.Kind, rj.Namespace, rj.Name)
+	})
+	return report
+}
+
+// Scan verifies existing resources protected by ResourceSigningProfiles, and reports unverified ones in a ConfigMap.
+// Resources created before iShield is installed or while the webhook is not available are found by this scan.
+func (self *IntegrityShieldObserver) Scan() error {
+	data, err := self.loader.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load IShield Resources; %s", err.Error())
+	}
+	if data.ShieldConfig.Spec.ShieldConfig == nil {
+		return fmt.Errorf("ShieldConfig %s has no shieldConfig in spec", self.ShieldConfigName)
+	}
+	config, err := kubeutil.GetKubeConfig()
+	if err != nil {
+		return err
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return err
+	}
+	dyClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	// some API groups may be unavailable (e.g. metrics), and other groups are still scanned
+	resourceLists, err := discoveryClient.ServerPreferredResources()
+	scanErrors := []string{}
+	if err != nil {
+		if len(resourceLists) == 0 {
+			return err
+		}
+		scanErrors = append(scanErrors, err.Error())
+	}
+	// only resource types which may be protected by any of the profiles are listed
+	targets := rspapi.GetProtectedResources(resourceLists, data.RSPList.Items)
+
+	scanner := shield.NewScanner(data.ShieldConfig.Spec.ShieldConfig.DeepCopy(), self.logger)
+	results := []*shield.ScanResult{}
+	drifted := []*driftedResource{}
+	for _, gvr := range targets {
+		// resources are listed in pages so that a large number of resources are not loaded at once
+		opts := metav1.ListOptions{Limit: scanListPageSize}
+		for {
+			objList, err := dyClient.Resource(gvr).List(context.Background(), opts)
+			if err != nil {
+				scanErrors = append(scanErrors, fmt.Sprintf("failed to list %s; %s", gvr.String(), err.Error()))
+				break
+			}
+			for i := range objList.Items {
+				if result := scanner.Scan(&objList.Items[i]); result != nil {
+					results = append(results, result)
+					if result.Result == shield.ScanResultDrifted {
+						drifted = append(drifted, &driftedResource{gvr: gvr, obj: &objList.Items[i], result: result})
+					}
+				}
+			}
+			opts.Continue = objList.GetContinue()
+			if opts.Continue == "" {
+				break
+			}
+		}
+	}
+
+	if len(drifted) > 0 {
+		client, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return err
+		}
+		self.reportDrift(client, dyClient, drifted)
+	}
+
+	now := time.Now()
+	self.setComplianceReport(compliance.NewReport(results, data.RSPList.Items, scanErrors, now))
+	report := newScanReport(results, scanErrors, now)
+	if err := self.updateScanReport(report); err != nil {
+		return fmt.Errorf("failed to create or update `%s`; %s", defaultScanReportConfigMapName, err.Error())
+	}
+	self.logger.Infof("Scanned %d protected resources; %v", len(results), report.Count)
+	return nil
+}
+
+func (self *IntegrityShieldObserver) updateScanReport(report *ScanReport) error {
+	reportBytes, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	data := map[string]string{scanReportDataKey: string(reportBytes)}
+
+	config, err := kubeutil.GetKubeConfig()
+	if err != nil {
+		return err
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	cmNS := self.IShiledNamespace
+	cmName := defaultScanReportConfigMapName
+	current, getErr := client.CoreV1().ConfigMaps(cmNS).Get(context.Background(), cmName, metav1.GetOptions{})
+	if getErr != nil && !errors.IsNotFound(getErr) {
+		return getErr
+	}
+	if getErr == nil {
+		current.Data = data
+		_, err = client.CoreV1().ConfigMaps(cmNS).Update(context.Background(), current, metav1.UpdateOptions{})
+	} else {
+		cm := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: cmName,
+			},
+			Data: data,
+		}
+		_, err = client.CoreV1().ConfigMaps(cmNS).Create(context.Background(), cm, metav1.CreateOptions{})
+	}
+	return err
+}
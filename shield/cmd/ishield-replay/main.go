@@ -0,0 +1,162 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// ishield-replay evaluates admission requests recorded by ishield-server (RECORD_FILE) offline,
+// and reports the requests whose decision differs from the recorded one.
+//
+//   ishield-replay -records records.json [-config shieldconfig.yaml] [-profile new-rsp.yaml ...] [-output text|json] [-all]
+//
+// The exit code is 1 if any decision is changed or any record cannot be replayed.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	shield "github.com/IBM/integrity-enforcer/shield/pkg/shield"
+	config "github.com/IBM/integrity-enforcer/shield/pkg/shield/config"
+	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	log "github.com/sirupsen/logrus"
+)
+
+type profileFlags []string
+
+func (f *profileFlags) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *profileFlags) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func main() {
+	var recordFile, configFile, output string
+	var showAll bool
+	var profileFiles profileFlags
+	flag.StringVar(&recordFile, "records", "", "file recorded by ishield-server (required)")
+	flag.StringVar(&configFile, "config", "", "ShieldConfig file (yaml or json) used instead of the recorded one")
+	flag.Var(&profileFiles, "profile", "ResourceSigningProfile file (yaml or json) which replaces or is added to the recorded profiles; can be repeated")
+	flag.StringVar(&output, "output", "text", "output format: text or json")
+	flag.BoolVar(&showAll, "all", false, "report unchanged requests too")
+	flag.Parse()
+
+	if recordFile == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	// logs of the shield are not needed for the report
+	logger.SetSingletonLoggerLevel(log.PanicLevel.String())
+
+	replayer := &shield.Replayer{}
+	if configFile != "" {
+		var conf *config.ShieldConfig
+		if err := loadYamlFile(configFile, &conf); err != nil {
+			exitWithError(err)
+		}
+		replayer.Config = conf
+	}
+	for _, profileFile := range profileFiles {
+		var rsp rspapi.ResourceSigningProfile
+		if err := loadYamlFile(profileFile, &rsp); err != nil {
+			exitWithError(err)
+		}
+		replayer.Profiles = append(replayer.Profiles, rsp)
+	}
+
+	records, err := shield.LoadAdmissionRecords(recordFile)
+	if err != nil {
+		exitWithError(err)
+	}
+	results := replayer.ReplayAll(records)
+
+	reported := []*shield.ReplayResult{}
+	changed := 0
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		} else if result.Changed {
+			changed++
+		} else if !showAll {
+			continue
+		}
+		reported = append(reported, result)
+	}
+
+	if output == "json" {
+		reportBytes, _ := json.MarshalIndent(reported, "", "  ")
+		fmt.Println(string(reportBytes))
+	} else {
+		for _, result := range reported {
+			fmt.Println(formatResult(result))
+		}
+		fmt.Printf("%d requests replayed: %d changed, %d failed\n", len(results), changed, failed)
+	}
+	if changed > 0 || failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func formatResult(result *shield.ReplayResult) string {
+	if result.Error != "" {
+		return fmt.Sprintf("[ERROR] %s", result.Error)
+	}
+	status := "UNCHANGED"
+	if result.Changed {
+		status = "CHANGED"
+	}
+	note := ""
+	if result.Sanitized {
+		note = " (sanitized)"
+	}
+	return fmt.Sprintf("[%s] %s %s %s/%s (uid: %s)%s: %s -> %s", status, result.Operation, result.Kind, result.Namespace, result.Name, result.RequestUID, note, formatDecision(result.Recorded), formatDecision(result.Replayed))
+}
+
+func formatDecision(d *shield.RecordedDecision) string {
+	if d == nil {
+		return "none"
+	}
+	result := "deny"
+	if d.Allowed {
+		result = "allow"
+	}
+	return fmt.Sprintf("%s (reason: %s)", result, d.Reason)
+}
+
+func loadYamlFile(fpath string, obj interface{}) error {
+	data, err := ioutil.ReadFile(filepath.Clean(fpath))
+	if err != nil {
+		return err
+	}
+	if err = yaml.Unmarshal(data, obj); err != nil {
+		return fmt.Errorf("failed to parse \"%s\"; %s", fpath, err.Error())
+	}
+	return nil
+}
+
+func exitWithError(err error) {
+	fmt.Fprintln(os.Stderr, err.Error())
+	os.Exit(2)
+}
@@ -18,93 +18,256 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
+	ecfgapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/shieldconfig/v1alpha1"
 	ecfgclient "github.com/IBM/integrity-enforcer/shield/pkg/client/shieldconfig/clientset/versioned/typed/shieldconfig/v1alpha1"
 	cfg "github.com/IBM/integrity-enforcer/shield/pkg/shield/config"
+	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	mapnode "github.com/IBM/integrity-enforcer/shield/pkg/util/mapnode"
 	log "github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/rest"
 )
 
+const (
+	defaultConfigResyncSec = 300
+	configWatchRetryPeriod = 5 * time.Second
+)
+
 type Config struct {
 	ShieldConfig *cfg.ShieldConfig
 	lastUpdated  time.Time
+
+	// base logger for admission requests; this is re-created only when ShieldConfig is updated
+	serverLogger *log.Logger
+	mu           sync.RWMutex
+
+	namespace       string
+	name            string
+	chartRepo       string
+	resyncInterval  time.Duration
+	resourceVersion string
+	client          *ecfgclient.ApisV1alpha1Client
+	updateHandlers  []func(*cfg.ShieldConfig)
 }
 
 func NewConfig() *Config {
-	config := &Config{}
+	resyncSec := defaultConfigResyncSec
+	if s := os.Getenv("SHIELD_CM_RELOAD_SEC"); s != "" {
+		if v, err := strconv.Atoi(s); err == nil && v > 0 {
+			resyncSec = v
+		}
+	}
+	config := &Config{
+		namespace:      os.Getenv("SHIELD_NS"),
+		name:           os.Getenv("SHIELD_CONFIG_NAME"),
+		chartRepo:      os.Getenv("CHART_BASE_URL"),
+		resyncInterval: time.Duration(resyncSec) * time.Second,
+	}
+	if restConfig, err := rest.InClusterConfig(); err == nil {
+		client, err := ecfgclient.NewForConfig(restConfig)
+		if err != nil {
+			log.Error(err)
+		}
+		config.client = client
+	}
 	return config
 }
 
-func (conf *Config) InitShieldConfig() bool {
+// Get returns the current ShieldConfig and the base logger safely for concurrent requests
+func (conf *Config) Get() (*cfg.ShieldConfig, *log.Logger) {
+	conf.mu.RLock()
+	defer conf.mu.RUnlock()
+	return conf.ShieldConfig, conf.serverLogger
+}
 
-	renew := false
-	t := time.Now()
-	if conf.ShieldConfig != nil {
+// AddUpdateHandler registers a function called with the new ShieldConfig after it is swapped in
+func (conf *Config) AddUpdateHandler(handler func(*cfg.ShieldConfig)) {
+	conf.mu.Lock()
+	defer conf.mu.Unlock()
+	conf.updateHandlers = append(conf.updateHandlers, handler)
+}
 
-		interval := 20
-		if s := os.Getenv("SHIELD_CM_RELOAD_SEC"); s != "" {
-			if v, err := strconv.Atoi(s); err != nil {
-				interval = v
-			}
-		}
+// InitShieldConfig loads ShieldConfig CR at startup. The server cannot start without a valid ShieldConfig.
+func (conf *Config) InitShieldConfig() {
+	ecres, err := conf.load()
+	if err != nil {
+		log.Fatal(fmt.Sprintf("Failed to initialize ShieldConfig. Exiting...; %s", err.Error()))
+	}
+	if err := conf.update(ecres); err != nil {
+		log.Fatal(fmt.Sprintf("Failed to initialize ShieldConfig. Exiting...; %s", err.Error()))
+	}
+}
+
+// Run watches ShieldConfig CR and swaps in a new version when it is updated, until stopCh is closed.
+// The CR is also re-fetched periodically in case any watch event is missed.
+func (conf *Config) Run(stopCh <-chan struct{}) {
+	if conf.client == nil {
+		logger.Warn("ShieldConfig watch is disabled because kubernetes client is not available")
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopCh
+		cancel()
+	}()
 
-		duration := t.Sub(conf.lastUpdated)
-		if int(duration.Seconds()) > interval {
-			renew = true
+	resyncTicker := time.NewTicker(conf.resyncInterval)
+	defer resyncTicker.Stop()
+	for {
+		w, err := conf.watch(ctx)
+		if err != nil {
+			logger.Warn("Failed to watch ShieldConfig; ", err.Error())
 		}
-	} else {
-		renew = true
-	}
-
-	if renew {
-		shieldNs := os.Getenv("SHIELD_NS")
-		shieldConfigName := os.Getenv("SHIELD_CONFIG_NAME")
-		shieldConfig := LoadEnforceConfig(shieldNs, shieldConfigName)
-		if shieldConfig == nil {
-			if conf.ShieldConfig == nil {
-				log.Fatal("Failed to initialize ShieldConfig. Exiting...")
-			} else {
-				shieldConfig = conf.ShieldConfig
-				log.Warn("The loaded ShieldConfig is nil, re-use the existing one.")
+		closed := w == nil
+		for !closed {
+			select {
+			case <-stopCh:
+				w.Stop()
+				return
+			case <-resyncTicker.C:
+				conf.resync()
+			case ev, ok := <-w.ResultChan():
+				if !ok {
+					closed = true
+					break
+				}
+				conf.handleEvent(ev)
 			}
 		}
-
-		chartRepo := os.Getenv("CHART_BASE_URL")
-		if chartRepo == "" {
-			chartRepo = ""
-		}
-
-		if shieldConfig != nil {
-			shieldConfig.ChartRepo = chartRepo
-			conf.ShieldConfig = shieldConfig
-			conf.lastUpdated = t
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(configWatchRetryPeriod):
+			// re-fetch before watching again because events may be missed while the watch is closed
+			conf.resync()
 		}
 	}
+}
 
-	return renew
+func (conf *Config) watch(ctx context.Context) (watch.Interface, error) {
+	conf.mu.RLock()
+	rv := conf.resourceVersion
+	conf.mu.RUnlock()
+	return conf.client.ShieldConfigs(conf.namespace).Watch(ctx, metav1.ListOptions{
+		FieldSelector:   fields.OneTermEqualSelector("metadata.name", conf.name).String(),
+		ResourceVersion: rv,
+	})
 }
 
-func LoadEnforceConfig(namespace, cmname string) *cfg.ShieldConfig {
+func (conf *Config) handleEvent(ev watch.Event) {
+	switch ev.Type {
+	case watch.Added, watch.Modified:
+		ecres, ok := ev.Object.(*ecfgapi.ShieldConfig)
+		if !ok {
+			return
+		}
+		if err := conf.update(ecres); err != nil {
+			logger.Error("ShieldConfig update is rejected; keep using the current one; ", err.Error())
+		}
+	case watch.Deleted:
+		logger.Warn("ShieldConfig has been deleted; keep using the current one.")
+	case watch.Error:
+		logger.Warn("ShieldConfig watch error; ", fmt.Sprintf("%v", ev.Object))
+		// the resource version may be too old; start watching from the latest one
+		conf.mu.Lock()
+		conf.resourceVersion = ""
+		conf.mu.Unlock()
+	}
+}
 
-	config, err := rest.InClusterConfig()
+func (conf *Config) resync() {
+	ecres, err := conf.load()
 	if err != nil {
-		return nil
+		logger.Warn("Failed to reload ShieldConfig; keep using the current one; ", err.Error())
+		return
 	}
-	clientset, err := ecfgclient.NewForConfig(config)
-	if err != nil {
-		log.Error(err)
-		return nil
+	if err := conf.update(ecres); err != nil {
+		logger.Error("ShieldConfig update is rejected; keep using the current one; ", err.Error())
 	}
-	ecres, err := clientset.ShieldConfigs(namespace).Get(context.Background(), cmname, metav1.GetOptions{})
+}
+
+func (conf *Config) load() (*ecfgapi.ShieldConfig, error) {
+	if conf.client == nil {
+		return nil, fmt.Errorf("kubernetes client is not available")
+	}
+	ecres, err := conf.client.ShieldConfigs(conf.namespace).Get(context.Background(), conf.name, metav1.GetOptions{})
 	if err != nil {
-		log.Error("failed to get ShieldConfig:", err.Error())
+		return nil, fmt.Errorf("failed to get ShieldConfig: %s", err.Error())
+	}
+	return ecres, nil
+}
+
+// update validates the ShieldConfig in the CR and swaps it in if it is valid and changed
+func (conf *Config) update(ecres *ecfgapi.ShieldConfig) error {
+	conf.mu.RLock()
+	currentRV := conf.resourceVersion
+	current := conf.ShieldConfig
+	conf.mu.RUnlock()
+
+	newRV := ecres.GetResourceVersion()
+	if current != nil && newRV != "" && newRV == currentRV {
 		return nil
 	}
 
-	ec := ecres.Spec.ShieldConfig
-	return ec
+	newConfig := ecres.Spec.ShieldConfig
+	if newConfig == nil {
+		return fmt.Errorf("ShieldConfig %s (resourceVersion: %s) has no shieldConfig in spec", conf.name, newRV)
+	}
+	newConfig = newConfig.DeepCopy()
+	newConfig.ChartRepo = conf.chartRepo
+	if err := newConfig.Validate(); err != nil {
+		return fmt.Errorf("ShieldConfig %s (resourceVersion: %s) is invalid; %s", conf.name, newRV, err.Error())
+	}
+
+	diffStr := ""
+	if current != nil {
+		diffStr = getConfigDiff(current, newConfig)
+	}
+
+	conf.mu.Lock()
+	conf.ShieldConfig = newConfig
+	conf.serverLogger = logger.NewLogger(newConfig.LoggerConfig())
+	conf.resourceVersion = newRV
+	conf.lastUpdated = time.Now()
+	handlers := append([]func(*cfg.ShieldConfig){}, conf.updateHandlers...)
+	conf.mu.Unlock()
+
+	if current != nil {
+		if diffStr == "" {
+			logger.Debug(fmt.Sprintf("ShieldConfig is reloaded without any change (resourceVersion: %s)", newRV))
+			return nil
+		}
+		logger.WithFields(log.Fields{
+			"resourceVersion": newRV,
+			"diff":            diffStr,
+		}).Info("ShieldConfig has been updated.")
+	}
+	for _, handler := range handlers {
+		handler(newConfig)
+	}
+	return nil
+}
+
+func getConfigDiff(current, newConfig *cfg.ShieldConfig) string {
+	currentBytes, _ := json.Marshal(current)
+	newBytes, _ := json.Marshal(newConfig)
+	currentNode, err1 := mapnode.NewFromBytes(currentBytes)
+	newNode, err2 := mapnode.NewFromBytes(newBytes)
+	if err1 != nil || err2 != nil {
+		return ""
+	}
+	dr := currentNode.Diff(newNode)
+	if dr == nil || dr.Size() == 0 {
+		return ""
+	}
+	return dr.String()
 }
@@ -0,0 +1,285 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	shield "github.com/IBM/integrity-enforcer/shield/pkg/shield"
+	cfg "github.com/IBM/integrity-enforcer/shield/pkg/shield/config"
+	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	healthStatusOK     = "ok"
+	healthStatusFailed = "failed"
+
+	componentShieldConfig     = "shieldConfig"
+	componentVerificationKeys = "verificationKeys"
+	componentResourceLoaders  = "resourceLoaders"
+	componentTLSCertificate   = "tlsCertificate"
+	componentServer           = "server"
+)
+
+// ComponentStatus is the health of a single component checked by the probes
+type ComponentStatus struct {
+	Name    string `json:"name"`
+	Ready   bool   `json:"ready"`
+	Message string `json:"message,omitempty"`
+}
+
+// HealthReport is returned as the response body of liveness / readiness probes
+type HealthReport struct {
+	Status     string            `json:"status"`
+	Components []ComponentStatus `json:"components"`
+}
+
+func newHealthReport(components ...ComponentStatus) *HealthReport {
+	status := healthStatusOK
+	for _, c := range components {
+		if !c.Ready {
+			status = healthStatusFailed
+			break
+		}
+	}
+	return &HealthReport{Status: status, Components: components}
+}
+
+func (r *HealthReport) IsOK() bool {
+	return r.Status == healthStatusOK
+}
+
+func writeHealthReport(w http.ResponseWriter, report *HealthReport) {
+	body, err := json.Marshal(report)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to marshal health report: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if report.IsOK() {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_, _ = w.Write(body)
+}
+
+func getShieldConfig() *cfg.ShieldConfig {
+	if config == nil {
+		return nil
+	}
+	shieldConfig, _ := config.Get()
+	return shieldConfig
+}
+
+func checkShieldConfig() ComponentStatus {
+	st := ComponentStatus{Name: componentShieldConfig}
+	shieldConfig := getShieldConfig()
+	if shieldConfig == nil {
+		st.Message = "ShieldConfig is not loaded"
+		return st
+	}
+	st.Ready = true
+	st.Message = fmt.Sprintf("ShieldConfig is loaded (mode: %s)", shieldConfig.Mode)
+	return st
+}
+
+// checkVerificationKeys confirms that every key in KeyPathList is parsed by KeyMaterialManager
+func checkVerificationKeys() ComponentStatus {
+	st := ComponentStatus{Name: componentVerificationKeys}
+	if getShieldConfig() == nil {
+		st.Message = "ShieldConfig is not loaded"
+		return st
+	}
+	keyManager := shield.GetKeyMaterialManager()
+	if keyManager == nil {
+		st.Message = "verification keys are not loaded"
+		return st
+	}
+	keyList := keyManager.List()
+	if len(keyList) == 0 {
+		st.Message = "no verification key is configured"
+		return st
+	}
+	failed := []string{}
+	loaded := 0
+	for _, km := range keyList {
+		if !km.IsValid() {
+			failed = append(failed, fmt.Sprintf("%s: %s", km.Path, describeKeyError(km.Error)))
+			continue
+		}
+		loaded += km.KeyCount()
+	}
+	if len(failed) > 0 {
+		st.Message = fmt.Sprintf("failed to load verification keys; %s", strings.Join(failed, "; "))
+		return st
+	}
+	st.Ready = true
+	st.Message = fmt.Sprintf("%d keys are loaded from %d key paths", loaded, len(keyList))
+	return st
+}
+
+func describeKeyError(err error) string {
+	if err == nil {
+		return "no key found"
+	}
+	return err.Error()
+}
+
+// interval of the access check of resources used for the decision; probes use the result of the last check
+const resourceLoaderCheckInterval = 10 * time.Second
+
+// resource loaders are reported as not ready only when no check has succeeded in this period,
+// so that a single slow response from the API server does not make all replicas unready at once
+const resourceLoaderStaleThreshold = 60 * time.Second
+
+type resourceLoaderChecker struct {
+	mu            sync.Mutex
+	loader        *shield.Loader
+	checking      bool
+	lastChecked   time.Time
+	lastSucceeded time.Time
+	lastError     string
+}
+
+var loaderChecker = &resourceLoaderChecker{}
+
+// checkResourceLoaders confirms that the resources used for the decision (RSP, Namespace) have been listed recently.
+// The access check runs in background at most once in the interval, and the probe never waits for the API server.
+func checkResourceLoaders() ComponentStatus {
+	st := ComponentStatus{Name: componentResourceLoaders}
+	shieldConfig := getShieldConfig()
+	if shieldConfig == nil {
+		st.Message = "ShieldConfig is not loaded"
+		return st
+	}
+	return loaderChecker.status(shieldConfig, time.Now())
+}
+
+func (self *resourceLoaderChecker) status(shieldConfig *cfg.ShieldConfig, now time.Time) ComponentStatus {
+	st := ComponentStatus{Name: componentResourceLoaders}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.loader == nil {
+		// clients are created once and reused by all probes
+		self.loader = shield.NewLoader(shieldConfig, "")
+	}
+	if self.loader.RSP.Client == nil || self.loader.Namespace.Client == nil {
+		st.Message = "kubernetes clients are not initialized"
+		return st
+	}
+	if !self.checking && now.Sub(self.lastChecked) >= resourceLoaderCheckInterval {
+		self.checking = true
+		self.lastChecked = now
+		go self.check()
+	}
+	if self.lastSucceeded.IsZero() {
+		st.Message = "ResourceSigningProfile and Namespace are not listed yet"
+		if self.lastError != "" {
+			st.Message = self.lastError
+		}
+		return st
+	}
+	if now.Sub(self.lastSucceeded) > resourceLoaderStaleThreshold {
+		st.Message = fmt.Sprintf("ResourceSigningProfile and Namespace have not been accessible since %s; %s", self.lastSucceeded.Format(time.RFC3339), self.lastError)
+		return st
+	}
+	st.Ready = true
+	st.Message = fmt.Sprintf("ResourceSigningProfile and Namespace were accessible at %s", self.lastSucceeded.Format(time.RFC3339))
+	return st
+}
+
+func (self *resourceLoaderChecker) check() {
+	errMsg := ""
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	opts := metav1.ListOptions{Limit: 1}
+	if _, err := self.loader.RSP.Client.ResourceSigningProfiles("").List(ctx, opts); err != nil {
+		errMsg = fmt.Sprintf("failed to list ResourceSigningProfile; %s", err.Error())
+	} else if _, err := self.loader.Namespace.Client.Namespaces().List(ctx, opts); err != nil {
+		errMsg = fmt.Sprintf("failed to list Namespace; %s", err.Error())
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.checking = false
+	self.lastError = errMsg
+	if errMsg == "" {
+		self.lastSucceeded = time.Now()
+	}
+}
+
+func (server *WebhookServer) checkTLSCertificate() ComponentStatus {
+	st := ComponentStatus{Name: componentTLSCertificate}
+	if server.certProvider == nil || server.certProvider.Leaf() == nil {
+		st.Message = "TLS certificate is not loaded"
+		return st
+	}
+	cert := server.certProvider.Leaf()
+	now := time.Now()
+	if now.Before(cert.NotBefore) {
+		st.Message = fmt.Sprintf("TLS certificate is not valid before %s", cert.NotBefore.Format(time.RFC3339))
+		return st
+	}
+	if now.After(cert.NotAfter) {
+		st.Message = fmt.Sprintf("TLS certificate expired at %s", cert.NotAfter.Format(time.RFC3339))
+		return st
+	}
+	st.Ready = true
+	st.Message = fmt.Sprintf("TLS certificate is valid until %s", cert.NotAfter.Format(time.RFC3339))
+	return st
+}
+
+func (server *WebhookServer) checkServer() ComponentStatus {
+	st := ComponentStatus{Name: componentServer}
+	if server.isShuttingDown() {
+		st.Message = "server is shutting down"
+		return st
+	}
+	st.Ready = true
+	st.Message = fmt.Sprintf("%d admission requests are in flight", len(server.inflight))
+	return st
+}
+
+func (server *WebhookServer) checkLiveness(w http.ResponseWriter, r *http.Request) {
+	report := newHealthReport(checkShieldConfig())
+	if !report.IsOK() {
+		logger.Warn("liveness check failed: ", report.Components)
+	}
+	writeHealthReport(w, report)
+}
+
+func (server *WebhookServer) checkReadiness(w http.ResponseWriter, r *http.Request) {
+	report := newHealthReport(
+		server.checkServer(),
+		checkShieldConfig(),
+		checkVerificationKeys(),
+		checkResourceLoaders(),
+		server.checkTLSCertificate(),
+	)
+	if !report.IsOK() {
+		logger.Warn("readiness check failed: ", report.Components)
+	}
+	writeHealthReport(w, report)
+}
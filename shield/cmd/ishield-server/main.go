@@ -17,7 +17,15 @@
 package main
 
 import (
+	"fmt"
+	"os"
+	"os/signal"
 	"path"
+	"strconv"
+	"syscall"
+	"time"
+
+	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
 )
 
 const (
@@ -26,10 +34,116 @@ const (
 	tlsKeyFile  = `tls.key`
 )
 
+const (
+	certReloadIntervalEnv    = "CERT_RELOAD_SEC"
+	keyReloadIntervalEnv     = "KEY_RELOAD_SEC"
+	defaultReloadIntervalSec = 10
+)
+
+const (
+	maxInflightRequestsEnv     = "MAX_INFLIGHT_REQUESTS"
+	saturationFailurePolicyEnv = "SATURATION_FAILURE_POLICY"
+	maxRequestBodyBytesEnv     = "MAX_REQUEST_BODY_BYTES"
+	shutdownDelaySecEnv        = "SHUTDOWN_DELAY_SEC"
+	shutdownTimeoutSecEnv      = "SHUTDOWN_TIMEOUT_SEC"
+
+	defaultMaxInflightRequests = 100
+	defaultMaxRequestBodyBytes = 3 * 1024 * 1024 // the same as the max request size of kube-apiserver
+	defaultShutdownDelaySec    = 5
+	defaultShutdownTimeoutSec  = 20
+)
+
+const (
+	// admission requests are recorded to this file for offline replay if set
+	recordFileEnv     = "RECORD_FILE"
+	recordMaxBytesEnv = "RECORD_MAX_BYTES"
+
+	defaultRecordMaxBytes = 100 * 1024 * 1024
+)
+
+const (
+	// allow requests without verification while the server is saturated
+	SaturationFailurePolicyIgnore = "Ignore"
+	// deny requests while the server is saturated (default)
+	SaturationFailurePolicyFail = "Fail"
+)
+
+type ServerOptions struct {
+	// 0 means no limit
+	MaxInflightRequests     int
+	SaturationFailurePolicy string
+	// must be positive
+	MaxRequestBodyBytes int64
+	// duration for which the server keeps serving after it becomes unready, so that it is removed from endpoints before draining
+	ShutdownDelay   time.Duration
+	ShutdownTimeout time.Duration
+	// empty means no recording
+	RecordFile     string
+	RecordMaxBytes int64
+}
+
 func main() {
 	tlsCertPath := path.Join(tlsDir, tlsCertFile)
 	tlsKeyPath := path.Join(tlsDir, tlsKeyFile)
 
-	webhookServer := createNewServer(tlsCertPath, tlsKeyPath)
-	webhookServer.Run()
+	initConfig()
+	webhookServer := createNewServer(tlsCertPath, tlsKeyPath, loadServerOptions())
+	if err := webhookServer.Run(setupSignalHandler()); err != nil {
+		logger.Fatal(err.Error())
+	}
+	logger.Info("Integrity Shield has been stopped.")
+}
+
+// setupSignalHandler returns a channel which is closed on SIGTERM or SIGINT.
+// The process exits immediately on the second signal.
+func setupSignalHandler() <-chan struct{} {
+	stopCh := make(chan struct{})
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		sig := <-sigCh
+		logger.Info("Received signal ", sig.String(), "; shutting down Integrity Shield.")
+		close(stopCh)
+		<-sigCh
+		os.Exit(1)
+	}()
+	return stopCh
+}
+
+func loadServerOptions() *ServerOptions {
+	opts := &ServerOptions{
+		MaxInflightRequests:     getIntFromEnv(maxInflightRequestsEnv, defaultMaxInflightRequests),
+		SaturationFailurePolicy: SaturationFailurePolicyFail,
+		MaxRequestBodyBytes:     int64(getIntFromEnv(maxRequestBodyBytesEnv, defaultMaxRequestBodyBytes)),
+		ShutdownDelay:           time.Duration(getIntFromEnv(shutdownDelaySecEnv, defaultShutdownDelaySec)) * time.Second,
+		ShutdownTimeout:         time.Duration(getIntFromEnv(shutdownTimeoutSecEnv, defaultShutdownTimeoutSec)) * time.Second,
+		RecordFile:              os.Getenv(recordFileEnv),
+		RecordMaxBytes:          int64(getIntFromEnv(recordMaxBytesEnv, defaultRecordMaxBytes)),
+	}
+	if s := os.Getenv(saturationFailurePolicyEnv); s == SaturationFailurePolicyIgnore {
+		opts.SaturationFailurePolicy = SaturationFailurePolicyIgnore
+	}
+	// every request would be rejected as too large with no body limit
+	if opts.MaxRequestBodyBytes <= 0 {
+		logger.Warn(fmt.Sprintf("%s must be positive; use the default value %d", maxRequestBodyBytesEnv, defaultMaxRequestBodyBytes))
+		opts.MaxRequestBodyBytes = defaultMaxRequestBodyBytes
+	}
+	return opts
+}
+
+func getIntFromEnv(envName string, defaultValue int) int {
+	if s := os.Getenv(envName); s != "" {
+		if v, err := strconv.Atoi(s); err == nil && v >= 0 {
+			return v
+		}
+	}
+	return defaultValue
+}
+
+func getReloadInterval(envName string) time.Duration {
+	interval := getIntFromEnv(envName, defaultReloadIntervalSec)
+	if interval == 0 {
+		interval = defaultReloadIntervalSec
+	}
+	return time.Duration(interval) * time.Second
 }
@@ -17,14 +17,21 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"sync/atomic"
+	"time"
 
 	shield "github.com/IBM/integrity-enforcer/shield/pkg/shield"
+	cfg "github.com/IBM/integrity-enforcer/shield/pkg/shield/config"
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/auditsink"
 	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	tlsutil "github.com/IBM/integrity-enforcer/shield/pkg/util/tlsutil"
 	log "github.com/sirupsen/logrus"
 	admv1 "k8s.io/api/admission/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -42,27 +49,46 @@ var (
 type WebhookServer struct {
 	mux               *http.ServeMux
 	certPath, keyPath string
+	certProvider      *tlsutil.CertificateProvider
+	options           *ServerOptions
+	recorder          *shield.AdmissionRecorder
+
+	// semaphore for bounding the number of admission requests processed concurrently
+	inflight     chan struct{}
+	shuttingDown int32
 }
 
 func init() {
 	log.SetFormatter(&log.JSONFormatter{})
+}
 
+// initConfig loads ShieldConfig and verification keys; the process exits if ShieldConfig cannot be loaded
+func initConfig() {
 	config = NewConfig()
 	config.InitShieldConfig()
-	logger.SetSingletonLoggerLevel(config.ShieldConfig.Log.LogLevel)
+	logger.SetSingletonLoggerLevel(config.ShieldConfig.LogConfig().LogLevel)
 	logger.Info("Integrity Shield has been started.")
 
 	cfgBytes, _ := json.Marshal(config)
 	logger.Trace(string(cfgBytes))
 	logger.Info("ShieldConfig is loaded.")
+
+	keyManager := shield.NewKeyMaterialManager(config.ShieldConfig.KeyPathList, getReloadInterval(keyReloadIntervalEnv))
+	shield.SetKeyMaterialManager(keyManager)
+
+	config.AddUpdateHandler(func(shieldConfig *cfg.ShieldConfig) {
+		logger.SetSingletonLoggerLevel(shieldConfig.LogConfig().LogLevel)
+		keyManager.SetKeyPathList(shieldConfig.KeyPathList)
+	})
 }
 
 func (server *WebhookServer) handleAdmissionRequest(admissionReviewReq *admv1.AdmissionReview) *admv1.AdmissionResponse {
 
-	_ = config.InitShieldConfig()
+	shieldConfig, serverLogger := config.Get()
 
 	gv := metav1.GroupVersion{Group: admissionReviewReq.Request.Kind.Group, Version: admissionReviewReq.Request.Kind.Version}
-	metaLogger := logger.NewLogger(config.ShieldConfig.LoggerConfig())
+	// logger for this request; its level can be changed by the handler without affecting other requests
+	metaLogger := logger.NewRequestLogger(serverLogger)
 	reqLog := metaLogger.WithFields(
 		log.Fields{
 			"namespace":  admissionReviewReq.Request.Namespace,
@@ -73,31 +99,103 @@ func (server *WebhookServer) handleAdmissionRequest(admissionReviewReq *admv1.Ad
 			"requestUID": string(admissionReviewReq.Request.UID),
 		},
 	)
-	reqHandler := shield.NewHandler(config.ShieldConfig, metaLogger, reqLog)
+	reqHandler := shield.NewHandler(shieldConfig, metaLogger, reqLog)
 	admissionRequest := admissionReviewReq.Request
 
 	//process request
 	admissionResponse := reqHandler.Run(admissionRequest)
 
+	if server.recorder != nil {
+		if record := reqHandler.Record(admissionRequest, admissionResponse); record != nil {
+			if err := server.recorder.Write(record); err != nil {
+				reqLog.Warn("Failed to record admission request; ", err.Error())
+			}
+		}
+	}
+
 	return admissionResponse
 
 }
 
-func (server *WebhookServer) checkLiveness(w http.ResponseWriter, r *http.Request) {
-	msg := "liveness ok"
-	_, _ = w.Write([]byte(msg))
+// acquire returns false immediately if the number of inflight requests reaches the limit
+func (server *WebhookServer) acquire() bool {
+	if server.inflight == nil {
+		return true
+	}
+	select {
+	case server.inflight <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (server *WebhookServer) release() {
+	if server.inflight == nil {
+		return
+	}
+	<-server.inflight
+}
+
+// createSaturatedResponse returns a response without verification based on SaturationFailurePolicy.
+// Requests are denied unless the policy is Ignore, and every request allowed without verification is logged as an error.
+func (server *WebhookServer) createSaturatedResponse(admissionReviewReq *admv1.AdmissionReview) *admv1.AdmissionResponse {
+	allowed := server.options.SaturationFailurePolicy == SaturationFailurePolicyIgnore
+	msg := fmt.Sprintf("IntegrityShield is saturated (max inflight requests: %d)", server.options.MaxInflightRequests)
+	reqLog := logger.WithFields(log.Fields{
+		"namespace":  admissionReviewReq.Request.Namespace,
+		"name":       admissionReviewReq.Request.Name,
+		"kind":       admissionReviewReq.Request.Kind.Kind,
+		"operation":  admissionReviewReq.Request.Operation,
+		"userName":   admissionReviewReq.Request.UserInfo.Username,
+		"requestUID": string(admissionReviewReq.Request.UID),
+		"allowed":    allowed,
+	})
+	if allowed {
+		msg = fmt.Sprintf("%s; this request is allowed without verification", msg)
+		reqLog.Error(msg)
+	} else {
+		reqLog.Warn(msg)
+	}
+	return &admv1.AdmissionResponse{
+		Allowed: allowed,
+		Result: &metav1.Status{
+			Message: msg,
+			Code:    http.StatusTooManyRequests,
+		},
+	}
 }
 
-func (server *WebhookServer) checkReadiness(w http.ResponseWriter, r *http.Request) {
-	msg := "readiness ok"
-	_, _ = w.Write([]byte(msg))
+// handleValidationRequest validates iShield custom resources for the validating webhook
+func (server *WebhookServer) handleValidationRequest(admissionReviewReq *admv1.AdmissionReview) *admv1.AdmissionResponse {
+	shieldConfig, _ := config.Get()
+	admissionResponse := shield.NewValidator(shieldConfig).Run(admissionReviewReq.Request)
+	if !admissionResponse.Allowed {
+		logger.WithFields(log.Fields{
+			"namespace":  admissionReviewReq.Request.Namespace,
+			"name":       admissionReviewReq.Request.Name,
+			"kind":       admissionReviewReq.Request.Kind.Kind,
+			"operation":  admissionReviewReq.Request.Operation,
+			"requestUID": string(admissionReviewReq.Request.UID),
+		}).Info(admissionResponse.Result.Message)
+	}
+	return admissionResponse
 }
 
 func (server *WebhookServer) serveRequest(w http.ResponseWriter, r *http.Request) {
+	server.serveAdmissionReview(w, r, server.handleAdmissionRequest)
+}
+
+func (server *WebhookServer) serveValidationRequest(w http.ResponseWriter, r *http.Request) {
+	server.serveAdmissionReview(w, r, server.handleValidationRequest)
+}
+
+func (server *WebhookServer) serveAdmissionReview(w http.ResponseWriter, r *http.Request, handle func(*admv1.AdmissionReview) *admv1.AdmissionResponse) {
 
 	var body []byte
 	if r.Body != nil {
-		if data, err := ioutil.ReadAll(r.Body); err != nil {
+		// read one more byte than the limit to detect too large body
+		if data, err := ioutil.ReadAll(io.LimitReader(r.Body, server.options.MaxRequestBodyBytes+1)); err != nil {
 			http.Error(w, "Could not read admission request", http.StatusBadRequest)
 			return
 		} else {
@@ -108,6 +206,10 @@ func (server *WebhookServer) serveRequest(w http.ResponseWriter, r *http.Request
 		http.Error(w, "Admission request has empty body", http.StatusBadRequest)
 		return
 	}
+	if int64(len(body)) > server.options.MaxRequestBodyBytes {
+		http.Error(w, fmt.Sprintf("Admission request body exceeds the limit (%d bytes)", server.options.MaxRequestBodyBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
 
 	contentType := r.Header.Get("Content-Type")
 	if contentType != "application/json" {
@@ -125,9 +227,25 @@ func (server *WebhookServer) serveRequest(w http.ResponseWriter, r *http.Request
 			},
 		}
 
+	} else if admissionReviewReq.Request == nil {
+
+		admissionResponse = &admv1.AdmissionResponse{
+			Result: &metav1.Status{
+				Message: "AdmissionReview does not contain a request",
+			},
+		}
+
+	} else if !server.acquire() {
+
+		admissionResponse = server.createSaturatedResponse(&admissionReviewReq)
+
 	} else {
 
-		admissionResponse = server.handleAdmissionRequest(&admissionReviewReq)
+		func() {
+			// the slot is released even if the handler panics
+			defer server.release()
+			admissionResponse = handle(&admissionReviewReq)
+		}()
 
 	}
 
@@ -147,43 +265,110 @@ func (server *WebhookServer) serveRequest(w http.ResponseWriter, r *http.Request
 
 	if err != nil {
 		http.Error(w, fmt.Sprintf("marshaling admision review response: %v", err), http.StatusInternalServerError)
-
+		return
 	}
 
 	if _, err := w.Write(resp); err != nil {
-		http.Error(w, fmt.Sprintf("could not write response: %v", err), http.StatusInternalServerError)
-
+		logger.Error(fmt.Sprintf("could not write response: %v", err))
 	}
 
 }
 
-func createNewServer(certPath, keyPath string) *WebhookServer {
-	return &WebhookServer{
+func createNewServer(certPath, keyPath string, options *ServerOptions) *WebhookServer {
+	server := &WebhookServer{
 		mux:      http.NewServeMux(),
 		certPath: certPath,
 		keyPath:  keyPath,
+		options:  options,
 	}
+	if options.MaxInflightRequests > 0 {
+		server.inflight = make(chan struct{}, options.MaxInflightRequests)
+	}
+	return server
 }
 
-func (server *WebhookServer) Run() {
+func (server *WebhookServer) isShuttingDown() bool {
+	return atomic.LoadInt32(&server.shuttingDown) == 1
+}
 
-	pair, err := tls.LoadX509KeyPair(server.certPath, server.keyPath)
+// Run serves admission requests until stopCh is closed, and then drains inflight requests.
+func (server *WebhookServer) Run(stopCh <-chan struct{}) error {
 
+	certProvider, err := tlsutil.NewCertificateProvider(server.certPath, server.keyPath, getReloadInterval(certReloadIntervalEnv))
 	if err != nil {
-		panic(fmt.Sprintf("unable to load certs: %v", err))
+		return fmt.Errorf("unable to load certs: %v", err)
 	}
+	server.certProvider = certProvider
+
+	if server.options.RecordFile != "" {
+		recorder, err := shield.NewAdmissionRecorder(server.options.RecordFile, server.options.RecordMaxBytes)
+		if err != nil {
+			return err
+		}
+		server.recorder = recorder
+		defer recorder.Close()
+		logger.Info(fmt.Sprintf("Admission requests are recorded to \"%s\"", server.options.RecordFile))
+	}
+
+	// TLS certificate and verification keys are reloaded when the mounted secrets are updated
+	go certProvider.Run(stopCh)
+	go shield.GetKeyMaterialManager().Run(stopCh)
+	go config.Run(stopCh)
 
 	server.mux.HandleFunc("/mutate", server.serveRequest)
+	server.mux.HandleFunc("/validate", server.serveValidationRequest)
+	// CRDs of iShield resources refer to this path for conversion between v1alpha1 and v1beta1
+	converter, err := shield.NewConversionWebhook()
+	if err != nil {
+		return fmt.Errorf("unable to create conversion webhook: %v", err)
+	}
+	server.mux.Handle("/convert", converter)
 	server.mux.HandleFunc("/health/liveness", server.checkLiveness)
 	server.mux.HandleFunc("/health/readiness", server.checkReadiness)
 
 	serverObj := &http.Server{
 		Addr:      ":8443",
-		TLSConfig: &tls.Config{Certificates: []tls.Certificate{pair}, MinVersion: tls.VersionTLS12},
+		TLSConfig: &tls.Config{GetCertificate: certProvider.GetCertificate, MinVersion: tls.VersionTLS12},
 		Handler:   server.mux,
 	}
 
-	if err := serverObj.ListenAndServeTLS("", ""); err != nil {
-		panic(fmt.Sprintf("Fail to run webhook server: %v", err))
+	errCh := make(chan error, 1)
+	go func() {
+		if err := serverObj.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+			errCh <- err
+		}
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("Fail to run webhook server: %v", err)
+	case <-stopCh:
+	}
+
+	return server.shutdown(serverObj)
+}
+
+// shutdown makes the server unready, and then stops it after inflight requests are drained
+func (server *WebhookServer) shutdown(serverObj *http.Server) error {
+	// become unready first, and keep serving until the pod is removed from the service endpoints
+	atomic.StoreInt32(&server.shuttingDown, 1)
+	logger.Info(fmt.Sprintf("Integrity Shield is shutting down; waiting %s before draining requests", server.options.ShutdownDelay))
+	time.Sleep(server.options.ShutdownDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), server.options.ShutdownTimeout)
+	defer cancel()
+	err := serverObj.Shutdown(ctx)
+	// results of the drained requests are sent before the process exits
+	server.stopBackgroundWorkers()
+	if err != nil {
+		return fmt.Errorf("Fail to drain inflight requests: %v", err)
 	}
+	return nil
+}
+
+// stopBackgroundWorkers sends the buffered results of admission requests and stops the workers
+func (server *WebhookServer) stopBackgroundWorkers() {
+	shield.StopRSPStatusUpdater()
+	shield.StopEventReporter()
+	auditsink.Close()
 }
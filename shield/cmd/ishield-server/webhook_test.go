@@ -0,0 +1,217 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	admv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestAdmissionReviewBody(t *testing.T, name string) []byte {
+	review := admv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admv1.AdmissionRequest{
+			UID:       "test-uid",
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+			Namespace: "test-ns",
+			Name:      name,
+			Operation: admv1.Create,
+		},
+	}
+	body, err := json.Marshal(review)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func newTestAdmissionRequest(body []byte) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/mutate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func decodeTestAdmissionResponse(t *testing.T, w *httptest.ResponseRecorder) *admv1.AdmissionResponse {
+	review := admv1.AdmissionReview{}
+	if err := json.Unmarshal(w.Body.Bytes(), &review); err != nil {
+		t.Fatalf("Failed to decode AdmissionReview; %s; %s", err.Error(), w.Body.String())
+	}
+	return review.Response
+}
+
+func allowAll(req *admv1.AdmissionReview) *admv1.AdmissionResponse {
+	return &admv1.AdmissionResponse{Allowed: true}
+}
+
+func TestServeAdmissionReviewSaturated(t *testing.T) {
+	for _, policy := range []string{SaturationFailurePolicyFail, SaturationFailurePolicyIgnore} {
+		server := createNewServer("", "", &ServerOptions{MaxInflightRequests: 1, SaturationFailurePolicy: policy, MaxRequestBodyBytes: defaultMaxRequestBodyBytes})
+		started := make(chan struct{})
+		unblock := make(chan struct{})
+		blocking := func(req *admv1.AdmissionReview) *admv1.AdmissionResponse {
+			close(started)
+			<-unblock
+			return &admv1.AdmissionResponse{Allowed: true}
+		}
+
+		done := make(chan *httptest.ResponseRecorder)
+		go func() {
+			w := httptest.NewRecorder()
+			server.serveAdmissionReview(w, newTestAdmissionRequest(newTestAdmissionReviewBody(t, "first")), blocking)
+			done <- w
+		}()
+		<-started
+
+		w := httptest.NewRecorder()
+		server.serveAdmissionReview(w, newTestAdmissionRequest(newTestAdmissionReviewBody(t, "second")), allowAll)
+		resp := decodeTestAdmissionResponse(t, w)
+		expectedAllowed := policy == SaturationFailurePolicyIgnore
+		if resp.Allowed != expectedAllowed || resp.Result == nil || resp.Result.Code != http.StatusTooManyRequests {
+			t.Errorf("Failed to test saturated response with %s policy; expected allowed: %v with code %d, but got %s", policy, expectedAllowed, http.StatusTooManyRequests, w.Body.String())
+		}
+
+		close(unblock)
+		if resp := decodeTestAdmissionResponse(t, <-done); !resp.Allowed {
+			t.Errorf("Failed to test inflight request with %s policy; the first request must be processed", policy)
+		}
+
+		// the slot is released after the first request
+		w = httptest.NewRecorder()
+		server.serveAdmissionReview(w, newTestAdmissionRequest(newTestAdmissionReviewBody(t, "third")), allowAll)
+		if resp := decodeTestAdmissionResponse(t, w); !resp.Allowed || resp.Result != nil {
+			t.Errorf("Failed to test inflight slot release with %s policy; got %s", policy, w.Body.String())
+		}
+	}
+}
+
+func TestServeAdmissionReviewReleaseOnPanic(t *testing.T) {
+	server := createNewServer("", "", &ServerOptions{MaxInflightRequests: 1, MaxRequestBodyBytes: defaultMaxRequestBodyBytes})
+	func() {
+		defer func() { _ = recover() }()
+		server.serveAdmissionReview(httptest.NewRecorder(), newTestAdmissionRequest(newTestAdmissionReviewBody(t, "panic")), func(req *admv1.AdmissionReview) *admv1.AdmissionResponse {
+			panic("test panic")
+		})
+	}()
+	if len(server.inflight) != 0 {
+		t.Errorf("Failed to test inflight slot release on panic; %d slots are still used", len(server.inflight))
+	}
+}
+
+func TestServeAdmissionReviewBodyLimit(t *testing.T) {
+	body := newTestAdmissionReviewBody(t, "sample")
+	testCases := []struct {
+		limit int64
+		code  int
+	}{
+		{int64(len(body)), http.StatusOK},
+		{int64(len(body)) - 1, http.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range testCases {
+		server := createNewServer("", "", &ServerOptions{MaxRequestBodyBytes: tc.limit})
+		w := httptest.NewRecorder()
+		server.serveAdmissionReview(w, newTestAdmissionRequest(body), allowAll)
+		if w.Code != tc.code {
+			t.Errorf("Failed to test body limit %d for %d bytes; expected %d, but got %d %s", tc.limit, len(body), tc.code, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestLoadServerOptions(t *testing.T) {
+	defer os.Unsetenv(maxRequestBodyBytesEnv)
+	defer os.Unsetenv(saturationFailurePolicyEnv)
+
+	opts := loadServerOptions()
+	if opts.SaturationFailurePolicy != SaturationFailurePolicyFail {
+		t.Errorf("Failed to test loadServerOptions; default saturation failure policy must be %s, but got %s", SaturationFailurePolicyFail, opts.SaturationFailurePolicy)
+	}
+
+	for _, v := range []string{"0", "-1"} {
+		os.Setenv(maxRequestBodyBytesEnv, v)
+		if opts := loadServerOptions(); opts.MaxRequestBodyBytes != defaultMaxRequestBodyBytes {
+			t.Errorf("Failed to test loadServerOptions; %s=%s must be replaced with the default, but got %d", maxRequestBodyBytesEnv, v, opts.MaxRequestBodyBytes)
+		}
+	}
+
+	os.Setenv(saturationFailurePolicyEnv, SaturationFailurePolicyIgnore)
+	if opts := loadServerOptions(); opts.SaturationFailurePolicy != SaturationFailurePolicyIgnore {
+		t.Errorf("Failed to test loadServerOptions; expected %s, but got %s", SaturationFailurePolicyIgnore, opts.SaturationFailurePolicy)
+	}
+}
+
+func TestShutdownDrainsInflightRequests(t *testing.T) {
+	server := createNewServer("", "", &ServerOptions{MaxRequestBodyBytes: defaultMaxRequestBodyBytes, ShutdownDelay: 100 * time.Millisecond, ShutdownTimeout: 5 * time.Second})
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	server.mux.HandleFunc("/mutate", func(w http.ResponseWriter, r *http.Request) {
+		server.serveAdmissionReview(w, r, func(req *admv1.AdmissionReview) *admv1.AdmissionResponse {
+			close(started)
+			<-unblock
+			return &admv1.AdmissionResponse{Allowed: true}
+		})
+	})
+	ts := httptest.NewServer(server.mux)
+	defer ts.Close()
+
+	respCh := make(chan *http.Response)
+	go func() {
+		resp, err := http.Post(ts.URL+"/mutate", "application/json", bytes.NewReader(newTestAdmissionReviewBody(t, "sample")))
+		if err != nil {
+			t.Errorf("Failed to test shutdown; inflight request failed; %s", err.Error())
+		}
+		respCh <- resp
+	}()
+	<-started
+
+	shutdownCh := make(chan error)
+	go func() {
+		shutdownCh <- server.shutdown(ts.Config)
+	}()
+	// the server becomes unready before draining
+	time.Sleep(50 * time.Millisecond)
+	if st := server.checkServer(); st.Ready {
+		t.Errorf("Failed to test shutdown; the server must be unready while shutting down")
+	}
+	select {
+	case err := <-shutdownCh:
+		t.Fatalf("Failed to test shutdown; shutdown returned before the inflight request was drained; %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	close(unblock)
+	resp := <-respCh
+	if resp == nil {
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"allowed":true`) {
+		t.Errorf("Failed to test shutdown; the inflight request must be completed, but got %d %s", resp.StatusCode, string(body))
+	}
+	if err := <-shutdownCh; err != nil {
+		t.Errorf("Failed to test shutdown; %s", err.Error())
+	}
+}
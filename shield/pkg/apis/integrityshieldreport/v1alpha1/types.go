@@ -0,0 +1,151 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	ComponentOperator string = "operator"
+	ComponentServer   string = "server"
+)
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +resource:path=integrityshieldreport,scope=Namespaced
+
+// IntegrityShieldReport is the CRD. It is created and updated by the observer, and summarizes the requests verified by iShield
+// and the health of iShield in a rolling history window.
+type IntegrityShieldReport struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard object's metadata.
+	metav1.ObjectMeta `json:"metadata"`
+	// Settings of the observer which updates this report.
+	Spec IntegrityShieldReportSpec `json:"spec"`
+	// Observed status of iShield.
+	Status IntegrityShieldReportStatus `json:"status"`
+}
+
+// IntegrityShieldReportSpec describes how the report is updated.
+type IntegrityShieldReportSpec struct {
+	// interval of the report update in seconds
+	IntervalSeconds int `json:"intervalSeconds,omitempty"`
+	// length of the history window in hours; counts in the status are aggregated over this window
+	HistoryWindowHours int `json:"historyWindowHours,omitempty"`
+}
+
+// IntegrityShieldReportStatus is the report of iShield.
+type IntegrityShieldReportStatus struct {
+	LastUpdated metav1.Time   `json:"lastUpdated,omitempty"`
+	Summary     ReportSummary `json:"summary"`
+	// request counts per namespace
+	Namespaces []NamespaceCount `json:"namespaces,omitempty"`
+	// denied request counts per ResourceSigningProfile
+	Profiles []ProfileCount `json:"profiles,omitempty"`
+	// resources with the most denied requests
+	TopDeniedResources []ResourceCount `json:"topDeniedResources,omitempty"`
+	// verified request counts per signer
+	Signers          []SignerCount           `json:"signers,omitempty"`
+	Pods             []PodHealth             `json:"pods,omitempty"`
+	VerificationKeys []VerificationKeyStatus `json:"verificationKeys,omitempty"`
+	// hourly buckets in the history window, from which the counts above are aggregated
+	History []HistoryBucket `json:"history,omitempty"`
+}
+
+type ReportSummary struct {
+	Events       int `json:"events"`
+	DeniedEvents int `json:"deniedEvents"`
+	NumOfRSPs    int `json:"numOfRSPs"`
+	NumOfResSigs int `json:"numOfResSigs"`
+}
+
+type NamespaceCount struct {
+	Namespace    string `json:"namespace"`
+	Events       int    `json:"events"`
+	DeniedEvents int    `json:"deniedEvents"`
+}
+
+type ProfileCount struct {
+	// "namespace/name" of the ResourceSigningProfile
+	Profile      string `json:"profile"`
+	DeniedEvents int    `json:"deniedEvents"`
+}
+
+type ResourceCount struct {
+	// "Kind/namespace/name", or "Kind/name" for cluster scope resources
+	Resource     string `json:"resource"`
+	DeniedEvents int    `json:"deniedEvents"`
+}
+
+type SignerCount struct {
+	Signer         string `json:"signer"`
+	VerifiedEvents int    `json:"verifiedEvents"`
+}
+
+type PodHealth struct {
+	Name         string            `json:"name"`
+	Component    string            `json:"component"`
+	Phase        string            `json:"phase,omitempty"`
+	Ready        bool              `json:"ready"`
+	RestartCount int32             `json:"restartCount"`
+	Containers   []ContainerHealth `json:"containers,omitempty"`
+}
+
+type ContainerHealth struct {
+	Name         string `json:"name"`
+	Ready        bool   `json:"ready"`
+	RestartCount int32  `json:"restartCount"`
+	// Running, Waiting or Terminated
+	State  string `json:"state,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type VerificationKeyStatus struct {
+	// name of the keyConfig
+	Name          string   `json:"name"`
+	Path          string   `json:"path"`
+	SignatureType string   `json:"signatureType"`
+	Valid         bool     `json:"valid"`
+	KeyCount      int      `json:"keyCount"`
+	Fingerprints  []string `json:"fingerprints,omitempty"`
+	Message       string   `json:"message,omitempty"`
+}
+
+// HistoryBucket counts requests in an hour which starts at Start
+type HistoryBucket struct {
+	Start        metav1.Time `json:"start"`
+	Events       int         `json:"events"`
+	DeniedEvents int         `json:"deniedEvents"`
+	// counts keyed by namespace, ResourceSigningProfile, resource and signer
+	Namespaces       map[string]int `json:"namespaces,omitempty"`
+	DeniedNamespaces map[string]int `json:"deniedNamespaces,omitempty"`
+	DeniedProfiles   map[string]int `json:"deniedProfiles,omitempty"`
+	DeniedResources  map[string]int `json:"deniedResources,omitempty"`
+	Signers          map[string]int `json:"signers,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// IntegrityShieldReportList is a list of Workflow resources
+type IntegrityShieldReportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []IntegrityShieldReport `json:"items"`
+}
@@ -17,15 +17,21 @@
 package v1alpha1
 
 import (
+	"strings"
 	"time"
 
 	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 var layout = "2006-01-02 15:04:05"
 
-const maxHistoryLength = 3
+// DefaultHistoryLength is the number of latest events kept in the status by default
+const DefaultHistoryLength = 3
+
+// DefaultOwnerTrustMaxDepth is the number of owners followed in the ownerReferences chain by default
+const DefaultOwnerTrustMaxDepth = 3
 
 // ResourceSigningProfileSpec defines the desired state of AppEnforcePolicy
 type ResourceSigningProfileSpec struct {
@@ -39,6 +45,23 @@ type ResourceSigningProfileSpec struct {
 	ProtectAttrs            []*common.AttrsPattern     `json:"protectAttrs,omitempty"`
 	UnprotectAttrs          []*common.AttrsPattern     `json:"unprotectAttrs,omitempty"`
 	IgnoreAttrs             []*common.AttrsPattern     `json:"ignoreAttrs,omitempty"`
+	// allow resources created by controllers from verified resources (e.g. Pods from a signed Deployment)
+	OwnerTrust *OwnerTrust `json:"ownerTrust,omitempty"`
+}
+
+// OwnerTrust allows resources without signature if their ownerReferences chain leads to
+// a resource labeled `integrityshield.io/resourceIntegrity: verified` which is protected by the same profile
+type OwnerTrust struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// max number of owners followed in the chain; default is 3
+	MaxDepth int `json:"maxDepth,omitempty"`
+	// kinds of owners which can be followed in the chain (e.g. ReplicaSet, Deployment); required
+	// +kubebuilder:validation:MinItems=1
+	OwnerKinds []string `json:"ownerKinds"`
+	// users who create resources from their owners (e.g. system:serviceaccount:kube-system:replicaset-controller);
+	// owners are followed only for requests by these users, because anyone can set ownerReferences; required
+	// +kubebuilder:validation:MinItems=1
+	Users []string `json:"users"`
 }
 
 // ResourceSigningProfileStatus defines the observed state of AppEnforcePolicy
@@ -46,6 +69,15 @@ type ResourceSigningProfileStatus struct {
 	DenyCount int                     `json:"denyCount,omitempty"`
 	Summary   []*ProfileStatusSummary `json:"denySummary,omitempty"`
 	Latest    []*ProfileStatusDetail  `json:"latestDeniedEvents,omitempty"`
+
+	// allowed requests, including ones allowed in detect mode or by break glass
+	AllowCount int `json:"allowCount,omitempty"`
+	// allowed requests which are verified by signature or no mutation
+	VerifiedCount int                     `json:"verifiedCount,omitempty"`
+	RuleSummary   []*ProfileRuleSummary   `json:"ruleSummary,omitempty"`
+	SignerSummary []*ProfileSignerSummary `json:"signerSummary,omitempty"`
+	LatestAllowed []*ProfileStatusDetail  `json:"latestAllowedEvents,omitempty"`
+	LastUpdated   string                  `json:"lastUpdated,omitempty"`
 }
 
 type ProfileStatusSummary struct {
@@ -53,9 +85,38 @@ type ProfileStatusSummary struct {
 	Count            int    `json:"count,omitempty"`
 }
 
+// ProfileRuleSummary is the number of requests matched with a rule in protectRules or forceCheckRules
+type ProfileRuleSummary struct {
+	Rule          string `json:"rule,omitempty"`
+	AllowCount    int    `json:"allowCount,omitempty"`
+	VerifiedCount int    `json:"verifiedCount,omitempty"`
+	DenyCount     int    `json:"denyCount,omitempty"`
+}
+
+// ProfileSignerSummary is the number of verified requests signed by a signer
+type ProfileSignerSummary struct {
+	Signer string `json:"signer,omitempty"`
+	Count  int    `json:"count,omitempty"`
+}
+
 type ProfileStatusDetail struct {
 	Request *common.Request `json:"request,omitempty"`
 	Result  *common.Result  `json:"result,omitempty"`
+	Rule    string          `json:"rule,omitempty"`
+	Signer  string          `json:"signer,omitempty"`
+}
+
+// ProfileEvent is a result of a request checked with the profile, which is recorded in the status
+// +k8s:deepcopy-gen=false
+type ProfileEvent struct {
+	Request  *common.Request
+	Message  string
+	Rule     string
+	Signer   string
+	Allowed  bool
+	Verified bool
+	// time when the request was checked
+	Timestamp time.Time
 }
 
 // +genclient
@@ -165,47 +226,290 @@ func (self ResourceSigningProfile) IgnoreAttrs(reqFields map[string]string) []*c
 	return patterns
 }
 
-func (self *ResourceSigningProfile) UpdateStatus(request *common.Request, errMsg string) *ResourceSigningProfile {
+func (self *OwnerTrust) IsEnabled() bool {
+	return self != nil && self.Enabled
+}
+
+func (self *OwnerTrust) GetMaxDepth() int {
+	if self == nil || self.MaxDepth <= 0 {
+		return DefaultOwnerTrustMaxDepth
+	}
+	return self.MaxDepth
+}
+
+// AllowsOwnerKind returns true if owners of the kind can be followed in the chain; no owner is followed if no kind is specified
+func (self *OwnerTrust) AllowsOwnerKind(kind string) bool {
+	if self == nil {
+		return false
+	}
+	for _, k := range self.OwnerKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsUser returns true if owners are followed for requests by the user; no owner is followed if no user is specified
+func (self *OwnerTrust) AllowsUser(userName string) bool {
+	if self == nil {
+		return false
+	}
+	for _, u := range self.Users {
+		if u == userName {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchedRule returns the protect or force check rule which matches the request, in the string format used in the status
+func (self ResourceSigningProfile) MatchedRule(reqFields map[string]string, iShieldNS string) string {
+	if protected, rule := self.Match(reqFields, iShieldNS); protected && rule != nil {
+		return rule.String()
+	}
+	return ""
+}
+
+// MayProtect returns true if a protect rule of the profile may match resources of the kind,
+// so that resources of other kinds do not have to be listed.
+func (self ResourceSigningProfile) MayProtect(apiGroup, apiVersion, kind string, namespaced bool) bool {
+	return self.matchProtectRules(apiGroup, apiVersion, kind, nil, namespaced)
+}
+
+// ProtectsResource returns true if a protect rule of the profile matches the existing resource.
+// Patterns of requests such as operation and user are not evaluated.
+func (self ResourceSigningProfile) ProtectsResource(apiGroup, apiVersion, kind, name string, namespaced bool) bool {
+	return self.matchProtectRules(apiGroup, apiVersion, kind, &name, namespaced)
+}
+
+// matchProtectRules matches protect rules with the resource type, and with the name if it is not nil
+func (self ResourceSigningProfile) matchProtectRules(apiGroup, apiVersion, kind string, name *string, namespaced bool) bool {
+	if self.Spec.Disabled {
+		return false
+	}
+	scope := string(common.ScopeNamespaced)
+	if !namespaced {
+		scope = string(common.ScopeCluster)
+	}
+	for _, rule := range self.Spec.ProtectRules {
+		if rule == nil {
+			continue
+		}
+		for _, pattern := range rule.Match {
+			if pattern == nil {
+				continue
+			}
+			if name != nil && !matchRulePattern(pattern.Name, *name) {
+				continue
+			}
+			if matchRulePattern(pattern.Scope, scope) && matchRulePattern(pattern.ApiGroup, apiGroup) &&
+				matchRulePattern(pattern.ApiVersion, apiVersion) && matchRulePattern(pattern.Kind, kind) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NamesKind returns true if a protect rule of the profile specifies the kind by its name, not by a wildcard
+func (self ResourceSigningProfile) NamesKind(kind string) bool {
+	if self.Spec.Disabled {
+		return false
+	}
+	for _, rule := range self.Spec.ProtectRules {
+		if rule == nil {
+			continue
+		}
+		for _, pattern := range rule.Match {
+			if pattern == nil || pattern.Kind == nil {
+				continue
+			}
+			for _, k := range strings.Split(string(*pattern.Kind), ",") {
+				if strings.TrimSpace(k) == kind {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
 
-	// Increment DenyCount
-	self.Status.DenyCount = self.Status.DenyCount + 1
+func matchRulePattern(pattern *common.RulePattern, value string) bool {
+	if pattern == nil {
+		return true
+	}
+	return common.MatchPattern(string(*pattern), value)
+}
 
-	// Update Summary
-	sumId := -1
-	var singleSummary *ProfileStatusSummary
-	for i, s := range self.Status.Summary {
-		if request.GroupVersionKind() == s.GroupVersionKind {
-			sumId = i
-			singleSummary = s
+// GetProtectedResources returns the resource types in the discovery result which may be protected by any of the profiles.
+// Secrets are included only if a profile names the kind explicitly, so that a wildcard rule does not expose them.
+func GetProtectedResources(resourceLists []*metav1.APIResourceList, profiles []ResourceSigningProfile) []schema.GroupVersionResource {
+	targets := []schema.GroupVersionResource{}
+	for _, resourceList := range resourceLists {
+		if resourceList == nil {
+			continue
+		}
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range resourceList.APIResources {
+			// subresources such as pods/status are not listed
+			if strings.Contains(resource.Name, "/") || !containsVerb(resource.Verbs, "list") {
+				continue
+			}
+			for _, profile := range profiles {
+				if !profile.MayProtect(gv.Group, gv.Version, resource.Kind, resource.Namespaced) {
+					continue
+				}
+				if gv.Group == "" && resource.Kind == "Secret" && !profile.NamesKind(resource.Kind) {
+					continue
+				}
+				targets = append(targets, gv.WithResource(resource.Name))
+				break
+			}
 		}
 	}
-	if sumId < 0 || singleSummary == nil {
-		singleSummary = &ProfileStatusSummary{
-			GroupVersionKind: request.GroupVersionKind(),
-			Count:            1,
+	return targets
+}
+
+func containsVerb(verbs metav1.Verbs, verb string) bool {
+	for _, v := range verbs {
+		if v == verb {
+			return true
 		}
-		self.Status.Summary = append(self.Status.Summary, singleSummary)
-	} else if sumId < len(self.Status.Summary) {
-		singleSummary.Count = singleSummary.Count + 1
-		self.Status.Summary[sumId] = singleSummary
 	}
+	return false
+}
+
+func (self *ResourceSigningProfile) UpdateStatus(request *common.Request, errMsg string) *ResourceSigningProfile {
+	event := &ProfileEvent{Request: request, Message: errMsg, Timestamp: time.Now()}
+	return self.RecordEvents([]*ProfileEvent{event}, DefaultHistoryLength)
+}
 
-	// Update Latest events
-	result := &common.Result{
-		Message:   errMsg,
-		Timestamp: time.Now().UTC().Format(layout),
+// RecordEvents updates the status with a batch of events; at most historyLength latest events are kept for each of denied and allowed ones
+func (self *ResourceSigningProfile) RecordEvents(events []*ProfileEvent, historyLength int) *ResourceSigningProfile {
+	if historyLength <= 0 {
+		historyLength = DefaultHistoryLength
 	}
-	newLatestEvents := []*ProfileStatusDetail{}
-	newSingleEvent := &ProfileStatusDetail{Request: request, Result: result}
-	newLatestEvents = append(newLatestEvents, newSingleEvent)
-	newLatestEvents = append(newLatestEvents, self.Status.Latest...)
-	if len(newLatestEvents) > maxHistoryLength {
-		newLatestEvents = newLatestEvents[:maxHistoryLength]
+	for _, event := range events {
+		self.recordEvent(event)
 	}
-	self.Status.Latest = newLatestEvents
+	self.Status.Latest = latestDetails(nil, self.Status.Latest, historyLength)
+	self.Status.LatestAllowed = latestDetails(nil, self.Status.LatestAllowed, historyLength)
+	self.Status.LastUpdated = time.Now().UTC().Format(layout)
 	return self
 }
 
+func (self *ResourceSigningProfile) recordEvent(event *ProfileEvent) {
+	if event.Rule != "" {
+		ruleSummary := self.Status.getRuleSummary(event.Rule)
+		if !event.Allowed {
+			ruleSummary.DenyCount = ruleSummary.DenyCount + 1
+		} else {
+			ruleSummary.AllowCount = ruleSummary.AllowCount + 1
+			if event.Verified {
+				ruleSummary.VerifiedCount = ruleSummary.VerifiedCount + 1
+			}
+		}
+	}
+
+	detail := &ProfileStatusDetail{
+		Request: event.Request,
+		Result: &common.Result{
+			Message:   event.Message,
+			Timestamp: event.Timestamp.UTC().Format(layout),
+		},
+		Rule:   event.Rule,
+		Signer: event.Signer,
+	}
+	// the latest event comes first
+	if !event.Allowed {
+		self.Status.DenyCount = self.Status.DenyCount + 1
+		self.Status.getDenySummary(event.Request.GroupVersionKind()).Count++
+		self.Status.Latest = append([]*ProfileStatusDetail{detail}, self.Status.Latest...)
+		return
+	}
+
+	self.Status.AllowCount = self.Status.AllowCount + 1
+	if event.Verified {
+		self.Status.VerifiedCount = self.Status.VerifiedCount + 1
+		if event.Signer != "" {
+			self.Status.getSignerSummary(event.Signer).Count++
+		}
+	}
+	self.Status.LatestAllowed = append([]*ProfileStatusDetail{detail}, self.Status.LatestAllowed...)
+}
+
+// Merge adds counts and latest events in another status which were recorded after this status
+func (self *ResourceSigningProfileStatus) Merge(delta *ResourceSigningProfileStatus, historyLength int) {
+	if historyLength <= 0 {
+		historyLength = DefaultHistoryLength
+	}
+	self.DenyCount = self.DenyCount + delta.DenyCount
+	self.AllowCount = self.AllowCount + delta.AllowCount
+	self.VerifiedCount = self.VerifiedCount + delta.VerifiedCount
+	for _, s := range delta.Summary {
+		self.getDenySummary(s.GroupVersionKind).Count += s.Count
+	}
+	for _, s := range delta.RuleSummary {
+		ruleSummary := self.getRuleSummary(s.Rule)
+		ruleSummary.AllowCount += s.AllowCount
+		ruleSummary.VerifiedCount += s.VerifiedCount
+		ruleSummary.DenyCount += s.DenyCount
+	}
+	for _, s := range delta.SignerSummary {
+		self.getSignerSummary(s.Signer).Count += s.Count
+	}
+	self.Latest = latestDetails(delta.Latest, self.Latest, historyLength)
+	self.LatestAllowed = latestDetails(delta.LatestAllowed, self.LatestAllowed, historyLength)
+	self.LastUpdated = time.Now().UTC().Format(layout)
+}
+
+func latestDetails(newer, older []*ProfileStatusDetail, historyLength int) []*ProfileStatusDetail {
+	details := []*ProfileStatusDetail{}
+	details = append(details, newer...)
+	details = append(details, older...)
+	if len(details) > historyLength {
+		details = details[:historyLength]
+	}
+	return details
+}
+
+func (self *ResourceSigningProfileStatus) getDenySummary(gvk string) *ProfileStatusSummary {
+	for _, s := range self.Summary {
+		if s.GroupVersionKind == gvk {
+			return s
+		}
+	}
+	s := &ProfileStatusSummary{GroupVersionKind: gvk}
+	self.Summary = append(self.Summary, s)
+	return s
+}
+
+func (self *ResourceSigningProfileStatus) getRuleSummary(rule string) *ProfileRuleSummary {
+	for _, s := range self.RuleSummary {
+		if s.Rule == rule {
+			return s
+		}
+	}
+	s := &ProfileRuleSummary{Rule: rule}
+	self.RuleSummary = append(self.RuleSummary, s)
+	return s
+}
+
+func (self *ResourceSigningProfileStatus) getSignerSummary(signer string) *ProfileSignerSummary {
+	for _, s := range self.SignerSummary {
+		if s.Signer == signer {
+			return s
+		}
+	}
+	s := &ProfileSignerSummary{Signer: signer}
+	self.SignerSummary = append(self.SignerSummary, s)
+	return s
+}
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // ResourceSigningProfileList contains a list of ResourceSigningProfile
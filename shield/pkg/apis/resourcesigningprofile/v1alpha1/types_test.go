@@ -0,0 +1,100 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package v1alpha1
+
+import (
+	"testing"
+
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMayProtect(t *testing.T) {
+	kind := common.RulePattern("ConfigMap")
+	profile := ResourceSigningProfile{}
+	profile.Spec.ProtectRules = []*common.Rule{{Match: []*common.RequestPattern{{Kind: &kind}}}}
+	if !profile.MayProtect("", "v1", "ConfigMap", true) {
+		t.Errorf("Failed to test MayProtect; ConfigMap must be protected")
+	}
+	if profile.MayProtect("", "v1", "Secret", true) {
+		t.Errorf("Failed to test MayProtect; Secret must not be protected")
+	}
+	profile.Spec.Disabled = true
+	if profile.MayProtect("", "v1", "ConfigMap", true) {
+		t.Errorf("Failed to test MayProtect; disabled profile must not protect any resource")
+	}
+}
+
+func TestGetProtectedResources(t *testing.T) {
+	kind := common.RulePattern("ConfigMap")
+	profile := ResourceSigningProfile{}
+	profile.Spec.ProtectRules = []*common.Rule{{Match: []*common.RequestPattern{{Kind: &kind}}}}
+	resourceLists := []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+				{Name: "secrets", Kind: "Secret", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+			},
+		},
+	}
+	targets := GetProtectedResources(resourceLists, []ResourceSigningProfile{profile})
+	if len(targets) != 1 || targets[0].Resource != "configmaps" {
+		t.Errorf("Failed to test GetProtectedResources; unexpected targets %v", targets)
+	}
+	// secrets are listed only if a profile names the kind
+	wildcard := common.RulePattern("*")
+	profile.Spec.ProtectRules = []*common.Rule{{Match: []*common.RequestPattern{{Kind: &wildcard}}}}
+	targets = GetProtectedResources(resourceLists, []ResourceSigningProfile{profile})
+	if len(targets) != 1 || targets[0].Resource != "configmaps" {
+		t.Errorf("Failed to test GetProtectedResources; secrets must not be listed by a wildcard rule %v", targets)
+	}
+	kind = common.RulePattern("ConfigMap,Secret")
+	profile.Spec.ProtectRules = []*common.Rule{{Match: []*common.RequestPattern{{Kind: &kind}}}}
+	targets = GetProtectedResources(resourceLists, []ResourceSigningProfile{profile})
+	if len(targets) != 2 {
+		t.Errorf("Failed to test GetProtectedResources; secrets must be listed if a rule names them %v", targets)
+	}
+}
+
+func TestProtectsResource(t *testing.T) {
+	kind := common.RulePattern("Deployment")
+	name := common.RulePattern("app-*")
+	profile := ResourceSigningProfile{}
+	profile.Spec.ProtectRules = []*common.Rule{{Match: []*common.RequestPattern{{Kind: &kind, Name: &name}}}}
+	if !profile.ProtectsResource("apps", "v1", "Deployment", "app-1", true) {
+		t.Errorf("Failed to test ProtectsResource; app-1 must be protected")
+	}
+	if profile.ProtectsResource("apps", "v1", "Deployment", "other", true) {
+		t.Errorf("Failed to test ProtectsResource; a resource of other name must not be protected")
+	}
+}
+
+func TestOwnerTrustAllowsUser(t *testing.T) {
+	controller := "system:serviceaccount:kube-system:replicaset-controller"
+	ownerTrust := &OwnerTrust{Enabled: true, OwnerKinds: []string{"ReplicaSet"}, Users: []string{controller}}
+	if !ownerTrust.AllowsUser(controller) {
+		t.Errorf("Failed to test AllowsUser; %s must be allowed", controller)
+	}
+	if ownerTrust.AllowsUser("kubernetes-admin") {
+		t.Errorf("Failed to test AllowsUser; a user who is not configured must not be allowed")
+	}
+	ownerTrust = nil
+	if ownerTrust.AllowsUser(controller) {
+		t.Errorf("Failed to test AllowsUser; no user must be allowed without owner trust")
+	}
+}
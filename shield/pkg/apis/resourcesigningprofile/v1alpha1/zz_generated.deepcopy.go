@@ -25,6 +25,64 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OwnerTrust) DeepCopyInto(out *OwnerTrust) {
+	*out = *in
+	if in.OwnerKinds != nil {
+		in, out := &in.OwnerKinds, &out.OwnerKinds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OwnerTrust.
+func (in *OwnerTrust) DeepCopy() *OwnerTrust {
+	if in == nil {
+		return nil
+	}
+	out := new(OwnerTrust)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProfileRuleSummary) DeepCopyInto(out *ProfileRuleSummary) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProfileRuleSummary.
+func (in *ProfileRuleSummary) DeepCopy() *ProfileRuleSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(ProfileRuleSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProfileSignerSummary) DeepCopyInto(out *ProfileSignerSummary) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProfileSignerSummary.
+func (in *ProfileSignerSummary) DeepCopy() *ProfileSignerSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(ProfileSignerSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ProfileStatusDetail) DeepCopyInto(out *ProfileStatusDetail) {
 	*out = *in
@@ -204,6 +262,11 @@ func (in *ResourceSigningProfileSpec) DeepCopyInto(out *ResourceSigningProfileSp
 			}
 		}
 	}
+	if in.OwnerTrust != nil {
+		in, out := &in.OwnerTrust, &out.OwnerTrust
+		*out = new(OwnerTrust)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -242,6 +305,39 @@ func (in *ResourceSigningProfileStatus) DeepCopyInto(out *ResourceSigningProfile
 			}
 		}
 	}
+	if in.RuleSummary != nil {
+		in, out := &in.RuleSummary, &out.RuleSummary
+		*out = make([]*ProfileRuleSummary, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(ProfileRuleSummary)
+				**out = **in
+			}
+		}
+	}
+	if in.SignerSummary != nil {
+		in, out := &in.SignerSummary, &out.SignerSummary
+		*out = make([]*ProfileSignerSummary, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(ProfileSignerSummary)
+				**out = **in
+			}
+		}
+	}
+	if in.LatestAllowed != nil {
+		in, out := &in.LatestAllowed, &out.LatestAllowed
+		*out = make([]*ProfileStatusDetail, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(ProfileStatusDetail)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
 	return
 }
 
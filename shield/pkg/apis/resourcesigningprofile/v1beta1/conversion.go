@@ -0,0 +1,62 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package v1beta1
+
+import (
+	v1alpha1 "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// ConvertTo converts this ResourceSigningProfile to the Hub version (v1alpha1).
+func (src *ResourceSigningProfile) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha1.ResourceSigningProfile)
+	src = src.DeepCopy()
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = v1alpha1.ResourceSigningProfileSpec{
+		Disabled:                src.Spec.Disabled,
+		TargetNamespaceSelector: src.Spec.TargetNamespaceSelector,
+		ProtectRules:            src.Spec.ProtectRules,
+		IgnoreRules:             src.Spec.IgnoreRules,
+		ForceCheckRules:         src.Spec.ForceCheckRules,
+		KustomizePatterns:       src.Spec.KustomizePatterns,
+		ProtectAttrs:            src.Spec.ProtectAttrs,
+		IgnoreAttrs:             src.Spec.IgnoreAttrs,
+		OwnerTrust:              src.Spec.OwnerTrust,
+	}
+	dst.Status = src.Status
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1alpha1) to this version.
+// `unprotectAttrs` works in the same way as `ignoreAttrs`, so it is merged into `ignoreAttrs`.
+func (dst *ResourceSigningProfile) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha1.ResourceSigningProfile).DeepCopy()
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = ResourceSigningProfileSpec{
+		Disabled:                src.Spec.Disabled,
+		TargetNamespaceSelector: src.Spec.TargetNamespaceSelector,
+		ProtectRules:            src.Spec.ProtectRules,
+		IgnoreRules:             src.Spec.IgnoreRules,
+		ForceCheckRules:         src.Spec.ForceCheckRules,
+		KustomizePatterns:       src.Spec.KustomizePatterns,
+		ProtectAttrs:            src.Spec.ProtectAttrs,
+		IgnoreAttrs:             append(src.Spec.IgnoreAttrs, src.Spec.UnprotectAttrs...),
+		OwnerTrust:              src.Spec.OwnerTrust,
+	}
+	dst.Status = src.Status
+	return nil
+}
@@ -0,0 +1,61 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package v1beta1
+
+import (
+	v1alpha1 "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ResourceSigningProfileSpec defines the desired state of ResourceSigningProfile.
+// The deprecated `unprotectAttrs` in v1alpha1 is removed; use `ignoreAttrs` instead.
+type ResourceSigningProfileSpec struct {
+	Disabled bool `json:"disabled,omitempty"`
+	// `TargetNamespaceSelector` is used only for profile in iShield NS
+	TargetNamespaceSelector *common.NamespaceSelector  `json:"targetNamespaceSelector,omitempty"`
+	ProtectRules            []*common.Rule             `json:"protectRules,omitempty"`
+	IgnoreRules             []*common.Rule             `json:"ignoreRules,omitempty"`
+	ForceCheckRules         []*common.Rule             `json:"forceCheckRules,omitempty"`
+	KustomizePatterns       []*common.KustomizePattern `json:"kustomizePatterns,omitempty"`
+	ProtectAttrs            []*common.AttrsPattern     `json:"protectAttrs,omitempty"`
+	IgnoreAttrs             []*common.AttrsPattern     `json:"ignoreAttrs,omitempty"`
+	OwnerTrust              *v1alpha1.OwnerTrust       `json:"ownerTrust,omitempty"`
+}
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +resource:path=resourcesigningprofile,scope=Namespaced
+
+// ResourceSigningProfile is the v1beta1 version of ResourceSigningProfile.
+type ResourceSigningProfile struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ResourceSigningProfileSpec            `json:"spec,omitempty"`
+	Status v1alpha1.ResourceSigningProfileStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ResourceSigningProfileList contains a list of ResourceSigningProfile
+type ResourceSigningProfileList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ResourceSigningProfile `json:"items"`
+}
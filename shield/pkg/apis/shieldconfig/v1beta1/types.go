@@ -0,0 +1,54 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package v1beta1
+
+import (
+	v1alpha1 "github.com/IBM/integrity-enforcer/shield/pkg/apis/shieldconfig/v1alpha1"
+	config "github.com/IBM/integrity-enforcer/shield/pkg/shield/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +resource:path=shieldconfig,scope=Namespaced
+
+// ShieldConfig is the v1beta1 version of ShieldConfig.
+type ShieldConfig struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard object's metadata.
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	// Specification of the desired behavior of ShieldConfig.
+	Spec ShieldConfigSpec `json:"spec,omitempty"`
+	// Observed status of ShieldConfig.
+	Status v1alpha1.ShieldConfigStatus `json:"status,omitempty"`
+}
+
+// ShieldConfigSpec is a desired state description of ShieldConfig.
+// In v1alpha1 the config is under `spec.ShieldConfig`, which is renamed to `spec.shieldConfig`.
+type ShieldConfigSpec struct {
+	ShieldConfig *config.ShieldConfig `json:"shieldConfig,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ShieldConfigList is a list of ShieldConfig.
+type ShieldConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ShieldConfig `json:"items"`
+}
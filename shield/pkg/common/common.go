@@ -72,6 +72,7 @@ const (
 	EventTypeAnnotationKey   = "integrityshield.io/eventType"
 	EventResultAnnotationKey = "integrityshield.io/eventResult"
 
+	EventTypeValueDriftReport     = "drift-report"
 	EventTypeValueReconcileReport = "reconcile-report"
 	EventTypeValueVerifyResult    = "verify-result"
 	EventResultValueAllow         = "allow"
@@ -312,7 +313,12 @@ type SignatureEvalResult struct {
 	Allow                bool        `json:"allow"`
 	MatchedSignerConfig  string      `json:"matchedSignerConfig"`
 	ResourceSignatureUID string      `json:"resourceSignatureUID"`
-	Error                *CheckError `json:"error"`
+	// key configs whose keys verified the signature
+	VerifiedKeyConfigs []string    `json:"verifiedKeyConfigs,omitempty"`
+	Error              *CheckError `json:"error"`
+	// true if a signature is found but the object is not identical with its signed message
+	MessageMismatch bool   `json:"messageMismatch,omitempty"`
+	MessageDiff     string `json:"messageDiff,omitempty"`
 }
 
 func (self *SignatureEvalResult) GetSignerName() string {
@@ -70,3 +70,22 @@ func TestResourceRef(t *testing.T) {
 		return
 	}
 }
+
+func TestSignerConfigWithRotatingKey(t *testing.T) {
+	signer := &SignerInfo{Email: "signer@example.com"}
+	sc := &SignerConfig{
+		Policies: []SignerConfigCondition{{Namespaces: []string{"*"}, Signers: []string{"SampleSigner"}}},
+		Signers: []SignerCondition{
+			{Name: "SampleSigner", KeyConfig: "old-key", Subjects: []SubjectMatchPattern{{Email: "signer@example.com"}}},
+			{Name: "SampleSigner", KeyConfig: "new-key", Subjects: []SubjectMatchPattern{{Email: "signer@example.com"}}},
+		},
+	}
+	for _, keyPath := range []string{"/old-key/pgp/pubring.gpg", "/new-key/x509/"} {
+		if ok, _ := sc.Match("test-ns", signer, []string{keyPath}); !ok {
+			t.Errorf("TestSignerConfigWithRotatingKey() Failed; signature verified with %s must be allowed", keyPath)
+		}
+	}
+	if ok, _ := sc.Match("test-ns", signer, []string{"/other-key/pgp/pubring.gpg"}); ok {
+		t.Error("TestSignerConfigWithRotatingKey() Failed; signature verified with other-key must not be allowed")
+	}
+}
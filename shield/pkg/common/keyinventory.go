@@ -0,0 +1,73 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package common
+
+import (
+	"fmt"
+	"sort"
+)
+
+/**********************************************
+
+					KeyInventory
+
+***********************************************/
+
+const (
+	KeyInventoryConfigMapName = "integrity-shield-key-inventory"
+	KeyInventoryDataKey       = "inventory.json"
+)
+
+// KeyInventory records the key configs which verified the latest signature of each resource.
+// It is written by the observer and read by the operator to find resources signed only by a retired key.
+type KeyInventory map[string]KeyInventoryEntry
+
+type KeyInventoryEntry struct {
+	KeyConfigs   []string `json:"keyConfigs"`
+	Signer       string   `json:"signer,omitempty"`
+	LastVerified string   `json:"lastVerified,omitempty"`
+}
+
+// KeyInventoryResourceName returns "Kind/namespace/name", or "Kind/name" for cluster scope resources
+func KeyInventoryResourceName(kind, namespace, name string) string {
+	if namespace == "" {
+		return fmt.Sprintf("%s/%s", kind, name)
+	}
+	return fmt.Sprintf("%s/%s/%s", kind, namespace, name)
+}
+
+// ResourcesVerifiedOnlyBy returns resources whose latest signature was verified with the key config but not with any of the others
+func (self KeyInventory) ResourcesVerifiedOnlyBy(keyConfig string, others []string) []string {
+	resources := []string{}
+	for resName, entry := range self {
+		if !ExactMatchWithPatternArray(keyConfig, entry.KeyConfigs) {
+			continue
+		}
+		verifiedByOthers := false
+		for _, other := range others {
+			if ExactMatchWithPatternArray(other, entry.KeyConfigs) {
+				verifiedByOthers = true
+				break
+			}
+		}
+		if !verifiedByOthers {
+			resources = append(resources, resName)
+		}
+	}
+	sort.Strings(resources)
+	return resources
+}
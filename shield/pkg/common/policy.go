@@ -62,6 +62,8 @@ func (p *SignerConfig) DeepCopy() *SignerConfig {
 	return p2
 }
 
+// GetSignerMap returns subject conditions of each signer name.
+// A name can be declared more than once with different key configs, e.g. while its key is being rotated.
 func (self *SignerConfig) GetSignerMap() map[string][]SubjectCondition {
 	signerMap := map[string][]SubjectCondition{}
 	for _, si := range self.Signers {
@@ -74,7 +76,7 @@ func (self *SignerConfig) GetSignerMap() map[string][]SubjectCondition {
 			}
 			tmpSC = append(tmpSC, sc)
 		}
-		signerMap[si.Name] = tmpSC
+		signerMap[si.Name] = append(signerMap[si.Name], tmpSC...)
 	}
 	return signerMap
 }
@@ -17,6 +17,7 @@
 package shield
 
 import (
+	"fmt"
 	"strings"
 
 	rsigapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesignature/v1alpha1"
@@ -25,6 +26,9 @@ import (
 
 	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
 	config "github.com/IBM/integrity-enforcer/shield/pkg/shield/config"
+	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 // check if request is inScope or not
@@ -198,6 +202,18 @@ func resourceSigningProfileCheck(singleProfile rspapi.ResourceSigningProfile, re
 	rsigList := data.GetResSigList(reqc)
 
 	allowed, evalReason, evalMessage, sigResult, mutResult = singleProfileCheck(singleProfile, reqc, config, sigConf, rsigList)
+	// ownerReferences can be set by anyone, so owners are followed only for requests by the configured controllers.
+	// also `verified` label of owners is removed from requests without signature only if patch is enabled
+	ownerTrust := singleProfile.Spec.OwnerTrust
+	if !allowed && evalReason == common.REASON_NO_SIG && ownerTrust.IsEnabled() && ownerTrust.AllowsUser(reqc.UserName) && config.PatchEnabled(reqc) {
+		ownerChain, verifiedOwner := verifiedOwnerCheck(singleProfile, reqc, data)
+		ctx.OwnerChain = ownerChain
+		if verifiedOwner {
+			allowed = true
+			evalReason = common.REASON_VERIFIED_OWNER
+			evalMessage = fmt.Sprintf("%s (%s)", common.ReasonCodeMap[common.REASON_VERIFIED_OWNER].Message, ownerChain[len(ownerChain)-1])
+		}
+	}
 
 	ctx.Allow = allowed
 	ctx.ReasonCode = evalReason
@@ -218,6 +234,7 @@ func resourceSigningProfileCheck(singleProfile rspapi.ResourceSigningProfile, re
 			Message:    evalMessage,
 		}
 	} else {
+		ctx.DenyingProfile = fmt.Sprintf("%s/%s", singleProfile.GetNamespace(), singleProfile.GetName())
 		return &DecisionResult{
 			Type:       common.DecisionDeny,
 			ReasonCode: evalReason,
@@ -273,3 +290,50 @@ func singleProfileCheck(singleProfile rspapi.ResourceSigningProfile, reqc *commo
 	}
 	return false, reasonCode, message, sigResult, mutResult
 }
+
+// verifiedOwnerCheck follows controller owners of the requested resource, and returns the owners in the chain
+// and true if an owner is labeled as verified. Each owner must have the UID in the reference, so that
+// a reference to a deleted and recreated resource is not trusted.
+func verifiedOwnerCheck(profile rspapi.ResourceSigningProfile, reqc *common.ReqContext, data *RunData) ([]string, bool) {
+	ownerTrust := profile.Spec.OwnerTrust
+	ownerChain := []string{}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(reqc.RawObject); err != nil {
+		return ownerChain, false
+	}
+	namespace := reqc.Namespace
+	for i := 0; i < ownerTrust.GetMaxDepth(); i++ {
+		ref := metav1.GetControllerOfNoCopy(obj)
+		if ref == nil || !ownerTrust.AllowsOwnerKind(ref.Kind) {
+			return ownerChain, false
+		}
+		owner, err := data.GetOwner(*ref, namespace)
+		if err != nil {
+			logger.Debug(fmt.Sprintf("failed to get owner %s %s; %s", ref.Kind, ref.Name, err.Error()))
+			return ownerChain, false
+		}
+		if owner.GetUID() != ref.UID {
+			return ownerChain, false
+		}
+		ownerChain = append(ownerChain, ownerString(owner))
+		// anyone who can edit the owner can set the label, so it is trusted only if the owner is protected by this profile,
+		// i.e. the label has been checked by the webhook with the same protect rules
+		if owner.GetLabels()[common.ResourceIntegrityLabelKey] == common.LabelValueVerified {
+			gvk := owner.GroupVersionKind()
+			if profile.ProtectsResource(gvk.Group, gvk.Version, gvk.Kind, owner.GetName(), owner.GetNamespace() != "") {
+				return ownerChain, true
+			}
+			logger.Debug(fmt.Sprintf("owner %s is labeled as verified, but it is not protected by the profile %s", ownerString(owner), profile.GetName()))
+		}
+		obj = owner
+		namespace = owner.GetNamespace()
+	}
+	return ownerChain, false
+}
+
+func ownerString(owner *unstructured.Unstructured) string {
+	if owner.GetNamespace() == "" {
+		return fmt.Sprintf("%s/%s", owner.GetKind(), owner.GetName())
+	}
+	return fmt.Sprintf("%s/%s/%s", owner.GetKind(), owner.GetNamespace(), owner.GetName())
+}
@@ -24,7 +24,15 @@ import (
 	"strings"
 	"testing"
 
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
 	"github.com/IBM/integrity-enforcer/shield/pkg/shield/config"
+	cache "github.com/IBM/integrity-enforcer/shield/pkg/util/cache"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
 )
 
 const (
@@ -150,3 +158,102 @@ func testRSPCheck(t *testing.T, caseNum int) {
 		t.Logf("[Case %s] Test for resourceSigningProfileCheck() passed.", strconv.Itoa(caseNum))
 	}
 }
+
+func newTestOwner(apiVersion, kind, name, uid string, owner *unstructured.Unstructured, labels map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace("test-ns")
+	obj.SetName(name)
+	obj.SetUID(types.UID(uid))
+	obj.SetLabels(labels)
+	if owner != nil {
+		isController := true
+		obj.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: owner.GetAPIVersion(), Kind: owner.GetKind(), Name: owner.GetName(), UID: owner.GetUID(), Controller: &isController}})
+	}
+	return obj
+}
+
+func TestVerifiedOwnerCheck(t *testing.T) {
+	deploy := newTestOwner("apps/v1", "Deployment", "app", "uid-deploy", nil, map[string]string{common.ResourceIntegrityLabelKey: common.LabelValueVerified})
+	rs := newTestOwner("apps/v1", "ReplicaSet", "app-1", "uid-rs", deploy, nil)
+	pod := newTestOwner("v1", "Pod", "app-1-x", "", rs, nil)
+	// the reference has the UID of a deleted ReplicaSet
+	stalePod := newTestOwner("v1", "Pod", "app-1-y", "", newTestOwner("apps/v1", "ReplicaSet", "app-1", "uid-deleted", nil, nil), nil)
+	// the Deployment is labeled as verified, but it is not protected
+	unprotectedDeploy := newTestOwner("apps/v1", "Deployment", "other", "uid-other", nil, map[string]string{common.ResourceIntegrityLabelKey: common.LabelValueVerified})
+	unprotectedPod := newTestOwner("v1", "Pod", "other-x", "", unprotectedDeploy, nil)
+	dyClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), deploy, rs, unprotectedDeploy)
+	data := &RunData{loader: &Loader{Owner: newOwnerLoader(dyClient)}}
+
+	ownerKinds := []string{"ReplicaSet", "Deployment"}
+	protectedKind := common.RulePattern("Deployment")
+	protectedName := common.RulePattern("app")
+	testCases := []struct {
+		name       string
+		obj        *unstructured.Unstructured
+		ownerTrust *rspapi.OwnerTrust
+		expected   bool
+		chainLen   int
+	}{
+		{"verified owner", pod, &rspapi.OwnerTrust{Enabled: true, OwnerKinds: ownerKinds}, true, 2},
+		{"max depth", pod, &rspapi.OwnerTrust{Enabled: true, MaxDepth: 1, OwnerKinds: ownerKinds}, false, 1},
+		{"owner kind", pod, &rspapi.OwnerTrust{Enabled: true, OwnerKinds: []string{"ReplicaSet"}}, false, 1},
+		{"no owner kind", pod, &rspapi.OwnerTrust{Enabled: true}, false, 0},
+		{"stale owner", stalePod, &rspapi.OwnerTrust{Enabled: true, OwnerKinds: ownerKinds}, false, 0},
+		{"unprotected owner", unprotectedPod, &rspapi.OwnerTrust{Enabled: true, OwnerKinds: ownerKinds}, false, 1},
+	}
+	for _, tc := range testCases {
+		cache.Clear()
+		objBytes, _ := json.Marshal(tc.obj.Object)
+		reqc := &common.ReqContext{Namespace: "test-ns", Kind: "Pod", Name: tc.obj.GetName(), RawObject: objBytes}
+		profile := rspapi.ResourceSigningProfile{
+			Spec: rspapi.ResourceSigningProfileSpec{
+				ProtectRules: []*common.Rule{{Match: []*common.RequestPattern{{Kind: &protectedKind, Name: &protectedName}}}},
+				OwnerTrust:   tc.ownerTrust,
+			},
+		}
+		chain, verified := verifiedOwnerCheck(profile, reqc, data)
+		if verified != tc.expected || len(chain) != tc.chainLen {
+			t.Errorf("Failed to test verifiedOwnerCheck; [%s] expected %v with %d owners, but got %v with %v", tc.name, tc.expected, tc.chainLen, verified, chain)
+		}
+	}
+}
+
+func TestOwnerTrustUser(t *testing.T) {
+	controller := "system:serviceaccount:kube-system:replicaset-controller"
+	deploy := newTestOwner("apps/v1", "Deployment", "app", "uid-deploy", nil, map[string]string{common.ResourceIntegrityLabelKey: common.LabelValueVerified})
+	deploy.SetNamespace("secure-ns")
+	dyClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), deploy)
+
+	protectedKind := common.RulePattern("Deployment")
+	protectedName := common.RulePattern("app")
+	testCases := []struct {
+		userName string
+		expected *DecisionResult
+	}{
+		// a user can point ownerReferences to a verified owner without signing the resource
+		{"kubernetes-admin", &DecisionResult{Type: common.DecisionDeny, ReasonCode: common.REASON_NO_SIG}},
+		{controller, &DecisionResult{Type: common.DecisionAllow, Verified: true, ReasonCode: common.REASON_VERIFIED_OWNER}},
+	}
+	for _, tc := range testCases {
+		cache.Clear()
+		reqc, config, data, ctx, _, prof, _ := getTestData(0)
+		data.loader = &Loader{Owner: newOwnerLoader(dyClient)}
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(reqc.RawObject); err != nil {
+			t.Fatal(err)
+		}
+		isController := true
+		obj.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "app", UID: deploy.GetUID(), Controller: &isController}})
+		reqc.RawObject, _ = obj.MarshalJSON()
+		reqc.UserName = tc.userName
+		prof.Spec.ProtectRules = append(prof.Spec.ProtectRules, &common.Rule{Match: []*common.RequestPattern{{Kind: &protectedKind, Name: &protectedName}}})
+		prof.Spec.OwnerTrust = &rspapi.OwnerTrust{Enabled: true, OwnerKinds: []string{"Deployment"}, Users: []string{controller}}
+
+		actualDr := resourceSigningProfileCheck(prof, reqc, config, data, ctx)
+		if actualDr.Type != tc.expected.Type || actualDr.Verified != tc.expected.Verified || actualDr.ReasonCode != tc.expected.ReasonCode {
+			t.Errorf("Failed to test owner trust for user %s; expected %s with reason %d, but got %s with reason %d", tc.userName, tc.expected.Type, tc.expected.ReasonCode, actualDr.Type, actualDr.ReasonCode)
+		}
+	}
+}
@@ -17,22 +17,19 @@
 package shield
 
 import (
-	"context"
 	"fmt"
 	"strings"
 	"time"
 
 	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
 	sigconfapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/signerconfig/v1alpha1"
-	rspclient "github.com/IBM/integrity-enforcer/shield/pkg/client/resourcesigningprofile/clientset/versioned/typed/resourcesigningprofile/v1alpha1"
-	"github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
 
 	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
 	config "github.com/IBM/integrity-enforcer/shield/pkg/shield/config"
 	admv1 "k8s.io/api/admission/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 func createAdmissionResponse(allowed bool, msg string, reqc *common.ReqContext, ctx *CheckContext, conf *config.ShieldConfig) *admv1.AdmissionResponse {
@@ -56,25 +53,29 @@ func createAdmissionResponse(allowed bool, msg string, reqc *common.ReqContext,
 	return resp
 }
 
-func createOrUpdateEvent(reqc *common.ReqContext, ctx *CheckContext, sconfig *config.ShieldConfig, denyRSP *rspapi.ResourceSigningProfile) error {
-	config, err := kubeutil.GetKubeConfig()
-	if err != nil {
-		return err
-	}
-	client, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return err
-	}
+// reportEvent reports the result as an Event; Events for the same resource and reason are aggregated and written in background
+func reportEvent(reqc *common.ReqContext, ctx *CheckContext, sconfig *config.ShieldConfig, denyRSP *rspapi.ResourceSigningProfile) {
+	evt := newEvent(reqc, ctx, sconfig, denyRSP)
+	reporter := getEventReporter()
+	reporter.SetConfig(sconfig.EventReportConfig())
+	reporter.Report(evt, time.Now())
+}
 
+func newEvent(reqc *common.ReqContext, ctx *CheckContext, sconfig *config.ShieldConfig, denyRSP *rspapi.ResourceSigningProfile) *v1.Event {
 	resultStr := "deny"
 	eventResult := common.EventResultValueDeny
 	if ctx.Allow {
 		resultStr = "allow"
 		eventResult = common.EventResultValueAllow
 	}
+	reason := common.ReasonCodeMap[ctx.ReasonCode].Code
 
 	sourceName := "IntegrityShield"
-	evtName := fmt.Sprintf("ishield-%s-%s-%s-%s", resultStr, strings.ToLower(reqc.Operation), strings.ToLower(reqc.Kind), reqc.Name)
+	// the name is made from the resource and the reason, so requests for the same resource are aggregated into an Event
+	evtName := fmt.Sprintf("ishield-%s-%s-%s-%s", resultStr, strings.ToLower(reqc.Kind), reqc.Name, reason)
+	if len(evtName) > validation.DNS1123SubdomainMaxLength {
+		evtName = evtName[:validation.DNS1123SubdomainMaxLength]
+	}
 
 	evtNamespace := reqc.Namespace
 	involvedObject := v1.ObjectReference{
@@ -93,10 +94,21 @@ func createOrUpdateEvent(reqc *common.ReqContext, ctx *CheckContext, sconfig *co
 		}
 	}
 
-	now := time.Now()
-	evt := &v1.Event{
+	rspInfo := ""
+	if denyRSP != nil {
+		rspInfo = fmt.Sprintf(" (RSP `namespace: %s, name: %s`)", denyRSP.GetNamespace(), denyRSP.GetName())
+	}
+	responseMessage := fmt.Sprintf("Result: %s, Reason: \"%s\"%s, Request: %s", resultStr, ctx.Message, rspInfo, reqc.Info(nil))
+	tmpMessage := fmt.Sprintf("[IntegrityShieldEvent] %s", responseMessage)
+	// Event.Message can have 1024 chars at most
+	if len(tmpMessage) > 1024 {
+		tmpMessage = tmpMessage[:950] + " ... Trimmed. `Event.Message` can have 1024 chars at maximum."
+	}
+
+	return &v1.Event{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: evtName,
+			Name:      evtName,
+			Namespace: evtNamespace,
 			Annotations: map[string]string{
 				common.EventTypeAnnotationKey:   common.EventTypeValueVerifyResult,
 				common.EventResultAnnotationKey: eventResult,
@@ -108,71 +120,9 @@ func createOrUpdateEvent(reqc *common.ReqContext, ctx *CheckContext, sconfig *co
 		ReportingController: sourceName,
 		ReportingInstance:   evtName,
 		Action:              evtName,
-		FirstTimestamp:      metav1.NewTime(now),
-	}
-	isExistingEvent := false
-	current, getErr := client.CoreV1().Events(evtNamespace).Get(context.Background(), evtName, metav1.GetOptions{})
-	if current != nil && getErr == nil {
-		isExistingEvent = true
-		evt = current
-	}
-
-	rspInfo := ""
-	if denyRSP != nil {
-		rspInfo = fmt.Sprintf(" (RSP `namespace: %s, name: %s`)", denyRSP.GetNamespace(), denyRSP.GetName())
-	}
-	responseMessage := fmt.Sprintf("Result: %s, Reason: \"%s\"%s, Request: %s", resultStr, ctx.Message, rspInfo, reqc.Info(nil))
-	tmpMessage := fmt.Sprintf("[IntegrityShieldEvent] %s", responseMessage)
-	// Event.Message can have 1024 chars at most
-	if len(tmpMessage) > 1024 {
-		tmpMessage = tmpMessage[:950] + " ... Trimmed. `Event.Message` can have 1024 chars at maximum."
-	}
-	evt.Message = tmpMessage
-	evt.Reason = common.ReasonCodeMap[ctx.ReasonCode].Code
-	evt.Count = evt.Count + 1
-	evt.EventTime = metav1.NewMicroTime(now)
-	evt.LastTimestamp = metav1.NewTime(now)
-
-	if isExistingEvent {
-		_, err = client.CoreV1().Events(evtNamespace).Update(context.Background(), evt, metav1.UpdateOptions{})
-	} else {
-		_, err = client.CoreV1().Events(evtNamespace).Create(context.Background(), evt, metav1.CreateOptions{})
-	}
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func updateRSPStatus(rsp *rspapi.ResourceSigningProfile, reqc *common.ReqContext, errMsg string) error {
-	if rsp == nil {
-		return nil
-	}
-
-	config, err := kubeutil.GetKubeConfig()
-	if err != nil {
-		return err
-	}
-	client, err := rspclient.NewForConfig(config)
-	if err != nil {
-		return err
-	}
-
-	rspNamespace := rsp.GetNamespace()
-	rspName := rsp.GetName()
-	rspOrg, err := client.ResourceSigningProfiles(rspNamespace).Get(context.Background(), rspName, metav1.GetOptions{})
-	if err != nil {
-		return err
-	}
-
-	req := common.NewRequestFromReqContext(reqc)
-	rspNew := rspOrg.UpdateStatus(req, errMsg)
-
-	_, err = client.ResourceSigningProfiles(rspNamespace).Update(context.Background(), rspNew, metav1.UpdateOptions{})
-	if err != nil {
-		return err
+		Message:             tmpMessage,
+		Reason:              reason,
 	}
-	return nil
 }
 
 func checkIfProfileTargetNamespace(reqNamespace, shieldNamespace string, data *RunData) bool {
@@ -47,6 +47,12 @@ type CheckContext struct {
 	MutationEvalResult  *common.MutationEvalResult  `json:"mutation"`
 
 	ReasonCode int `json:"reasonCode"`
+
+	// "namespace/name" of the ResourceSigningProfile which denied the request
+	DenyingProfile string `json:"denyingProfile"`
+
+	// owners followed in the ownerReferences chain by the verified owner check
+	OwnerChain []string `json:"ownerChain"`
 }
 
 func InitCheckContext(config *config.ShieldConfig) *CheckContext {
@@ -108,6 +114,14 @@ func (self *CheckContext) convertToLogRecord(reqc *common.ReqContext) map[string
 		logRecord["error"] = self.Error.Error()
 	}
 
+	if self.DenyingProfile != "" {
+		logRecord["denyingProfile"] = self.DenyingProfile
+	}
+
+	if len(self.OwnerChain) > 0 {
+		logRecord["ownerChain"] = self.OwnerChain
+	}
+
 	//context from sign policy eval
 	if self.SignatureEvalResult != nil {
 		r := self.SignatureEvalResult
@@ -119,6 +133,9 @@ func (self *CheckContext) convertToLogRecord(reqc *common.ReqContext) map[string
 			logRecord["sig.signer.displayName"] = r.GetSignerName()
 		}
 		logRecord["sig.allow"] = r.Allow
+		if len(r.VerifiedKeyConfigs) > 0 {
+			logRecord["sig.verifiedKeyConfigs"] = r.VerifiedKeyConfigs
+		}
 		if r.Error != nil {
 			logRecord["sig.errOccured"] = true
 			logRecord["sig.errMsg"] = r.Error.Msg
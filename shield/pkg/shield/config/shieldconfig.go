@@ -17,9 +17,14 @@
 package config
 
 import (
+	"fmt"
+	"strings"
+
 	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/auditsink"
 	"github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
 	"github.com/jinzhu/copier"
+	log "github.com/sirupsen/logrus"
 )
 
 type IntegrityShieldMode string
@@ -50,6 +55,8 @@ type ShieldConfig struct {
 	Mode                     IntegrityShieldMode       `json:"mode,omitempty"`
 	Plugin                   []PluginConfig            `json:"plugin,omitempty"`
 	CommonProfile            *common.CommonProfile     `json:"commonProfile,omitempty"`
+	ProfileStatus            *ProfileStatusConfig      `json:"profileStatus,omitempty"`
+	EventReport              *EventReportConfig        `json:"eventReport,omitempty"`
 
 	Namespace          string   `json:"namespace,omitempty"`
 	SignatureNamespace string   `json:"signatureNamespace,omitempty"`
@@ -68,16 +75,36 @@ type ShieldConfig struct {
 }
 
 type LoggingScopeConfig struct {
-	LogLevel             string          `json:"logLevel,omitempty"`
-	LogAllResponse       bool            `json:"logAllResponse,omitempty"`
-	IncludeRequest       bool            `json:"includeRequest,omitempty"`
-	IncludeRelease       bool            `json:"includeRelease,omitempty"`
-	ConsoleLog           *LogScopeConfig `json:"consoleLog,omitempty"`
-	ContextLog           *LogScopeConfig `json:"contextLog,omitempty"`
-	ConsoleLogFormat     string          `json:"consoleLogFormat,omitempty"`
-	ConsoleLogFile       string          `json:"consoleLogFile,omitempty"`
-	ContextLogFile       string          `json:"contextLogFile,omitempty"`
-	ContextLogRotateSize int64           `json:"contextLogRotateSize,omitempty"`
+	LogLevel              string          `json:"logLevel,omitempty"`
+	LogAllResponse        bool            `json:"logAllResponse,omitempty"`
+	IncludeRequest        bool            `json:"includeRequest,omitempty"`
+	IncludeRelease        bool            `json:"includeRelease,omitempty"`
+	ConsoleLog            *LogScopeConfig `json:"consoleLog,omitempty"`
+	ContextLog            *LogScopeConfig `json:"contextLog,omitempty"`
+	ConsoleLogFormat      string          `json:"consoleLogFormat,omitempty"`
+	ConsoleLogFile        string          `json:"consoleLogFile,omitempty"`
+	ContextLogFile        string          `json:"contextLogFile,omitempty"`
+	ContextLogRotateSize  int64           `json:"contextLogRotateSize,omitempty"`
+	ContextLogGenerations int             `json:"contextLogGenerations,omitempty"`
+	// destinations of context log records in addition to the context log file
+	AuditSinks []*auditsink.SinkConfig `json:"auditSinks,omitempty"`
+}
+
+// ProfileStatusConfig is the config of status of ResourceSigningProfiles, which is updated in batches in background
+type ProfileStatusConfig struct {
+	// the number of latest denied / allowed events in the status
+	HistoryLength int `json:"historyLength,omitempty"`
+	// interval between status updates; results of requests in the interval are written at once
+	UpdateIntervalSeconds int `json:"updateIntervalSeconds,omitempty"`
+}
+
+// EventReportConfig is the config of Events which report denied requests; Events for the same resource and reason are aggregated
+type EventReportConfig struct {
+	// minimum interval between updates of an Event; requests in the interval are added to the count at once
+	AggregationIntervalSeconds int `json:"aggregationIntervalSeconds,omitempty"`
+	// rate limit of Event writes by each server; Events over the limit are written later
+	QPS   int `json:"qps,omitempty"`
+	Burst int `json:"burst,omitempty"`
 }
 
 type PluginConfig struct {
@@ -191,6 +218,7 @@ func (ec *ShieldConfig) LogConfig() *LoggingScopeConfig {
 	defaultLogOutput := "" // console
 	defaultFilePath := "/ishield-app/public/events.txt"
 	defaultRotateSize := int64(10485760) // 10MB
+	defaultGenerations := 3
 	if lc.ConsoleLogFormat == "" {
 		lc.ConsoleLogFormat = defaultFormat
 	}
@@ -203,6 +231,9 @@ func (ec *ShieldConfig) LogConfig() *LoggingScopeConfig {
 	if lc.ContextLogRotateSize == 0 {
 		lc.ContextLogRotateSize = defaultRotateSize
 	}
+	if lc.ContextLogGenerations == 0 {
+		lc.ContextLogGenerations = defaultGenerations
+	}
 
 	return lc
 
@@ -225,7 +256,43 @@ func (ec *ShieldConfig) LoggerConfig() logger.LoggerConfig {
 
 func (ec *ShieldConfig) ContextLoggerConfig() logger.ContextLoggerConfig {
 	lc := ec.LogConfig()
-	return logger.ContextLoggerConfig{Enabled: lc.ContextLog.Enabled, File: lc.ContextLogFile, LimitSize: lc.ContextLogRotateSize}
+	return logger.ContextLoggerConfig{Enabled: lc.ContextLog.Enabled, File: lc.ContextLogFile, LimitSize: lc.ContextLogRotateSize, Generations: lc.ContextLogGenerations}
+}
+
+func (ec *ShieldConfig) ProfileStatusConfig() ProfileStatusConfig {
+	defaultHistoryLength := 3
+	defaultUpdateIntervalSeconds := 10
+	conf := ProfileStatusConfig{}
+	if ec.ProfileStatus != nil {
+		conf = *ec.ProfileStatus
+	}
+	if conf.HistoryLength == 0 {
+		conf.HistoryLength = defaultHistoryLength
+	}
+	if conf.UpdateIntervalSeconds == 0 {
+		conf.UpdateIntervalSeconds = defaultUpdateIntervalSeconds
+	}
+	return conf
+}
+
+func (ec *ShieldConfig) EventReportConfig() EventReportConfig {
+	defaultAggregationIntervalSeconds := 10
+	defaultQPS := 5
+	defaultBurst := 10
+	conf := EventReportConfig{}
+	if ec.EventReport != nil {
+		conf = *ec.EventReport
+	}
+	if conf.AggregationIntervalSeconds == 0 {
+		conf.AggregationIntervalSeconds = defaultAggregationIntervalSeconds
+	}
+	if conf.QPS == 0 {
+		conf.QPS = defaultQPS
+	}
+	if conf.Burst == 0 {
+		conf.Burst = defaultBurst
+	}
+	return conf
 }
 
 func (ec *ShieldConfig) ConsoleLogEnabled(reqc *common.ReqContext) (bool, string) {
@@ -248,3 +315,62 @@ func (ec *ShieldConfig) GetEnabledPlugins() map[string]bool {
 	}
 	return plugins
 }
+
+// Validate checks if this ShieldConfig can be used by the server; an invalid config must not replace the current one
+func (ec *ShieldConfig) Validate() error {
+	errs := []string{}
+	if ec.Namespace == "" {
+		errs = append(errs, "namespace must be specified")
+	}
+	if ec.Mode != UnknownMode && ec.Mode != EnforceMode && ec.Mode != DetectMode {
+		errs = append(errs, fmt.Sprintf("mode must be \"%s\" or \"%s\", but \"%s\" is specified", EnforceMode, DetectMode, ec.Mode))
+	}
+	if ec.Log != nil {
+		if ec.Log.LogLevel != "" {
+			if _, err := log.ParseLevel(ec.Log.LogLevel); err != nil {
+				errs = append(errs, fmt.Sprintf("log.logLevel is invalid; %s", err.Error()))
+			}
+		}
+		if ec.Log.ContextLogRotateSize < 0 {
+			errs = append(errs, "log.contextLogRotateSize must not be negative")
+		}
+		if ec.Log.ContextLogGenerations < 0 {
+			errs = append(errs, "log.contextLogGenerations must not be negative")
+		}
+		for i, sink := range ec.Log.AuditSinks {
+			if sink == nil {
+				continue
+			}
+			if err := sink.Validate(); err != nil {
+				errs = append(errs, fmt.Sprintf("log.auditSinks[%d] is invalid; %s", i, err.Error()))
+			}
+		}
+	}
+	if ec.ProfileStatus != nil {
+		if ec.ProfileStatus.HistoryLength < 0 {
+			errs = append(errs, "profileStatus.historyLength must not be negative")
+		}
+		if ec.ProfileStatus.UpdateIntervalSeconds < 0 {
+			errs = append(errs, "profileStatus.updateIntervalSeconds must not be negative")
+		}
+	}
+	if ec.EventReport != nil {
+		if ec.EventReport.AggregationIntervalSeconds < 0 {
+			errs = append(errs, "eventReport.aggregationIntervalSeconds must not be negative")
+		}
+		if ec.EventReport.QPS < 0 || ec.EventReport.Burst < 0 {
+			errs = append(errs, "eventReport.qps and eventReport.burst must not be negative")
+		}
+	}
+	pgpPattern := fmt.Sprintf("/%s/", string(common.SignatureTypePGP))
+	x509Pattern := fmt.Sprintf("/%s/", string(common.SignatureTypeX509))
+	for _, keyPath := range ec.KeyPathList {
+		if !strings.Contains(keyPath, pgpPattern) && !strings.Contains(keyPath, x509Pattern) {
+			errs = append(errs, fmt.Sprintf("keyPathList contains a path with unknown signature type: %s", keyPath))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid ShieldConfig; %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
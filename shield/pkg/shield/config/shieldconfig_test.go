@@ -0,0 +1,54 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package config
+
+import (
+	"testing"
+
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/auditsink"
+)
+
+func TestValidate(t *testing.T) {
+	valid := &ShieldConfig{
+		Namespace:   "integrity-shield-operator-system",
+		Mode:        EnforceMode,
+		Log:         &LoggingScopeConfig{LogLevel: "info"},
+		KeyPathList: []string{"/keyring/pgp/pubring.gpg", "/cert/x509/"},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("valid ShieldConfig is rejected; %s", err.Error())
+	}
+
+	invalidCases := map[string]func(sc *ShieldConfig){
+		"empty namespace":           func(sc *ShieldConfig) { sc.Namespace = "" },
+		"unknown mode":              func(sc *ShieldConfig) { sc.Mode = "audit" },
+		"invalid loglevel":          func(sc *ShieldConfig) { sc.Log = &LoggingScopeConfig{LogLevel: "verbose"} },
+		"unknown key type":          func(sc *ShieldConfig) { sc.KeyPathList = []string{"/keyring/pubring.gpg"} },
+		"negative history length":   func(sc *ShieldConfig) { sc.ProfileStatus = &ProfileStatusConfig{HistoryLength: -1} },
+		"negative event rate limit": func(sc *ShieldConfig) { sc.EventReport = &EventReportConfig{QPS: -1} },
+		"kafka sink without topic": func(sc *ShieldConfig) {
+			sc.Log.AuditSinks = []*auditsink.SinkConfig{{Name: "kafka", Type: auditsink.SinkTypeKafkaREST, URL: "http://kafka-rest:8082"}}
+		},
+	}
+	for name, modify := range invalidCases {
+		sc := valid.DeepCopy()
+		modify(sc)
+		if err := sc.Validate(); err == nil {
+			t.Errorf("ShieldConfig with %s should be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	hrmv1alpha1 "github.com/IBM/integrity-enforcer/shield/pkg/apis/helmreleasemetadata/v1alpha1"
+	hrmv1beta1 "github.com/IBM/integrity-enforcer/shield/pkg/apis/helmreleasemetadata/v1beta1"
+	rsigv1alpha1 "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesignature/v1alpha1"
+	rsigv1beta1 "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesignature/v1beta1"
+	rspv1alpha1 "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	rspv1beta1 "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1beta1"
+	sconfv1alpha1 "github.com/IBM/integrity-enforcer/shield/pkg/apis/shieldconfig/v1alpha1"
+	sconfv1beta1 "github.com/IBM/integrity-enforcer/shield/pkg/apis/shieldconfig/v1beta1"
+	sigconfv1alpha1 "github.com/IBM/integrity-enforcer/shield/pkg/apis/signerconfig/v1alpha1"
+	sigconfv1beta1 "github.com/IBM/integrity-enforcer/shield/pkg/apis/signerconfig/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+)
+
+// NewConversionWebhook returns the handler of ConversionReview for iShield custom resources.
+// v1alpha1 is the storage version (hub), and objects are converted to/from v1beta1 through it.
+func NewConversionWebhook() (*conversion.Webhook, error) {
+	scheme := runtime.NewScheme()
+	addToSchemes := []func(*runtime.Scheme) error{
+		rspv1alpha1.AddToScheme, rspv1beta1.AddToScheme,
+		sigconfv1alpha1.AddToScheme, sigconfv1beta1.AddToScheme,
+		sconfv1alpha1.AddToScheme, sconfv1beta1.AddToScheme,
+		rsigv1alpha1.AddToScheme, rsigv1beta1.AddToScheme,
+		hrmv1alpha1.AddToScheme, hrmv1beta1.AddToScheme,
+	}
+	for _, addToScheme := range addToSchemes {
+		if err := addToScheme(scheme); err != nil {
+			return nil, err
+		}
+	}
+	wh := &conversion.Webhook{}
+	if err := wh.InjectScheme(scheme); err != nil {
+		return nil, err
+	}
+	return wh, nil
+}
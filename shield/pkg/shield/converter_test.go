@@ -0,0 +1,100 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apix "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func convertForTest(t *testing.T, desiredAPIVersion string, objects ...string) []map[string]interface{} {
+	wh, err := NewConversionWebhook()
+	if err != nil {
+		t.Fatal(err)
+	}
+	review := &apix.ConversionReview{
+		Request: &apix.ConversionRequest{UID: "test-uid", DesiredAPIVersion: desiredAPIVersion},
+	}
+	review.APIVersion = "apiextensions.k8s.io/v1beta1"
+	review.Kind = "ConversionReview"
+	for _, obj := range objects {
+		review.Request.Objects = append(review.Request.Objects, runtime.RawExtension{Raw: []byte(obj)})
+	}
+	reqBody, _ := json.Marshal(review)
+	req := httptest.NewRequest(http.MethodPost, "/convert", bytes.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	wh.ServeHTTP(rec, req)
+
+	result := &apix.ConversionReview{}
+	if err := json.Unmarshal(rec.Body.Bytes(), result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Response == nil || result.Response.Result.Status != "Success" {
+		t.Fatalf("conversion failed: %s", rec.Body.String())
+	}
+	converted := []map[string]interface{}{}
+	for _, obj := range result.Response.ConvertedObjects {
+		var m map[string]interface{}
+		if err := json.Unmarshal(obj.Raw, &m); err != nil {
+			t.Fatal(err)
+		}
+		if m["apiVersion"] != desiredAPIVersion {
+			t.Errorf("unexpected apiVersion: %v", m["apiVersion"])
+		}
+		converted = append(converted, m)
+	}
+	return converted
+}
+
+func TestConversionWebhook(t *testing.T) {
+	rspV1alpha1 := `{"apiVersion":"apis.integrityshield.io/v1alpha1","kind":"ResourceSigningProfile","metadata":{"name":"test-rsp","namespace":"test-ns"},` +
+		`"spec":{"protectRules":[{"match":[{"kind":"ConfigMap"}]}],"ignoreAttrs":[{"match":[{"kind":"ConfigMap"}],"attrs":["data.a"]}],"unprotectAttrs":[{"match":[{"kind":"ConfigMap"}],"attrs":["data.b"]}]}}`
+	scV1alpha1 := `{"apiVersion":"apis.integrityshield.io/v1alpha1","kind":"ShieldConfig","metadata":{"name":"test-sc","namespace":"test-ns"},"spec":{"ShieldConfig":{"mode":"detect"}}}`
+
+	converted := convertForTest(t, "apis.integrityshield.io/v1beta1", rspV1alpha1, scV1alpha1)
+	if len(converted) != 2 {
+		t.Fatalf("unexpected number of converted objects: %d", len(converted))
+	}
+	rspSpec := converted[0]["spec"].(map[string]interface{})
+	if _, ok := rspSpec["unprotectAttrs"]; ok {
+		t.Errorf("unprotectAttrs must be removed in v1beta1")
+	}
+	if ignoreAttrs, ok := rspSpec["ignoreAttrs"].([]interface{}); !ok || len(ignoreAttrs) != 2 {
+		t.Errorf("unprotectAttrs must be merged into ignoreAttrs: %v", rspSpec)
+	}
+	scSpec := converted[1]["spec"].(map[string]interface{})
+	if sc, ok := scSpec["shieldConfig"].(map[string]interface{}); !ok || sc["mode"] != "detect" {
+		t.Errorf("unexpected ShieldConfig spec in v1beta1: %v", scSpec)
+	}
+
+	scV1beta1, _ := json.Marshal(converted[1])
+	converted = convertForTest(t, "apis.integrityshield.io/v1alpha1", string(scV1beta1))
+	scSpec = converted[0]["spec"].(map[string]interface{})
+	if sc, ok := scSpec["ShieldConfig"].(map[string]interface{}); !ok || sc["mode"] != "detect" {
+		t.Errorf("unexpected ShieldConfig spec in v1alpha1: %v", scSpec)
+	}
+	if metadata := converted[0]["metadata"].(map[string]interface{}); metadata["name"] != "test-sc" {
+		t.Errorf("metadata must be kept: %v", metadata)
+	}
+}
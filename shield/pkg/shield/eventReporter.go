@@ -0,0 +1,272 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	config "github.com/IBM/integrity-enforcer/shield/pkg/shield/config"
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
+	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/client-go/util/retry"
+)
+
+// Events over this number are dropped until pending ones are written
+const maxPendingEvents = 10000
+
+// interval to check pending Events; each Event is written at most once in the aggregation interval
+const eventFlushInterval = time.Second
+
+// pending Events are written on stop within this timeout; the rate limit is still applied
+const eventStopFlushTimeout = 10 * time.Second
+
+/**********************************************
+
+				EventReporter
+
+***********************************************/
+
+// pendingEvent is an Event aggregated in memory which is not written yet
+type pendingEvent struct {
+	evt   *v1.Event
+	count int32
+	first time.Time
+	last  time.Time
+}
+
+// EventReporter aggregates Events for the same resource and reason, and writes them in background with a rate limit.
+// Event names are made from the resource and the reason, so that replicas of the server update the same Event,
+// and counts from replicas are added to the existing Event with optimistic concurrency.
+type EventReporter struct {
+	client corev1client.EventsGetter
+
+	mu      sync.Mutex
+	conf    config.EventReportConfig
+	limiter flowcontrol.RateLimiter
+	pending map[string]*pendingEvent
+	// time when each Event was written last
+	lastWritten map[string]time.Time
+	stopCh      chan struct{}
+	doneCh      chan struct{}
+}
+
+var defaultEventReporter *EventReporter
+var defaultEventReporterOnce sync.Once
+
+func getEventReporter() *EventReporter {
+	defaultEventReporterOnce.Do(func() {
+		var client corev1client.EventsGetter
+		if kubeconfig, err := kubeutil.GetKubeConfig(); err == nil {
+			if clientset, err := kubernetes.NewForConfig(kubeconfig); err == nil {
+				client = clientset.CoreV1()
+			}
+		}
+		defaultEventReporter = NewEventReporter(client)
+		defaultEventReporter.Start()
+	})
+	return defaultEventReporter
+}
+
+// StopEventReporter writes the pending Events and stops the default reporter if it has been started.
+// This is called after admission requests are drained, so that Events of the last requests are not lost.
+func StopEventReporter() {
+	if defaultEventReporter != nil {
+		defaultEventReporter.Stop()
+	}
+}
+
+func NewEventReporter(client corev1client.EventsGetter) *EventReporter {
+	reporter := &EventReporter{
+		client:      client,
+		pending:     map[string]*pendingEvent{},
+		lastWritten: map[string]time.Time{},
+	}
+	reporter.SetConfig((&config.ShieldConfig{}).EventReportConfig())
+	return reporter
+}
+
+// SetConfig updates the aggregation interval and the rate limit
+func (self *EventReporter) SetConfig(conf config.EventReportConfig) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.limiter != nil && conf.QPS == self.conf.QPS && conf.Burst == self.conf.Burst {
+		self.conf = conf
+		return
+	}
+	self.conf = conf
+	self.limiter = flowcontrol.NewTokenBucketRateLimiter(float32(conf.QPS), conf.Burst)
+}
+
+// Report adds an occurrence of the Event; message, reason and annotations of the latest one are written
+func (self *EventReporter) Report(evt *v1.Event, now time.Time) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	key := eventKey(evt.GetNamespace(), evt.GetName())
+	pe, ok := self.pending[key]
+	if !ok {
+		if len(self.pending) >= maxPendingEvents {
+			logger.Warn(fmt.Sprintf("Event %s is dropped; too many Events are pending", key))
+			return
+		}
+		pe = &pendingEvent{first: now}
+		self.pending[key] = pe
+	}
+	pe.evt = evt
+	pe.count++
+	pe.last = now
+}
+
+func (self *EventReporter) Start() {
+	self.stopCh = make(chan struct{})
+	self.doneCh = make(chan struct{})
+	go func() {
+		defer close(self.doneCh)
+		ticker := time.NewTicker(eventFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-self.stopCh:
+				return
+			case now := <-ticker.C:
+				self.Flush(now)
+			}
+		}
+	}()
+}
+
+// Stop stops the background writes, and writes all pending Events regardless of the aggregation interval
+func (self *EventReporter) Stop() {
+	if self.stopCh == nil {
+		return
+	}
+	close(self.stopCh)
+	<-self.doneCh
+	self.stopCh = nil
+	self.flushAll(eventStopFlushTimeout)
+}
+
+// flushAll writes all pending Events while the rate limit allows them in the timeout; the rest are dropped
+func (self *EventReporter) flushAll(timeout time.Duration) {
+	self.mu.Lock()
+	pending := self.pending
+	limiter := self.limiter
+	self.pending = map[string]*pendingEvent{}
+	self.mu.Unlock()
+
+	if self.client == nil || len(pending) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	dropped := 0
+	for key, pe := range pending {
+		if err := limiter.Wait(ctx); err != nil {
+			dropped++
+			continue
+		}
+		if err := self.write(pe); err != nil {
+			logger.Error(fmt.Sprintf("Failed to write Event %s; %s", key, err.Error()))
+		}
+	}
+	if dropped > 0 {
+		logger.Warn(fmt.Sprintf("%d pending Events are dropped on stop; they could not be written in %s", dropped, timeout))
+	}
+}
+
+// Flush writes pending Events whose aggregation interval has passed, as long as the rate limit allows
+func (self *EventReporter) Flush(now time.Time) {
+	self.mu.Lock()
+	interval := time.Duration(self.conf.AggregationIntervalSeconds) * time.Second
+	ready := map[string]*pendingEvent{}
+	for key, pe := range self.pending {
+		if last, ok := self.lastWritten[key]; ok && now.Sub(last) < interval {
+			continue
+		}
+		if !self.limiter.TryAccept() {
+			break
+		}
+		ready[key] = pe
+		delete(self.pending, key)
+		self.lastWritten[key] = now
+	}
+	for key, last := range self.lastWritten {
+		if _, ok := self.pending[key]; !ok && now.Sub(last) >= interval {
+			delete(self.lastWritten, key)
+		}
+	}
+	self.mu.Unlock()
+
+	if self.client == nil {
+		return
+	}
+	for key, pe := range ready {
+		if err := self.write(pe); err != nil {
+			logger.Error(fmt.Sprintf("Failed to write Event %s; %s", key, err.Error()))
+		}
+	}
+}
+
+// write creates the Event or adds the count to the existing one, which may be written by other replicas
+func (self *EventReporter) write(pe *pendingEvent) error {
+	namespace := pe.evt.GetNamespace()
+	name := pe.evt.GetName()
+	events := self.client.Events(namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := events.Get(context.Background(), name, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			evt := pe.evt.DeepCopy()
+			evt.Count = pe.count
+			evt.FirstTimestamp = metav1.NewTime(pe.first)
+			evt.LastTimestamp = metav1.NewTime(pe.last)
+			evt.EventTime = metav1.NewMicroTime(pe.last)
+			_, err = events.Create(context.Background(), evt, metav1.CreateOptions{})
+			if k8serrors.IsAlreadyExists(err) {
+				// created by another replica; retry as an update
+				return k8serrors.NewConflict(v1.Resource("events"), name, err)
+			}
+			return err
+		} else if err != nil {
+			return err
+		}
+		current.Message = pe.evt.Message
+		current.Reason = pe.evt.Reason
+		current.Annotations = pe.evt.Annotations
+		current.Count = current.Count + pe.count
+		if current.FirstTimestamp.IsZero() || pe.first.Before(current.FirstTimestamp.Time) {
+			current.FirstTimestamp = metav1.NewTime(pe.first)
+		}
+		if pe.last.After(current.LastTimestamp.Time) {
+			current.LastTimestamp = metav1.NewTime(pe.last)
+			current.EventTime = metav1.NewMicroTime(pe.last)
+		}
+		_, err = events.Update(context.Background(), current, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+func eventKey(namespace, name string) string {
+	return fmt.Sprintf("%s/%s", namespace, name)
+}
@@ -0,0 +1,114 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	config "github.com/IBM/integrity-enforcer/shield/pkg/shield/config"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestEvent(name, message string) *v1.Event {
+	return &v1.Event{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: name}, Message: message, Reason: "no-signature"}
+}
+
+func TestEventReporter(t *testing.T) {
+	start := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	// an Event written by another replica
+	existing := newTestEvent("ishield-deny-configmap-cm2-no-signature", "old")
+	existing.Count = 3
+	existing.FirstTimestamp = metav1.NewTime(start.Add(-time.Hour))
+	client := k8sfake.NewSimpleClientset(existing).CoreV1()
+
+	reporter := NewEventReporter(client)
+	reporter.SetConfig(config.EventReportConfig{AggregationIntervalSeconds: 10, QPS: 100, Burst: 100})
+	for i := 0; i < 5; i++ {
+		reporter.Report(newTestEvent("ishield-deny-configmap-cm1-no-signature", fmt.Sprintf("request %d", i)), start.Add(time.Duration(i)*time.Second))
+	}
+	reporter.Report(newTestEvent("ishield-deny-configmap-cm2-no-signature", "new"), start)
+	reporter.Flush(start.Add(5 * time.Second))
+
+	evt, err := client.Events("test-ns").Get(context.Background(), "ishield-deny-configmap-cm1-no-signature", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evt.Count != 5 || evt.Message != "request 4" || !evt.FirstTimestamp.Time.Equal(start) || !evt.LastTimestamp.Time.Equal(start.Add(4*time.Second)) {
+		t.Errorf("Failed to test EventReporter; requests must be aggregated into an Event: %d, %s, %v, %v", evt.Count, evt.Message, evt.FirstTimestamp, evt.LastTimestamp)
+	}
+	evt, _ = client.Events("test-ns").Get(context.Background(), "ishield-deny-configmap-cm2-no-signature", metav1.GetOptions{})
+	if evt.Count != 4 || evt.Message != "new" || !evt.FirstTimestamp.Time.Equal(start.Add(-time.Hour)) {
+		t.Errorf("Failed to test EventReporter; the count must be added to the existing Event: %d, %s, %v", evt.Count, evt.Message, evt.FirstTimestamp)
+	}
+
+	// the Event is not updated again in the aggregation interval
+	reporter.Report(newTestEvent("ishield-deny-configmap-cm1-no-signature", "request 5"), start.Add(6*time.Second))
+	reporter.Flush(start.Add(8 * time.Second))
+	evt, _ = client.Events("test-ns").Get(context.Background(), "ishield-deny-configmap-cm1-no-signature", metav1.GetOptions{})
+	if evt.Count != 5 {
+		t.Errorf("Failed to test EventReporter; the Event must not be updated in the aggregation interval: %d", evt.Count)
+	}
+	reporter.Flush(start.Add(15 * time.Second))
+	evt, _ = client.Events("test-ns").Get(context.Background(), "ishield-deny-configmap-cm1-no-signature", metav1.GetOptions{})
+	if evt.Count != 6 {
+		t.Errorf("Failed to test EventReporter; the Event must be updated after the aggregation interval: %d", evt.Count)
+	}
+}
+
+func TestEventReporterRateLimit(t *testing.T) {
+	now := time.Now()
+	client := k8sfake.NewSimpleClientset().CoreV1()
+	reporter := NewEventReporter(client)
+	reporter.SetConfig(config.EventReportConfig{AggregationIntervalSeconds: 10, QPS: 1, Burst: 1})
+	for i := 0; i < 3; i++ {
+		reporter.Report(newTestEvent(fmt.Sprintf("ishield-deny-configmap-cm%d-no-signature", i), ""), now)
+	}
+	reporter.Flush(now)
+	list, _ := client.Events("test-ns").List(context.Background(), metav1.ListOptions{})
+	if len(list.Items) != 1 || len(reporter.pending) != 2 {
+		t.Errorf("Failed to test EventReporter; Events over the rate limit must be kept pending: %d written, %d pending", len(list.Items), len(reporter.pending))
+	}
+}
+
+func TestEventReporterStop(t *testing.T) {
+	client := k8sfake.NewSimpleClientset().CoreV1()
+	reporter := NewEventReporter(client)
+	reporter.SetConfig(config.EventReportConfig{AggregationIntervalSeconds: 60, QPS: 100, Burst: 100})
+	reporter.Start()
+	now := time.Now()
+	reporter.Report(newTestEvent("ishield-deny-configmap-cm1-no-signature", "first"), now)
+	// the second Event is in the aggregation interval of the first one, and is written on stop
+	time.Sleep(1500 * time.Millisecond)
+	reporter.Report(newTestEvent("ishield-deny-configmap-cm1-no-signature", "second"), now.Add(time.Second))
+	reporter.Stop()
+
+	evt, err := client.Events("test-ns").Get(context.Background(), "ishield-deny-configmap-cm1-no-signature", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evt.Count != 2 || evt.Message != "second" {
+		t.Errorf("Failed to test EventReporter; pending Events must be written on stop: %d, %s", evt.Count, evt.Message)
+	}
+	if len(reporter.pending) != 0 {
+		t.Errorf("Failed to test EventReporter; pending Events must be cleared on stop")
+	}
+}
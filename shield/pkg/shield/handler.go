@@ -21,6 +21,7 @@ import (
 	"fmt"
 
 	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	auditsink "github.com/IBM/integrity-enforcer/shield/pkg/util/auditsink"
 	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
 	log "github.com/sirupsen/logrus"
 
@@ -44,12 +45,27 @@ type Handler struct {
 	requestLog    *log.Entry
 	contextLogger *logger.ContextLogger
 	logInScope    bool
+	// profiles which protect the requested resource
+	matchedProfiles []rspapi.ResourceSigningProfile
+	// results of the profiles checked for the request, which are recorded in their status
+	profileEvents []*profileEvent
+
+	// clients are used instead of the ones for the cluster if set
+	clients *LoaderClients
+	// offline handler does not report results to the cluster nor write context logs
+	offline bool
 }
 
 func NewHandler(config *config.ShieldConfig, metaLogger *log.Logger, reqLog *log.Entry) *Handler {
 	return &Handler{config: config, data: &RunData{}, serverLogger: metaLogger, requestLog: reqLog}
 }
 
+// NewOfflineHandler returns a handler which loads resources with the given clients (e.g. fake clientsets),
+// and does not create Events, update RSP status nor write context logs.
+func NewOfflineHandler(config *config.ShieldConfig, clients *LoaderClients, metaLogger *log.Logger, reqLog *log.Entry) *Handler {
+	return &Handler{config: config, data: &RunData{}, serverLogger: metaLogger, requestLog: reqLog, clients: clients, offline: true}
+}
+
 func (self *Handler) Run(req *admv1.AdmissionRequest) *admv1.AdmissionResponse {
 
 	// init ctx, reqc and data & init logger
@@ -74,10 +90,12 @@ func (self *Handler) Run(req *admv1.AdmissionRequest) *admv1.AdmissionResponse {
 
 	// log results
 	self.logResponse(req, resp)
-	self.logContext()
+	if !self.offline {
+		self.logContext()
 
-	// create Event & update RSP status
-	_ = self.Report(dr.denyRSP)
+		// create Event & update RSP status
+		_ = self.Report(dr.denyRSP)
+	}
 
 	// clear some cache if needed
 	self.finalize(resp)
@@ -116,9 +134,11 @@ func (self *Handler) Check() *DecisionResult {
 	if !dr.isUndetermined() {
 		return dr
 	}
+	self.matchedProfiles = matchedProfiles
 
 	for _, prof := range matchedProfiles {
 		dr = resourceSigningProfileCheck(prof, self.reqc, self.config, self.data, self.ctx)
+		self.profileEvents = append(self.profileEvents, newProfileEvent(prof, dr, self.reqc, self.config, self.ctx))
 		if dr.isAllowed() {
 			// this RSP allowed the request. will check next RSP.
 		} else {
@@ -138,6 +158,9 @@ func (self *Handler) Check() *DecisionResult {
 }
 
 func (self *Handler) Report(denyRSP *rspapi.ResourceSigningProfile) error {
+	// status of profiles is updated in background for all protected requests
+	self.recordProfileStatus()
+
 	// report only for denying request or for IShield resource request by IShield Admin
 	shouldReport := false
 	if !self.ctx.Allow {
@@ -152,23 +175,27 @@ func (self *Handler) Report(denyRSP *rspapi.ResourceSigningProfile) error {
 		return nil
 	}
 
-	var err error
 	// create/update Event
-	err = createOrUpdateEvent(self.reqc, self.ctx, self.config, denyRSP)
-	if err != nil {
-		self.requestLog.Error("Failed to create event; ", err)
-		return err
-	}
-
-	// update RSP status
-	err = updateRSPStatus(denyRSP, self.reqc, self.ctx.Message)
-	if err != nil {
-		self.requestLog.Error("Failed to update status; ", err)
-	}
+	reportEvent(self.reqc, self.ctx, self.config, denyRSP)
 
 	return nil
 }
 
+func (self *Handler) recordProfileStatus() {
+	if len(self.profileEvents) == 0 {
+		return
+	}
+	updater := getRSPStatusUpdater()
+	updater.SetConfig(self.config.ProfileStatusConfig())
+	for _, pe := range self.profileEvents {
+		if !pe.event.Allowed {
+			// the request denied by the profile is allowed in detect mode or by break glass
+			pe.event.Allowed = self.ctx.Allow
+		}
+		updater.Add(pe.namespace, pe.name, pe.event)
+	}
+}
+
 // load resoruces / set default values
 func (self *Handler) initialize(req *admv1.AdmissionRequest) *DecisionResult {
 
@@ -182,7 +209,12 @@ func (self *Handler) initialize(req *admv1.AdmissionRequest) *DecisionResult {
 	// Note: logEntry() calls ShieldConfig.ConsoleLogEnabled() internally, and this requires ReqContext.
 	self.logEntry()
 
-	runDataLoader := NewLoader(self.config, reqNamespace)
+	var runDataLoader *Loader
+	if self.clients != nil {
+		runDataLoader = NewLoaderWithClients(self.config, reqNamespace, self.clients)
+	} else {
+		runDataLoader = NewLoader(self.config, reqNamespace)
+	}
 	self.data.loader = runDataLoader
 	self.data.Init(self.reqc, self.config)
 
@@ -249,9 +281,9 @@ func (self *Handler) finalize(resp *admv1.AdmissionResponse) {
 
 func (self *Handler) logEntry() {
 	if ok, levelStr := self.config.ConsoleLogEnabled(self.reqc); ok {
-		logger.SetSingletonLoggerLevel(levelStr) // change singleton logger level; this might be overwritten by parallel handler instance
+		// set custom log level only for this request; the singleton logger is not changed because it is shared by parallel handler instances
 		lvl, _ := log.ParseLevel(levelStr)
-		self.serverLogger.SetLevel(lvl) // set custom log level for this request
+		self.serverLogger.SetLevel(lvl)
 		self.requestLog.Trace("New Admission Request Received")
 	}
 }
@@ -270,13 +302,15 @@ func (self *Handler) logContext() {
 		}
 		if self.reqc.ResourceScope == "Namespaced" || (self.reqc.ResourceScope == "Cluster" && self.ctx.Protected) {
 			self.contextLogger.SendLog(logBytes)
+			// the same records are sent to audit sinks; sinks are recreated only when the config is changed
+			auditsink.Update(self.config.Log.AuditSinks)
+			auditsink.Send(logBytes)
 		}
 	}
 }
 
 func (self *Handler) logExit() {
 	if ok, _ := self.config.ConsoleLogEnabled(self.reqc); ok {
-		logger.SetSingletonLoggerLevel(self.config.Log.LogLevel)
 		self.requestLog.WithFields(log.Fields{
 			"allowed":    self.ctx.Allow,
 			"aborted":    self.ctx.Aborted,
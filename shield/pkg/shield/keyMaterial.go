@@ -0,0 +1,304 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"crypto/sha256"
+	cx509 "crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	pgp "github.com/IBM/integrity-enforcer/shield/pkg/util/sign/pgp"
+	x509 "github.com/IBM/integrity-enforcer/shield/pkg/util/sign/x509"
+	"golang.org/x/crypto/openpgp"
+)
+
+/**********************************************
+
+				KeyMaterial
+
+***********************************************/
+
+// KeyMaterial is a parsed verification key (pgp keyring or x509 cert dir) mounted at Path
+type KeyMaterial struct {
+	Path       string
+	SignType   common.SignatureType
+	PGPKeyRing openpgp.EntityList
+	X509Certs  []*cx509.Certificate
+	Error      error
+	LoadedTime time.Time
+
+	digest string
+}
+
+func (self *KeyMaterial) KeyCount() int {
+	if self.SignType == common.SignatureTypePGP {
+		return len(self.PGPKeyRing)
+	} else if self.SignType == common.SignatureTypeX509 {
+		return len(self.X509Certs)
+	}
+	return 0
+}
+
+func (self *KeyMaterial) IsValid() bool {
+	return self.Error == nil && self.KeyCount() > 0
+}
+
+// Fingerprints returns pgp key fingerprints or sha256 digests of x509 certificates
+func (self *KeyMaterial) Fingerprints() []string {
+	fingerprints := []string{}
+	for _, ent := range self.PGPKeyRing {
+		if ent.PrimaryKey != nil {
+			fingerprints = append(fingerprints, fmt.Sprintf("%X", ent.PrimaryKey.Fingerprint))
+		}
+	}
+	for _, cert := range self.X509Certs {
+		fingerprints = append(fingerprints, fmt.Sprintf("%X", sha256.Sum256(cert.Raw)))
+	}
+	return fingerprints
+}
+
+func GetSignatureTypeFromKeyPath(keyPath string) common.SignatureType {
+	if strings.Contains(keyPath, fmt.Sprintf("/%s/", string(common.SignatureTypePGP))) {
+		return common.SignatureTypePGP
+	} else if strings.Contains(keyPath, fmt.Sprintf("/%s/", string(common.SignatureTypeX509))) {
+		return common.SignatureTypeX509
+	}
+	return common.SignatureTypeDefault
+}
+
+func loadKeyMaterial(keyPath, digest string) *KeyMaterial {
+	km := &KeyMaterial{
+		Path:       keyPath,
+		SignType:   GetSignatureTypeFromKeyPath(keyPath),
+		LoadedTime: time.Now().UTC(),
+		digest:     digest,
+	}
+	if km.SignType == common.SignatureTypePGP {
+		km.PGPKeyRing, km.Error = pgp.LoadKeyRing(keyPath)
+	} else if km.SignType == common.SignatureTypeX509 {
+		km.X509Certs, km.Error = x509.LoadCertDir(keyPath)
+	} else {
+		km.Error = fmt.Errorf("unknown signature type for key path \"%s\"", keyPath)
+	}
+	return km
+}
+
+// getKeyPathDigest returns a digest of the key file, or of all cert files in the case of a cert dir.
+// It is used to detect the update of the mounted secret without parsing keys.
+func getKeyPathDigest(keyPath string) string {
+	h := sha256.New()
+	if GetSignatureTypeFromKeyPath(keyPath) == common.SignatureTypeX509 {
+		files, err := ioutil.ReadDir(keyPath)
+		if err != nil {
+			return ""
+		}
+		names := []string{}
+		for _, f := range files {
+			if !f.IsDir() && (path.Ext(f.Name()) == ".crt" || path.Ext(f.Name()) == ".pem") {
+				names = append(names, f.Name())
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			data, err := ioutil.ReadFile(filepath.Clean(path.Join(keyPath, name)))
+			if err != nil {
+				return ""
+			}
+			_, _ = h.Write([]byte(name))
+			_, _ = h.Write(data)
+		}
+	} else {
+		data, err := ioutil.ReadFile(filepath.Clean(keyPath))
+		if err != nil {
+			return ""
+		}
+		_, _ = h.Write(data)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+/**********************************************
+
+				KeyMaterialManager
+
+***********************************************/
+
+// KeyMaterialManager parses verification keys once and reloads them only when the mounted files are changed
+type KeyMaterialManager struct {
+	interval time.Duration
+
+	mu          sync.RWMutex
+	keyPathList []string
+	keys        map[string]*KeyMaterial
+}
+
+var keyMaterialManager *KeyMaterialManager
+
+func SetKeyMaterialManager(manager *KeyMaterialManager) {
+	keyMaterialManager = manager
+}
+
+func GetKeyMaterialManager() *KeyMaterialManager {
+	return keyMaterialManager
+}
+
+func NewKeyMaterialManager(keyPathList []string, interval time.Duration) *KeyMaterialManager {
+	manager := &KeyMaterialManager{
+		interval: interval,
+		keys:     map[string]*KeyMaterial{},
+	}
+	manager.SetKeyPathList(keyPathList)
+	return manager
+}
+
+// SetKeyPathList replaces the target key paths; keys are reloaded only if the list is changed
+func (self *KeyMaterialManager) SetKeyPathList(keyPathList []string) {
+	self.mu.Lock()
+	changed := !reflect.DeepEqual(self.keyPathList, keyPathList)
+	if changed {
+		self.keyPathList = append([]string{}, keyPathList...)
+	}
+	self.mu.Unlock()
+	if changed {
+		self.Reload()
+	}
+}
+
+// Reload re-parses keys whose files are changed since the last load, and returns true if any key is reloaded
+func (self *KeyMaterialManager) Reload() bool {
+	self.mu.RLock()
+	keyPathList := append([]string{}, self.keyPathList...)
+	current := self.keys
+	self.mu.RUnlock()
+
+	updated := false
+	keys := map[string]*KeyMaterial{}
+	for _, keyPath := range keyPathList {
+		digest := getKeyPathDigest(keyPath)
+		// a missing file keeps the empty digest, so it is not reloaded on every tick until the file is mounted
+		if km, ok := current[keyPath]; ok && km.digest == digest {
+			keys[keyPath] = km
+			continue
+		}
+		km := loadKeyMaterial(keyPath, digest)
+		if km.IsValid() {
+			logger.Info(fmt.Sprintf("Verification key is loaded from \"%s\" (%d keys)", keyPath, km.KeyCount()))
+		} else {
+			logger.Warn(fmt.Sprintf("Failed to load verification key from \"%s\"; %v", keyPath, km.Error))
+		}
+		keys[keyPath] = km
+		updated = true
+	}
+	if len(keys) != len(current) {
+		updated = true
+	}
+
+	self.mu.Lock()
+	self.keys = keys
+	self.mu.Unlock()
+	return updated
+}
+
+// Run reloads keys periodically until stopCh is closed
+func (self *KeyMaterialManager) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(self.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			if self.Reload() {
+				logger.Info("Verification keys have been reloaded.")
+			}
+		}
+	}
+}
+
+// Get returns the loaded key material, or nil if the key path is not managed
+func (self *KeyMaterialManager) Get(keyPath string) *KeyMaterial {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	km, ok := self.keys[keyPath]
+	if !ok {
+		return nil
+	}
+	return km
+}
+
+// List returns all managed key materials in the order of the key path list
+func (self *KeyMaterialManager) List() []*KeyMaterial {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	list := []*KeyMaterial{}
+	for _, keyPath := range self.keyPathList {
+		if km, ok := self.keys[keyPath]; ok {
+			list = append(list, km)
+		}
+	}
+	return list
+}
+
+func getManagedKeyMaterial(keyPath string) *KeyMaterial {
+	if keyMaterialManager == nil {
+		return nil
+	}
+	return keyMaterialManager.Get(keyPath)
+}
+
+// loadPGPKeyRing returns the keyring from KeyMaterialManager, or reads the file if the path is not managed
+func loadPGPKeyRing(keyPath string) (openpgp.EntityList, error) {
+	if km := getManagedKeyMaterial(keyPath); km != nil {
+		return km.PGPKeyRing, km.Error
+	}
+	return pgp.LoadKeyRing(keyPath)
+}
+
+// loadX509CertDir returns the certs from KeyMaterialManager, or reads the dir if the path is not managed
+func loadX509CertDir(certDir string) ([]*cx509.Certificate, error) {
+	if km := getManagedKeyMaterial(certDir); km != nil {
+		return km.X509Certs, km.Error
+	}
+	return x509.LoadCertDir(certDir)
+}
+
+func verifyPGPSignature(keyPath, msg, sig string) (bool, string, *pgp.Signer, []byte, error) {
+	keyRing, err := loadPGPKeyRing(keyPath)
+	if err != nil && msg != "" && sig != "" {
+		return false, "Error when loading key ring", nil, nil, err
+	}
+	return pgp.VerifySignatureWithKeyRing(keyRing, msg, sig)
+}
+
+func verifyX509Certificate(certPemBytes []byte, caCertPath string) (bool, string, error) {
+	poolCerts, err := loadX509CertDir(caCertPath)
+	if err != nil {
+		reasonFail := fmt.Sprintf("failed to load certificate pool: %s", err.Error())
+		return false, reasonFail, fmt.Errorf(reasonFail)
+	}
+	return x509.VerifyCertificateWithPool(certPemBytes, poolCerts)
+}
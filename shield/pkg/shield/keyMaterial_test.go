@@ -0,0 +1,126 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const (
+	testPGPPubring    = "testdata/sample-signer-keyconfig/pgp/pubring"
+	testPGPPubringNew = "testdata/sample-signer-keyconfig/pgp/pubring-new"
+	testX509CACert    = "testdata/sample-signer-keyconfig/x509/ca.crt"
+)
+
+func copyTestFile(t *testing.T, src, dst string) {
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(dst, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeyMaterialManager(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "ishield-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	pgpKeyPath := filepath.Join(tmpDir, "keyring", "pgp", "pubring.gpg")
+	x509KeyPath := filepath.Join(tmpDir, "cert", "x509") + "/"
+	copyTestFile(t, testPGPPubring, pgpKeyPath)
+	copyTestFile(t, testX509CACert, filepath.Join(x509KeyPath, "ca.crt"))
+
+	manager := NewKeyMaterialManager([]string{pgpKeyPath, x509KeyPath}, time.Second)
+	keyList := manager.List()
+	if len(keyList) != 2 {
+		t.Fatalf("expected 2 key materials, actual: %d", len(keyList))
+	}
+	for _, km := range keyList {
+		if !km.IsValid() {
+			t.Errorf("key material for %s should be valid; %v", km.Path, km.Error)
+		}
+		if len(km.Fingerprints()) != km.KeyCount() {
+			t.Errorf("fingerprints for %s should be listed for each key", km.Path)
+		}
+	}
+
+	// no reload happens without any change
+	orgKeyRing := manager.Get(pgpKeyPath).PGPKeyRing
+	if manager.Reload() {
+		t.Errorf("Reload() should return false when key files are not changed")
+	}
+
+	// key is reloaded after the mounted file is updated
+	copyTestFile(t, testPGPPubringNew, pgpKeyPath)
+	if !manager.Reload() {
+		t.Errorf("Reload() should return true when key file is changed")
+	}
+	newKeyRing := manager.Get(pgpKeyPath).PGPKeyRing
+	if reflect.DeepEqual(manager.Get(pgpKeyPath).Fingerprints(), (&KeyMaterial{PGPKeyRing: orgKeyRing}).Fingerprints()) {
+		t.Errorf("keyring should be replaced with the new one")
+	}
+
+	// managed keyring is used for verification instead of reading the file
+	SetKeyMaterialManager(manager)
+	defer SetKeyMaterialManager(nil)
+	loaded, err := loadPGPKeyRing(pgpKeyPath)
+	if err != nil || len(loaded) != len(newKeyRing) {
+		t.Errorf("loadPGPKeyRing() should return the managed keyring; %v", err)
+	}
+
+	// broken key is reported as invalid
+	if err := ioutil.WriteFile(pgpKeyPath, []byte("invalid"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manager.Reload()
+	if manager.Get(pgpKeyPath).IsValid() {
+		t.Errorf("key material should be invalid after the key file is broken")
+	}
+
+	// missing key file is reported as invalid, and is not reloaded again until the file is mounted
+	if err := os.Remove(pgpKeyPath); err != nil {
+		t.Fatal(err)
+	}
+	if !manager.Reload() || manager.Get(pgpKeyPath).IsValid() {
+		t.Errorf("key material should be invalid after the key file is removed")
+	}
+	if manager.Reload() {
+		t.Errorf("Reload() should return false while the key file is missing")
+	}
+	copyTestFile(t, testPGPPubring, pgpKeyPath)
+	if !manager.Reload() || !manager.Get(pgpKeyPath).IsValid() {
+		t.Errorf("key should be reloaded after the key file is mounted again")
+	}
+
+	// key path list update
+	manager.SetKeyPathList([]string{x509KeyPath})
+	if manager.Get(pgpKeyPath) != nil {
+		t.Errorf("key material for removed key path should not be returned")
+	}
+}
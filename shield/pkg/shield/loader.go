@@ -17,7 +17,13 @@
 package shield
 
 import (
+	rsigclient "github.com/IBM/integrity-enforcer/shield/pkg/client/resourcesignature/clientset/versioned/typed/resourcesignature/v1alpha1"
+	rspclient "github.com/IBM/integrity-enforcer/shield/pkg/client/resourcesigningprofile/clientset/versioned/typed/resourcesigningprofile/v1alpha1"
+	sigconfclient "github.com/IBM/integrity-enforcer/shield/pkg/client/signerconfig/clientset/versioned/typed/signerconfig/v1alpha1"
 	config "github.com/IBM/integrity-enforcer/shield/pkg/shield/config"
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
+	"k8s.io/client-go/dynamic"
+	v1client "k8s.io/client-go/kubernetes/typed/core/v1"
 )
 
 /**********************************************
@@ -31,6 +37,7 @@ type Loader struct {
 	RSP               *RSPLoader
 	Namespace         *NamespaceLoader
 	ResourceSignature *ResSigLoader
+	Owner             *OwnerLoader
 }
 
 func NewLoader(cfg *config.ShieldConfig, reqNamespace string) *Loader {
@@ -43,6 +50,54 @@ func NewLoader(cfg *config.ShieldConfig, reqNamespace string) *Loader {
 		RSP:               NewRSPLoader(shieldNamespace, profileNamespace, requestNamespace, cfg.CommonProfile),
 		Namespace:         NewNamespaceLoader(),
 		ResourceSignature: NewResSigLoader(signatureNamespace, requestNamespace),
+		Owner:             NewOwnerLoader(),
+	}
+	return loader
+}
+
+// LoaderClients is a set of clients used by Loader instead of the ones for the cluster
+// (e.g. fake clientsets for evaluating recorded requests offline)
+type LoaderClients struct {
+	SignerConfig      sigconfclient.ApisV1alpha1Interface
+	RSP               rspclient.ApisV1alpha1Interface
+	Namespace         v1client.CoreV1Interface
+	ResourceSignature rsigclient.ApisV1alpha1Interface
+	// used to get owners of requested resources; owners are not trusted if nil
+	Dynamic dynamic.Interface
+}
+
+// NewLoaderClients returns clients for the cluster, which can be shared by loaders of many requests
+func NewLoaderClients() *LoaderClients {
+	clients := &LoaderClients{}
+	config, _ := kubeutil.GetKubeConfig()
+	if config == nil {
+		return clients
+	}
+	if c, err := sigconfclient.NewForConfig(config); err == nil {
+		clients.SignerConfig = c
+	}
+	if c, err := rspclient.NewForConfig(config); err == nil {
+		clients.RSP = c
+	}
+	if c, err := v1client.NewForConfig(config); err == nil {
+		clients.Namespace = c
+	}
+	if c, err := rsigclient.NewForConfig(config); err == nil {
+		clients.ResourceSignature = c
+	}
+	if c, err := dynamic.NewForConfig(config); err == nil {
+		clients.Dynamic = c
+	}
+	return clients
+}
+
+func NewLoaderWithClients(cfg *config.ShieldConfig, reqNamespace string, clients *LoaderClients) *Loader {
+	loader := &Loader{
+		SignerConfig:      newSignerConfigLoader(cfg.Namespace, clients.SignerConfig),
+		RSP:               newRSPLoader(cfg.Namespace, cfg.ProfileNamespace, reqNamespace, cfg.CommonProfile, clients.RSP),
+		Namespace:         newNamespaceLoader(clients.Namespace),
+		ResourceSignature: newResSigLoader(cfg.SignatureNamespace, reqNamespace, clients.ResourceSignature),
+		Owner:             newOwnerLoader(clients.Dynamic),
 	}
 	return loader
 }
@@ -34,15 +34,21 @@ import (
 
 type NamespaceLoader struct {
 	interval time.Duration
-	Client   *v1client.CoreV1Client
+	Client   v1client.CoreV1Interface
 	Data     []v1.Namespace
 }
 
 func NewNamespaceLoader() *NamespaceLoader {
-	interval := time.Second * 30
 	config, _ := kubeutil.GetKubeConfig()
-	client, _ := v1client.NewForConfig(config)
+	var client v1client.CoreV1Interface
+	if c, err := v1client.NewForConfig(config); err == nil {
+		client = c
+	}
+	return newNamespaceLoader(client)
+}
 
+func newNamespaceLoader(client v1client.CoreV1Interface) *NamespaceLoader {
+	interval := time.Second * 30
 	return &NamespaceLoader{
 		interval: interval,
 		Client:   client,
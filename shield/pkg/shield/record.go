@@ -0,0 +1,295 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	config "github.com/IBM/integrity-enforcer/shield/pkg/shield/config"
+	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	ishieldyaml "github.com/IBM/integrity-enforcer/shield/pkg/util/yaml"
+	admv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const redactedValue = "REDACTED"
+
+// annotations of Secret which may contain the Secret data
+var secretContentAnnotationKeys = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	common.MessageAnnotationKey,
+}
+
+/**********************************************
+
+				AdmissionRecord
+
+***********************************************/
+
+// AdmissionRecord is a sanitized admission request with the config and the data used for its decision.
+// It can be evaluated again offline by Replayer.
+type AdmissionRecord struct {
+	Timestamp time.Time              `json:"timestamp"`
+	Review    *admv1.AdmissionReview `json:"review"`
+	Config    *config.ShieldConfig   `json:"config"`
+	Data      *RunData               `json:"data"`
+	Decision  *RecordedDecision      `json:"decision"`
+	// true if some values in the request are redacted; the decision may differ on replay (e.g. signature of Secret)
+	Sanitized bool `json:"sanitized,omitempty"`
+}
+
+type RecordedDecision struct {
+	Allowed    bool   `json:"allowed"`
+	Verified   bool   `json:"verified"`
+	ReasonCode int    `json:"reasonCode"`
+	Reason     string `json:"reason"`
+	Message    string `json:"message"`
+}
+
+func newRecordedDecision(ctx *CheckContext, resp *admv1.AdmissionResponse) *RecordedDecision {
+	return &RecordedDecision{
+		Allowed:    resp.Allowed,
+		Verified:   ctx.Verified,
+		ReasonCode: ctx.ReasonCode,
+		Reason:     common.ReasonCodeMap[ctx.ReasonCode].Code,
+		Message:    ctx.Message,
+	}
+}
+
+// Equal compares the decision without message, because it may contain request specific values
+func (self *RecordedDecision) Equal(d *RecordedDecision) bool {
+	if self == nil || d == nil {
+		return self == d
+	}
+	return self.Allowed == d.Allowed && self.Verified == d.Verified && self.ReasonCode == d.ReasonCode
+}
+
+// Record returns a sanitized record of the request processed by Run(), or nil if the request is out of scope
+func (self *Handler) Record(req *admv1.AdmissionRequest, resp *admv1.AdmissionResponse) *AdmissionRecord {
+	if !self.logInScope || self.reqc == nil || self.ctx == nil {
+		return nil
+	}
+	sanitizedReq, sanitized, err := sanitizeAdmissionRequest(req)
+	if err != nil {
+		self.requestLog.Warn("Failed to sanitize admission request for recording; ", err.Error())
+		return nil
+	}
+	data, dataSanitized := sanitizeRunData(self.data)
+	return &AdmissionRecord{
+		Timestamp: time.Now().UTC(),
+		Review: &admv1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: admv1.SchemeGroupVersion.String(), Kind: "AdmissionReview"},
+			Request:  sanitizedReq,
+		},
+		Config:    self.config,
+		Data:      data,
+		Decision:  newRecordedDecision(self.ctx, resp),
+		Sanitized: sanitized || dataSanitized,
+	}
+}
+
+// sanitizeAdmissionRequest returns a copy of the request without secret values and user extra info
+func sanitizeAdmissionRequest(req *admv1.AdmissionRequest) (*admv1.AdmissionRequest, bool, error) {
+	var newReq *admv1.AdmissionRequest
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, false, err
+	}
+	if err = json.Unmarshal(reqBytes, &newReq); err != nil {
+		return nil, false, err
+	}
+	sanitized := false
+	if len(newReq.UserInfo.Extra) > 0 {
+		newReq.UserInfo.Extra = nil
+		sanitized = true
+	}
+	if newReq.Kind.Group == "" && newReq.Kind.Kind == "Secret" {
+		for _, raw := range []*[]byte{&newReq.Object.Raw, &newReq.OldObject.Raw} {
+			redacted, err := redactSecretData(*raw)
+			if err != nil {
+				return nil, false, err
+			}
+			*raw = redacted
+		}
+		newReq.Object.Object = nil
+		newReq.OldObject.Object = nil
+		sanitized = true
+	}
+	return newReq, sanitized, nil
+}
+
+func redactSecretData(raw []byte) ([]byte, error) {
+	if len(raw) == 0 {
+		return raw, nil
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+	for _, field := range []string{"data", "stringData"} {
+		values, ok := obj[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k := range values {
+			values[k] = redactedValue
+		}
+	}
+	if metadata, ok := obj["metadata"].(map[string]interface{}); ok {
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			for _, key := range secretContentAnnotationKeys {
+				if _, ok := annotations[key]; ok {
+					annotations[key] = redactedValue
+				}
+			}
+		}
+	}
+	return json.Marshal(obj)
+}
+
+// sanitizeRunData returns a copy of the data whose signed messages of Secrets are redacted
+func sanitizeRunData(data *RunData) (*RunData, bool) {
+	if data == nil || data.ResSigList == nil {
+		return data, false
+	}
+	sanitized := false
+	resSigList := data.ResSigList.DeepCopy()
+	for _, rsig := range resSigList.Items {
+		if rsig == nil {
+			continue
+		}
+		for _, si := range rsig.Spec.Data {
+			if si != nil && messageContainsSecret(si.Message) {
+				si.Message = redactedValue
+				sanitized = true
+			}
+		}
+	}
+	if !sanitized {
+		return data, false
+	}
+	newData := *data
+	newData.ResSigList = resSigList
+	return &newData, true
+}
+
+func messageContainsSecret(message string) bool {
+	if message == "" {
+		return false
+	}
+	for _, ri := range ishieldyaml.ParseMessage([]byte(message)) {
+		if ri.ApiVersion == "v1" && ri.Kind == "Secret" {
+			return true
+		}
+	}
+	return false
+}
+
+/**********************************************
+
+				AdmissionRecorder
+
+***********************************************/
+
+// AdmissionRecorder appends admission records to a file as JSON lines.
+// Recording is stopped when the file size reaches maxBytes (0 means no limit).
+type AdmissionRecorder struct {
+	path     string
+	maxBytes int64
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	stopped bool
+}
+
+func NewAdmissionRecorder(path string, maxBytes int64) (*AdmissionRecorder, error) {
+	file, err := os.OpenFile(filepath.Clean(path), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open record file; %s", err.Error())
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to stat record file; %s", err.Error())
+	}
+	return &AdmissionRecorder{path: path, maxBytes: maxBytes, file: file, size: info.Size()}, nil
+}
+
+func (self *AdmissionRecorder) Write(record *AdmissionRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.file == nil || self.stopped {
+		return nil
+	}
+	if self.maxBytes > 0 && self.size+int64(len(line)) > self.maxBytes {
+		self.stopped = true
+		logger.Warn(fmt.Sprintf("Record file \"%s\" reached the size limit (%d bytes); admission requests are no longer recorded", self.path, self.maxBytes))
+		return nil
+	}
+	n, err := self.file.Write(line)
+	self.size += int64(n)
+	return err
+}
+
+func (self *AdmissionRecorder) Close() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.file == nil {
+		return nil
+	}
+	err := self.file.Close()
+	self.file = nil
+	return err
+}
+
+// LoadAdmissionRecords reads all records written by AdmissionRecorder
+func LoadAdmissionRecords(path string) ([]*AdmissionRecord, error) {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records := []*AdmissionRecord{}
+	decoder := json.NewDecoder(file)
+	for {
+		var record *AdmissionRecord
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode record #%d; %s", len(records)+1, err.Error())
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
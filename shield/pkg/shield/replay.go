@@ -0,0 +1,165 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	rsigfake "github.com/IBM/integrity-enforcer/shield/pkg/client/resourcesignature/clientset/versioned/fake"
+	rspfake "github.com/IBM/integrity-enforcer/shield/pkg/client/resourcesigningprofile/clientset/versioned/fake"
+	sigconffake "github.com/IBM/integrity-enforcer/shield/pkg/client/signerconfig/clientset/versioned/fake"
+	config "github.com/IBM/integrity-enforcer/shield/pkg/shield/config"
+	cache "github.com/IBM/integrity-enforcer/shield/pkg/util/cache"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+/**********************************************
+
+				Replayer
+
+***********************************************/
+
+// Replayer evaluates recorded admission requests offline with fake clientsets, and reports decision differences.
+// Note that the replay does not access the cluster, so verification which requires DryRun may differ from the record.
+// Owners followed by the verified owner check are served from the recorded metadata.
+type Replayer struct {
+	// Config is used instead of the recorded ShieldConfig if set
+	Config *config.ShieldConfig
+	// Profiles replace the recorded ResourceSigningProfiles which have the same namespace and name, or are added to them
+	Profiles []rspapi.ResourceSigningProfile
+}
+
+type ReplayResult struct {
+	RequestUID string            `json:"requestUID"`
+	Operation  string            `json:"operation"`
+	Kind       string            `json:"kind"`
+	Namespace  string            `json:"namespace"`
+	Name       string            `json:"name"`
+	Recorded   *RecordedDecision `json:"recorded"`
+	Replayed   *RecordedDecision `json:"replayed"`
+	Changed    bool              `json:"changed"`
+	Sanitized  bool              `json:"sanitized,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// Replay evaluates a record again; the loader cache is cleared so that only the recorded data is used
+func (self *Replayer) Replay(record *AdmissionRecord) (*ReplayResult, error) {
+	if record.Review == nil || record.Review.Request == nil {
+		return nil, fmt.Errorf("record does not contain an admission request")
+	}
+	req := record.Review.Request
+	conf := record.Config
+	if self.Config != nil {
+		conf = self.Config
+	}
+	if conf == nil {
+		return nil, fmt.Errorf("record does not contain ShieldConfig")
+	}
+	data := record.Data
+	if data == nil {
+		data = &RunData{}
+	}
+
+	cache.Clear()
+	clients := self.newFakeClients(data)
+	metaLogger := log.New()
+	metaLogger.SetOutput(ioutil.Discard)
+	reqLog := metaLogger.WithField("requestUID", string(req.UID))
+	handler := NewOfflineHandler(conf, clients, metaLogger, reqLog)
+	resp := handler.Run(req)
+
+	replayed := newRecordedDecision(handler.ctx, resp)
+	result := &ReplayResult{
+		RequestUID: string(req.UID),
+		Operation:  string(req.Operation),
+		Kind:       req.Kind.Kind,
+		Namespace:  req.Namespace,
+		Name:       req.Name,
+		Recorded:   record.Decision,
+		Replayed:   replayed,
+		Changed:    !record.Decision.Equal(replayed),
+		Sanitized:  record.Sanitized,
+	}
+	return result, nil
+}
+
+// ReplayAll evaluates all records; a record which cannot be replayed is reported with its error
+func (self *Replayer) ReplayAll(records []*AdmissionRecord) []*ReplayResult {
+	results := []*ReplayResult{}
+	for i, record := range records {
+		result, err := self.Replay(record)
+		if err != nil {
+			result = &ReplayResult{Error: fmt.Sprintf("record #%d: %s", i+1, err.Error())}
+			if record.Review != nil && record.Review.Request != nil {
+				result.RequestUID = string(record.Review.Request.UID)
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func (self *Replayer) newFakeClients(data *RunData) *LoaderClients {
+	rspObjs := []runtime.Object{}
+	for _, rsp := range mergeProfiles(data.RSPList, self.Profiles) {
+		rspObjs = append(rspObjs, rsp.DeepCopy())
+	}
+	nsObjs := []runtime.Object{}
+	for _, ns := range data.NSList {
+		nsObjs = append(nsObjs, ns.DeepCopy())
+	}
+	sigConfObjs := []runtime.Object{}
+	if data.SignerConfig != nil && data.SignerConfig.GetName() != "" {
+		sigConfObjs = append(sigConfObjs, data.SignerConfig.DeepCopy())
+	}
+	resSigObjs := []runtime.Object{}
+	if data.ResSigList != nil {
+		for _, rsig := range data.ResSigList.Items {
+			resSigObjs = append(resSigObjs, rsig.DeepCopy())
+		}
+	}
+	ownerObjs := []runtime.Object{}
+	for _, owner := range data.Owners {
+		ownerObjs = append(ownerObjs, owner.DeepCopy())
+	}
+	return &LoaderClients{
+		SignerConfig:      sigconffake.NewSimpleClientset(sigConfObjs...).ApisV1alpha1(),
+		RSP:               rspfake.NewSimpleClientset(rspObjs...).ApisV1alpha1(),
+		Namespace:         k8sfake.NewSimpleClientset(nsObjs...).CoreV1(),
+		ResourceSignature: rsigfake.NewSimpleClientset(resSigObjs...).ApisV1alpha1(),
+		Dynamic:           dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), ownerObjs...),
+	}
+}
+
+func mergeProfiles(recorded, profiles []rspapi.ResourceSigningProfile) []rspapi.ResourceSigningProfile {
+	merged := []rspapi.ResourceSigningProfile{}
+	replaced := map[string]bool{}
+	for _, p := range profiles {
+		replaced[p.GetNamespace()+"/"+p.GetName()] = true
+	}
+	for _, p := range recorded {
+		if !replaced[p.GetNamespace()+"/"+p.GetName()] {
+			merged = append(merged, p)
+		}
+	}
+	return append(merged, profiles...)
+}
@@ -0,0 +1,210 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	rsigapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesignature/v1alpha1"
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	log "github.com/sirupsen/logrus"
+	admv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func getTestRecord(caseNum int) *AdmissionRecord {
+	reqc, cfg, data, _, _, _, expectedDr := getTestData(caseNum)
+	var req *admv1.AdmissionRequest
+	_ = json.Unmarshal([]byte(reqc.RequestJsonStr), &req)
+	return &AdmissionRecord{
+		Review: &admv1.AdmissionReview{Request: req},
+		Config: cfg,
+		Data:   data,
+		Decision: &RecordedDecision{
+			Allowed:    expectedDr.isAllowed(),
+			Verified:   expectedDr.Verified,
+			ReasonCode: expectedDr.ReasonCode,
+		},
+	}
+}
+
+func TestReplay(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "replay-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	recordFile := filepath.Join(tmpDir, "records.json")
+
+	// record the decisions made by the offline handler with the test data
+	recorder, err := NewAdmissionRecorder(recordFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i <= MaxCaseNum; i++ {
+		if skipCaseNum[i] {
+			continue
+		}
+		record := getTestRecord(i)
+		result, err := (&Replayer{}).Replay(record)
+		if err != nil {
+			t.Fatalf("[Case %s] failed to replay: %s", strconv.Itoa(i), err.Error())
+		}
+		if result.Changed {
+			t.Errorf("[Case %s] decision is changed; recorded: %v, replayed: %v", strconv.Itoa(i), result.Recorded, result.Replayed)
+		}
+		if err = recorder.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = recorder.Close()
+
+	records, err := LoadAdmissionRecords(recordFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != MaxCaseNum+1-len(skipCaseNum) {
+		t.Fatalf("unexpected number of records: %d", len(records))
+	}
+
+	// replace the RSP in the record with a profile which does not protect ConfigMap
+	rsp := records[0].Data.RSPList[0]
+	newRSP := rspapi.ResourceSigningProfile{ObjectMeta: rsp.ObjectMeta}
+	results := (&Replayer{Profiles: []rspapi.ResourceSigningProfile{newRSP}}).ReplayAll(records[:1])
+	if len(results) != 1 || results[0].Error != "" {
+		t.Fatalf("unexpected replay results: %v", results)
+	}
+	if !results[0].Changed || !results[0].Replayed.Allowed {
+		t.Errorf("replayed decision is expected to be changed to allow; recorded: %v, replayed: %v", results[0].Recorded, results[0].Replayed)
+	}
+}
+
+func TestReplayOwnerTrust(t *testing.T) {
+	controller := "system:serviceaccount:kube-system:replicaset-controller"
+	deploy := newTestOwner("apps/v1", "Deployment", "app", "uid-deploy", nil, map[string]string{common.ResourceIntegrityLabelKey: common.LabelValueVerified})
+	deploy.SetNamespace("secure-ns")
+
+	// a ConfigMap without signature created by the controller from the verified Deployment
+	record := getTestRecord(0)
+	req := record.Review.Request
+	req.UserInfo.Username = controller
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(req.Object.Raw); err != nil {
+		t.Fatal(err)
+	}
+	isController := true
+	obj.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "app", UID: deploy.GetUID(), Controller: &isController}})
+	req.Object.Raw, _ = obj.MarshalJSON()
+	protectedKind := common.RulePattern("Deployment")
+	protectedName := common.RulePattern("app")
+	rsp := &record.Data.RSPList[0]
+	rsp.Spec.ProtectRules = append(rsp.Spec.ProtectRules, &common.Rule{Match: []*common.RequestPattern{{Kind: &protectedKind, Name: &protectedName}}})
+	rsp.Spec.OwnerTrust = &rspapi.OwnerTrust{Enabled: true, OwnerKinds: []string{"Deployment"}, Users: []string{controller}}
+	record.Data.Owners = []*unstructured.Unstructured{deploy}
+	record.Decision = &RecordedDecision{Allowed: true, Verified: true, ReasonCode: common.REASON_VERIFIED_OWNER}
+
+	result, err := (&Replayer{}).Replay(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Changed {
+		t.Errorf("decision with verified owner is changed; recorded: %v, replayed: %v", result.Recorded, result.Replayed)
+	}
+
+	// the owner got by the check is recorded again
+	clients := (&Replayer{}).newFakeClients(record.Data)
+	metaLogger := log.New()
+	metaLogger.SetOutput(ioutil.Discard)
+	handler := NewOfflineHandler(record.Config, clients, metaLogger, metaLogger.WithField("test", "owner"))
+	resp := handler.Run(req)
+	newRecord := handler.Record(req, resp)
+	if newRecord == nil {
+		t.Fatalf("request is not recorded")
+	}
+	if len(newRecord.Data.Owners) != 1 || newRecord.Data.Owners[0].GetUID() != deploy.GetUID() {
+		t.Errorf("owner is not recorded: %v", newRecord.Data.Owners)
+	}
+}
+
+func TestSanitizeAdmissionRequest(t *testing.T) {
+	req := &admv1.AdmissionRequest{}
+	req.Kind.Version = "v1"
+	req.Kind.Kind = "Secret"
+	req.Object.Raw = []byte(`{"apiVersion":"v1","kind":"Secret","metadata":{"name":"test","annotations":{"kubectl.kubernetes.io/last-applied-configuration":"{\"data\":{\"password\":\"cGFzc3dvcmQ=\"}}","integrityshield.io/message":"c2VjcmV0"}},"data":{"password":"cGFzc3dvcmQ="}}`)
+	req.OldObject.Raw = req.Object.Raw
+
+	sanitizedReq, sanitized, err := sanitizeAdmissionRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sanitized {
+		t.Errorf("Secret request is expected to be sanitized")
+	}
+	var obj map[string]interface{}
+	_ = json.Unmarshal(sanitizedReq.Object.Raw, &obj)
+	if obj["data"].(map[string]interface{})["password"] != redactedValue {
+		t.Errorf("Secret data is not redacted: %s", string(sanitizedReq.Object.Raw))
+	}
+	for _, raw := range [][]byte{sanitizedReq.Object.Raw, sanitizedReq.OldObject.Raw} {
+		var o map[string]interface{}
+		_ = json.Unmarshal(raw, &o)
+		annotations := o["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})
+		for _, key := range secretContentAnnotationKeys {
+			if annotations[key] != redactedValue {
+				t.Errorf("Secret annotation %s is not redacted: %s", key, string(raw))
+			}
+		}
+	}
+	if string(req.Object.Raw) == string(sanitizedReq.Object.Raw) {
+		t.Errorf("original request must not be modified")
+	}
+}
+
+func TestSanitizeRunData(t *testing.T) {
+	secretMsg := base64.StdEncoding.EncodeToString([]byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: test\ndata:\n  password: cGFzc3dvcmQ=\n"))
+	cmMsg := base64.StdEncoding.EncodeToString([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\ndata:\n  key: value\n"))
+	data := &RunData{
+		ResSigList: &rsigapi.ResourceSignatureList{
+			Items: []*rsigapi.ResourceSignature{
+				{Spec: rsigapi.ResourceSignatureSpec{Data: []*rsigapi.SignItem{{Message: secretMsg}, {Message: cmMsg}}}},
+			},
+		},
+	}
+
+	sanitizedData, sanitized := sanitizeRunData(data)
+	if !sanitized {
+		t.Errorf("data with a signed message of Secret is expected to be sanitized")
+	}
+	items := sanitizedData.ResSigList.Items[0].Spec.Data
+	if items[0].Message != redactedValue {
+		t.Errorf("signed message of Secret is not redacted: %s", items[0].Message)
+	}
+	if items[1].Message != cmMsg {
+		t.Errorf("signed message of ConfigMap must not be redacted: %s", items[1].Message)
+	}
+	if data.ResSigList.Items[0].Spec.Data[0].Message != secretMsg {
+		t.Errorf("original data must not be modified")
+	}
+}
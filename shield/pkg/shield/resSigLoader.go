@@ -44,15 +44,21 @@ type ResSigLoader struct {
 	reqApiVersion      string
 	reqKind            string
 
-	Client *rsigclient.ApisV1alpha1Client
+	Client rsigclient.ApisV1alpha1Interface
 	Data   *rsigapi.ResourceSignatureList
 }
 
 func NewResSigLoader(signatureNamespace, requestNamespace string) *ResSigLoader {
-	interval := time.Second * 0
 	config, _ := kubeutil.GetKubeConfig()
-	client, _ := rsigclient.NewForConfig(config)
+	var client rsigclient.ApisV1alpha1Interface
+	if c, err := rsigclient.NewForConfig(config); err == nil {
+		client = c
+	}
+	return newResSigLoader(signatureNamespace, requestNamespace, client)
+}
 
+func newResSigLoader(signatureNamespace, requestNamespace string, client rsigclient.ApisV1alpha1Interface) *ResSigLoader {
+	interval := time.Second * 0
 	return &ResSigLoader{
 		interval:           interval,
 		signatureNamespace: signatureNamespace,
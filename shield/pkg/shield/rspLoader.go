@@ -40,15 +40,21 @@ type RSPLoader struct {
 	commonProfile          *common.CommonProfile
 	defaultProfileInterval time.Duration
 
-	Client *rspclient.ApisV1alpha1Client
+	Client rspclient.ApisV1alpha1Interface
 	Data   []rspapi.ResourceSigningProfile
 }
 
 func NewRSPLoader(shieldNamespace, profileNamespace, requestNamespace string, commonProfile *common.CommonProfile) *RSPLoader {
-	defaultProfileInterval := time.Second * 60
 	config, _ := kubeutil.GetKubeConfig()
-	client, _ := rspclient.NewForConfig(config)
+	var client rspclient.ApisV1alpha1Interface
+	if c, err := rspclient.NewForConfig(config); err == nil {
+		client = c
+	}
+	return newRSPLoader(shieldNamespace, profileNamespace, requestNamespace, commonProfile, client)
+}
 
+func newRSPLoader(shieldNamespace, profileNamespace, requestNamespace string, commonProfile *common.CommonProfile, client rspclient.ApisV1alpha1Interface) *RSPLoader {
+	defaultProfileInterval := time.Second * 60
 	return &RSPLoader{
 		shieldNamespace:        shieldNamespace,
 		profileNamespace:       profileNamespace,
@@ -0,0 +1,199 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	rspclient "github.com/IBM/integrity-enforcer/shield/pkg/client/resourcesigningprofile/clientset/versioned/typed/resourcesigningprofile/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	config "github.com/IBM/integrity-enforcer/shield/pkg/shield/config"
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
+	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// profileEvent is a result of a profile for a request
+type profileEvent struct {
+	namespace string
+	name      string
+	event     *rspapi.ProfileEvent
+}
+
+func newProfileEvent(prof rspapi.ResourceSigningProfile, dr *DecisionResult, reqc *common.ReqContext, config *config.ShieldConfig, ctx *CheckContext) *profileEvent {
+	event := &rspapi.ProfileEvent{
+		Request:   common.NewRequestFromReqContext(reqc),
+		Message:   dr.Message,
+		Rule:      prof.MatchedRule(reqc.Map(), config.Namespace),
+		Allowed:   dr.isAllowed(),
+		Verified:  dr.isAllowed() && dr.Verified,
+		Timestamp: time.Now(),
+	}
+	// the signature result is not set if the request is allowed without signature check (e.g. no mutation)
+	if event.Verified && ctx.SignatureEvalResult != nil && ctx.SignatureEvalResult.Checked && ctx.SignatureEvalResult.Allow {
+		event.Signer = ctx.SignatureEvalResult.GetSignerName()
+	}
+	return &profileEvent{namespace: prof.GetNamespace(), name: prof.GetName(), event: event}
+}
+
+/**********************************************
+
+				RSPStatusUpdater
+
+***********************************************/
+
+// RSPStatusUpdater aggregates results of requests for each ResourceSigningProfile in memory, and writes them to the status
+// in background at every interval, so that admission requests do not wait for status updates and do not conflict with each other.
+type RSPStatusUpdater struct {
+	client rspclient.ApisV1alpha1Interface
+
+	mu   sync.Mutex
+	conf config.ProfileStatusConfig
+	// results which are not written yet; only status of each profile is used
+	pending map[string]*rspapi.ResourceSigningProfile
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+}
+
+var defaultRSPStatusUpdater *RSPStatusUpdater
+var defaultRSPStatusUpdaterOnce sync.Once
+
+func getRSPStatusUpdater() *RSPStatusUpdater {
+	defaultRSPStatusUpdaterOnce.Do(func() {
+		var client rspclient.ApisV1alpha1Interface
+		if kubeconfig, err := kubeutil.GetKubeConfig(); err == nil {
+			client, _ = rspclient.NewForConfig(kubeconfig)
+		}
+		defaultRSPStatusUpdater = NewRSPStatusUpdater(client)
+		defaultRSPStatusUpdater.Start()
+	})
+	return defaultRSPStatusUpdater
+}
+
+// StopRSPStatusUpdater writes the pending results and stops the default updater if it has been started.
+// This is called after admission requests are drained, so that results of the last requests are not lost.
+func StopRSPStatusUpdater() {
+	if defaultRSPStatusUpdater != nil {
+		defaultRSPStatusUpdater.Stop()
+	}
+}
+
+func NewRSPStatusUpdater(client rspclient.ApisV1alpha1Interface) *RSPStatusUpdater {
+	return &RSPStatusUpdater{
+		client:  client,
+		conf:    (&config.ShieldConfig{}).ProfileStatusConfig(),
+		pending: map[string]*rspapi.ResourceSigningProfile{},
+	}
+}
+
+// SetConfig updates the history length and the interval; the new interval is used from the next update
+func (self *RSPStatusUpdater) SetConfig(conf config.ProfileStatusConfig) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.conf = conf
+}
+
+// Add records the event of a profile, which is written to the status in the next update
+func (self *RSPStatusUpdater) Add(namespace, name string, event *rspapi.ProfileEvent) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	key := fmt.Sprintf("%s/%s", namespace, name)
+	delta, ok := self.pending[key]
+	if !ok {
+		delta = &rspapi.ResourceSigningProfile{}
+		delta.SetNamespace(namespace)
+		delta.SetName(name)
+		self.pending[key] = delta
+	}
+	delta.RecordEvents([]*rspapi.ProfileEvent{event}, self.conf.HistoryLength)
+}
+
+func (self *RSPStatusUpdater) Start() {
+	self.stopCh = make(chan struct{})
+	self.doneCh = make(chan struct{})
+	go func() {
+		defer close(self.doneCh)
+		for {
+			select {
+			case <-self.stopCh:
+				self.Flush()
+				return
+			case <-time.After(self.interval()):
+				self.Flush()
+			}
+		}
+	}()
+}
+
+// Stop writes the pending results and stops the background updates
+func (self *RSPStatusUpdater) Stop() {
+	if self.stopCh == nil {
+		return
+	}
+	close(self.stopCh)
+	<-self.doneCh
+	self.stopCh = nil
+}
+
+func (self *RSPStatusUpdater) interval() time.Duration {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return time.Duration(self.conf.UpdateIntervalSeconds) * time.Second
+}
+
+// Flush writes all pending results; each profile is updated once with the results aggregated since the last update
+func (self *RSPStatusUpdater) Flush() {
+	self.mu.Lock()
+	pending := self.pending
+	historyLength := self.conf.HistoryLength
+	self.pending = map[string]*rspapi.ResourceSigningProfile{}
+	self.mu.Unlock()
+
+	if self.client == nil {
+		return
+	}
+	for key, delta := range pending {
+		if err := self.update(delta, historyLength); err != nil {
+			logger.Error(fmt.Sprintf("Failed to update status of ResourceSigningProfile %s; %s", key, err.Error()))
+		}
+	}
+}
+
+func (self *RSPStatusUpdater) update(delta *rspapi.ResourceSigningProfile, historyLength int) error {
+	namespace := delta.GetNamespace()
+	name := delta.GetName()
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		rspOrg, err := self.client.ResourceSigningProfiles(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		rspOrg.Status.Merge(&delta.Status, historyLength)
+		_, err = self.client.ResourceSigningProfiles(namespace).Update(context.Background(), rspOrg, metav1.UpdateOptions{})
+		return err
+	})
+	// the profile has been deleted since the request
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
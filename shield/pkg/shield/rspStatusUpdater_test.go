@@ -0,0 +1,98 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	rspfake "github.com/IBM/integrity-enforcer/shield/pkg/client/resourcesigningprofile/clientset/versioned/fake"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	config "github.com/IBM/integrity-enforcer/shield/pkg/shield/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRSPStatusUpdater(t *testing.T) {
+	rsp := &rspapi.ResourceSigningProfile{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "test-rsp"}}
+	rsp.Status.DenyCount = 1
+	rsp.Status.Latest = []*rspapi.ProfileStatusDetail{{Request: &common.Request{Kind: "ConfigMap", Name: "old"}}}
+	client := rspfake.NewSimpleClientset(rsp).ApisV1alpha1()
+
+	updater := NewRSPStatusUpdater(client)
+	updater.SetConfig(config.ProfileStatusConfig{HistoryLength: 2, UpdateIntervalSeconds: 10})
+	rule := `{"match":[{"kind":"ConfigMap"}]}`
+	now := time.Now()
+	events := []*rspapi.ProfileEvent{
+		{Request: &common.Request{ApiVersion: "v1", Kind: "ConfigMap", Name: "cm1"}, Rule: rule, Allowed: true, Verified: true, Signer: "signer1", Timestamp: now},
+		{Request: &common.Request{ApiVersion: "v1", Kind: "ConfigMap", Name: "cm2"}, Rule: rule, Allowed: true, Verified: true, Signer: "signer1", Timestamp: now},
+		{Request: &common.Request{ApiVersion: "v1", Kind: "ConfigMap", Name: "cm3"}, Rule: rule, Allowed: false, Message: "no signature found", Timestamp: now},
+		{Request: &common.Request{ApiVersion: "v1", Kind: "ConfigMap", Name: "cm4"}, Rule: rule, Allowed: true, Verified: true, Signer: "signer2", Timestamp: now},
+	}
+	for _, event := range events {
+		updater.Add("test-ns", "test-rsp", event)
+	}
+	// results of a deleted profile are dropped
+	updater.Add("test-ns", "deleted-rsp", events[0])
+	updater.Flush()
+
+	updated, err := client.ResourceSigningProfiles("test-ns").Get(context.Background(), "test-rsp", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := updated.Status
+	if status.DenyCount != 2 || status.AllowCount != 3 || status.VerifiedCount != 3 {
+		t.Errorf("Failed to test RSPStatusUpdater; unexpected counts: %d denied, %d allowed, %d verified", status.DenyCount, status.AllowCount, status.VerifiedCount)
+	}
+	if len(status.RuleSummary) != 1 || status.RuleSummary[0].AllowCount != 3 || status.RuleSummary[0].DenyCount != 1 {
+		t.Errorf("Failed to test RSPStatusUpdater; unexpected rule summary: %v", status.RuleSummary)
+	}
+	if len(status.SignerSummary) != 2 || status.SignerSummary[0].Signer != "signer1" || status.SignerSummary[0].Count != 2 {
+		t.Errorf("Failed to test RSPStatusUpdater; unexpected signer summary: %v", status.SignerSummary)
+	}
+	// the latest event comes first, and events are kept up to the history length
+	if len(status.LatestAllowed) != 2 || status.LatestAllowed[0].Request.Name != "cm4" {
+		t.Errorf("Failed to test RSPStatusUpdater; unexpected latest allowed events: %v", status.LatestAllowed)
+	}
+	if len(status.Latest) != 2 || status.Latest[0].Request.Name != "cm3" || status.Latest[1].Request.Name != "old" {
+		t.Errorf("Failed to test RSPStatusUpdater; unexpected latest denied events: %v", status.Latest)
+	}
+	if len(updater.pending) != 0 {
+		t.Errorf("Failed to test RSPStatusUpdater; pending results must be cleared")
+	}
+}
+
+func TestRSPStatusUpdaterStop(t *testing.T) {
+	rsp := &rspapi.ResourceSigningProfile{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "test-rsp"}}
+	client := rspfake.NewSimpleClientset(rsp).ApisV1alpha1()
+
+	updater := NewRSPStatusUpdater(client)
+	updater.SetConfig(config.ProfileStatusConfig{HistoryLength: 2, UpdateIntervalSeconds: 60})
+	updater.Start()
+	updater.Add("test-ns", "test-rsp", &rspapi.ProfileEvent{Request: &common.Request{ApiVersion: "v1", Kind: "ConfigMap", Name: "cm1"}, Allowed: false, Message: "no signature found", Timestamp: time.Now()})
+	// pending results are written on stop, before the interval
+	updater.Stop()
+
+	updated, err := client.ResourceSigningProfiles("test-ns").Get(context.Background(), "test-rsp", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status.DenyCount != 1 {
+		t.Errorf("Failed to test RSPStatusUpdater; pending results must be written on stop: %d denied", updated.Status.DenyCount)
+	}
+}
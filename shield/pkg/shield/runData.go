@@ -18,6 +18,7 @@ package shield
 
 import (
 	"encoding/json"
+	"fmt"
 
 	rsigapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesignature/v1alpha1"
 	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
@@ -27,6 +28,9 @@ import (
 
 	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 /**********************************************
@@ -40,6 +44,8 @@ type RunData struct {
 	NSList       []v1.Namespace                  `json:"nsList,omitempty"`
 	SignerConfig *sigconfapi.SignerConfig        `json:"signerConfig,omitempty"`
 	ResSigList   *rsigapi.ResourceSignatureList  `json:"resSigList,omitempty"`
+	// metadata of owners got by the verified owner check, so that the check can be evaluated again offline
+	Owners []*unstructured.Unstructured `json:"owners,omitempty"`
 
 	loader        *Loader               `json:"-"`
 	commonProfile *common.CommonProfile `json:"-"`
@@ -60,6 +66,34 @@ func (self *RunData) GetResSigList(reqc *common.ReqContext) *rsigapi.ResourceSig
 	return self.ResSigList
 }
 
+func (self *RunData) GetOwner(ref metav1.OwnerReference, namespace string) (*unstructured.Unstructured, error) {
+	if self.loader == nil || self.loader.Owner == nil {
+		return nil, fmt.Errorf("no loader to get owner %s %s", ref.Kind, ref.Name)
+	}
+	owner, err := self.loader.Owner.GetOwner(ref, namespace)
+	if err != nil {
+		return nil, err
+	}
+	self.addOwner(owner)
+	return owner, nil
+}
+
+// addOwner keeps the metadata of the owner, which is enough for the verified owner check
+func (self *RunData) addOwner(owner *unstructured.Unstructured) {
+	for _, o := range self.Owners {
+		if o.GetUID() == owner.GetUID() && o.GroupVersionKind() == owner.GroupVersionKind() {
+			return
+		}
+	}
+	ownerMeta := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	ownerMeta.SetAPIVersion(owner.GetAPIVersion())
+	ownerMeta.SetKind(owner.GetKind())
+	if metadata, ok := owner.Object["metadata"]; ok {
+		ownerMeta.Object["metadata"] = runtime.DeepCopyJSONValue(metadata)
+	}
+	self.Owners = append(self.Owners, ownerMeta)
+}
+
 func (self *RunData) setRuleTable(shieldNamespace string) bool {
 	updated := false
 	ruleTable := NewRuleTable(self.RSPList, self.NSList, self.commonProfile, shieldNamespace)
@@ -0,0 +1,172 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"encoding/json"
+	"fmt"
+
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	config "github.com/IBM/integrity-enforcer/shield/pkg/shield/config"
+	log "github.com/sirupsen/logrus"
+	admv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+/**********************************************
+
+				Scanner
+
+***********************************************/
+
+type ScanResultType string
+
+const (
+	ScanResultVerified        ScanResultType = "Verified"
+	ScanResultUnsigned        ScanResultType = "Unsigned"
+	ScanResultTampered        ScanResultType = "Tampered"
+	ScanResultUntrustedSigner ScanResultType = "UntrustedSigner"
+	ScanResultError           ScanResultType = "Error"
+	// the resource has a signature, but it has been changed from the signed message without the webhook
+	ScanResultDrifted ScanResultType = "Drifted"
+)
+
+const (
+	SignatureSourceAnnotation        = "annotation"
+	SignatureSourceResourceSignature = "ResourceSignature"
+)
+
+type ScanResult struct {
+	ApiVersion string         `json:"apiVersion"`
+	Kind       string         `json:"kind"`
+	Namespace  string         `json:"namespace,omitempty"`
+	Name       string         `json:"name"`
+	Result     ScanResultType `json:"result"`
+	Signer     string         `json:"signer,omitempty"`
+	Message    string         `json:"message,omitempty"`
+	// "namespace/name" of ResourceSigningProfiles which protect the resource
+	Profiles []string `json:"profiles,omitempty"`
+	// where the verified signature is found; "annotation" or "ResourceSignature"
+	SignatureSource      string `json:"signatureSource,omitempty"`
+	ResourceSignatureUID string `json:"resourceSignatureUID,omitempty"`
+	// timestamp when the resource was verified by the admission request, which is annotated by iShield
+	LastVerified string `json:"lastVerified,omitempty"`
+	// diff between the signed message and the resource if drifted
+	Diff string `json:"diff,omitempty"`
+}
+
+// Scanner verifies resources which already exist in the cluster, e.g. ones created before iShield is installed.
+// Each resource is evaluated by the same checks as a request which creates it, but the result is not reported to the cluster.
+type Scanner struct {
+	config     *config.ShieldConfig
+	metaLogger *log.Logger
+
+	// clients are created once and shared by loaders of all scanned resources
+	clients *LoaderClients
+}
+
+func NewScanner(config *config.ShieldConfig, metaLogger *log.Logger) *Scanner {
+	return &Scanner{config: config, metaLogger: metaLogger, clients: NewLoaderClients()}
+}
+
+// NewScannerWithClients returns a scanner which loads iShield resources with the given clients (e.g. fake clientsets)
+func NewScannerWithClients(config *config.ShieldConfig, clients *LoaderClients, metaLogger *log.Logger) *Scanner {
+	return &Scanner{config: config, metaLogger: metaLogger, clients: clients}
+}
+
+// Scan returns the verification result of the resource, or nil if it is not protected by any ResourceSigningProfile
+func (self *Scanner) Scan(obj *unstructured.Unstructured) *ScanResult {
+	result := &ScanResult{
+		ApiVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+	}
+	req, err := newScanRequest(obj)
+	if err != nil {
+		result.Result = ScanResultError
+		result.Message = err.Error()
+		return result
+	}
+
+	reqLog := self.metaLogger.WithField("requestUID", string(req.UID))
+	handler := &Handler{config: self.config, data: &RunData{}, serverLogger: self.metaLogger, requestLog: reqLog, clients: self.clients, offline: true}
+	handler.initialize(req)
+	dr := handler.Check()
+	if !handler.ctx.Protected {
+		return nil
+	}
+
+	result.Result = getScanResultType(dr.ReasonCode)
+	result.Message = dr.Message
+	for _, profile := range handler.matchedProfiles {
+		result.Profiles = append(result.Profiles, fmt.Sprintf("%s/%s", profile.GetNamespace(), profile.GetName()))
+	}
+	sigResult := handler.ctx.SignatureEvalResult
+	if result.Result == ScanResultTampered && sigResult != nil && sigResult.MessageMismatch {
+		result.Result = ScanResultDrifted
+		result.Diff = sigResult.MessageDiff
+	}
+	if sigResult != nil && sigResult.Signer != nil {
+		result.Signer = sigResult.Signer.GetName()
+	}
+	if sigResult != nil && sigResult.ResourceSignatureUID != "" {
+		result.SignatureSource = SignatureSourceResourceSignature
+		result.ResourceSignatureUID = sigResult.ResourceSignatureUID
+	} else if dr.ReasonCode == common.REASON_VALID_SIG {
+		result.SignatureSource = SignatureSourceAnnotation
+	}
+	result.LastVerified = obj.GetAnnotations()[common.LastVerifiedTimestampAnnotationKey]
+	return result
+}
+
+// newScanRequest builds a CREATE request of the resource; the request has no user, so rules for specific users do not match it
+func newScanRequest(obj *unstructured.Unstructured) (*admv1.AdmissionRequest, error) {
+	objBytes, err := json.Marshal(obj.Object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s %s; %s", obj.GetKind(), obj.GetName(), err.Error())
+	}
+	gvk := obj.GroupVersionKind()
+	dryRun := false
+	req := &admv1.AdmissionRequest{
+		UID:       types.UID(fmt.Sprintf("scan-%s", obj.GetUID())),
+		Kind:      metav1.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind},
+		Name:      obj.GetName(),
+		Namespace: obj.GetNamespace(),
+		Operation: admv1.Create,
+		DryRun:    &dryRun,
+	}
+	req.Object.Raw = objBytes
+	return req, nil
+}
+
+func getScanResultType(reasonCode int) ScanResultType {
+	switch reasonCode {
+	case common.REASON_VALID_SIG, common.REASON_VERIFIED_OWNER:
+		return ScanResultVerified
+	case common.REASON_NO_SIG:
+		return ScanResultUnsigned
+	case common.REASON_INVALID_SIG:
+		return ScanResultTampered
+	case common.REASON_NO_MATCH_SIGNER_CONFIG:
+		return ScanResultUntrustedSigner
+	default:
+		return ScanResultError
+	}
+}
@@ -0,0 +1,72 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	cache "github.com/IBM/integrity-enforcer/shield/pkg/util/cache"
+	log "github.com/sirupsen/logrus"
+	admv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func getTestScanObject(caseNum int) (*unstructured.Unstructured, *Scanner) {
+	reqc, cfg, data, _, _, _, _ := getTestData(caseNum)
+	var req *admv1.AdmissionRequest
+	_ = json.Unmarshal([]byte(reqc.RequestJsonStr), &req)
+	obj := &unstructured.Unstructured{}
+	_ = json.Unmarshal(req.Object.Raw, &obj.Object)
+
+	cache.Clear()
+	metaLogger := log.New()
+	metaLogger.SetOutput(ioutil.Discard)
+	scanner := NewScannerWithClients(cfg, (&Replayer{}).newFakeClients(data), metaLogger)
+	return obj, scanner
+}
+
+func TestScan(t *testing.T) {
+	// case 0 is a ConfigMap without signature, and case 2 is the one with a valid signature
+	obj, scanner := getTestScanObject(0)
+	if result := scanner.Scan(obj); result == nil || result.Result != ScanResultUnsigned {
+		t.Errorf("unexpected scan result for the unsigned resource: %v", result)
+	}
+
+	obj, scanner = getTestScanObject(2)
+	result := scanner.Scan(obj)
+	if result == nil || result.Result != ScanResultVerified || result.Signer == "" {
+		t.Errorf("unexpected scan result for the signed resource: %v", result)
+	}
+	if result != nil && (len(result.Profiles) == 0 || result.SignatureSource == "") {
+		t.Errorf("protecting profiles and signature source must be reported: %v", result)
+	}
+
+	// the signed resource which is changed without the webhook
+	obj, scanner = getTestScanObject(2)
+	_ = unstructured.SetNestedField(obj.Object, "changed", "data", "key1")
+	if result := scanner.Scan(obj); result == nil || result.Result != ScanResultDrifted || result.Diff == "" {
+		t.Errorf("unexpected scan result for the drifted resource: %v", result)
+	}
+
+	obj, scanner = getTestScanObject(2)
+	obj.SetNamespace("not-protected-ns")
+	if result := scanner.Scan(obj); result != nil {
+		t.Errorf("resources which are not protected must not be reported: %v", result)
+	}
+}
@@ -36,15 +36,21 @@ type SignerConfigLoader struct {
 	interval        time.Duration
 	shieldNamespace string
 
-	Client *sigconfclient.ApisV1alpha1Client
+	Client sigconfclient.ApisV1alpha1Interface
 	Data   *sigconfapi.SignerConfig
 }
 
 func NewSignerConfigLoader(shieldNamespace string) *SignerConfigLoader {
-	interval := time.Second * 10
 	config, _ := kubeutil.GetKubeConfig()
-	client, _ := sigconfclient.NewForConfig(config)
+	var client sigconfclient.ApisV1alpha1Interface
+	if c, err := sigconfclient.NewForConfig(config); err == nil {
+		client = c
+	}
+	return newSignerConfigLoader(shieldNamespace, client)
+}
 
+func newSignerConfigLoader(shieldNamespace string, client sigconfclient.ApisV1alpha1Interface) *SignerConfigLoader {
+	interval := time.Second * 10
 	return &SignerConfigLoader{
 		interval:        interval,
 		shieldNamespace: shieldNamespace,
@@ -26,8 +26,6 @@ import (
 	helm "github.com/IBM/integrity-enforcer/shield/pkg/plugins/helm"
 	config "github.com/IBM/integrity-enforcer/shield/pkg/shield/config"
 	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
-	pgp "github.com/IBM/integrity-enforcer/shield/pkg/util/sign/pgp"
-	x509 "github.com/IBM/integrity-enforcer/shield/pkg/util/sign/x509"
 	ishieldyaml "github.com/IBM/integrity-enforcer/shield/pkg/util/yaml"
 )
 
@@ -209,13 +207,13 @@ func (self *ConcreteSignatureEvaluator) Eval(reqc *common.ReqContext, resSigList
 	if candidateKeyCount > 0 {
 		validKeyCount := 0
 		for _, keyPath := range pgpPubkeys {
-			if loaded, _ := pgp.LoadKeyRing(keyPath); len(loaded) > 0 {
+			if loaded, _ := loadPGPKeyRing(keyPath); len(loaded) > 0 {
 				validKeyCount += 1
 			}
 		}
 
 		for _, certDir := range x509Pubkeys {
-			if loaded, _ := x509.LoadCertDir(certDir); len(loaded) > 0 {
+			if loaded, _ := loadX509CertDir(certDir); len(loaded) > 0 {
 				validKeyCount += 1
 			}
 		}
@@ -263,14 +261,19 @@ func (self *ConcreteSignatureEvaluator) Eval(reqc *common.ReqContext, resSigList
 		if sigVerifyResult != nil && sigVerifyResult.Error != nil {
 			reasonFail = fmt.Sprintf("%s; %s", reasonFail, sigVerifyResult.Error.Reason)
 		}
-		return &common.SignatureEvalResult{
+		result := &common.SignatureEvalResult{
 			Allow:   false,
 			Checked: true,
 			Error: &common.CheckError{
 				Reason: reasonFail,
 			},
 			ResourceSignatureUID: rsigUID,
-		}, nil
+		}
+		if sigVerifyResult != nil {
+			result.MessageMismatch = sigVerifyResult.MessageMismatch
+			result.MessageDiff = sigVerifyResult.Diff
+		}
+		return result, nil
 	}
 
 	// signer
@@ -292,6 +295,7 @@ func (self *ConcreteSignatureEvaluator) Eval(reqc *common.ReqContext, resSigList
 			MatchedSignerConfig:  matchedSignerConfigStr,
 			Error:                nil,
 			ResourceSignatureUID: rsigUID,
+			VerifiedKeyConfigs:   GetKeyConfigNames(verifiedKeyPathList, false),
 		}, nil
 	} else {
 		reasonFail := common.ReasonCodeMap[common.REASON_NO_MATCH_SIGNER_CONFIG].Message
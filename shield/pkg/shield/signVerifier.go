@@ -28,7 +28,6 @@ import (
 	kubeutil "github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
 	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
 	mapnode "github.com/IBM/integrity-enforcer/shield/pkg/util/mapnode"
-	pgp "github.com/IBM/integrity-enforcer/shield/pkg/util/sign/pgp"
 	x509 "github.com/IBM/integrity-enforcer/shield/pkg/util/sign/x509"
 )
 
@@ -101,7 +100,9 @@ func (self *ResourceVerifier) Verify(sig *GeneralSignature, reqc *common.ReqCont
 					Reason: msg,
 					Error:  nil,
 				},
-				Signer: nil,
+				Signer:          nil,
+				MessageMismatch: true,
+				Diff:            diffStr,
 			}, []string{}, nil
 		}
 	}
@@ -147,7 +148,7 @@ func (self *ResourceVerifier) Verify(sig *GeneralSignature, reqc *common.ReqCont
 	verifiedKeyPathList := []string{}
 	if len(self.PGPKeyPathList) > 0 {
 		for _, keyPath := range self.PGPKeyPathList {
-			ok, reasonFail, signer, fingerprint, err := pgp.VerifySignature(keyPath, message, signature)
+			ok, reasonFail, signer, fingerprint, err := verifyPGPSignature(keyPath, message, signature)
 			if err != nil {
 				vcerr = &common.CheckError{
 					Msg:    fmt.Sprintf("Error occured while verifying signature in %s", sigFrom),
@@ -176,7 +177,7 @@ func (self *ResourceVerifier) Verify(sig *GeneralSignature, reqc *common.ReqCont
 	if len(self.X509KeyPathList) > 0 && certFound {
 		for _, caCertPath := range self.X509KeyPathList {
 			certificate := []byte(certificateStr)
-			certOk, reasonFail, err := x509.VerifyCertificate(certificate, caCertPath)
+			certOk, reasonFail, err := verifyX509Certificate(certificate, caCertPath)
 			if err != nil {
 				vcerr = &common.CheckError{
 					Msg:    fmt.Sprintf("Error occured while verifying certificate in %s", sigFrom),
@@ -230,7 +231,7 @@ func (self *ResourceVerifier) Verify(sig *GeneralSignature, reqc *common.ReqCont
 	if vsinfo == nil {
 		for _, keyPath := range self.AllMountedKeyPathList {
 			if strings.Contains(keyPath, "/pgp/") {
-				if ok2, _, signer2, fingerprint2, _ := pgp.VerifySignature(keyPath, message, signature); ok2 && signer2 != nil {
+				if ok2, _, signer2, fingerprint2, _ := verifyPGPSignature(keyPath, message, signature); ok2 && signer2 != nil {
 					signerAlt := &common.SignerInfo{
 						Email:       signer2.Email,
 						Name:        signer2.Name,
@@ -296,7 +297,8 @@ func (self *ResourceVerifier) MatchMessage(message, reqObj []byte, protectAttrs,
 		simObj, err := kubeutil.DryRunCreate([]byte(nsMaskedOrgBytes), self.dryRunNamespace)
 		if err != nil {
 			logger.Error(fmt.Sprintf("Error in DryRunCreate: %s", err.Error()))
-			return false, ""
+			// keep the diff of the direct matching
+			return false, diffStr
 		}
 		mask = getMaskDef("")
 		mask = append(mask, addMask...)
@@ -315,14 +317,16 @@ func (self *ResourceVerifier) MatchMessage(message, reqObj []byte, protectAttrs,
 		_, patchedBytes, err := kubeutil.GetApplyPatchBytes(orgObj, reqNamespace)
 		if err != nil {
 			logger.Error(fmt.Sprintf("Error in getting patched bytes: %s", err.Error()))
-			return false, ""
+			// keep the diff of the direct matching
+			return false, diffStr
 		}
 		patchedNode, _ := mapnode.NewFromBytes(patchedBytes)
 		nsMaskedPatchedNode := patchedNode.Mask([]string{"metadata.namespace"})
 		simPatchedObj, err := kubeutil.DryRunCreate([]byte(nsMaskedPatchedNode.ToYaml()), self.dryRunNamespace)
 		if err != nil {
 			logger.Error(fmt.Sprintf("Error in DryRunCreate for Patch: %s", err.Error()))
-			return false, ""
+			// keep the diff of the direct matching
+			return false, diffStr
 		}
 		mask = getMaskDef("")
 		mask = append(mask, addMask...)
@@ -490,6 +494,9 @@ func makeAllowDiffPatterns(reqc *common.ReqContext, kustomizeList []*common.Kust
 type SigVerifyResult struct {
 	Error  *common.CheckError
 	Signer *common.SignerInfo
+	// the object is not identical with the signed message
+	MessageMismatch bool
+	Diff            string
 }
 
 /**********************************************
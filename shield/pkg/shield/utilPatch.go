@@ -18,6 +18,7 @@ package shield
 
 import (
 	"encoding/json"
+	"strings"
 	"time"
 
 	"github.com/IBM/integrity-enforcer/shield/pkg/common"
@@ -49,6 +50,10 @@ func generatePatchBytes(reqc *common.ReqContext, ctx *CheckContext) []byte {
 		verifyResultLabel = common.LabelValueUnverified
 	}
 	if verifyResultLabel == "" {
+		// `verified` label is trusted by the owner check, so it must not be set by users without signature
+		if verifiedLabelAddedWithoutSignature(reqc) {
+			return createRemoveLabelPatchBytes(common.ResourceIntegrityLabelKey)
+		}
 		return nil
 	}
 
@@ -76,6 +81,26 @@ func generatePatchBytes(reqc *common.ReqContext, ctx *CheckContext) []byte {
 	return createJSONPatchBytes(name, string(reqJson), labels, annotations, deleteKeys)
 }
 
+// verifiedLabelAddedWithoutSignature returns true if the requested object has `verified` label which the existing object does not have.
+// The label is not checked as a mutation, so it can be added by an update which is allowed without signature.
+func verifiedLabelAddedWithoutSignature(reqc *common.ReqContext) bool {
+	if reqc.ClaimedMetadata == nil || reqc.ClaimedMetadata.Labels == nil || !reqc.ClaimedMetadata.Labels.IntegrityVerified() {
+		return false
+	}
+	if reqc.IsUpdateRequest() && reqc.OrgMetadata != nil && reqc.OrgMetadata.Labels != nil && reqc.OrgMetadata.Labels.IntegrityVerified() {
+		return false
+	}
+	return true
+}
+
+func createRemoveLabelPatchBytes(key string) []byte {
+	// "~" and "/" in the key must be escaped in JSON Pointer (RFC 6901)
+	escapedKey := strings.Replace(strings.Replace(key, "~", "~0", -1), "/", "~1", -1)
+	patch := []PatchOperation{{Op: "remove", Path: "/metadata/labels/" + escapedKey}}
+	patchBytes, _ := json.Marshal(patch)
+	return patchBytes
+}
+
 // Return value is a document of JSON Patch.
 // JSON Patch format is specified in RFC 6902 from the IETF.
 func createJSONPatchBytes(name, reqJson string, labels map[string]string, annotations map[string]string, deleteKeys []string) []byte {
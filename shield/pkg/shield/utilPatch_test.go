@@ -0,0 +1,85 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"encoding/json"
+	"testing"
+
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	admv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestPatchReqContext(operation admv1.Operation, labels, oldLabels map[string]string) *common.ReqContext {
+	cm := &v1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: "sample-cm", Namespace: "test-ns", Labels: labels},
+	}
+	dryRun := false
+	req := &admv1.AdmissionRequest{
+		DryRun:    &dryRun,
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+		Name:      "sample-cm",
+		Namespace: "test-ns",
+		Operation: operation,
+	}
+	req.Object.Raw, _ = json.Marshal(cm)
+	if operation == admv1.Update {
+		oldCm := cm.DeepCopy()
+		oldCm.SetLabels(oldLabels)
+		req.OldObject.Raw, _ = json.Marshal(oldCm)
+	}
+	return common.NewReqContext(req)
+}
+
+func TestGeneratePatchBytes(t *testing.T) {
+	verifiedLabel := map[string]string{common.ResourceIntegrityLabelKey: common.LabelValueVerified}
+	removePatch := `[{"op":"remove","path":"/metadata/labels/integrityshield.io~1resourceIntegrity"}]`
+	testCases := []struct {
+		name      string
+		reqc      *common.ReqContext
+		allow     bool
+		sigResult *common.SignatureEvalResult
+		expected  string
+	}{
+		{"label added on create", newTestPatchReqContext(admv1.Create, verifiedLabel, nil), true, &common.SignatureEvalResult{}, removePatch},
+		{"label added on update", newTestPatchReqContext(admv1.Update, verifiedLabel, nil), true, &common.SignatureEvalResult{}, removePatch},
+		// the label has been attached by a request with signature
+		{"label kept on update", newTestPatchReqContext(admv1.Update, verifiedLabel, verifiedLabel), true, &common.SignatureEvalResult{}, ""},
+		{"no label", newTestPatchReqContext(admv1.Create, nil, nil), true, &common.SignatureEvalResult{}, ""},
+		{"denied", newTestPatchReqContext(admv1.Create, verifiedLabel, nil), false, &common.SignatureEvalResult{}, ""},
+	}
+	for _, tc := range testCases {
+		ctx := &CheckContext{Allow: tc.allow, SignatureEvalResult: tc.sigResult}
+		actual := string(generatePatchBytes(tc.reqc, ctx))
+		if actual != tc.expected {
+			t.Errorf("Failed to test generatePatchBytes; [%s] expected %s, but got %s", tc.name, tc.expected, actual)
+		}
+	}
+
+	// the label is attached to a request with valid signature
+	ctx := &CheckContext{Allow: true, SignatureEvalResult: &common.SignatureEvalResult{Checked: true, Allow: true}}
+	var patch []PatchOperation
+	if err := json.Unmarshal(generatePatchBytes(newTestPatchReqContext(admv1.Create, nil, nil), ctx), &patch); err != nil {
+		t.Fatalf("Failed to test generatePatchBytes; %s", err.Error())
+	}
+	if len(patch) == 0 || patch[0].Path != "/metadata/labels" {
+		t.Errorf("Failed to test generatePatchBytes; the label must be added to a verified request, but got %v", patch)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
 
@@ -157,3 +158,21 @@ func ValidateHelmReleaseMetadata(reqc *common.ReqContext) (bool, error) {
 	}
 	return true, nil
 }
+
+// GetKeyConfigNames returns the names of keyConfig whose key is mounted, from key paths like "/<name>/<signatureType>/..."
+func GetKeyConfigNames(keyPathList []string, mountedOnly bool) []string {
+	names := []string{}
+	for _, keyPath := range keyPathList {
+		parts := strings.Split(strings.TrimPrefix(keyPath, "/"), "/")
+		if len(parts) == 0 || parts[0] == "" {
+			continue
+		}
+		if mountedOnly {
+			if _, err := os.Stat(keyPath); err != nil {
+				continue
+			}
+		}
+		names = common.GetUnionOfArrays(names, []string{parts[0]})
+	}
+	return names
+}
@@ -0,0 +1,315 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package validation checks the semantics of iShield specs shared by the server and the operator,
+// so that the operator does not depend on the server implementation.
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	rsp "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+/**********************************************
+
+				Semantic Validation
+
+***********************************************/
+
+var (
+	knownScopes     = []string{string(common.ScopeNamespaced), string(common.ScopeCluster)}
+	knownOperations = []string{"CREATE", "UPDATE", "DELETE", "CONNECT"}
+)
+
+// ValidateProfileSpec checks that the profile actually protects resources as intended.
+// `namespace` is the namespace of the profile.
+func ValidateProfileSpec(spec *rsp.ResourceSigningProfileSpec, namespace, shieldNamespace string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if spec.TargetNamespaceSelector != nil {
+		selectorPath := fldPath.Child("targetNamespaceSelector")
+		if namespace != shieldNamespace {
+			allErrs = append(allErrs, field.Forbidden(selectorPath, fmt.Sprintf("allowed only for %s in %s; this profile protects its own namespace", common.ProfileCustomResourceKind, shieldNamespace)))
+		} else {
+			allErrs = append(allErrs, ValidateNamespaceSelector(spec.TargetNamespaceSelector, selectorPath)...)
+		}
+	}
+
+	if !spec.Disabled && len(spec.ProtectRules) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("protectRules"), "profile without protect rules protects nothing"))
+	}
+	allErrs = append(allErrs, validateRules(spec.ProtectRules, fldPath.Child("protectRules"))...)
+	allErrs = append(allErrs, validateRules(spec.IgnoreRules, fldPath.Child("ignoreRules"))...)
+	allErrs = append(allErrs, validateRules(spec.ForceCheckRules, fldPath.Child("forceCheckRules"))...)
+
+	// an ignore rule which is identical to a protect rule makes the protect rule meaningless
+	for i, ignoreRule := range spec.IgnoreRules {
+		for j, protectRule := range spec.ProtectRules {
+			if ignoreRule != nil && protectRule != nil && reflect.DeepEqual(ignoreRule, protectRule) {
+				allErrs = append(allErrs, field.Invalid(fldPath.Child("ignoreRules").Index(i), ignoreRule.String(), fmt.Sprintf("identical to protectRules[%d], which never takes effect", j)))
+			}
+		}
+	}
+
+	for i, pattern := range spec.KustomizePatterns {
+		if pattern == nil {
+			continue
+		}
+		patternPath := fldPath.Child("kustomizePatterns").Index(i)
+		allErrs = append(allErrs, validateRequestPatterns(pattern.Match, patternPath.Child("match"))...)
+		if pattern.NamePrefix != nil {
+			allErrs = append(allErrs, validatePattern(string(*pattern.NamePrefix), patternPath.Child("namePrefix"))...)
+		}
+		if pattern.NameSuffix != nil {
+			allErrs = append(allErrs, validatePattern(string(*pattern.NameSuffix), patternPath.Child("nameSuffix"))...)
+		}
+	}
+	allErrs = append(allErrs, validateAttrsPatterns(spec.ProtectAttrs, fldPath.Child("protectAttrs"))...)
+	allErrs = append(allErrs, validateAttrsPatterns(spec.UnprotectAttrs, fldPath.Child("unprotectAttrs"))...)
+	allErrs = append(allErrs, validateAttrsPatterns(spec.IgnoreAttrs, fldPath.Child("ignoreAttrs"))...)
+
+	if spec.OwnerTrust != nil {
+		ownerTrustPath := fldPath.Child("ownerTrust")
+		if spec.OwnerTrust.MaxDepth < 0 {
+			allErrs = append(allErrs, field.Invalid(ownerTrustPath.Child("maxDepth"), spec.OwnerTrust.MaxDepth, "must not be negative"))
+		}
+		if len(spec.OwnerTrust.OwnerKinds) == 0 {
+			allErrs = append(allErrs, field.Required(ownerTrustPath.Child("ownerKinds"), "kinds of owners which can be followed must be specified"))
+		}
+		for i, kind := range spec.OwnerTrust.OwnerKinds {
+			if kind == "" {
+				allErrs = append(allErrs, field.Required(ownerTrustPath.Child("ownerKinds").Index(i), "empty kind"))
+			}
+		}
+		if len(spec.OwnerTrust.Users) == 0 {
+			allErrs = append(allErrs, field.Required(ownerTrustPath.Child("users"), "users who create resources from owners must be specified"))
+		}
+		for i, user := range spec.OwnerTrust.Users {
+			if user == "" {
+				allErrs = append(allErrs, field.Required(ownerTrustPath.Child("users").Index(i), "empty user"))
+			}
+		}
+	}
+	return allErrs
+}
+
+// ValidateSignerConfigSpec checks references between policies, signers and keys.
+// If `keyConfigNames` is nil, key names used by signers are not checked.
+func ValidateSignerConfigSpec(sc *common.SignerConfig, keyConfigNames []string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if sc == nil {
+		return append(allErrs, field.Required(fldPath, ""))
+	}
+
+	signersPath := fldPath.Child("signers")
+	if len(sc.Signers) == 0 {
+		allErrs = append(allErrs, field.Required(signersPath, "no signer is defined"))
+	}
+	signerNames := map[string]bool{}
+	// a signer can be declared with more than one keyConfig while its key is rotated
+	signerKeyConfigs := map[string]bool{}
+	for i, signer := range sc.Signers {
+		signerPath := signersPath.Index(i)
+		signerKeyConfig := fmt.Sprintf("%s/%s", signer.Name, signer.KeyConfig)
+		if signer.Name == "" {
+			allErrs = append(allErrs, field.Required(signerPath.Child("name"), ""))
+		} else if signerKeyConfigs[signerKeyConfig] {
+			allErrs = append(allErrs, field.Duplicate(signerPath.Child("name"), signer.Name))
+		}
+		signerNames[signer.Name] = true
+		signerKeyConfigs[signerKeyConfig] = true
+		if signer.KeyConfig == "" {
+			allErrs = append(allErrs, field.Required(signerPath.Child("keyConfig"), "signer without keyConfig never matches"))
+		} else if keyConfigNames != nil && !common.ExactMatchWithPatternArray(signer.KeyConfig, keyConfigNames) {
+			allErrs = append(allErrs, field.NotFound(signerPath.Child("keyConfig"), signer.KeyConfig))
+		}
+		if len(signer.Subjects) == 0 {
+			allErrs = append(allErrs, field.Required(signerPath.Child("subjects"), ""))
+		}
+	}
+
+	for i, policy := range sc.Policies {
+		policyPath := fldPath.Child("policies").Index(i)
+		allErrs = append(allErrs, validateScope(policy.Scope, policyPath.Child("scope"))...)
+		if policy.Scope != common.ScopeCluster && len(policy.Namespaces) == 0 {
+			allErrs = append(allErrs, field.Required(policyPath.Child("namespaces"), "namespaced policy without namespaces matches nothing"))
+		}
+		allErrs = append(allErrs, validatePatterns(policy.Namespaces, policyPath.Child("namespaces"))...)
+		allErrs = append(allErrs, validatePatterns(policy.ExcludeNamespaces, policyPath.Child("excludeNamespaces"))...)
+		if len(policy.Signers) == 0 {
+			allErrs = append(allErrs, field.Required(policyPath.Child("signers"), ""))
+		}
+		for j, signerName := range policy.Signers {
+			if !signerNames[signerName] {
+				allErrs = append(allErrs, field.NotFound(policyPath.Child("signers").Index(j), signerName))
+			}
+		}
+	}
+
+	for i, breakGlass := range sc.BreakGlass {
+		breakGlassPath := fldPath.Child("breakGlass").Index(i)
+		allErrs = append(allErrs, validateScope(breakGlass.Scope, breakGlassPath.Child("scope"))...)
+		allErrs = append(allErrs, validatePatterns(breakGlass.Namespaces, breakGlassPath.Child("namespaces"))...)
+	}
+	return allErrs
+}
+
+// ValidateNamespaceSelector checks that the selector selects some namespaces with valid patterns
+func ValidateNamespaceSelector(selector *common.NamespaceSelector, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if selector.LabelSelector == nil && len(selector.Include) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath, "either labelSelector or include is required to select any namespace"))
+	}
+	if selector.LabelSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(selector.LabelSelector); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("labelSelector"), selector.LabelSelector, err.Error()))
+		}
+	}
+	allErrs = append(allErrs, validatePatterns(selector.Include, fldPath.Child("include"))...)
+	allErrs = append(allErrs, validatePatterns(selector.Exclude, fldPath.Child("exclude"))...)
+	return allErrs
+}
+
+func validateRules(rules []*common.Rule, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, rule := range rules {
+		rulePath := fldPath.Index(i)
+		if rule == nil || len(rule.Match) == 0 {
+			allErrs = append(allErrs, field.Required(rulePath.Child("match"), "rule without match patterns matches nothing"))
+			continue
+		}
+		allErrs = append(allErrs, validateRequestPatterns(rule.Match, rulePath.Child("match"))...)
+		allErrs = append(allErrs, validateRequestPatterns(rule.Exclude, rulePath.Child("exclude"))...)
+	}
+	return allErrs
+}
+
+func validateAttrsPatterns(patterns []*common.AttrsPattern, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, pattern := range patterns {
+		if pattern == nil {
+			continue
+		}
+		patternPath := fldPath.Index(i)
+		if len(pattern.Attrs) == 0 {
+			allErrs = append(allErrs, field.Required(patternPath.Child("attrs"), ""))
+		}
+		allErrs = append(allErrs, validateRequestPatterns(pattern.Match, patternPath.Child("match"))...)
+	}
+	return allErrs
+}
+
+func validateRequestPatterns(patterns []*common.RequestPattern, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, p := range patterns {
+		patternPath := fldPath.Index(i)
+		if p == nil || reflect.DeepEqual(*p, common.RequestPattern{}) {
+			// a pattern matches a request only if at least one field is specified
+			allErrs = append(allErrs, field.Required(patternPath, "pattern without any field matches nothing"))
+			continue
+		}
+		fields := map[string]*common.RulePattern{
+			"scope":      p.Scope,
+			"apiGroup":   p.ApiGroup,
+			"apiVersion": p.ApiVersion,
+			"kind":       p.Kind,
+			"name":       p.Name,
+			"operation":  p.Operation,
+			"username":   p.UserName,
+			"usergroup":  p.UserGroup,
+		}
+		for name, value := range fields {
+			if value != nil {
+				allErrs = append(allErrs, validatePattern(string(*value), patternPath.Child(name))...)
+			}
+		}
+		if p.Scope != nil {
+			allErrs = append(allErrs, validatePatternValues(string(*p.Scope), knownScopes, patternPath.Child("scope"))...)
+		}
+		if p.Operation != nil {
+			allErrs = append(allErrs, validatePatternValues(string(*p.Operation), knownOperations, patternPath.Child("operation"))...)
+		}
+	}
+	// sort errors because fields are checked in random order of the map
+	sort.SliceStable(allErrs, func(i, j int) bool { return allErrs[i].Field < allErrs[j].Field })
+	return allErrs
+}
+
+func validatePatterns(patterns []string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, pattern := range patterns {
+		allErrs = append(allErrs, validatePattern(pattern, fldPath.Index(i))...)
+	}
+	return allErrs
+}
+
+// validatePattern checks that the pattern is supported by common.MatchPattern;
+// a wildcard is supported only at the end, and "," separates multiple patterns.
+func validatePattern(pattern string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for _, p := range splitPattern(pattern) {
+		if strings.Contains(p, ",") {
+			// common.MatchPattern does not split a pattern which ends with a wildcard
+			allErrs = append(allErrs, field.Invalid(fldPath, pattern, "comma-separated patterns cannot end with a wildcard; the whole value is matched as a single prefix"))
+		} else if p == "" && strings.Contains(pattern, ",") {
+			allErrs = append(allErrs, field.Invalid(fldPath, pattern, "empty pattern in comma-separated list"))
+		} else if idx := strings.Index(p, "*"); idx >= 0 && idx != len(p)-1 {
+			allErrs = append(allErrs, field.Invalid(fldPath, pattern, fmt.Sprintf("wildcard is supported only at the end of a pattern, but \"%s\" is specified", p)))
+		}
+	}
+	return allErrs
+}
+
+// splitPattern splits the pattern in the same way as common.MatchPattern
+func splitPattern(pattern string) []string {
+	pattern = strings.TrimSpace(pattern)
+	if strings.HasSuffix(pattern, "*") || !strings.Contains(pattern, ",") {
+		return []string{pattern}
+	}
+	return common.SplitRule(pattern)
+}
+
+// validatePatternValues checks that each pattern can match at least one of the known values
+func validatePatternValues(pattern string, knownValues []string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for _, p := range splitPattern(pattern) {
+		matched := false
+		for _, v := range knownValues {
+			if common.MatchPattern(p, v) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			allErrs = append(allErrs, field.NotSupported(fldPath, p, knownValues))
+		}
+	}
+	return allErrs
+}
+
+func validateScope(scope common.ScopeType, fldPath *field.Path) field.ErrorList {
+	if scope == common.ScopeUndefined || scope == common.ScopeNamespaced || scope == common.ScopeCluster {
+		return field.ErrorList{}
+	}
+	return field.ErrorList{field.NotSupported(fldPath, string(scope), knownScopes)}
+}
@@ -0,0 +1,136 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package validation
+
+import (
+	"testing"
+
+	"github.com/ghodss/yaml"
+
+	rsp "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func hasFieldError(errs field.ErrorList, errType field.ErrorType, fieldPath string) bool {
+	for _, err := range errs {
+		if err.Type == errType && err.Field == fieldPath {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidateProfileSpec(t *testing.T) {
+	specYaml := `
+targetNamespaceSelector:
+  include:
+  - "*-test"
+protectRules:
+- match:
+  - kind: ConfigMap
+    operation: "CREATE,PATCH"
+- match:
+  - {}
+- match:
+  - kind: "ConfigMap,Secret*"
+ignoreRules:
+- match:
+  - kind: ConfigMap
+    operation: "CREATE,PATCH"
+ownerTrust:
+  enabled: true
+  maxDepth: -1
+`
+	var spec *rsp.ResourceSigningProfileSpec
+	if err := yaml.Unmarshal([]byte(specYaml), &spec); err != nil {
+		t.Fatal(err)
+	}
+	errs := ValidateProfileSpec(spec, "ishield-ns", "ishield-ns", field.NewPath("spec"))
+	expected := map[string]field.ErrorType{
+		"spec.targetNamespaceSelector.include[0]": field.ErrorTypeInvalid,
+		"spec.protectRules[0].match[0].operation": field.ErrorTypeNotSupported,
+		"spec.protectRules[1].match[0]":           field.ErrorTypeRequired,
+		"spec.protectRules[2].match[0].kind":      field.ErrorTypeInvalid,
+		"spec.ignoreRules[0]":                     field.ErrorTypeInvalid,
+		"spec.ownerTrust.maxDepth":                field.ErrorTypeInvalid,
+		"spec.ownerTrust.ownerKinds":              field.ErrorTypeRequired,
+		"spec.ownerTrust.users":                   field.ErrorTypeRequired,
+	}
+	for fieldPath, errType := range expected {
+		if !hasFieldError(errs, errType, fieldPath) {
+			t.Errorf("expected %s error for %s, but got %v", errType, fieldPath, errs)
+		}
+	}
+
+	// targetNamespaceSelector is not allowed out of iShield namespace
+	errs = ValidateProfileSpec(spec, "app-ns", "ishield-ns", field.NewPath("spec"))
+	if !hasFieldError(errs, field.ErrorTypeForbidden, "spec.targetNamespaceSelector") {
+		t.Errorf("targetNamespaceSelector must be forbidden: %v", errs)
+	}
+
+	errs = ValidateProfileSpec(&rsp.ResourceSigningProfileSpec{}, "app-ns", "ishield-ns", field.NewPath("spec"))
+	if !hasFieldError(errs, field.ErrorTypeRequired, "spec.protectRules") {
+		t.Errorf("profile without protect rules must be rejected: %v", errs)
+	}
+}
+
+func TestValidateSignerConfigSpec(t *testing.T) {
+	scYaml := `
+policies:
+- namespaces:
+  - "*"
+  signers:
+  - SampleSigner
+  - UnknownSigner
+- scope: Global
+  signers:
+  - SampleSigner
+signers:
+- name: SampleSigner
+  keyConfig: sample-signer-keyconfig
+  subjects:
+  - email: "*"
+- name: SampleSigner
+  keyConfig: not-mounted-keyconfig
+- name: SampleSigner
+  keyConfig: sample-signer-keyconfig
+  subjects:
+  - email: "*"
+`
+	var sc *common.SignerConfig
+	if err := yaml.Unmarshal([]byte(scYaml), &sc); err != nil {
+		t.Fatal(err)
+	}
+	errs := ValidateSignerConfigSpec(sc, []string{"sample-signer-keyconfig"}, field.NewPath("spec", "config"))
+	expected := map[string]field.ErrorType{
+		"spec.config.policies[0].signers[1]": field.ErrorTypeNotFound,
+		"spec.config.policies[1].scope":      field.ErrorTypeNotSupported,
+		"spec.config.policies[1].namespaces": field.ErrorTypeRequired,
+		"spec.config.signers[2].name":        field.ErrorTypeDuplicate,
+		"spec.config.signers[1].keyConfig":   field.ErrorTypeNotFound,
+		"spec.config.signers[1].subjects":    field.ErrorTypeRequired,
+	}
+	for fieldPath, errType := range expected {
+		if !hasFieldError(errs, errType, fieldPath) {
+			t.Errorf("expected %s error for %s, but got %v", errType, fieldPath, errs)
+		}
+	}
+	if len(errs) != len(expected) {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
@@ -0,0 +1,136 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"bytes"
+	"encoding/json"
+
+	rsig "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesignature/v1alpha1"
+	rsp "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	sconf "github.com/IBM/integrity-enforcer/shield/pkg/apis/shieldconfig/v1alpha1"
+	sigconf "github.com/IBM/integrity-enforcer/shield/pkg/apis/signerconfig/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	config "github.com/IBM/integrity-enforcer/shield/pkg/shield/config"
+	validation "github.com/IBM/integrity-enforcer/shield/pkg/shield/validation"
+	admv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Validator serves the validating webhook for iShield custom resources.
+// Unlike the format validation in the mutating webhook, it rejects resources which are well-formed
+// but do not work as intended (e.g. a profile which protects nothing), with field errors.
+type Validator struct {
+	config *config.ShieldConfig
+	// names of keyConfig whose keys are mounted on the server; nil if not checked
+	keyConfigNames []string
+}
+
+func NewValidator(conf *config.ShieldConfig) *Validator {
+	return &Validator{
+		config:         conf,
+		keyConfigNames: GetKeyConfigNames(conf.KeyPathList, true),
+	}
+}
+
+func (self *Validator) Run(req *admv1.AdmissionRequest) *admv1.AdmissionResponse {
+	if req.Operation == admv1.Delete {
+		return &admv1.AdmissionResponse{Allowed: true}
+	}
+	allErrs := self.Validate(req.Kind.Kind, req.Namespace, req.Object.Raw)
+	if len(allErrs) == 0 {
+		return &admv1.AdmissionResponse{Allowed: true}
+	}
+	gk := schema.GroupKind{Group: req.Kind.Group, Kind: req.Kind.Kind}
+	status := errors.NewInvalid(gk, req.Name, allErrs).ErrStatus
+	return &admv1.AdmissionResponse{
+		Allowed: false,
+		Result:  &status,
+	}
+}
+
+// Validate returns field errors of the object; unknown kinds are not validated
+func (self *Validator) Validate(kind, namespace string, raw []byte) field.ErrorList {
+	specPath := field.NewPath("spec")
+	switch kind {
+	case common.ProfileCustomResourceKind:
+		var data *rsp.ResourceSigningProfile
+		if errs := decodeStrict(raw, &data); len(errs) > 0 {
+			return errs
+		}
+		return validation.ValidateProfileSpec(&data.Spec, namespace, self.config.Namespace, specPath)
+	case common.SignerConfigCustomResourceKind:
+		var data *sigconf.SignerConfig
+		if errs := decodeStrict(raw, &data); len(errs) > 0 {
+			return errs
+		}
+		return validation.ValidateSignerConfigSpec(data.Spec.Config, self.keyConfigNames, specPath.Child("config"))
+	case common.ShieldConfigCustomResourceKind:
+		var data *sconf.ShieldConfig
+		if errs := decodeStrict(raw, &data); len(errs) > 0 {
+			return errs
+		}
+		return validateShieldConfigSpec(data.Spec.ShieldConfig, specPath.Child("ShieldConfig"))
+	case common.SignatureCustomResourceKind:
+		var data *rsig.ResourceSignature
+		if errs := decodeStrict(raw, &data); len(errs) > 0 {
+			return errs
+		}
+		return validateResourceSignature(data)
+	}
+	return field.ErrorList{}
+}
+
+func decodeStrict(raw []byte, obj interface{}) field.ErrorList {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields() // Force errors if data has undefined fields
+	if err := dec.Decode(obj); err != nil {
+		return field.ErrorList{field.Invalid(field.NewPath(""), "", err.Error())}
+	}
+	return field.ErrorList{}
+}
+
+func validateShieldConfigSpec(conf *config.ShieldConfig, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if conf == nil {
+		return append(allErrs, field.Required(fldPath, ""))
+	}
+	if err := conf.Validate(); err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath, "", err.Error()))
+	}
+	if conf.InScopeNamespaceSelector != nil {
+		allErrs = append(allErrs, validation.ValidateNamespaceSelector(conf.InScopeNamespaceSelector, fldPath.Child("inScopeNamespaceSelector"))...)
+	}
+	return allErrs
+}
+
+func validateResourceSignature(data *rsig.ResourceSignature) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if len(data.Spec.Data) > 1 {
+		allErrs = append(allErrs, field.TooMany(field.NewPath("spec", "data"), len(data.Spec.Data), 1))
+	}
+	labels := data.GetLabels()
+	labelsPath := field.NewPath("metadata", "labels")
+	for _, key := range []string{common.ResSigLabelApiVer, common.ResSigLabelKind, common.ResSigLabelTime} {
+		if _, ok := labels[key]; !ok {
+			allErrs = append(allErrs, field.Required(labelsPath.Key(key), ""))
+		}
+	}
+	return allErrs
+}
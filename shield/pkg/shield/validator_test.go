@@ -0,0 +1,62 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"net/http"
+	"testing"
+
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	config "github.com/IBM/integrity-enforcer/shield/pkg/shield/config"
+	admv1 "k8s.io/api/admission/v1"
+)
+
+func TestValidator(t *testing.T) {
+	keyPathList := []string{"/sample-signer-keyconfig/pgp/pubring.gpg", "/sample-x509-keyconfig/x509/", "/sample-signer-keyconfig/pgp/pubring-new.gpg"}
+	if names := GetKeyConfigNames(keyPathList, false); len(names) != 2 || names[0] != "sample-signer-keyconfig" || names[1] != "sample-x509-keyconfig" {
+		t.Errorf("unexpected keyConfig names: %v", names)
+	}
+	if names := GetKeyConfigNames(keyPathList, true); len(names) != 0 {
+		t.Errorf("keys are not mounted in the test, but got %v", names)
+	}
+
+	validator := NewValidator(&config.ShieldConfig{Namespace: "ishield-ns"})
+	req := &admv1.AdmissionRequest{
+		Name:      "test-rsp",
+		Namespace: "app-ns",
+		Operation: admv1.Create,
+	}
+	req.Kind.Group = "apis.integrityshield.io"
+	req.Kind.Kind = common.ProfileCustomResourceKind
+	req.Object.Raw = []byte(`{"apiVersion":"apis.integrityshield.io/v1alpha1","kind":"ResourceSigningProfile","metadata":{"name":"test-rsp"},"spec":{"protectRules":[{"match":[{"kind":"ConfigMap"}]}]}}`)
+	if resp := validator.Run(req); !resp.Allowed {
+		t.Errorf("valid profile is rejected: %s", resp.Result.Message)
+	}
+
+	req.Object.Raw = []byte(`{"apiVersion":"apis.integrityshield.io/v1alpha1","kind":"ResourceSigningProfile","metadata":{"name":"test-rsp"},"spec":{"protectRules":[{"match":[{"kind":"*Map"}]}]}}`)
+	resp := validator.Run(req)
+	if resp.Allowed || resp.Result.Code != http.StatusUnprocessableEntity || resp.Result.Details == nil || len(resp.Result.Details.Causes) != 1 {
+		t.Errorf("invalid profile must be rejected with a field error: %v", resp.Result)
+	} else if resp.Result.Details.Causes[0].Field != "spec.protectRules[0].match[0].kind" {
+		t.Errorf("unexpected field of the error: %s", resp.Result.Details.Causes[0].Field)
+	}
+
+	req.Operation = admv1.Delete
+	if resp := validator.Run(req); !resp.Allowed {
+		t.Errorf("delete request must not be validated")
+	}
+}
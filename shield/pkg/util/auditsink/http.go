@@ -0,0 +1,232 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package auditsink
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	cloudEventsBatchContentType = "application/cloudevents-batch+json"
+	kafkaRESTContentType        = "application/vnd.kafka.json.v2+json"
+	// "type" attribute of CloudEvents
+	decisionEventType = "io.integrityshield.decision"
+)
+
+// time format of "timestamp" in decision records
+const recordTimeFormat = "2006-01-02T15:04:05.000Z"
+
+func newTLSConfig(conf *SinkConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: conf.InsecureSkipVerify} // NOSONAR
+	if conf.CAFile != "" {
+		caBytes, err := ioutil.ReadFile(filepath.Clean(conf.CAFile))
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("no certificate is found in %s", conf.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// httpWriter posts a batch of records in a single request; body and content type are made by encode
+type httpWriter struct {
+	url       string
+	tokenFile string
+	client    *http.Client
+	encode    func(records [][]byte) ([]byte, string, error)
+}
+
+func newHTTPWriter(conf *SinkConfig, url string, encode func(records [][]byte) ([]byte, string, error)) (*httpWriter, error) {
+	tlsConfig, err := newTLSConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Timeout:   time.Duration(conf.TimeoutSeconds) * time.Second,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+	return &httpWriter{url: url, tokenFile: conf.TokenFile, client: client, encode: encode}, nil
+}
+
+func (self *httpWriter) Write(records [][]byte) error {
+	body, contentType, err := self.encode(records)
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+	req, err := http.NewRequest(http.MethodPost, self.url, bytes.NewReader(body))
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+	req.Header.Set("Content-Type", contentType)
+	if self.tokenFile != "" {
+		// the token is read for each request so that a rotated token in the mounted Secret is used
+		token, err := ioutil.ReadFile(filepath.Clean(self.tokenFile))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := self.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("%s returned %s", self.url, resp.Status)
+	// client errors other than throttling are not retried
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return &PermanentError{Err: err}
+	}
+	return err
+}
+
+func (self *httpWriter) Close() error {
+	self.client.CloseIdleConnections()
+	return nil
+}
+
+/**********************************************
+
+				Webhook
+
+***********************************************/
+
+func newWebhookWriter(conf *SinkConfig) (Writer, error) {
+	return newHTTPWriter(conf, conf.URL, encodeJSONArray)
+}
+
+func encodeJSONArray(records [][]byte) ([]byte, string, error) {
+	body := append([]byte{'['}, bytes.Join(records, []byte{','})...)
+	body = append(body, ']')
+	return body, "application/json", nil
+}
+
+/**********************************************
+
+				CloudEvents
+
+***********************************************/
+
+// CloudEvent is an event in the structured mode of CloudEvents 1.0
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            string          `json:"time,omitempty"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+}
+
+func newCloudEventsWriter(conf *SinkConfig) (Writer, error) {
+	source := conf.Source
+	return newHTTPWriter(conf, conf.URL, func(records [][]byte) ([]byte, string, error) {
+		events := []*CloudEvent{}
+		for _, record := range records {
+			events = append(events, newCloudEvent(source, record))
+		}
+		body, err := json.Marshal(events)
+		return body, cloudEventsBatchContentType, err
+	})
+}
+
+// newCloudEvent makes an event of a record; the request UID is used as the event ID if available
+func newCloudEvent(source string, record []byte) *CloudEvent {
+	var fields struct {
+		RequestUID string `json:"request.uid"`
+		Namespace  string `json:"namespace"`
+		Name       string `json:"name"`
+		Kind       string `json:"kind"`
+		Timestamp  string `json:"timestamp"`
+	}
+	_ = json.Unmarshal(record, &fields)
+	event := &CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              fields.RequestUID,
+		Source:          source,
+		Type:            decisionEventType,
+		DataContentType: "application/json",
+		Data:            json.RawMessage(record),
+	}
+	if event.ID == "" {
+		event.ID = randomID()
+	}
+	if fields.Kind != "" {
+		if fields.Namespace != "" {
+			event.Subject = fmt.Sprintf("%s/%s/%s", fields.Kind, fields.Namespace, fields.Name)
+		} else {
+			event.Subject = fmt.Sprintf("%s/%s", fields.Kind, fields.Name)
+		}
+	}
+	if t, err := time.Parse(recordTimeFormat, fields.Timestamp); err == nil {
+		event.Time = t.UTC().Format(time.RFC3339Nano)
+	}
+	return event
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+/**********************************************
+
+				Kafka REST
+
+***********************************************/
+
+// records are produced through a Kafka REST proxy (v2 API), so that no Kafka client is embedded in the server.
+// The proxy must be deployed separately; this writer does not speak the Kafka protocol to brokers.
+func newKafkaRESTWriter(conf *SinkConfig) (Writer, error) {
+	url := fmt.Sprintf("%s/topics/%s", strings.TrimSuffix(conf.URL, "/"), conf.Topic)
+	return newHTTPWriter(conf, url, func(records [][]byte) ([]byte, string, error) {
+		type kafkaRecord struct {
+			Value json.RawMessage `json:"value"`
+		}
+		body := struct {
+			Records []kafkaRecord `json:"records"`
+		}{}
+		for _, record := range records {
+			body.Records = append(body.Records, kafkaRecord{Value: json.RawMessage(record)})
+		}
+		bodyBytes, err := json.Marshal(body)
+		return bodyBytes, kafkaRESTContentType, err
+	})
+}
@@ -0,0 +1,408 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package auditsink
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	log "github.com/sirupsen/logrus"
+)
+
+type SinkType string
+
+const (
+	// JSON array of records is posted to the URL
+	SinkTypeWebhook SinkType = "webhook"
+	// records are posted to the URL as a batch of CloudEvents in structured mode
+	SinkTypeCloudEvents SinkType = "cloudevents"
+	// RFC 5424 messages are sent to "udp://host:port", "tcp://host:port" or "tls://host:port"
+	SinkTypeSyslog SinkType = "syslog"
+	// records are produced to the topic through a Kafka REST proxy (e.g. Confluent REST Proxy v2 API) at the URL;
+	// the server does not connect to Kafka brokers directly
+	SinkTypeKafkaREST SinkType = "kafka-rest"
+)
+
+const (
+	defaultBufferSize           = 1000
+	defaultBatchSize            = 100
+	defaultFlushIntervalSeconds = 1
+	defaultMaxRetries           = 3
+	defaultTimeoutSeconds       = 10
+)
+
+// interval before the first retry; it is doubled for each retry
+var retryBaseInterval = 500 * time.Millisecond
+
+// SinkConfig is a destination of decision records, which is defined in `log.auditSinks` of ShieldConfig
+type SinkConfig struct {
+	Name string   `json:"name"`
+	Type SinkType `json:"type"`
+	URL  string   `json:"url"`
+	// Kafka topic for kafka-rest
+	Topic string `json:"topic,omitempty"`
+	// "source" attribute of CloudEvents; default is "integrity-shield"
+	Source string `json:"source,omitempty"`
+	// file of a bearer token sent in Authorization header, e.g. mounted from a Secret
+	TokenFile          string `json:"tokenFile,omitempty"`
+	CAFile             string `json:"caFile,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+	// records are buffered up to this size; default is 1000
+	BufferSize int `json:"bufferSize,omitempty"`
+	// records are sent when the batch is full or at the flush interval; defaults are 100 and 1
+	BatchSize            int `json:"batchSize,omitempty"`
+	FlushIntervalSeconds int `json:"flushIntervalSeconds,omitempty"`
+	// a batch is dropped when it fails after the retries; default is 3
+	MaxRetries     int `json:"maxRetries,omitempty"`
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+	// when the buffer is full, a request waits for this duration before its record is dropped; default is 0 (dropped immediately)
+	BlockTimeoutMillis int `json:"blockTimeoutMillis,omitempty"`
+}
+
+func (self *SinkConfig) Validate() error {
+	if self.Name == "" {
+		return fmt.Errorf("name must be specified")
+	}
+	if self.URL == "" {
+		return fmt.Errorf("url must be specified")
+	}
+	if _, ok := writerFactories[self.Type]; !ok {
+		return fmt.Errorf("type \"%s\" is not supported", self.Type)
+	}
+	if self.Type == SinkTypeKafkaREST && self.Topic == "" {
+		return fmt.Errorf("topic must be specified for kafka-rest")
+	}
+	if self.BufferSize < 0 || self.BatchSize < 0 || self.FlushIntervalSeconds < 0 || self.MaxRetries < 0 || self.TimeoutSeconds < 0 || self.BlockTimeoutMillis < 0 {
+		return fmt.Errorf("sizes, intervals and retries must not be negative")
+	}
+	return nil
+}
+
+func (self *SinkConfig) withDefaults() *SinkConfig {
+	conf := *self
+	if conf.BufferSize == 0 {
+		conf.BufferSize = defaultBufferSize
+	}
+	if conf.BatchSize == 0 {
+		conf.BatchSize = defaultBatchSize
+	}
+	if conf.FlushIntervalSeconds == 0 {
+		conf.FlushIntervalSeconds = defaultFlushIntervalSeconds
+	}
+	if conf.MaxRetries == 0 {
+		conf.MaxRetries = defaultMaxRetries
+	}
+	if conf.TimeoutSeconds == 0 {
+		conf.TimeoutSeconds = defaultTimeoutSeconds
+	}
+	if conf.Source == "" {
+		conf.Source = "integrity-shield"
+	}
+	return &conf
+}
+
+/**********************************************
+
+				Writer
+
+***********************************************/
+
+// Writer sends a batch of records (JSON objects) to a destination
+type Writer interface {
+	Write(records [][]byte) error
+	Close() error
+}
+
+// PermanentError is returned by Writer when retries will not succeed, e.g. the request is rejected
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+type WriterFactory func(conf *SinkConfig) (Writer, error)
+
+var writerFactories = map[SinkType]WriterFactory{
+	SinkTypeWebhook:     newWebhookWriter,
+	SinkTypeCloudEvents: newCloudEventsWriter,
+	SinkTypeSyslog:      newSyslogWriter,
+	SinkTypeKafkaREST:   newKafkaRESTWriter,
+}
+
+// RegisterWriter adds a type of sinks, or replaces the writer of the type
+func RegisterWriter(sinkType SinkType, factory WriterFactory) {
+	writerFactories[sinkType] = factory
+}
+
+/**********************************************
+
+				Sink
+
+***********************************************/
+
+type SinkStats struct {
+	Sent    uint64
+	Dropped uint64
+	Failed  uint64
+}
+
+// Sink buffers records and sends them in batches with retries in background.
+// Requests are not blocked by slow destinations; records are dropped when the buffer is full.
+type Sink struct {
+	// counters are accessed atomically, and placed first for 64-bit alignment
+	sent    uint64
+	dropped uint64
+	failed  uint64
+
+	config *SinkConfig
+	writer Writer
+	queue  chan []byte
+	done   chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func NewSink(conf *SinkConfig) (*Sink, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	conf = conf.withDefaults()
+	writer, err := writerFactories[conf.Type](conf)
+	if err != nil {
+		return nil, err
+	}
+	return newSinkWithWriter(conf, writer), nil
+}
+
+func newSinkWithWriter(conf *SinkConfig, writer Writer) *Sink {
+	s := &Sink{
+		config: conf,
+		writer: writer,
+		queue:  make(chan []byte, conf.BufferSize),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Send adds a record to the buffer
+func (self *Sink) Send(record []byte) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	if self.closed {
+		return
+	}
+	select {
+	case self.queue <- record:
+		return
+	default:
+	}
+	if self.config.BlockTimeoutMillis > 0 {
+		timer := time.NewTimer(time.Duration(self.config.BlockTimeoutMillis) * time.Millisecond)
+		defer timer.Stop()
+		select {
+		case self.queue <- record:
+			return
+		case <-timer.C:
+		}
+	}
+	// warn once per buffer size so that logs are not flooded while the destination is down
+	if dropped := atomic.AddUint64(&self.dropped, 1); (dropped-1)%uint64(self.config.BufferSize) == 0 {
+		logger.WithFields(log.Fields{
+			"sink":    self.config.Name,
+			"dropped": dropped,
+		}).Warn("Audit sink buffer is full; records are dropped")
+	}
+}
+
+// Close sends the buffered records and stops the sink
+func (self *Sink) Close() {
+	self.mu.Lock()
+	if self.closed {
+		self.mu.Unlock()
+		return
+	}
+	self.closed = true
+	close(self.queue)
+	self.mu.Unlock()
+	<-self.done
+}
+
+func (self *Sink) Stats() SinkStats {
+	return SinkStats{
+		Sent:    atomic.LoadUint64(&self.sent),
+		Dropped: atomic.LoadUint64(&self.dropped),
+		Failed:  atomic.LoadUint64(&self.failed),
+	}
+}
+
+func (self *Sink) run() {
+	defer close(self.done)
+	ticker := time.NewTicker(time.Duration(self.config.FlushIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+	batch := [][]byte{}
+	for {
+		select {
+		case record, ok := <-self.queue:
+			if !ok {
+				self.flush(batch)
+				if err := self.writer.Close(); err != nil {
+					logger.WithFields(log.Fields{"sink": self.config.Name, "err": err}).Warn("Failed to close audit sink")
+				}
+				return
+			}
+			batch = append(batch, record)
+			if len(batch) >= self.config.BatchSize {
+				self.flush(batch)
+				batch = [][]byte{}
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				self.flush(batch)
+				batch = [][]byte{}
+			}
+		}
+	}
+}
+
+func (self *Sink) flush(batch [][]byte) {
+	if len(batch) == 0 {
+		return
+	}
+	var err error
+	interval := retryBaseInterval
+	for i := 0; i <= self.config.MaxRetries; i++ {
+		if i > 0 {
+			time.Sleep(interval)
+			interval *= 2
+		}
+		if err = self.writer.Write(batch); err == nil {
+			atomic.AddUint64(&self.sent, uint64(len(batch)))
+			return
+		}
+		if _, ok := err.(*PermanentError); ok {
+			break
+		}
+	}
+	atomic.AddUint64(&self.failed, uint64(len(batch)))
+	logger.WithFields(log.Fields{
+		"sink":    self.config.Name,
+		"records": len(batch),
+		"err":     err,
+	}).Error("Failed to send records to audit sink")
+}
+
+/**********************************************
+
+				Manager
+
+***********************************************/
+
+// Manager keeps sinks of the current ShieldConfig; sinks are recreated when the config is changed
+type Manager struct {
+	mu      sync.RWMutex
+	configs []*SinkConfig
+	sinks   []*Sink
+}
+
+var defaultManager = &Manager{}
+
+// Update replaces the sinks of the default manager if the configs are changed
+func Update(configs []*SinkConfig) {
+	defaultManager.Update(configs)
+}
+
+// Send adds a record to all sinks of the default manager
+func Send(record []byte) {
+	defaultManager.Send(record)
+}
+
+// Close sends the buffered records of the default manager and stops the sinks
+func Close() {
+	defaultManager.Close()
+}
+
+func (self *Manager) Update(configs []*SinkConfig) {
+	self.mu.RLock()
+	unchanged := reflect.DeepEqual(self.configs, configs)
+	self.mu.RUnlock()
+	if unchanged {
+		return
+	}
+
+	self.mu.Lock()
+	if reflect.DeepEqual(self.configs, configs) {
+		self.mu.Unlock()
+		return
+	}
+	oldSinks := self.sinks
+	self.sinks = []*Sink{}
+	self.configs = make([]*SinkConfig, len(configs))
+	for i, conf := range configs {
+		if conf == nil {
+			continue
+		}
+		c := *conf
+		self.configs[i] = &c
+		s, err := NewSink(conf)
+		if err != nil {
+			logger.WithFields(log.Fields{"sink": conf.Name, "err": err}).Error("Failed to initialize audit sink")
+			continue
+		}
+		self.sinks = append(self.sinks, s)
+	}
+	self.mu.Unlock()
+
+	// old sinks send the buffered records in background so that requests are not blocked
+	for _, s := range oldSinks {
+		go s.Close()
+	}
+}
+
+// Close sends the buffered records and stops all sinks; records sent after this are dropped
+func (self *Manager) Close() {
+	self.mu.Lock()
+	sinks := self.sinks
+	self.sinks = []*Sink{}
+	self.configs = nil
+	self.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, s := range sinks {
+		wg.Add(1)
+		go func(s *Sink) {
+			defer wg.Done()
+			s.Close()
+		}(s)
+	}
+	wg.Wait()
+}
+
+func (self *Manager) Send(record []byte) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	for _, s := range self.sinks {
+		s.Send(record)
+	}
+}
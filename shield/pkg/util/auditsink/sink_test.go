@@ -0,0 +1,266 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package auditsink
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testRecords = [][]byte{
+	[]byte(`{"request.uid":"uid-1","namespace":"test-ns","name":"cm1","kind":"ConfigMap","allowed":false,"reasonCode":"no-signature","timestamp":"2020-10-01T00:00:00.000Z"}`),
+	[]byte(`{"request.uid":"uid-2","namespace":"test-ns","name":"cm2","kind":"ConfigMap","allowed":true,"reasonCode":"valid-sig","timestamp":"2020-10-01T00:00:01.000Z"}`),
+}
+
+func init() {
+	retryBaseInterval = 10 * time.Millisecond
+}
+
+// testServer is a stand-in of an HTTP destination; it fails the first `failures` requests
+type testServer struct {
+	mu          sync.Mutex
+	failures    int
+	requests    int
+	contentType string
+	auth        string
+	bodies      [][]byte
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if s.requests <= s.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body, _ := ioutil.ReadAll(req.Body)
+	s.bodies = append(s.bodies, body)
+	s.contentType = req.Header.Get("Content-Type")
+	s.auth = req.Header.Get("Authorization")
+}
+
+func sendTestRecords(t *testing.T, conf *SinkConfig) *Sink {
+	sink, err := NewSink(conf)
+	if err != nil {
+		t.Fatalf("Failed to test NewSink(); %s", err.Error())
+	}
+	for _, record := range testRecords {
+		sink.Send(record)
+	}
+	sink.Close()
+	return sink
+}
+
+func TestWebhookSink(t *testing.T) {
+	server := &testServer{failures: 1}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	tokenFile, _ := ioutil.TempFile("", "token")
+	defer tokenFile.Close()
+	_, _ = tokenFile.WriteString("test-token\n")
+
+	sink := sendTestRecords(t, &SinkConfig{Name: "webhook", Type: SinkTypeWebhook, URL: ts.URL, TokenFile: tokenFile.Name()})
+	var records []map[string]interface{}
+	if len(server.bodies) != 1 || json.Unmarshal(server.bodies[0], &records) != nil || len(records) != 2 {
+		t.Errorf("Failed to test webhook sink; records must be sent in a batch: %v", server.bodies)
+	}
+	if server.requests != 2 || sink.Stats().Sent != 2 {
+		t.Errorf("Failed to test webhook sink; the failed request must be retried: %d requests, %v", server.requests, sink.Stats())
+	}
+	if server.auth != "Bearer test-token" {
+		t.Errorf("Failed to test webhook sink; unexpected Authorization header: %s", server.auth)
+	}
+}
+
+func TestCloudEventsSink(t *testing.T) {
+	server := &testServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	sendTestRecords(t, &SinkConfig{Name: "ce", Type: SinkTypeCloudEvents, URL: ts.URL})
+	var events []*CloudEvent
+	if len(server.bodies) != 1 || json.Unmarshal(server.bodies[0], &events) != nil || len(events) != 2 {
+		t.Fatalf("Failed to test cloudevents sink; unexpected body %v", server.bodies)
+	}
+	if server.contentType != cloudEventsBatchContentType {
+		t.Errorf("Failed to test cloudevents sink; unexpected content type %s", server.contentType)
+	}
+	e := events[0]
+	if e.SpecVersion != "1.0" || e.ID != "uid-1" || e.Source != "integrity-shield" || e.Type != decisionEventType || e.Subject != "ConfigMap/test-ns/cm1" || e.Time != "2020-10-01T00:00:00Z" {
+		t.Errorf("Failed to test cloudevents sink; unexpected event %v", e)
+	}
+}
+
+func TestKafkaRESTSink(t *testing.T) {
+	server := &testServer{}
+	mux := http.NewServeMux()
+	mux.Handle("/topics/decisions", server)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	if _, err := NewSink(&SinkConfig{Name: "kafka", Type: SinkTypeKafkaREST, URL: ts.URL}); err == nil {
+		t.Errorf("Failed to test kafka-rest sink; topic must be required")
+	}
+	sendTestRecords(t, &SinkConfig{Name: "kafka", Type: SinkTypeKafkaREST, URL: ts.URL + "/", Topic: "decisions"})
+	var body struct {
+		Records []struct {
+			Value map[string]interface{} `json:"value"`
+		} `json:"records"`
+	}
+	if len(server.bodies) != 1 || json.Unmarshal(server.bodies[0], &body) != nil || len(body.Records) != 2 || body.Records[1].Value["name"] != "cm2" {
+		t.Errorf("Failed to test kafka-rest sink; unexpected body %v", server.bodies)
+	}
+	if server.contentType != kafkaRESTContentType {
+		t.Errorf("Failed to test kafka-rest sink; unexpected content type %s", server.contentType)
+	}
+}
+
+func TestSyslogSink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		// read messages framed by octet counting
+		reader := bufio.NewReader(conn)
+		msgs := []string{}
+		for len(msgs) < len(testRecords) {
+			lenStr, err := reader.ReadString(' ')
+			if err != nil {
+				break
+			}
+			msgLen, _ := strconv.Atoi(strings.TrimSpace(lenStr))
+			msg := make([]byte, msgLen)
+			if _, err := io.ReadFull(reader, msg); err != nil {
+				break
+			}
+			msgs = append(msgs, string(msg))
+		}
+		received <- msgs
+	}()
+
+	sendTestRecords(t, &SinkConfig{Name: "syslog", Type: SinkTypeSyslog, URL: fmt.Sprintf("tcp://%s", ln.Addr().String())})
+	msgs := <-received
+	if len(msgs) != 2 {
+		t.Fatalf("Failed to test syslog sink; unexpected messages %v", msgs)
+	}
+	// local0.warning for the denied request, and local0.notice for the allowed one
+	if !strings.HasPrefix(msgs[0], "<132>1 2020-10-01T00:00:00Z ") || !strings.HasPrefix(msgs[1], "<133>1 ") || !strings.HasSuffix(msgs[0], string(testRecords[0])) {
+		t.Errorf("Failed to test syslog sink; unexpected messages %v", msgs)
+	}
+}
+
+// blockingWriter blocks until it is released, so that records are buffered
+type blockingWriter struct {
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(records [][]byte) error {
+	<-w.release
+	return nil
+}
+
+func (w *blockingWriter) Close() error {
+	return nil
+}
+
+func TestSinkBackpressure(t *testing.T) {
+	writer := &blockingWriter{release: make(chan struct{})}
+	conf := (&SinkConfig{Name: "blocking", BufferSize: 2, BatchSize: 1}).withDefaults()
+	sink := newSinkWithWriter(conf, writer)
+	// one record is taken by the writer, two are buffered, and the rest are dropped
+	for i := 0; i < 10; i++ {
+		sink.Send(testRecords[0])
+		if i == 0 {
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	close(writer.release)
+	sink.Close()
+	stats := sink.Stats()
+	if stats.Sent != 3 || stats.Dropped != 7 {
+		t.Errorf("Failed to test Sink; records over the buffer must be dropped: %v", stats)
+	}
+}
+
+func TestManagerUpdate(t *testing.T) {
+	server := &testServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	manager := &Manager{}
+	configs := []*SinkConfig{{Name: "webhook", Type: SinkTypeWebhook, URL: ts.URL}, {Name: "invalid", Type: "unknown", URL: ts.URL}}
+	manager.Update(configs)
+	if len(manager.sinks) != 1 {
+		t.Errorf("Failed to test Manager; invalid sinks must be skipped: %d sinks", len(manager.sinks))
+	}
+	sink := manager.sinks[0]
+	manager.Update([]*SinkConfig{{Name: "webhook", Type: SinkTypeWebhook, URL: ts.URL}, {Name: "invalid", Type: "unknown", URL: ts.URL}})
+	if manager.sinks[0] != sink {
+		t.Errorf("Failed to test Manager; sinks must be kept if configs are not changed")
+	}
+	manager.Send(testRecords[0])
+	manager.Update(nil)
+	if len(manager.sinks) != 0 {
+		t.Errorf("Failed to test Manager; sinks must be removed")
+	}
+	// the old sink sends the buffered record in background
+	time.Sleep(100 * time.Millisecond)
+	if sink.Stats().Sent != 1 {
+		t.Errorf("Failed to test Manager; buffered records must be sent on update: %v", sink.Stats())
+	}
+}
+
+func TestManagerClose(t *testing.T) {
+	server := &testServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	manager := &Manager{}
+	manager.Update([]*SinkConfig{{Name: "webhook", Type: SinkTypeWebhook, URL: ts.URL, FlushIntervalSeconds: 60}})
+	sink := manager.sinks[0]
+	manager.Send(testRecords[0])
+	manager.Close()
+	// buffered records are sent before Close returns, not at the flush interval
+	if sink.Stats().Sent != 1 {
+		t.Errorf("Failed to test Manager; buffered records must be sent on close: %v", sink.Stats())
+	}
+	if len(manager.sinks) != 0 {
+		t.Errorf("Failed to test Manager; sinks must be removed on close")
+	}
+}
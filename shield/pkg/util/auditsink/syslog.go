@@ -0,0 +1,138 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package auditsink
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	// facility local0
+	syslogFacility        = 16
+	syslogSeverityWarning = 4
+	syslogSeverityNotice  = 5
+	syslogAppName         = "integrity-shield"
+	syslogMsgID           = "decision"
+)
+
+// syslogWriter sends RFC 5424 messages; messages over TCP and TLS are framed by octet counting (RFC 6587)
+type syslogWriter struct {
+	network   string
+	address   string
+	tlsConfig *tls.Config
+	timeout   time.Duration
+	hostname  string
+
+	conn net.Conn
+}
+
+func newSyslogWriter(conf *SinkConfig) (Writer, error) {
+	u, err := url.Parse(conf.URL)
+	if err != nil {
+		return nil, err
+	}
+	w := &syslogWriter{
+		network: u.Scheme,
+		address: u.Host,
+		timeout: time.Duration(conf.TimeoutSeconds) * time.Second,
+	}
+	switch u.Scheme {
+	case "udp", "tcp":
+	case "tls":
+		if w.tlsConfig, err = newTLSConfig(conf); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("scheme of syslog url must be udp, tcp or tls, but \"%s\" is specified", u.Scheme)
+	}
+	if w.hostname, err = os.Hostname(); err != nil || w.hostname == "" {
+		w.hostname = "-"
+	}
+	return w, nil
+}
+
+func (self *syslogWriter) connect() error {
+	if self.conn != nil {
+		return nil
+	}
+	dialer := &net.Dialer{Timeout: self.timeout}
+	var conn net.Conn
+	var err error
+	if self.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", self.address, self.tlsConfig)
+	} else {
+		conn, err = dialer.Dial(self.network, self.address)
+	}
+	if err != nil {
+		return err
+	}
+	self.conn = conn
+	return nil
+}
+
+func (self *syslogWriter) Write(records [][]byte) error {
+	if err := self.connect(); err != nil {
+		return err
+	}
+	_ = self.conn.SetWriteDeadline(time.Now().Add(self.timeout))
+	for _, record := range records {
+		msg := formatSyslogMessage(self.hostname, record)
+		if self.network != "udp" {
+			msg = fmt.Sprintf("%d %s", len(msg), msg)
+		}
+		if _, err := self.conn.Write([]byte(msg)); err != nil {
+			// reconnect on the retry; records in the batch may be sent twice
+			_ = self.conn.Close()
+			self.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (self *syslogWriter) Close() error {
+	if self.conn == nil {
+		return nil
+	}
+	err := self.conn.Close()
+	self.conn = nil
+	return err
+}
+
+// formatSyslogMessage makes an RFC 5424 message whose MSG is the record; denied requests have warning severity
+func formatSyslogMessage(hostname string, record []byte) string {
+	var fields struct {
+		Allowed   bool   `json:"allowed"`
+		Timestamp string `json:"timestamp"`
+	}
+	_ = json.Unmarshal(record, &fields)
+	severity := syslogSeverityNotice
+	if !fields.Allowed {
+		severity = syslogSeverityWarning
+	}
+	ts := time.Now().UTC()
+	if t, err := time.Parse(recordTimeFormat, fields.Timestamp); err == nil {
+		ts = t
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s - %s", syslogFacility*8+severity, ts.Format(time.RFC3339Nano), hostname, syslogAppName, os.Getpid(), syslogMsgID, string(record))
+}
@@ -109,6 +109,12 @@ func (self *Cache) Unset(name string) {
 	self.mu.Unlock()
 }
 
+func (self *Cache) Clear() {
+	self.mu.Lock()
+	self.data = make(map[string]*CachedObject)
+	self.mu.Unlock()
+}
+
 func (self *Cache) Get(name string) interface{} {
 	self.mu.RLock()
 	now := time.Now()
@@ -151,6 +157,11 @@ func Unset(name string) {
 	cache.Unset(name)
 }
 
+// Clear removes all cached objects
+func Clear() {
+	cache.Clear()
+}
+
 func SetString(name string, object string, ttl *time.Duration) {
 	cache.Set(name, object, ttl)
 }
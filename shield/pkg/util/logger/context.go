@@ -17,8 +17,11 @@
 package logger
 
 import (
+	"compress/gzip"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -27,43 +30,93 @@ type ContextLoggerConfig struct {
 	Enabled   bool
 	File      string
 	LimitSize int64
+	// number of compressed files kept on rotation, e.g. "events.txt.1.gz" is the newest one
+	Generations int
 }
 
 type ContextLogger struct {
-	enabled   bool
-	file      string
-	limitSize int64
+	enabled     bool
+	file        string
+	limitSize   int64
+	generations int
 }
 
 func InitContextLogger(config ContextLoggerConfig) *ContextLogger {
 	contextLogger := &ContextLogger{
-		enabled:   config.Enabled,
-		file:      config.File,
-		limitSize: config.LimitSize,
+		enabled:     config.Enabled,
+		file:        config.File,
+		limitSize:   config.LimitSize,
+		generations: config.Generations,
 	}
 	return contextLogger
 }
 
 func (cxLogger *ContextLogger) sizeCheckAndRotate() error {
-	f, err := os.OpenFile(cxLogger.file, os.O_CREATE|os.O_WRONLY, 0640) // NOSONAR
+	fi, err := os.Stat(cxLogger.file)
 	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
 		return err
 	}
-	defer func() {
-		_ = f.Close()
-	}()
+	if fi.Size() > cxLogger.limitSize {
+		return cxLogger.rotate()
+	}
+	return nil
+}
 
-	fi, err := f.Stat()
-	if err != nil {
-		return err
+func (cxLogger *ContextLogger) generationFile(generation int) string {
+	return fmt.Sprintf("%s.%d.gz", cxLogger.file, generation)
+}
+
+// rotate compresses the current file into the newest generation, and removes the oldest one
+func (cxLogger *ContextLogger) rotate() error {
+	if cxLogger.generations <= 0 {
+		return os.Remove(cxLogger.file)
 	}
-	if fi.Size() > cxLogger.limitSize {
-		err := os.Remove(cxLogger.file)
+	for i := cxLogger.generations; i >= 1; i-- {
+		src := cxLogger.generationFile(i)
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		var err error
+		if i == cxLogger.generations {
+			err = os.Remove(src)
+		} else {
+			err = os.Rename(src, cxLogger.generationFile(i+1))
+		}
 		if err != nil {
 			return err
 		}
 	}
-	return nil
+	if err := compressFile(cxLogger.file, cxLogger.generationFile(1)); err != nil {
+		return err
+	}
+	return os.Remove(cxLogger.file)
+}
+
+func compressFile(src, dst string) error {
+	in, err := os.Open(filepath.Clean(src))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0640) // NOSONAR
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
 }
 
 func (cxLogger *ContextLogger) writeToFile(logBytes []byte) error {
@@ -93,6 +93,18 @@ func NewLogger(conf LoggerConfig) *log.Logger {
 	return logger
 }
 
+// NewRequestLogger returns a logger which shares the output and the formatter with the base logger.
+// The level of the returned logger can be changed for each request without affecting the base logger or other requests.
+func NewRequestLogger(base *log.Logger) *log.Logger {
+	logger := log.New()
+	logger.Out = base.Out
+	logger.Formatter = base.Formatter
+	logger.Hooks = base.Hooks
+	logger.ReportCaller = base.ReportCaller
+	logger.SetLevel(base.GetLevel())
+	return logger
+}
+
 func GetGreaterLevel(lvStr1, lvStr2 string) string {
 	// "error" is the minimum level without fatal crash, so this function returns it in case of no custom level
 	if lvStr1 == "" {
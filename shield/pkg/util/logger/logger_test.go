@@ -17,7 +17,14 @@
 package logger
 
 import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	log "github.com/sirupsen/logrus"
 )
 
 var logConfig LoggerConfig
@@ -46,3 +53,48 @@ func TestLogger(t *testing.T) {
 	ctxLogger.sizeCheckAndRotate()
 
 }
+
+func TestRequestLogger(t *testing.T) {
+	base := NewLogger(logConfig)
+	reqLogger := NewRequestLogger(base)
+	reqLogger.SetLevel(log.TraceLevel)
+	if base.GetLevel() != log.InfoLevel {
+		t.Errorf("base logger level should not be changed by request logger; actual: %s", base.GetLevel().String())
+	}
+	if reqLogger.Out != base.Out {
+		t.Errorf("request logger should share the output with base logger")
+	}
+}
+
+func TestContextLogRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ctxlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "events.txt")
+	ctxLogger := InitContextLogger(ContextLoggerConfig{Enabled: true, File: file, LimitSize: 10, Generations: 2})
+	for _, msg := range []string{"first log line", "second log line", "third log line", "fourth log line"} {
+		ctxLogger.SendLog([]byte(msg))
+	}
+
+	if _, err := os.Stat(file + ".3.gz"); !os.IsNotExist(err) {
+		t.Errorf("generations older than the limit must be removed")
+	}
+	f, err := os.Open(file + ".1.gz")
+	if err != nil {
+		t.Fatalf("the newest generation must be kept; %s", err.Error())
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := ioutil.ReadAll(zr)
+	if strings.TrimSpace(string(content)) != "third log line" {
+		t.Errorf("unexpected content of the newest generation: %s", string(content))
+	}
+	if current, _ := ioutil.ReadFile(file); strings.TrimSpace(string(current)) != "fourth log line" {
+		t.Errorf("unexpected content of the current file: %s", string(current))
+	}
+}
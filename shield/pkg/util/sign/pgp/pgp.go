@@ -67,6 +67,21 @@ func GetFirstIdentity(signer *openpgp.Entity) *openpgp.Identity {
 }
 
 func VerifySignature(keyPath string, msg, sig string) (bool, string, *Signer, []byte, error) {
+	if msg == "" {
+		return false, "Message to be verified is empty", nil, nil, nil
+	}
+	if sig == "" {
+		return false, "Signature to be verified is empty", nil, nil, nil
+	}
+	keyRing, err := LoadKeyRing(keyPath)
+	if err != nil {
+		return false, "Error when loading key ring", nil, nil, err
+	}
+	return VerifySignatureWithKeyRing(keyRing, msg, sig)
+}
+
+// VerifySignatureWithKeyRing verifies the signature with a keyring which is already loaded
+func VerifySignatureWithKeyRing(keyRing openpgp.EntityList, msg, sig string) (bool, string, *Signer, []byte, error) {
 	if msg == "" {
 		return false, "Message to be verified is empty", nil, nil, nil
 	}
@@ -76,9 +91,7 @@ func VerifySignature(keyPath string, msg, sig string) (bool, string, *Signer, []
 	cfgReader := strings.NewReader(msg)
 	sigReader := strings.NewReader(sig)
 
-	if keyRing, err := LoadKeyRing(keyPath); err != nil {
-		return false, "Error when loading key ring", nil, nil, err
-	} else if signer, err := openpgp.CheckArmoredDetachedSignature(keyRing, cfgReader, sigReader); signer == nil {
+	if signer, err := openpgp.CheckArmoredDetachedSignature(keyRing, cfgReader, sigReader); signer == nil {
 		logger.Debug("msg:", msg)
 		logger.Debug("sig:", sig)
 		if err != nil {
@@ -156,18 +156,39 @@ func loadPublicKey(fpath string) (*rsa.PublicKey, error) {
 	return public.(*rsa.PublicKey), nil
 }
 
-func loadCertificate(fpath string) (*x509.Certificate, error) {
+// loadCertificates returns all certificates in the file, which may be a PEM bundle
+func loadCertificates(fpath string) ([]*x509.Certificate, error) {
 	cpath := filepath.Clean(fpath)
 	certPemBytes, err := ioutil.ReadFile(cpath)
 	if err != nil {
 		return nil, err
 	}
-	certBytes := PEMDecode(certPemBytes, PEMTypeCertificate)
-	cert, err := x509.ParseCertificate(certBytes)
-	if err != nil {
-		return nil, err
+	return ParseCertificates(certPemBytes)
+}
+
+// ParseCertificates parses all CERTIFICATE blocks in PEM bytes, and returns an error if no certificate is found
+func ParseCertificates(certPemBytes []byte) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	rest := certPemBytes
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != PEMTypeCertificate {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
 	}
-	return cert, nil
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate is found")
+	}
+	return certs, nil
 }
 
 func GetPublicKeyFromCertificate(certPemBytes []byte) ([]byte, error) {
@@ -256,17 +277,27 @@ func LoadCertDir(certDir string) ([]*x509.Certificate, error) {
 	for _, f := range files {
 		if !f.IsDir() && (path.Ext(f.Name()) == ".crt" || path.Ext(f.Name()) == ".pem") {
 			fpath := path.Join(certDir, f.Name())
-			cert, err := loadCertificate(fpath)
+			fileCerts, err := loadCertificates(fpath)
 			if err != nil {
 				return nil, fmt.Errorf("failed to load cert file \"%s\" ; %s", fpath, err.Error())
 			}
-			certs = append(certs, cert)
+			certs = append(certs, fileCerts...)
 		}
 	}
 	return certs, nil
 }
 
 func VerifyCertificate(certPemBytes []byte, caCertPath string) (bool, string, error) {
+	poolCerts, err := LoadCertDir(caCertPath)
+	if err != nil {
+		reasonFail := fmt.Sprintf("failed to load certificate pool: %s", err.Error())
+		return false, reasonFail, fmt.Errorf(reasonFail)
+	}
+	return VerifyCertificateWithPool(certPemBytes, poolCerts)
+}
+
+// VerifyCertificateWithPool verifies the certificate with CA certificates which are already loaded
+func VerifyCertificateWithPool(certPemBytes []byte, poolCerts []*x509.Certificate) (bool, string, error) {
 	var reasonFail string
 	var err error
 	certBytes := PEMDecode(certPemBytes, PEMTypeCertificate)
@@ -277,11 +308,6 @@ func VerifyCertificate(certPemBytes []byte, caCertPath string) (bool, string, er
 	}
 
 	roots := x509.NewCertPool()
-	poolCerts, err := LoadCertDir(caCertPath)
-	if err != nil {
-		reasonFail = fmt.Sprintf("failed to load certificate pool: %s", err.Error())
-		return false, reasonFail, fmt.Errorf(reasonFail)
-	}
 	for _, poolCert := range poolCerts {
 		if !poolCert.Equal(cert) || isSelfSignedCert(cert) {
 			roots.AddCert(poolCert)
@@ -77,3 +77,31 @@ func TestEndToEndCAVerification(t *testing.T) {
 	os.Remove(testInterCert)
 	os.Remove(testServiceCert)
 }
+
+func TestLoadCertDirWithBundle(t *testing.T) {
+	rootCert, rootPrvKeyBytes, _, err := CreateCertificate("RootCA", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interCert, _, _, err := CreateCertificate("IntermediateCA", rootCert, rootPrvKeyBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certDir, err := ioutil.TempDir("", "ishield-cert-dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(certDir)
+
+	bundle := append(append([]byte{}, rootCert...), interCert...)
+	_ = ioutil.WriteFile(certDir+"/bundle.pem", bundle, 0644)
+	_ = ioutil.WriteFile(certDir+"/README", []byte("not a cert"), 0644)
+
+	certs, err := LoadCertDir(certDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 2 {
+		t.Errorf("expected 2 certs in the bundle, but got %d", len(certs))
+	}
+}
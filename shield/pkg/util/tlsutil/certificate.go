@@ -0,0 +1,129 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tlsutil
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+
+	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+)
+
+// CertificateProvider serves the TLS key pair via tls.Config.GetCertificate,
+// and reloads it when the cert / key files are changed (e.g. the mounted secret is rotated).
+type CertificateProvider struct {
+	certPath string
+	keyPath  string
+	interval time.Duration
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	leaf     *x509.Certificate
+	certData []byte
+	keyData  []byte
+}
+
+func NewCertificateProvider(certPath, keyPath string, interval time.Duration) (*CertificateProvider, error) {
+	provider := &CertificateProvider{
+		certPath: certPath,
+		keyPath:  keyPath,
+		interval: interval,
+	}
+	if _, err := provider.Reload(); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// Reload loads the key pair if the files are changed, and returns true if the certificate is replaced.
+// If the new files are invalid, the current certificate is kept.
+func (p *CertificateProvider) Reload() (bool, error) {
+	certData, err := ioutil.ReadFile(filepath.Clean(p.certPath))
+	if err != nil {
+		return false, fmt.Errorf("failed to read TLS cert file; %s", err.Error())
+	}
+	keyData, err := ioutil.ReadFile(filepath.Clean(p.keyPath))
+	if err != nil {
+		return false, fmt.Errorf("failed to read TLS key file; %s", err.Error())
+	}
+
+	p.mu.RLock()
+	unchanged := p.cert != nil && bytes.Equal(certData, p.certData) && bytes.Equal(keyData, p.keyData)
+	p.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	pair, err := tls.X509KeyPair(certData, keyData)
+	if err != nil {
+		return false, fmt.Errorf("failed to load TLS key pair; %s", err.Error())
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return false, fmt.Errorf("failed to parse TLS certificate; %s", err.Error())
+	}
+	pair.Leaf = leaf
+
+	p.mu.Lock()
+	p.cert = &pair
+	p.leaf = leaf
+	p.certData = certData
+	p.keyData = keyData
+	p.mu.Unlock()
+	return true, nil
+}
+
+// Run checks the files periodically until stopCh is closed
+func (p *CertificateProvider) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			reloaded, err := p.Reload()
+			if err != nil {
+				logger.Warn("Failed to reload TLS certificate, keep using the current one; ", err.Error())
+			} else if reloaded {
+				logger.Info(fmt.Sprintf("TLS certificate has been reloaded (valid until %s)", p.Leaf().NotAfter.Format(time.RFC3339)))
+			}
+		}
+	}
+}
+
+func (p *CertificateProvider) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.cert == nil {
+		return nil, fmt.Errorf("TLS certificate is not loaded")
+	}
+	return p.cert, nil
+}
+
+// Leaf returns the parsed certificate currently served
+func (p *CertificateProvider) Leaf() *x509.Certificate {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.leaf
+}
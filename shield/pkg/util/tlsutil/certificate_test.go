@@ -0,0 +1,109 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tlsutil
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestKeyPair(t *testing.T, certPath, keyPath string, serial int64) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "ishield-server"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := ioutil.WriteFile(certPath, certPem, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyPath, keyPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCertificateProvider(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "ishield-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	certPath := filepath.Join(tmpDir, "tls.crt")
+	keyPath := filepath.Join(tmpDir, "tls.key")
+
+	if _, err := NewCertificateProvider(certPath, keyPath, time.Second); err == nil {
+		t.Errorf("NewCertificateProvider() should fail when cert files do not exist")
+	}
+
+	writeTestKeyPair(t, certPath, keyPath, 1)
+	provider, err := NewCertificateProvider(certPath, keyPath, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := provider.GetCertificate(nil)
+	if err != nil || cert == nil {
+		t.Fatalf("GetCertificate() should return the loaded certificate; %v", err)
+	}
+	if provider.Leaf().SerialNumber.Int64() != 1 {
+		t.Errorf("expected serial number: 1, actual: %d", provider.Leaf().SerialNumber.Int64())
+	}
+
+	if reloaded, err := provider.Reload(); reloaded || err != nil {
+		t.Errorf("Reload() should not replace the certificate when files are not changed")
+	}
+
+	// rotated cert is served after reload
+	writeTestKeyPair(t, certPath, keyPath, 2)
+	if reloaded, err := provider.Reload(); !reloaded || err != nil {
+		t.Errorf("Reload() should replace the certificate after rotation; %v", err)
+	}
+	if provider.Leaf().SerialNumber.Int64() != 2 {
+		t.Errorf("expected serial number: 2, actual: %d", provider.Leaf().SerialNumber.Int64())
+	}
+
+	// broken files do not replace the current certificate
+	if err := ioutil.WriteFile(keyPath, []byte("invalid"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Reload(); err == nil {
+		t.Errorf("Reload() should fail with an invalid key file")
+	}
+	if provider.Leaf().SerialNumber.Int64() != 2 {
+		t.Errorf("current certificate should be kept when reload fails")
+	}
+}